@@ -0,0 +1,191 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Package actitopotest provides helpers for tests that work with hardware
+// topologies.
+//
+// All Assert helpers report failures through t.Errorf and return false, so that
+// the test goes on; all Must helpers report failures through t.Fatalf.
+package actitopotest
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// maxReportedChanges is the maximum number of Changes reported on failures of
+// AssertEquivalent.
+const maxReportedChanges = 10
+
+// MustLoad returns the Topology decoded from the JSON file at the provided
+// path, and stops the test if it cannot be loaded or is not valid.
+func MustLoad(t testing.TB, path string) *actitopo.Topology {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to load Topology: %v", err)
+	}
+	defer f.Close()
+	topo, err := actitopo.NewDecoder(actitopo.DecodeLimits{}).Decode(f)
+	if err != nil {
+		t.Fatalf("failed to load Topology from %s: %v", path, err)
+	}
+	return topo
+}
+
+// MustGenerate returns the Topology of the provided synthetic shape (see
+// actitopo.GenerateSynthetic), and stops the test if the shape is malformed.
+func MustGenerate(t testing.TB, shape string) *actitopo.Topology {
+	t.Helper()
+	topo, err := actitopo.GenerateSynthetic(shape)
+	if err != nil {
+		t.Fatalf("failed to generate Topology: %v", err)
+	}
+	return topo
+}
+
+// AssertValid checks that the provided Topology is valid (see
+// actitopo.Tree.Validate).
+func AssertValid(t testing.TB, topo *actitopo.Topology) bool {
+	t.Helper()
+	if nil == topo {
+		t.Errorf("Topology is nil")
+		return false
+	}
+	if err := topo.Validate(); err != nil {
+		t.Errorf("Topology is not valid: %v", err)
+		return false
+	}
+	return true
+}
+
+// AssertEquivalent checks that the provided Topologies describe the same
+// machine, regardless of their NodeIDs and of the order of their Elements
+// (i.e., that their full Fingerprints match), and reports their differences
+// otherwise (see actitopo.Diff).
+func AssertEquivalent(t testing.TB, a, b *actitopo.Topology) bool {
+	t.Helper()
+	fa, fb, ok := fingerprints(t, a, b)
+	if !ok {
+		return false
+	}
+	if fa.Full == fb.Full {
+		return true
+	}
+	changes, err := actitopo.Diff(a, b)
+	if err != nil {
+		t.Errorf("Topologies are not equivalent: %v", err)
+		return false
+	}
+	var sb strings.Builder
+	for i, change := range changes {
+		if i == maxReportedChanges {
+			sb.WriteString("\n\t...")
+			break
+		}
+		sb.WriteString("\n\t")
+		sb.WriteString(change.String())
+	}
+	t.Errorf("Topologies are not equivalent (%d changes):%s", len(changes), sb.String())
+	return false
+}
+
+// AssertSameShape checks that the provided Topologies have the same structure
+// (i.e., that their structure Fingerprints match), regardless of the OS
+// indices of their Processing nodes and the attributes of their Caches.
+func AssertSameShape(t testing.TB, a, b *actitopo.Topology) bool {
+	t.Helper()
+	fa, fb, ok := fingerprints(t, a, b)
+	if !ok {
+		return false
+	}
+	if fa.Structure != fb.Structure {
+		t.Errorf("Topologies have different shapes: %s and %s", fa.Structure, fb.Structure)
+		return false
+	}
+	return true
+}
+
+// AssertContainsKind checks that the provided Topology contains exactly n
+// Processing nodes of the provided kind.
+func AssertContainsKind(t testing.TB, topo *actitopo.Topology, kind actitopo.ProcessingKind, n int) bool {
+	t.Helper()
+	var got int
+	switch kind {
+	case actitopo.Package:
+		got = len(topo.Packages())
+	case actitopo.NUMANode:
+		got = len(topo.NUMANodes())
+	case actitopo.Core:
+		got = len(topo.Cores())
+	case actitopo.Thread:
+		got = len(topo.Threads())
+	default:
+		t.Errorf("invalid ProcessingKind %v", kind)
+		return false
+	}
+	if got != n {
+		t.Errorf("Topology contains %d %s elements; expected %d", got, kind, n)
+		return false
+	}
+	return true
+}
+
+// AssertContainsCacheLevel checks that the provided Topology contains exactly n
+// Caches of the provided level.
+func AssertContainsCacheLevel(t testing.TB, topo *actitopo.Topology, level actitopo.CacheLevel, n int) bool {
+	t.Helper()
+	var got int
+	switch level {
+	case actitopo.L1:
+		got = len(topo.L1Caches())
+	case actitopo.L2:
+		got = len(topo.L2Caches())
+	case actitopo.L3:
+		got = len(topo.L3Caches())
+	case actitopo.L4:
+		got = len(topo.L4Caches())
+	case actitopo.L5:
+		got = len(topo.L5Caches())
+	default:
+		t.Errorf("invalid CacheLevel %v", level)
+		return false
+	}
+	if got != n {
+		t.Errorf("Topology contains %d %s caches; expected %d", got, level, n)
+		return false
+	}
+	return true
+}
+
+// fingerprints returns the Fingerprints of the provided Topologies, reporting
+// any failure.
+func fingerprints(t testing.TB, a, b *actitopo.Topology) (fa, fb actitopo.Fingerprint, ok bool) {
+	t.Helper()
+	var err error
+	if fa, err = a.Fingerprint(); err != nil {
+		t.Errorf("failed to fingerprint first Topology: %v", err)
+		return
+	}
+	if fb, err = b.Fingerprint(); err != nil {
+		t.Errorf("failed to fingerprint second Topology: %v", err)
+		return
+	}
+	return fa, fb, true
+}
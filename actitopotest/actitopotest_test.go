@@ -0,0 +1,65 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopotest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// recorder records the failures reported by the helpers, instead of failing
+// the test.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	topo := MustGenerate(t, "2:1:4:2")
+	other := MustGenerate(t, "2:1:4:2:16M")
+	loaded := MustLoad(t, "../test_artifacts/t4_de.json")
+
+	for _, tc := range []struct {
+		name string
+		ok   bool
+		fn   func(r *recorder) bool
+	}{
+		{"valid", true, func(r *recorder) bool { return AssertValid(r, topo) }},
+		{"nil", false, func(r *recorder) bool { return AssertValid(r, nil) }},
+		{"equivalent", true, func(r *recorder) bool { return AssertEquivalent(r, topo, MustGenerate(t, "2:1:4:2")) }},
+		{"not equivalent", false, func(r *recorder) bool { return AssertEquivalent(r, topo, other) }},
+		{"same shape", true, func(r *recorder) bool { return AssertSameShape(r, topo, other) }},
+		{"different shape", false, func(r *recorder) bool { return AssertSameShape(r, topo, loaded) }},
+		{"cores", true, func(r *recorder) bool { return AssertContainsKind(r, topo, actitopo.Core, 8) }},
+		{"threads", false, func(r *recorder) bool { return AssertContainsKind(r, topo, actitopo.Thread, 8) }},
+		{"l3", true, func(r *recorder) bool { return AssertContainsCacheLevel(r, topo, actitopo.L3, 2) }},
+		{"l3 missing", false, func(r *recorder) bool { return AssertContainsCacheLevel(r, loaded, actitopo.L3, 1) }},
+	} {
+		r := &recorder{TB: t}
+		if ok := tc.fn(r); ok != tc.ok || (len(r.failures) == 0) != tc.ok {
+			t.Errorf("%s: got %v with failures %q; expected %v", tc.name, ok, r.failures, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "fmt"
+
+// DeviceNUMADistance is the distance between a Device and a NUMA node, in the
+// same units as the distances between NUMA nodes (see Topology.NUMADistance).
+type DeviceNUMADistance struct {
+	// Device is the NodeID of the Device element.
+	Device NodeID `json:"device"`
+	// NUMANode is the NodeID of the NUMA node element.
+	NUMANode NodeID `json:"numa_node"`
+	// Distance is the distance between them.
+	Distance uint32 `json:"distance"`
+}
+
+// DeviceNUMAMatrix holds the distances between a set of Devices and all NUMA
+// nodes of a Topology (see Topology.DeviceNUMAMatrix).
+type DeviceNUMAMatrix struct {
+	// Devices lists the NodeIDs of the Device elements, which index the
+	// rows of Distances.
+	Devices []NodeID `json:"devices"`
+	// NUMANodes lists the NodeIDs of the NUMA node elements, which index
+	// the columns of Distances.
+	NUMANodes []NodeID `json:"numa_nodes"`
+	// Distances holds the distance between each Device and each NUMA node.
+	Distances [][]uint32 `json:"distances"`
+}
+
+// DeviceNUMAMatrix returns the distances between the Device elements stored
+// under the provided NodeIDs (or all GPUs and Accelerators of the Topology, if
+// none is provided) and all NUMA nodes of the Topology, or a non-nil error
+// value in case of failure.
+//
+// The distance between a Device and a NUMA node is derived from the placement
+// of the Device in the Tree, as the shortest distance between any of its local
+// NUMA nodes (see LocalNUMANodes) and that NUMA node (see NUMADistance); hence,
+// it is LocalDistance for its local NUMA nodes. Any explicit distances that
+// are provided (e.g., as measured, or as reported by the vendor's tools)
+// override the derived ones; those of other Devices are ignored.
+func (t *Topology) DeviceNUMAMatrix(deviceIDs []NodeID, explicit []DeviceNUMADistance) (*DeviceNUMAMatrix, error) {
+	if len(deviceIDs) == 0 {
+		deviceIDs = t.Devices(GPU, Accelerator)
+	}
+	m := &DeviceNUMAMatrix{
+		Devices:   append(make([]NodeID, 0, len(deviceIDs)), deviceIDs...),
+		NUMANodes: t.NUMANodes(),
+		Distances: make([][]uint32, len(deviceIDs)),
+	}
+	row := make(map[NodeID]int, len(deviceIDs))
+	for i, deviceID := range deviceIDs {
+		local, err := t.LocalNUMANodes(deviceID)
+		if err != nil {
+			return nil, err
+		}
+		m.Distances[i] = make([]uint32, len(m.NUMANodes))
+		for j, numaID := range m.NUMANodes {
+			for k, localID := range local {
+				dist, err := t.NUMADistance(localID, numaID)
+				if err != nil {
+					return nil, err
+				}
+				if k == 0 || dist < m.Distances[i][j] {
+					m.Distances[i][j] = dist
+				}
+			}
+		}
+		row[deviceID] = i
+	}
+
+	column := make(map[NodeID]int, len(m.NUMANodes))
+	for j, numaID := range m.NUMANodes {
+		column[numaID] = j
+	}
+	for _, d := range explicit {
+		j, ok := column[d.NUMANode]
+		if !ok {
+			return nil, fmt.Errorf("element %d is not a NUMA node", d.NUMANode)
+		}
+		if i, ok := row[d.Device]; ok {
+			m.Distances[i][j] = d.Distance
+		}
+	}
+	return m, nil
+}
+
+// Nearest returns the NodeIDs of the NUMA nodes that are nearest to the Device
+// stored under the provided NodeID, in ascending order, or a non-nil error
+// value if the Device is not part of the DeviceNUMAMatrix.
+func (m *DeviceNUMAMatrix) Nearest(deviceID NodeID) ([]NodeID, error) {
+	for i, id := range m.Devices {
+		if id != deviceID {
+			continue
+		}
+		ret := make([]NodeID, 0, 1)
+		var nearest uint32
+		for j, dist := range m.Distances[i] {
+			switch {
+			case len(ret) == 0 || dist < nearest:
+				ret, nearest = append(ret[:0], m.NUMANodes[j]), dist
+			case dist == nearest:
+				ret = append(ret, m.NUMANodes[j])
+			}
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("device %d is not part of the matrix", deviceID)
+}
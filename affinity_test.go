@@ -0,0 +1,73 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeviceNUMAMatrix(t *testing.T) {
+	topo := &Topology{deviceTree()}
+	const nic, gpu, storage = 6, 15, 16
+	numaIDs := topo.NUMANodes()
+	topo.Nodes[numaIDs[2]].Data.Memory = &MemoryAttributes{Distances: map[uint32]uint32{0: 30, 1: 15, 2: 10}}
+
+	m, err := topo.DeviceNUMAMatrix(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DeviceNUMAMatrix{Devices: []NodeID{gpu}, NUMANodes: numaIDs, Distances: [][]uint32{{30, 15, 10}}}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("DeviceNUMAMatrix() = %+v; expected %+v", m, want)
+	}
+
+	// Explicit distances override the derived ones, and devices of
+	// unknown locality are local to all NUMA nodes.
+	m, err = topo.DeviceNUMAMatrix([]NodeID{nic, gpu, storage}, []DeviceNUMADistance{
+		{Device: gpu, NUMANode: numaIDs[1], Distance: 11},
+		{Device: 4, NUMANode: numaIDs[1], Distance: 11},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]uint32{{10, 20, 30}, {30, 11, 10}, {10, 10, 10}}; !reflect.DeepEqual(m.Distances, want) {
+		t.Errorf("got distances %v; expected %v", m.Distances, want)
+	}
+	for _, tc := range []struct {
+		id   NodeID
+		want []NodeID
+	}{
+		{nic, numaIDs[:1]},
+		{gpu, numaIDs[2:]},
+		{storage, numaIDs},
+	} {
+		if got, err := m.Nearest(tc.id); err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Nearest(%d) = %v, %v; expected %v", tc.id, got, err, tc.want)
+		}
+	}
+	if _, err = m.Nearest(4); err == nil {
+		t.Errorf("expected an error for an element that is not part of the matrix")
+	}
+
+	if _, err = topo.DeviceNUMAMatrix([]NodeID{4}, nil); err == nil {
+		t.Errorf("expected an error for a thread")
+	}
+	if _, err = topo.DeviceNUMAMatrix(nil, []DeviceNUMADistance{{Device: gpu, NUMANode: 4}}); err == nil {
+		t.Errorf("expected an error for a thread instead of a NUMA node")
+	}
+}
@@ -0,0 +1,356 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultTopologyAnnotation is the default annotation of Kubernetes Node
+// objects that an Aggregator reads the Topologies from.
+const DefaultTopologyAnnotation = "actik8s.io/topology"
+
+// Aggregator collects the Topologies of the nodes of a Kubernetes cluster into
+// a ClusterTopology.
+//
+// It lists the cluster's Node objects through the Kubernetes API, and pulls
+// the Topology of each Node either from one of its annotations or, if AgentURL
+// is set, from the endpoint of an agent running on it (e.g., "actitopo
+// serve"). All Topologies are untrusted, and are decoded under Limits.
+//
+// The Kubernetes API is accessed over plain HTTP rather than through
+// client-go, so that the module has no external dependencies. Within a Pod,
+// NewInClusterAggregator sets up the service account's credentials, while
+// NewKubeconfigAggregator sets up those of a kubeconfig file (without
+// client-go's credential plugins); otherwise, APIServer, Client and
+// BearerToken (or TokenFile) must be set up by the caller.
+type Aggregator struct {
+	// APIServer is the base URL of the Kubernetes API server (e.g.,
+	// "https://10.96.0.1:443").
+	APIServer string
+	// Client is used for the requests to the API server (or
+	// http.DefaultClient, if nil); it is expected to carry the credentials
+	// of the API server.
+	Client *http.Client
+	// AgentClient is used for the requests to the agents (or
+	// http.DefaultClient, if nil), so that they are neither sent the API
+	// server's credentials nor required to be trusted by its certificate
+	// authority.
+	AgentClient *http.Client
+	// BearerToken, if not empty, is sent to the API server (only) in the
+	// Authorization header of each request.
+	BearerToken string
+	// TokenFile, if not empty, is the path of a file that holds the bearer
+	// token instead, which is read anew for every listing of the Nodes, so
+	// that rotated tokens (e.g., projected service account tokens) are
+	// picked up.
+	TokenFile string
+	// LabelSelector, if not empty, restricts the Nodes that are listed
+	// (e.g., "node-role.kubernetes.io/worker").
+	LabelSelector string
+	// Annotation is the annotation of the Nodes that holds their Topology
+	// (or DefaultTopologyAnnotation, if empty).
+	Annotation string
+	// AgentURL, if not empty, is the URL template of the agents' endpoint
+	// to pull each Node's Topology from instead, in which "{name}" and
+	// "{address}" are replaced by the Node's name and InternalIP (e.g.,
+	// "http://{address}:8080/topology?format=json").
+	AgentURL string
+	// Concurrency is the maximum number of agents that are queried
+	// concurrently (or 16, if not positive).
+	Concurrency int
+	// Limits are the DecodeLimits enforced on each Topology, whose
+	// MaxBytes also bounds the size of the agents' responses (or
+	// DefaultDecodeLimits, if zero).
+	Limits DecodeLimits
+}
+
+// maxNodeListBytes bounds the size of each page of Nodes that an Aggregator
+// reads from the API server; pages hold up to nodeListPageSize Nodes, each of
+// which may carry a Topology annotation.
+const (
+	maxNodeListBytes = 256 << 20
+	nodeListPageSize = 100
+)
+
+// NewInClusterAggregator returns a new Aggregator that accesses the Kubernetes
+// API server with the credentials of the Pod's service account (re-reading its
+// token as it gets rotated), or a non-nil error value if it is not running in
+// a Kubernetes Pod.
+func NewInClusterAggregator() (*Aggregator, error) {
+	const dir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes Pod")
+	}
+	if _, err := os.Stat(dir + "token"); err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(dir + "ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("invalid CA certificate in %sca.crt", dir)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &Aggregator{
+		APIServer: "https://" + net.JoinHostPort(host, port),
+		Client:    &http.Client{Transport: transport},
+		TokenFile: dir + "token",
+	}, nil
+}
+
+// NodeErrors maps the names of the nodes whose Topology could not be collected
+// to the reason.
+type NodeErrors map[string]error
+
+// Error returns the string representation of the NodeErrors.
+func (e NodeErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "failed to collect the Topology of %d node(s)", len(e))
+	for _, name := range names {
+		fmt.Fprintf(&sb, "; %s: %v", name, e[name])
+	}
+	return sb.String()
+}
+
+// k8sNode is the subset of a Kubernetes Node object that an Aggregator uses.
+type k8sNode struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Status struct {
+		Addresses []struct {
+			Type    string `json:"type"`
+			Address string `json:"address"`
+		} `json:"addresses"`
+	} `json:"status"`
+}
+
+// Collect lists the Nodes of the cluster and returns a ClusterTopology with the
+// Topologies of all of them, or a non-nil error value in case of failure.
+//
+// If the Nodes cannot be listed, the ClusterTopology is nil. If only some of
+// their Topologies cannot be collected (e.g., they lack the annotation or their
+// agent is unreachable), the ClusterTopology holds the rest, and the returned
+// error is a NodeErrors value that reports each failure; once the provided
+// context is done, the Nodes that have not been queried yet fail with its
+// error.
+func (a *Aggregator) Collect(ctx context.Context) (*ClusterTopology, error) {
+	nodes, err := a.listNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		cluster  = NewClusterTopology()
+		failures = make(NodeErrors)
+	)
+	concurrency := a.Concurrency
+	if concurrency <= 0 {
+		concurrency = 16
+	}
+	sem := make(chan struct{}, concurrency)
+launch:
+	for i := range nodes {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			for _, skipped := range nodes[i:] {
+				failures[skipped.Metadata.Name] = ctx.Err()
+			}
+			mu.Unlock()
+			break launch
+		}
+		node := &nodes[i]
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			topo, err := a.nodeTopology(ctx, node)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				err = cluster.Add(node.Metadata.Name, topo)
+			}
+			if err != nil {
+				failures[node.Metadata.Name] = err
+			}
+		}()
+	}
+	wg.Wait()
+	if len(failures) > 0 {
+		return cluster, failures
+	}
+	return cluster, nil
+}
+
+// listNodes lists all Nodes that match the LabelSelector, following the API
+// server's pagination.
+func (a *Aggregator) listNodes(ctx context.Context) ([]k8sNode, error) {
+	var nodes []k8sNode
+	for cont := ""; ; {
+		query := url.Values{"limit": {strconv.Itoa(nodeListPageSize)}}
+		if a.LabelSelector != "" {
+			query.Set("labelSelector", a.LabelSelector)
+		}
+		if cont != "" {
+			query.Set("continue", cont)
+		}
+		var list struct {
+			Metadata struct {
+				Continue string `json:"continue"`
+			} `json:"metadata"`
+			Items []k8sNode `json:"items"`
+		}
+		data, err := a.get(ctx, strings.TrimSuffix(a.APIServer, "/")+"/api/v1/nodes?"+query.Encode(), true, maxNodeListBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Nodes: %w", err)
+		}
+		if err = json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("failed to list Nodes: %w", err)
+		}
+		nodes = append(nodes, list.Items...)
+		if cont = list.Metadata.Continue; cont == "" {
+			return nodes, nil
+		}
+	}
+}
+
+// nodeTopology returns the Topology of the provided Node, pulled from its
+// annotation or from its agent.
+func (a *Aggregator) nodeTopology(ctx context.Context, node *k8sNode) (*Topology, error) {
+	limits := a.Limits
+	if limits == (DecodeLimits{}) {
+		limits = DefaultDecodeLimits
+	}
+	var data []byte
+	if a.AgentURL == "" {
+		annotation := a.Annotation
+		if annotation == "" {
+			annotation = DefaultTopologyAnnotation
+		}
+		value, ok := node.Metadata.Annotations[annotation]
+		if !ok {
+			return nil, fmt.Errorf("missing annotation '%s'", annotation)
+		}
+		data = []byte(value)
+	} else {
+		var address string
+		for _, addr := range node.Status.Addresses {
+			if addr.Type == "InternalIP" {
+				address = addr.Address
+				break
+			}
+		}
+		if address == "" && strings.Contains(a.AgentURL, "{address}") {
+			return nil, fmt.Errorf("no InternalIP address")
+		}
+		if strings.Contains(address, ":") {
+			address = "[" + address + "]"
+		}
+		var err error
+		agentURL := strings.NewReplacer("{name}", node.Metadata.Name, "{address}", address).Replace(a.AgentURL)
+		if data, err = a.get(ctx, agentURL, false, limits.MaxBytes); err != nil {
+			return nil, err
+		}
+	}
+	return NewDecoder(limits).Decode(bytes.NewReader(data))
+}
+
+// get returns the body of the response to a GET request for the provided URL,
+// which is authorized with the bearer token if it is addressed to the API
+// server, or a non-nil error value if it is larger than maxBytes (unless
+// maxBytes is not positive).
+func (a *Aggregator) get(ctx context.Context, url string, apiServer bool, maxBytes int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if apiServer {
+		token := a.BearerToken
+		if a.TokenFile != "" {
+			data, err := os.ReadFile(a.TokenFile)
+			if err != nil {
+				return nil, err
+			}
+			token = strings.TrimSpace(string(data))
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	client := a.AgentClient
+	if apiServer {
+		client = a.Client
+	}
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body := io.Reader(resp.Body)
+	if maxBytes > 0 {
+		body = io.LimitReader(body, maxBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%w: response is larger than %d bytes", ErrLimitExceeded, maxBytes)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &status) != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(data))
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, status.Message)
+	}
+	return data, nil
+}
@@ -0,0 +1,144 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAggregator(t *testing.T) {
+	small, _ := json.Marshal(&Topology{syntheticTree(1, 1, 2, 2)})
+	largeTree := syntheticTree(2, 2, 4, 2)
+	large, _ := json.Marshal(&Topology{largeTree})
+	pages := []string{
+		`{"metadata":{"continue":"page-2"},"items":[
+			{"metadata":{"name":"node-a","annotations":{"actik8s.io/topology":` + jsonString(small) + `}}},
+			{"metadata":{"name":"node-b","annotations":{"actik8s.io/topology":` + jsonString(large) + `}}}]}`,
+		`{"metadata":{},"items":[{"metadata":{"name":"node-c"}}]}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/nodes", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, `{"kind":"Status","message":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("continue") == "page-2" {
+			w.Write([]byte(pages[1]))
+		} else {
+			w.Write([]byte(pages[0]))
+		}
+	})
+	mux.HandleFunc("/agents/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("the bearer token was sent to an agent")
+		}
+		switch strings.TrimPrefix(r.URL.Path, "/agents/") {
+		case "node-a", "node-c":
+			w.Write(small)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	aggregator := &Aggregator{APIServer: server.URL, Client: server.Client(), BearerToken: "secret"}
+	cluster, err := aggregator.Collect(context.Background())
+	var failures NodeErrors
+	if !errors.As(err, &failures) || len(failures) != 1 || failures["node-c"] == nil {
+		t.Errorf("got %v; expected a failure for node-c only", err)
+	}
+	if names := cluster.Names(); len(names) != 2 || names[0] != "node-a" || names[1] != "node-b" {
+		t.Errorf("got nodes %v; expected [node-a node-b]", names)
+	}
+	if topo, _ := cluster.Get("node-b"); topo.Size() != largeTree.Size() {
+		t.Errorf("got a Topology of %d elements for node-b", topo.Size())
+	}
+
+	aggregator.AgentURL = server.URL + "/agents/{name}"
+	cluster, err = aggregator.Collect(context.Background())
+	if !errors.As(err, &failures) || len(failures) != 1 || failures["node-b"] == nil {
+		t.Errorf("got %v; expected a failure for node-b only", err)
+	}
+	if cluster.Size() != 2 {
+		t.Errorf("got %d nodes; expected 2", cluster.Size())
+	}
+
+	// Topologies are decoded under the Limits, which also bound the size of
+	// the agents' responses.
+	aggregator.AgentURL = server.URL + "/agents/node-a"
+	aggregator.Limits = DecodeLimits{MaxBytes: int64(len(small)) - 1}
+	if _, err = aggregator.Collect(context.Background()); !errors.As(err, &failures) || len(failures) != 3 ||
+		!errors.Is(failures["node-a"], ErrLimitExceeded) {
+		t.Errorf("got %v; expected ErrLimitExceeded for all nodes", err)
+	}
+	aggregator.AgentURL = ""
+	aggregator.Limits = DecodeLimits{MaxNodes: 4}
+	if _, err = aggregator.Collect(context.Background()); !errors.As(err, &failures) ||
+		!errors.Is(failures["node-b"], ErrLimitExceeded) {
+		t.Errorf("got %v; expected ErrLimitExceeded for node-b", err)
+	}
+	aggregator.Limits = DecodeLimits{}
+
+	// Nodes that have not been queried by the time the context is done
+	// fail with its error.
+	ctx, cancel := context.WithCancel(context.Background())
+	block := make(chan struct{})
+	mux.HandleFunc("/blocking/", func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		<-block
+	})
+	aggregator.AgentURL = server.URL + "/blocking/{name}"
+	aggregator.Concurrency = 1
+	go func() {
+		<-ctx.Done()
+		close(block)
+	}()
+	if _, err = aggregator.Collect(ctx); !errors.As(err, &failures) || len(failures) != 3 ||
+		!errors.Is(failures["node-c"], context.Canceled) {
+		t.Errorf("got %v; expected context.Canceled for all nodes", err)
+	}
+	aggregator.AgentURL, aggregator.Concurrency = "", 0
+
+	// The bearer token may be read from a file.
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err = os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	aggregator.BearerToken, aggregator.TokenFile = "", tokenFile
+	if cluster, err = aggregator.Collect(context.Background()); cluster.Size() != 2 {
+		t.Errorf("got %d nodes (%v) with a token file; expected 2", cluster.Size(), err)
+	}
+
+	aggregator.TokenFile = ""
+	if cluster, err = aggregator.Collect(context.Background()); err == nil || nil != cluster {
+		t.Errorf("expected an error when the Nodes cannot be listed")
+	}
+}
+
+func jsonString(data []byte) string {
+	quoted, _ := json.Marshal(string(data))
+	return string(quoted)
+}
@@ -0,0 +1,1010 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Allocation represents a set of hardware threads that have been handed out
+// by an Allocator.
+type Allocation struct {
+	// Threads is the list of NodeIDs of the hardware thread elements that
+	// constitute the Allocation, in ascending order.
+	Threads []NodeID `json:"threads"`
+	// Memory maps the NodeIDs of the NUMA node elements that memory has
+	// been reserved from to the number of bytes reserved from each one.
+	// It is empty if no memory was requested.
+	Memory map[NodeID]uint64 `json:"memory,omitempty"`
+	// NUMANodes lists the NodeIDs of the NUMA node elements that the
+	// threads of the Allocation belong to, in ascending order.
+	NUMANodes []NodeID `json:"numa_nodes,omitempty"`
+	// DistanceCost is the sum of the distances among all pairs of NUMANodes
+	// (see Topology.NUMADistance); it is 0 if the Allocation spans a single
+	// NUMA node.
+	DistanceCost uint32 `json:"distance_cost,omitempty"`
+}
+
+// MemoryNodes returns the NodeIDs of the NUMA node elements that memory has
+// been reserved from for the Allocation (i.e., its memset), in ascending
+// order.
+func (alloc *Allocation) MemoryNodes() []NodeID {
+	ret := make([]NodeID, 0, len(alloc.Memory))
+	for numaID := range alloc.Memory {
+		ret = append(ret, numaID)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+// clone returns a deep copy of the Allocation, so that the Allocator's own
+// copy cannot be modified through the ones it hands out.
+func (alloc *Allocation) clone() *Allocation {
+	ret := &Allocation{
+		Threads:      append([]NodeID(nil), alloc.Threads...),
+		NUMANodes:    append([]NodeID(nil), alloc.NUMANodes...),
+		DistanceCost: alloc.DistanceCost,
+	}
+	if nil != alloc.Memory {
+		ret.Memory = make(map[NodeID]uint64, len(alloc.Memory))
+		for numaID, bytes := range alloc.Memory {
+			ret.Memory[numaID] = bytes
+		}
+	}
+	return ret
+}
+
+// AllocationPolicy enumerates the strategies that an Allocator may follow to
+// select hardware threads.
+type AllocationPolicy byte
+
+const (
+	// Pack places the requested threads in as few hierarchy subtrees as
+	// possible (i.e., touching the minimum number of distinct Packages,
+	// NUMA nodes and L3 caches), preferring full physical cores over
+	// splitting SMT siblings.
+	//
+	// It suits latency-sensitive workloads that benefit from sharing
+	// caches and local memory.
+	Pack AllocationPolicy = iota
+	// Spread balances the requested threads across as many hierarchy
+	// subtrees as possible (i.e., across Packages and NUMA nodes first,
+	// and then across caches and physical cores within them).
+	//
+	// It suits throughput-oriented workloads that benefit from aggregate
+	// memory bandwidth.
+	Spread
+)
+
+// String returns the string representation of the AllocationPolicy.
+func (ap AllocationPolicy) String() string {
+	switch ap {
+	case Pack:
+		return "Pack"
+	case Spread:
+		return "Spread"
+	default:
+		return fmt.Sprintf("Unknown allocation policy %d", ap)
+	}
+}
+
+// ParseAllocationPolicy returns an AllocationPolicy parsed from the provided
+// string representation, or a non-nil error value if parsing fails.
+func ParseAllocationPolicy(str string) (AllocationPolicy, error) {
+	switch strings.ToLower(str) {
+	case "pack":
+		return Pack, nil
+	case "spread":
+		return Spread, nil
+	default:
+		return Pack, fmt.Errorf("unknown allocation policy: '%s'", str)
+	}
+}
+
+// Allocator keeps track of the hardware threads of a Topology that have been
+// allocated, and selects new ones for each incoming request according to the
+// requested AllocationPolicy.
+//
+// An Allocator is safe for concurrent use by multiple goroutines, as long as
+// its Topology is not modified.
+type Allocator struct {
+	// mu guards all fields but topo, which is never modified.
+	mu   sync.Mutex
+	topo *Topology
+	// allocations maps the opaque owner IDs to their Allocations.
+	allocations map[string]*Allocation
+	// allocated is indexed by NodeID and is true for the hardware threads
+	// that are currently part of some Allocation.
+	allocated []bool
+	// reserved is indexed by NodeID and is true for the hardware threads
+	// that must never be allocated.
+	reserved []bool
+	// threadByOSID maps the OS index of each hardware thread to its NodeID.
+	threadByOSID map[uint32]NodeID
+	// numaOf maps the NodeID of each hardware thread to the NodeID of the
+	// NUMA node element that it belongs to, if any.
+	numaOf map[NodeID]NodeID
+	// memCapacity and memReserved map the NodeIDs of NUMA node elements to
+	// their memory capacity and to the memory reserved from them, in
+	// bytes, respectively.
+	memCapacity map[NodeID]uint64
+	memReserved map[NodeID]uint64
+}
+
+// NewAllocator returns a new Allocator for the provided Topology, or a non-nil
+// error value if the Topology contains no hardware threads.
+//
+// The memory capacity of the NUMA nodes of the Topology that have memory
+// attributes is initialized accordingly (see SetMemoryCapacity).
+func NewAllocator(topo *Topology) (*Allocator, error) {
+	if nil == topo || nil == topo.Tree {
+		return nil, ErrNilTree
+	}
+	if topo.IsEmpty() {
+		return nil, ErrEmptyTree
+	}
+	if len(topo.Threads()) == 0 {
+		return nil, fmt.Errorf("Topology contains no hardware threads")
+	}
+	threadByOSID := make(map[uint32]NodeID)
+	for _, id := range topo.Threads() {
+		threadByOSID[topo.Nodes[id].Data.ID] = id
+	}
+	numaOf := make(map[NodeID]NodeID)
+	memCapacity := make(map[NodeID]uint64)
+	for _, numaID := range topo.NUMANodes() {
+		if mem := topo.Nodes[numaID].Data.Memory; nil != mem {
+			memCapacity[numaID] = mem.Bytes
+		}
+		leafIDs, err := topo.LeafDescendantIDs(numaID)
+		if err != nil {
+			return nil, err
+		}
+		for _, leafID := range leafIDs {
+			numaOf[leafID] = numaID
+		}
+	}
+	return &Allocator{
+		topo:         topo,
+		allocations:  make(map[string]*Allocation),
+		allocated:    make([]bool, topo.Size()),
+		reserved:     make([]bool, topo.Size()),
+		threadByOSID: threadByOSID,
+		numaOf:       numaOf,
+		memCapacity:  memCapacity,
+		memReserved:  make(map[NodeID]uint64),
+	}, nil
+}
+
+// Reserve excludes the hardware threads whose OS indices are included in the
+// provided cpulist string (e.g., "0-1,12-13") from all future allocations.
+// It may be called multiple times (e.g., once for the system-reserved, once
+// for the kube-reserved and once for the IRQ-handling threads), in which case
+// the reserved sets accumulate.
+//
+// It returns a non-nil error value, without reserving anything, if the cpulist
+// cannot be parsed, or if it includes an unknown or already allocated thread.
+func (a *Allocator) Reserve(cpulist string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	osIDs, err := ParseCPUList(cpulist)
+	if err != nil {
+		return err
+	}
+	ids := make([]NodeID, 0, len(osIDs))
+	for _, osID := range osIDs {
+		id, ok := a.threadByOSID[osID]
+		if !ok {
+			return fmt.Errorf("cannot reserve unknown thread with OS index %d", osID)
+		}
+		if a.allocated[id] {
+			return fmt.Errorf("cannot reserve allocated thread with OS index %d", osID)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		a.reserved[id] = true
+	}
+	return nil
+}
+
+// Reserved returns the NodeIDs of all hardware threads that have been excluded
+// from allocation through Reserve.
+func (a *Allocator) Reserved() []NodeID {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.reservedIDs()
+}
+
+// reservedIDs implements Reserved, and must be called with the lock held.
+func (a *Allocator) reservedIDs() []NodeID {
+	ret := make([]NodeID, 0)
+	for _, id := range a.topo.Threads() {
+		if a.reserved[id] {
+			ret = append(ret, id)
+		}
+	}
+	return ret
+}
+
+// SetMemoryCapacity sets the memory capacity, in bytes, of the NUMA node
+// element stored under the provided NodeID, to be used for memory
+// co-placement by subsequent calls to Allocate.
+//
+// It returns a non-nil error value if the NodeID does not correspond to a
+// NUMA node or if the capacity is less than the memory already reserved from
+// it.
+func (a *Allocator) SetMemoryCapacity(numaID NodeID, bytes uint64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	data, err := a.topo.Get(numaID)
+	if err != nil {
+		return err
+	}
+	if !data.IsProcessing() || data.Kind != NUMANode {
+		return fmt.Errorf("element %d is not a NUMA node", numaID)
+	}
+	if bytes < a.memReserved[numaID] {
+		return fmt.Errorf("capacity %dB of NUMA node %d is less than the %dB already reserved",
+			bytes, numaID, a.memReserved[numaID])
+	}
+	a.memCapacity[numaID] = bytes
+	return nil
+}
+
+// Available returns the NodeIDs of all hardware threads that are neither
+// reserved nor part of any Allocation at the moment.
+func (a *Allocator) Available() []NodeID {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ret := make([]NodeID, 0)
+	for _, id := range a.topo.Threads() {
+		if !a.allocated[id] && !a.reserved[id] {
+			ret = append(ret, id)
+		}
+	}
+	return ret
+}
+
+// AllocationRequest describes the hardware threads (and, optionally, the
+// memory) requested from an Allocator, along with any placement constraints
+// that they must satisfy.
+type AllocationRequest struct {
+	// Owner is the opaque ID that the resulting Allocation will be held
+	// by. Each owner may hold at most one Allocation at a time.
+	Owner string
+	// MinThreads is the minimum number of threads to allocate.
+	MinThreads int
+	// MaxThreads is the maximum number of threads to allocate. If it is
+	// zero, exactly MinThreads threads are allocated.
+	MaxThreads int
+	// Memory is the number of bytes to also reserve from the NUMA nodes
+	// that the allocated threads belong to, or zero if no memory is
+	// requested.
+	Memory uint64
+	// Policy is the AllocationPolicy to select threads with.
+	Policy AllocationPolicy
+	// SingleL3, if true, requires all allocated threads to share the same
+	// L3 cache.
+	SingleL3 bool
+	// NUMANodes, if non-empty, restricts the allocated threads to the NUMA
+	// node elements stored under these NodeIDs.
+	NUMANodes []NodeID
+	// AvoidSiblingsOf, if non-empty, excludes these hardware threads, as
+	// well as their SMT siblings (i.e., the threads of their nearest Core
+	// ancestors), from the allocation.
+	AvoidSiblingsOf []NodeID
+}
+
+// Allocate selects available hardware threads according to the provided
+// AllocationRequest and marks them as allocated on behalf of its owner, or
+// returns a non-nil error value explaining why the request cannot be
+// satisfied.
+//
+// As many threads as possible are allocated, between MinThreads and
+// MaxThreads, subject to the request's constraints.
+//
+// If the Pack policy cannot fit the selected threads in a single NUMA node, it
+// confines them to the set of NUMA nodes that can fit them with the lowest sum
+// of pairwise distances (see Topology.NUMADistance), rather than spilling over
+// to arbitrary ones. The NUMA nodes that the threads of the returned Allocation
+// span, along with their distance cost, are reported in it.
+//
+// If memory is requested, it is reserved from the NUMA nodes that the selected
+// threads belong to, as configured through SetMemoryCapacity. In that case,
+// threads are only selected among NUMA nodes with free memory, and the memory
+// is split among the NUMA nodes in proportion to the number of selected
+// threads in each of them. Memory is never reserved from a NUMA node that none
+// of the selected threads belongs to.
+//
+// The returned Allocation is a copy, which the caller may modify freely.
+//
+// Nothing is allocated on failure.
+func (a *Allocator) Allocate(req AllocationRequest) (*Allocation, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.allocations[req.Owner]; exists {
+		return nil, fmt.Errorf("owner '%s' already holds an Allocation", req.Owner)
+	}
+	if req.MinThreads <= 0 {
+		return nil, fmt.Errorf("invalid number of threads requested: %d", req.MinThreads)
+	}
+	maxThreads := req.MaxThreads
+	if maxThreads == 0 {
+		maxThreads = req.MinThreads
+	}
+	if maxThreads < req.MinThreads {
+		return nil, fmt.Errorf("invalid range of threads requested: [%d, %d]", req.MinThreads, maxThreads)
+	}
+	if req.Policy != Pack && req.Policy != Spread {
+		return nil, fmt.Errorf("unknown allocation policy %d", req.Policy)
+	}
+
+	excluded, err := a.excludedBy(&req)
+	if err != nil {
+		return nil, err
+	}
+	free := a.freeCounts(excluded)
+
+	// Find the subtree to select threads from, and how many of them can
+	// be selected there.
+	root, avail := NodeID(0), free[0]
+	if req.SingleL3 {
+		l3IDs := a.topo.L3Caches()
+		if len(l3IDs) == 0 {
+			return nil, fmt.Errorf("cannot allocate within a single L3 cache: Topology has no L3 caches")
+		}
+		avail = 0
+		for _, l3ID := range l3IDs {
+			// Pack prefers the fullest L3 that fits the request, while
+			// Spread prefers the emptiest one.
+			better := free[l3ID] > avail
+			if req.Policy == Pack && avail >= maxThreads {
+				better = free[l3ID] >= maxThreads && free[l3ID] < avail
+			}
+			if better {
+				root, avail = l3ID, free[l3ID]
+			}
+		}
+	}
+	if avail < req.MinThreads {
+		return nil, fmt.Errorf("cannot allocate %d threads for '%s': only %d available%s",
+			req.MinThreads, req.Owner, avail, describeConstraints(&req))
+	}
+	n := maxThreads
+	if n > avail {
+		n = avail
+	}
+
+	// If Pack cannot fit the request in a single NUMA node, restrict it to
+	// the closest NUMA nodes that can, rather than to arbitrary ones.
+	if req.Policy == Pack && !req.SingleL3 {
+		if numaIDs, err := a.closestNUMANodes(free, n); err != nil {
+			return nil, err
+		} else if len(numaIDs) > 1 {
+			chosen := make(map[NodeID]bool, len(numaIDs))
+			for _, numaID := range numaIDs {
+				chosen[numaID] = true
+			}
+			for _, numaID := range a.topo.NUMANodes() {
+				if !chosen[numaID] {
+					excluded[numaID] = true
+				}
+			}
+			free = a.freeCounts(excluded)
+		}
+	}
+
+	var threads []NodeID
+	switch req.Policy {
+	case Pack:
+		threads = a.pack(root, n, free, make([]NodeID, 0, n))
+	case Spread:
+		threads = a.spread(root, n, free, make([]NodeID, 0, n))
+	}
+	sort.Slice(threads, func(i, j int) bool { return threads[i] < threads[j] })
+
+	var reservation map[NodeID]uint64
+	if req.Memory > 0 {
+		if reservation, err = a.placeMemory(threads, req.Memory); err != nil {
+			return nil, err
+		}
+	}
+	alloc := &Allocation{Threads: threads, Memory: reservation}
+	if alloc.NUMANodes, alloc.DistanceCost, err = a.numaNodesOf(threads); err != nil {
+		return nil, err
+	}
+	for _, id := range threads {
+		a.allocated[id] = true
+	}
+	for numaID, bytes := range reservation {
+		a.memReserved[numaID] += bytes
+	}
+	a.allocations[req.Owner] = alloc
+	return alloc.clone(), nil
+}
+
+// closestNUMANodes returns the NodeIDs of the NUMA nodes that the provided
+// number of threads should be packed into, given the free threads of each
+// element: a single NUMA node, if any can fit them all, or otherwise the set
+// of NUMA nodes that can fit them with the lowest sum of pairwise distances
+// (see Topology.NUMADistance). It returns no NUMA nodes if the Topology
+// contains none.
+//
+// The set is built greedily from each NUMA node in turn, by repeatedly adding
+// the one that is closest to those already in the set (preferring the one
+// with the most free threads, and then the lowest NodeID).
+func (a *Allocator) closestNUMANodes(free []int, n int) ([]NodeID, error) {
+	candidates := make([]NodeID, 0)
+	for _, numaID := range a.topo.NUMANodes() {
+		if free[numaID] >= n {
+			return []NodeID{numaID}, nil
+		}
+		if free[numaID] > 0 {
+			candidates = append(candidates, numaID)
+		}
+	}
+
+	var best []NodeID
+	var bestCost uint32
+	for _, seed := range candidates {
+		set, avail := []NodeID{seed}, free[seed]
+		for avail < n {
+			next, nextDist := -1, uint32(0)
+			for i, candidate := range candidates {
+				if containsNodeID(set, candidate) {
+					continue
+				}
+				var dist uint32
+				for _, numaID := range set {
+					d, err := a.topo.NUMADistance(candidate, numaID)
+					if err != nil {
+						return nil, err
+					}
+					dist += d
+				}
+				if next == -1 || dist < nextDist || (dist == nextDist && free[candidate] > free[candidates[next]]) {
+					next, nextDist = i, dist
+				}
+			}
+			if next == -1 {
+				break
+			}
+			set = append(set, candidates[next])
+			avail += free[candidates[next]]
+		}
+		if avail < n {
+			continue
+		}
+		cost, err := a.topo.distanceCost(set)
+		if err != nil {
+			return nil, err
+		}
+		if nil == best || cost < bestCost || (cost == bestCost && len(set) < len(best)) {
+			best, bestCost = set, cost
+		}
+	}
+	sort.Slice(best, func(i, j int) bool { return best[i] < best[j] })
+	return best, nil
+}
+
+// numaNodesOf returns the NodeIDs of the NUMA nodes that the provided hardware
+// threads belong to, in ascending order, along with the sum of the distances
+// among all pairs of them.
+func (a *Allocator) numaNodesOf(threads []NodeID) ([]NodeID, uint32, error) {
+	numaIDs := make([]NodeID, 0)
+	for _, id := range threads {
+		if numaID, ok := a.numaOf[id]; ok && !containsNodeID(numaIDs, numaID) {
+			numaIDs = append(numaIDs, numaID)
+		}
+	}
+	if len(numaIDs) == 0 {
+		return nil, 0, nil
+	}
+	sort.Slice(numaIDs, func(i, j int) bool { return numaIDs[i] < numaIDs[j] })
+	cost, err := a.topo.distanceCost(numaIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	return numaIDs, cost, nil
+}
+
+// containsNodeID returns true if the provided list contains the provided
+// NodeID.
+func containsNodeID(ids []NodeID, id NodeID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedBy returns the set of elements whose subtrees must not be considered
+// when selecting threads for the provided AllocationRequest, or a non-nil
+// error value if the request's constraints refer to invalid elements.
+func (a *Allocator) excludedBy(req *AllocationRequest) (map[NodeID]bool, error) {
+	excluded := make(map[NodeID]bool)
+	if req.Memory > 0 {
+		for _, numaID := range a.topo.NUMANodes() {
+			if a.memCapacity[numaID] == a.memReserved[numaID] {
+				excluded[numaID] = true
+			}
+		}
+	}
+
+	if len(req.NUMANodes) > 0 {
+		allowed := make(map[NodeID]bool, len(req.NUMANodes))
+		for _, numaID := range req.NUMANodes {
+			data, err := a.topo.Get(numaID)
+			if err != nil {
+				return nil, err
+			}
+			if !data.IsProcessing() || data.Kind != NUMANode {
+				return nil, fmt.Errorf("element %d is not a NUMA node", numaID)
+			}
+			allowed[numaID] = true
+		}
+		for _, id := range a.topo.Threads() {
+			if numaID, ok := a.numaOf[id]; !ok || !allowed[numaID] {
+				excluded[id] = true
+			}
+		}
+	}
+
+	for _, id := range req.AvoidSiblingsOf {
+		data, err := a.topo.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if !data.IsProcessing() || data.Kind != Thread {
+			return nil, fmt.Errorf("element %d is not a thread", id)
+		}
+		excluded[id] = true
+		// The SMT siblings are the threads of the nearest Core ancestor;
+		// without one, the thread has none.
+		coreID, ok, err := a.topo.coreOf(id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		start, end, err := a.topo.SubtreeRange(coreID)
+		if err != nil {
+			return nil, err
+		}
+		for siblingID := start; siblingID < end; siblingID++ {
+			if data := a.topo.Nodes[siblingID].Data; data.IsProcessing() && data.Kind == Thread {
+				excluded[siblingID] = true
+			}
+		}
+	}
+	return excluded, nil
+}
+
+// describeConstraints returns a human-readable description of the placement
+// constraints of the provided AllocationRequest, suitable for explaining why
+// it could not be satisfied.
+func describeConstraints(req *AllocationRequest) string {
+	constraints := make([]string, 0, 4)
+	if req.Memory > 0 {
+		constraints = append(constraints, "on NUMA nodes with free memory")
+	}
+	if len(req.NUMANodes) > 0 {
+		constraints = append(constraints, fmt.Sprintf("on NUMA nodes %v", req.NUMANodes))
+	}
+	if len(req.AvoidSiblingsOf) > 0 {
+		constraints = append(constraints, fmt.Sprintf("avoiding SMT siblings of threads %v", req.AvoidSiblingsOf))
+	}
+	if req.SingleL3 {
+		constraints = append(constraints, "within a single L3 cache")
+	}
+	if len(constraints) == 0 {
+		return ""
+	}
+	return " " + strings.Join(constraints, ", ")
+}
+
+// Lookup returns a copy of the Allocation held by the provided owner ID, if
+// any.
+func (a *Allocator) Lookup(owner string) (*Allocation, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	alloc, ok := a.allocations[owner]
+	if !ok {
+		return nil, false
+	}
+	return alloc.clone(), true
+}
+
+// Owners returns the IDs of all owners that currently hold an Allocation, in
+// ascending order.
+func (a *Allocator) Owners() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ret := make([]string, 0, len(a.allocations))
+	for owner := range a.allocations {
+		ret = append(ret, owner)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// placeMemory splits the requested amount of memory among the NUMA nodes that
+// the provided hardware threads belong to, in proportion to the number of
+// threads in each of them and without exceeding their free memory, or returns
+// a non-nil error value if they cannot accommodate it.
+func (a *Allocator) placeMemory(threads []NodeID, memory uint64) (map[NodeID]uint64, error) {
+	perNUMA := make(map[NodeID]uint64)
+	for _, id := range threads {
+		numaID, ok := a.numaOf[id]
+		if !ok {
+			return nil, fmt.Errorf("thread %d does not belong to any NUMA node", id)
+		}
+		perNUMA[numaID]++
+	}
+	numaIDs := make([]NodeID, 0, len(perNUMA))
+	for numaID := range perNUMA {
+		numaIDs = append(numaIDs, numaID)
+	}
+	sort.Slice(numaIDs, func(i, j int) bool { return numaIDs[i] < numaIDs[j] })
+
+	// First, the proportional share of each NUMA node (capped by its free
+	// memory); then, any remainder is spilled over to the local NUMA nodes
+	// that still have free memory.
+	reservation := make(map[NodeID]uint64, len(numaIDs))
+	remaining := memory
+	for _, numaID := range numaIDs {
+		share := memory / uint64(len(threads)) * perNUMA[numaID]
+		if avail := a.memCapacity[numaID] - a.memReserved[numaID]; share > avail {
+			share = avail
+		}
+		reservation[numaID] = share
+		remaining -= share
+	}
+	for _, numaID := range numaIDs {
+		if remaining == 0 {
+			break
+		}
+		avail := a.memCapacity[numaID] - a.memReserved[numaID] - reservation[numaID]
+		if avail > remaining {
+			avail = remaining
+		}
+		reservation[numaID] += avail
+		remaining -= avail
+	}
+	if remaining > 0 {
+		return nil, fmt.Errorf("cannot reserve %dB of memory: NUMA nodes %v are %dB short",
+			memory, numaIDs, remaining)
+	}
+	for _, numaID := range numaIDs {
+		if reservation[numaID] == 0 {
+			delete(reservation, numaID)
+		}
+	}
+	return reservation, nil
+}
+
+// Release marks all hardware threads (and memory) of the Allocation held by
+// the provided owner ID as available again, or returns a non-nil error value
+// if the owner holds no Allocation.
+func (a *Allocator) Release(owner string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	alloc, ok := a.allocations[owner]
+	if !ok {
+		return fmt.Errorf("owner '%s' holds no Allocation", owner)
+	}
+	delete(a.allocations, owner)
+	for _, id := range alloc.Threads {
+		a.allocated[id] = false
+	}
+	for numaID, bytes := range alloc.Memory {
+		a.memReserved[numaID] -= bytes
+	}
+	return nil
+}
+
+// Overlay returns an Overlay that visualizes the current state of the
+// Allocator: every hardware thread is labeled with the owner of the Allocation
+// that it is part of (or as "reserved"), and every Element is assigned the
+// fraction of the hardware threads in its subtree that are unavailable.
+func (a *Allocator) Overlay() *Overlay {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	overlay := &Overlay{
+		Values: make(map[NodeID]float64),
+		Labels: make(map[NodeID]string),
+	}
+	for owner, alloc := range a.allocations {
+		for _, id := range alloc.Threads {
+			overlay.Labels[id] = owner
+		}
+	}
+	for _, id := range a.reservedIDs() {
+		overlay.Labels[id] = "reserved"
+	}
+
+	// Count the total and the unavailable threads in every subtree, from
+	// the leaves up, relying on the pre-order layout of the Tree.
+	total, used := make([]int, len(a.topo.Nodes)), make([]int, len(a.topo.Nodes))
+	for id := len(a.topo.Nodes) - 1; id >= 0; id-- {
+		if data := a.topo.Nodes[id].Data; data.IsProcessing() && data.Kind == Thread {
+			total[id]++
+			if a.allocated[id] || a.reserved[id] {
+				used[id]++
+			}
+		}
+		for _, child := range a.topo.Nodes[id].Children {
+			total[id] += total[child]
+			used[id] += used[child]
+		}
+		if total[id] > 0 {
+			overlay.Values[NodeID(id)] = float64(used[id]) / float64(total[id])
+		}
+	}
+	return overlay
+}
+
+// isAvailableThread returns true if the element stored under the provided
+// NodeID is a hardware thread that is neither reserved nor currently
+// allocated.
+func (a *Allocator) isAvailableThread(id NodeID) bool {
+	data := a.topo.Nodes[id].Data
+	return data.IsProcessing() && data.Kind == Thread && !a.allocated[id] && !a.reserved[id]
+}
+
+// freeCounts returns a slice, indexed by NodeID, holding the number of
+// available hardware threads in the subtree rooted at each element.
+//
+// Subtrees rooted at the excluded elements are considered to have no
+// available hardware threads.
+func (a *Allocator) freeCounts(excluded map[NodeID]bool) []int {
+	nodes := a.topo.Nodes
+	free := make([]int, len(nodes))
+
+	// Visit the Tree in pre-order, then accumulate the counts in reverse
+	// so that each element is processed after all of its descendants.
+	order := make([]NodeID, 0, len(nodes))
+	stack := []NodeID{0}
+	for len(stack) > 0 {
+		last := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		order = append(order, last)
+		stack = append(stack, nodes[last].Children...)
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		if excluded[id] {
+			continue
+		}
+		if a.isAvailableThread(id) {
+			free[id]++
+		}
+		for _, child := range nodes[id].Children {
+			free[id] += free[child]
+		}
+	}
+	return free
+}
+
+// pack appends to out n available hardware threads from the subtree rooted at
+// the provided NodeID, following the Pack policy.
+//
+// At each level of the hierarchy, the child with the fewest available threads
+// that can still accommodate the whole request is preferred (best fit). When
+// no single child suffices, children are filled in descending order of
+// available threads, so that the number of subtrees touched is minimized.
+func (a *Allocator) pack(id NodeID, n int, free []int, out []NodeID) []NodeID {
+	if a.isAvailableThread(id) {
+		return append(out, id)
+	}
+
+	children := a.topo.Nodes[id].Children
+	used := make([]bool, len(children))
+	for n > 0 {
+		best := -1
+		for i, child := range children {
+			if used[i] || free[child] < n {
+				continue
+			}
+			if best == -1 || free[child] < free[children[best]] {
+				best = i
+			}
+		}
+		if best == -1 {
+			for i, child := range children {
+				if used[i] || free[child] == 0 {
+					continue
+				}
+				if best == -1 || free[child] > free[children[best]] {
+					best = i
+				}
+			}
+		}
+		used[best] = true
+
+		take := free[children[best]]
+		if take > n {
+			take = n
+		}
+		out = a.pack(children[best], take, free, out)
+		n -= take
+	}
+	return out
+}
+
+// spread appends to out n available hardware threads from the subtree rooted
+// at the provided NodeID, following the Spread policy.
+//
+// At each level of the hierarchy, the request is split among the children one
+// thread at a time, always favoring the child that has been assigned the
+// fewest threads so far (and, among those, the one with the most available
+// threads), so that the assigned counts stay as balanced as capacity allows.
+func (a *Allocator) spread(id NodeID, n int, free []int, out []NodeID) []NodeID {
+	if a.isAvailableThread(id) {
+		return append(out, id)
+	}
+
+	children := a.topo.Nodes[id].Children
+	assigned := make([]int, len(children))
+	for ; n > 0; n-- {
+		best := -1
+		for i, child := range children {
+			if assigned[i] == free[child] {
+				continue
+			}
+			if best == -1 || assigned[i] < assigned[best] ||
+				(assigned[i] == assigned[best] && free[child] > free[children[best]]) {
+				best = i
+			}
+		}
+		assigned[best]++
+	}
+	for i, child := range children {
+		if assigned[i] > 0 {
+			out = a.spread(child, assigned[i], free, out)
+		}
+	}
+	return out
+}
+
+// allocatorState is the serializable state of an Allocator.
+type allocatorState struct {
+	Reserved       []NodeID               `json:"reserved,omitempty"`
+	MemoryCapacity map[NodeID]uint64      `json:"memcap,omitempty"`
+	Allocations    map[string]*Allocation `json:"allocations"`
+}
+
+// MarshalJSON returns the state of the Allocator (i.e., its reserved threads,
+// the configured memory capacities and all Allocations keyed by their owner
+// IDs) marshalled in JSON, or a non-nil error value in case of failure.
+//
+// The Topology itself is not included.
+func (a *Allocator) MarshalJSON() ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state := allocatorState{
+		Reserved:       a.reservedIDs(),
+		MemoryCapacity: a.memCapacity,
+		Allocations:    a.allocations,
+	}
+	return json.Marshal(&state)
+}
+
+// UnmarshalJSON attempts to restore the state of the Allocator from the
+// provided byte slice, as previously produced by MarshalJSON, and returns a
+// non-nil error if it fails.
+//
+// The Allocator must have been created through NewAllocator for the same
+// Topology that the state was captured on. Any state it held before is
+// discarded on success, and left untouched on failure.
+func (a *Allocator) UnmarshalJSON(data []byte) error {
+	if nil == a.topo {
+		return fmt.Errorf("Allocator has not been created through NewAllocator")
+	}
+	var state allocatorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	isThread := func(id NodeID) bool {
+		if int(id) >= len(a.topo.Nodes) {
+			return false
+		}
+		data := a.topo.Nodes[id].Data
+		return data.IsProcessing() && data.Kind == Thread
+	}
+	isNUMANode := func(id NodeID) bool {
+		if int(id) >= len(a.topo.Nodes) {
+			return false
+		}
+		data := a.topo.Nodes[id].Data
+		return data.IsProcessing() && data.Kind == NUMANode
+	}
+	reserved := make([]bool, len(a.topo.Nodes))
+	for _, id := range state.Reserved {
+		if !isThread(id) {
+			return fmt.Errorf("reserved element %d is not a thread", id)
+		}
+		reserved[id] = true
+	}
+	memCapacity := make(map[NodeID]uint64, len(state.MemoryCapacity))
+	for numaID, bytes := range state.MemoryCapacity {
+		if !isNUMANode(numaID) {
+			return fmt.Errorf("element %d is not a NUMA node", numaID)
+		}
+		memCapacity[numaID] = bytes
+	}
+	allocated := make([]bool, len(a.topo.Nodes))
+	memReserved := make(map[NodeID]uint64)
+	allocations := make(map[string]*Allocation, len(state.Allocations))
+	for owner, alloc := range state.Allocations {
+		if nil == alloc {
+			return fmt.Errorf("Allocation of owner '%s' is null", owner)
+		}
+		for _, id := range alloc.Threads {
+			if !isThread(id) {
+				return fmt.Errorf("element %d allocated to '%s' is not a thread", id, owner)
+			}
+			if reserved[id] || allocated[id] {
+				return fmt.Errorf("thread %d allocated to '%s' is reserved or allocated twice", id, owner)
+			}
+			allocated[id] = true
+		}
+		for _, numaID := range alloc.NUMANodes {
+			if !isNUMANode(numaID) {
+				return fmt.Errorf("element %d allocated to '%s' is not a NUMA node", numaID, owner)
+			}
+		}
+		for numaID, bytes := range alloc.Memory {
+			if !isNUMANode(numaID) {
+				return fmt.Errorf("element %d allocated to '%s' is not a NUMA node", numaID, owner)
+			}
+			memReserved[numaID] += bytes
+			if memReserved[numaID] > memCapacity[numaID] {
+				return fmt.Errorf("memory reserved from NUMA node %d exceeds its capacity", numaID)
+			}
+		}
+		allocations[owner] = alloc
+	}
+
+	a.allocations = allocations
+	a.allocated = allocated
+	a.reserved = reserved
+	a.memCapacity = memCapacity
+	a.memReserved = memReserved
+	return nil
+}
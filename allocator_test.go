@@ -0,0 +1,499 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func loadTopology(t *testing.T, path string) *Topology {
+	t.Helper()
+	var (
+		topo Topology
+		data []byte
+		err  error
+	)
+	if data, err = os.ReadFile(path); err != nil {
+		t.Fatalf("Error reading from file %q: %v\n", path, err)
+	}
+	if err = json.Unmarshal(data, &topo); err != nil {
+		t.Fatalf("Error unmarshaling JSON: %v\n", err)
+	}
+	return &topo
+}
+
+func TestAllocatePack(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/topo__immutree.json")
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+
+	// A pair of threads should land on the two SMT siblings of one core.
+	a1, err := alloc.Allocate(AllocationRequest{Owner: "a", MinThreads: 2, Policy: Pack})
+	if err != nil {
+		t.Fatalf("Failed to allocate 2 threads: %v\n", err)
+	}
+	if want := []NodeID{6, 7}; !reflect.DeepEqual(a1.Threads, want) {
+		t.Errorf("Allocate(2) = %v; want %v", a1.Threads, want)
+	}
+
+	// A single thread should not split a full core if a partially used one
+	// is available.
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "b", MinThreads: 1, Policy: Pack}); err != nil {
+		t.Fatalf("Failed to allocate 1 thread: %v\n", err)
+	}
+	a3, err := alloc.Allocate(AllocationRequest{Owner: "c", MinThreads: 1, Policy: Pack})
+	if err != nil {
+		t.Fatalf("Failed to allocate 1 thread: %v\n", err)
+	}
+	if want := []NodeID{12}; !reflect.DeepEqual(a3.Threads, want) {
+		t.Errorf("Allocate(1) = %v; want %v", a3.Threads, want)
+	}
+
+	// The remaining 8 threads of package 0 fit in its L3, so the second
+	// package should not be touched.
+	a4, err := alloc.Allocate(AllocationRequest{Owner: "d", MinThreads: 8, Policy: Pack})
+	if err != nil {
+		t.Fatalf("Failed to allocate 8 threads: %v\n", err)
+	}
+	for _, id := range a4.Threads {
+		if id >= 33 {
+			t.Errorf("Allocate(8) touched the second package: %v", a4.Threads)
+			break
+		}
+	}
+
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "a", MinThreads: 1, Policy: Pack}); err == nil {
+		t.Errorf("Allocate succeeded for an owner that already holds an Allocation")
+	}
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "e", MinThreads: 13, Policy: Pack}); err == nil {
+		t.Errorf("Allocate(13) succeeded with only 12 threads available")
+	}
+	if err = alloc.Release("b"); err != nil {
+		t.Fatalf("Failed to release Allocation of 'b': %v\n", err)
+	}
+	if err = alloc.Release("b"); err == nil {
+		t.Errorf("Release of an already released Allocation succeeded")
+	}
+	if n := len(alloc.Available()); n != 13 {
+		t.Errorf("len(Available()) = %d; want 13", n)
+	}
+	if want := []string{"a", "c", "d"}; !reflect.DeepEqual(alloc.Owners(), want) {
+		t.Errorf("Owners() = %v; want %v", alloc.Owners(), want)
+	}
+}
+
+func TestAllocateSpread(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+
+	// Four threads should be split evenly across the two packages, and
+	// should not share any L2 cache.
+	a, err := alloc.Allocate(AllocationRequest{Owner: "a", MinThreads: 4, Policy: Spread})
+	if err != nil {
+		t.Fatalf("Failed to allocate 4 threads: %v\n", err)
+	}
+	perPackage := make(map[NodeID]int)
+	perParent := make(map[NodeID]int)
+	for _, id := range a.Threads {
+		ancestorIDs, err := topo.AncestorIDs(id)
+		if err != nil {
+			t.Fatalf("Failed to retrieve ancestors of %d: %v\n", id, err)
+		}
+		perParent[ancestorIDs[0]]++
+		perPackage[ancestorIDs[len(ancestorIDs)-2]]++
+	}
+	if perPackage[1] != 2 || perPackage[21] != 2 {
+		t.Errorf("Allocate(4, Spread) = %v; unbalanced across packages", a.Threads)
+	}
+	if len(perParent) != 4 {
+		t.Errorf("Allocate(4, Spread) = %v; threads share L2 caches", a.Threads)
+	}
+
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "b", MinThreads: 1, Policy: AllocationPolicy(42)}); err == nil {
+		t.Errorf("Allocate with an unknown policy succeeded")
+	}
+}
+
+func TestAllocateMemory(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+	const GiB = 1 << 30
+	if err = alloc.SetMemoryCapacity(2, 4*GiB); err != nil {
+		t.Fatalf("Failed to set memory capacity: %v\n", err)
+	}
+	if err = alloc.SetMemoryCapacity(22, 4*GiB); err != nil {
+		t.Fatalf("Failed to set memory capacity: %v\n", err)
+	}
+	if err = alloc.SetMemoryCapacity(1, GiB); err == nil {
+		t.Errorf("SetMemoryCapacity succeeded on a Package")
+	}
+
+	// Memory of a packed allocation should come from its own NUMA node.
+	a1, err := alloc.Allocate(AllocationRequest{Owner: "a", MinThreads: 2, Memory: 3 * GiB, Policy: Pack})
+	if err != nil {
+		t.Fatalf("Failed to allocate 2 threads and 3GiB: %v\n", err)
+	}
+	if want := map[NodeID]uint64{2: 3 * GiB}; !reflect.DeepEqual(a1.Memory, want) {
+		t.Errorf("Allocate(2, 3GiB, Pack).Memory = %v; want %v", a1.Memory, want)
+	}
+
+	// NUMA node 2 only has 1GiB left, so a spread allocation should spill
+	// the rest over to NUMA node 22.
+	a2, err := alloc.Allocate(AllocationRequest{Owner: "b", MinThreads: 2, Memory: 4 * GiB, Policy: Spread})
+	if err != nil {
+		t.Fatalf("Failed to allocate 2 threads and 4GiB: %v\n", err)
+	}
+	if want := map[NodeID]uint64{2: GiB, 22: 3 * GiB}; !reflect.DeepEqual(a2.Memory, want) {
+		t.Errorf("Allocate(2, 4GiB, Spread).Memory = %v; want %v", a2.Memory, want)
+	}
+	if want := []NodeID{2, 22}; !reflect.DeepEqual(a2.MemoryNodes(), want) {
+		t.Errorf("MemoryNodes() = %v; want %v", a2.MemoryNodes(), want)
+	}
+
+	// NUMA node 2 is now full, so threads must come from NUMA node 22.
+	a3, err := alloc.Allocate(AllocationRequest{Owner: "c", MinThreads: 1, Memory: GiB, Policy: Pack})
+	if err != nil {
+		t.Fatalf("Failed to allocate 1 thread and 1GiB: %v\n", err)
+	}
+	if a3.Threads[0] < 22 {
+		t.Errorf("Allocate(1, 1GiB, Pack) = %v; want a thread of NUMA node 22", a3.Threads)
+	}
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "d", MinThreads: 1, Memory: GiB, Policy: Pack}); err == nil {
+		t.Errorf("Allocate succeeded with no free memory left")
+	}
+
+	if err = alloc.Release("a"); err != nil {
+		t.Fatalf("Failed to release Allocation: %v\n", err)
+	}
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "d", MinThreads: 4, Memory: 3 * GiB, Policy: Pack}); err != nil {
+		t.Errorf("Failed to allocate 4 threads and 3GiB after release: %v\n", err)
+	}
+}
+
+func TestAllocationCopies(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+	const GiB = 1 << 30
+	if err = alloc.SetMemoryCapacity(2, 4*GiB); err != nil {
+		t.Fatalf("Failed to set memory capacity: %v\n", err)
+	}
+	a1, err := alloc.Allocate(AllocationRequest{Owner: "a", MinThreads: 2, Memory: GiB, Policy: Pack})
+	if err != nil {
+		t.Fatalf("Failed to allocate 2 threads and 1GiB: %v\n", err)
+	}
+	want := a1.clone()
+
+	// Modifying the returned Allocations must not affect the Allocator.
+	a1.Threads[0], a1.NUMANodes[0] = 0, 0
+	delete(a1.Memory, 2)
+	got, ok := alloc.Lookup("a")
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Fatalf("Lookup(\"a\") = %v after modifying the result of Allocate; want %v", got, want)
+	}
+	got.Threads = got.Threads[:0]
+	got.Memory[2] = 0
+	if got, _ = alloc.Lookup("a"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup(\"a\") = %v after modifying the result of Lookup; want %v", got, want)
+	}
+
+	// Releasing must free what was actually allocated.
+	if err = alloc.Release("a"); err != nil {
+		t.Fatalf("Failed to release: %v\n", err)
+	}
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "b", MinThreads: 2, Memory: 4 * GiB, Policy: Pack}); err != nil {
+		t.Errorf("Failed to allocate 2 threads and 4GiB after releasing: %v\n", err)
+	}
+	if _, ok = alloc.Lookup("a"); ok {
+		t.Errorf("Lookup(\"a\") succeeded after releasing")
+	}
+}
+
+func TestAllocateReserved(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+	// Reserve the first core of each package, plus its SMT sibling.
+	if err = alloc.Reserve("0,12"); err != nil {
+		t.Fatalf("Failed to reserve threads: %v\n", err)
+	}
+	if err = alloc.Reserve("6,18"); err != nil {
+		t.Fatalf("Failed to reserve threads: %v\n", err)
+	}
+	if err = alloc.Reserve("0-99"); err == nil {
+		t.Errorf("Reserve succeeded with unknown OS indices")
+	}
+	if want := []NodeID{4, 5, 24, 25}; !reflect.DeepEqual(alloc.Reserved(), want) {
+		t.Errorf("Reserved() = %v; want %v", alloc.Reserved(), want)
+	}
+
+	a, err := alloc.Allocate(AllocationRequest{Owner: "a", MinThreads: 20, Policy: Spread})
+	if err != nil {
+		t.Fatalf("Failed to allocate 20 threads: %v\n", err)
+	}
+	for _, id := range a.Threads {
+		if id == 4 || id == 5 || id == 24 || id == 25 {
+			t.Errorf("Allocate(20) = %v; includes reserved thread %d", a.Threads, id)
+		}
+	}
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "b", MinThreads: 1, Policy: Pack}); err == nil {
+		t.Errorf("Allocate succeeded with only reserved threads left")
+	}
+}
+
+func TestAllocatorState(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+	if err = alloc.Reserve("0,12"); err != nil {
+		t.Fatalf("Failed to reserve threads: %v\n", err)
+	}
+	if err = alloc.SetMemoryCapacity(22, 1<<30); err != nil {
+		t.Fatalf("Failed to set memory capacity: %v\n", err)
+	}
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "pod-a", MinThreads: 3, Policy: Pack}); err != nil {
+		t.Fatalf("Failed to allocate: %v\n", err)
+	}
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "pod-b", MinThreads: 2, Memory: 1 << 20, Policy: Spread}); err != nil {
+		t.Fatalf("Failed to allocate: %v\n", err)
+	}
+
+	checkpoint, err := json.Marshal(alloc)
+	if err != nil {
+		t.Fatalf("Failed to marshal Allocator state: %v\n", err)
+	}
+	t.Logf("Checkpoint:\n%s", checkpoint)
+
+	recovered, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+	if err = json.Unmarshal(checkpoint, recovered); err != nil {
+		t.Fatalf("Failed to unmarshal Allocator state: %v\n", err)
+	}
+	if !reflect.DeepEqual(recovered.Available(), alloc.Available()) {
+		t.Errorf("Available() = %v after recovery; want %v", recovered.Available(), alloc.Available())
+	}
+	if !reflect.DeepEqual(recovered.Reserved(), alloc.Reserved()) {
+		t.Errorf("Reserved() = %v after recovery; want %v", recovered.Reserved(), alloc.Reserved())
+	}
+	for _, owner := range alloc.Owners() {
+		want, _ := alloc.Lookup(owner)
+		if got, ok := recovered.Lookup(owner); !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("Lookup(%q) = %v after recovery; want %v", owner, got, want)
+		}
+	}
+	if err = recovered.Release("pod-b"); err != nil {
+		t.Errorf("Failed to release recovered Allocation: %v\n", err)
+	}
+
+	// Threads that are allocated twice must be rejected.
+	bad := []byte(`{"allocations":{"x":{"threads":[7]},"y":{"threads":[7]}}}`)
+	if err = json.Unmarshal(bad, recovered); err == nil {
+		t.Errorf("Unmarshaling conflicting Allocations succeeded")
+	}
+
+	// NUMA nodes must exist in the Topology.
+	for _, bad := range []string{
+		`{"allocations":{"x":{"threads":[4],"numa_nodes":[4]}}}`,
+		`{"allocations":{"x":{"threads":[4],"numa_nodes":[1000000]}}}`,
+		`{"allocations":{"x":{"threads":[4],"memory":{"4":0}}}}`,
+	} {
+		if err = json.Unmarshal([]byte(bad), recovered); err == nil {
+			t.Errorf("Unmarshaling %s succeeded", bad)
+		}
+	}
+}
+
+func TestAllocateConstraints(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/topo__immutree.json")
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+
+	// Up to 16 threads in a single L3 cache; only 12 can be satisfied.
+	a1, err := alloc.Allocate(AllocationRequest{Owner: "a", MinThreads: 10, MaxThreads: 16, SingleL3: true})
+	if err != nil {
+		t.Fatalf("Failed to allocate 10-16 threads in a single L3: %v\n", err)
+	}
+	if len(a1.Threads) != 12 {
+		t.Errorf("Allocate(10-16, SingleL3) = %v; want 12 threads", a1.Threads)
+	}
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "b", MinThreads: 13, SingleL3: true}); err == nil {
+		t.Errorf("Allocate(13, SingleL3) succeeded")
+	} else {
+		t.Logf("Allocate(13, SingleL3) failed as expected: %v", err)
+	}
+
+	// Avoid the SMT sibling of thread 38 (i.e., thread 39).
+	a2, err := alloc.Allocate(AllocationRequest{Owner: "c", MinThreads: 1, AvoidSiblingsOf: []NodeID{38}})
+	if err != nil {
+		t.Fatalf("Failed to allocate 1 thread avoiding SMT siblings: %v\n", err)
+	}
+	if a2.Threads[0] == 38 || a2.Threads[0] == 39 {
+		t.Errorf("Allocate(1, AvoidSiblingsOf(38)) = %v", a2.Threads)
+	}
+
+	// Topology has no NUMA node elements.
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "d", MinThreads: 1, NUMANodes: []NodeID{1}}); err == nil {
+		t.Errorf("Allocate restricted on a Package instead of a NUMA node succeeded")
+	}
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "d", MinThreads: 2, MaxThreads: 1}); err == nil {
+		t.Errorf("Allocate with MaxThreads < MinThreads succeeded")
+	}
+}
+
+func TestAllocateAvoidSiblingsWithoutCores(t *testing.T) {
+	processing := func(kind ProcessingKind, id uint32) *Element {
+		return &Element{Processing: &Processing{Kind: kind, ID: id}}
+	}
+	cache := func(level CacheLevel) *Element {
+		return &Element{Cache: &Cache{Level: level}}
+	}
+	// Threads 3, 4 and 5 hang off an L2 cache without a Core, while threads
+	// 8 and 10 belong to Core 6, each under an L1 cache of its own.
+	topo := &Topology{NewTree([]TreeNode{
+		{Data: &Element{}, Children: []NodeID{1}},
+		{Data: processing(Package, 0), Children: []NodeID{2, 6}},
+		{Data: cache(L2), Children: []NodeID{3, 4, 5}},
+		{Data: processing(Thread, 0)},
+		{Data: processing(Thread, 1)},
+		{Data: processing(Thread, 2)},
+		{Data: processing(Core, 0), Children: []NodeID{7, 9}},
+		{Data: cache(L1), Children: []NodeID{8}},
+		{Data: processing(Thread, 3)},
+		{Data: cache(L1), Children: []NodeID{10}},
+		{Data: processing(Thread, 4)},
+	})}
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+
+	for _, tc := range []struct {
+		avoid NodeID
+		want  []NodeID
+	}{
+		{3, []NodeID{4, 5, 8, 10}},
+		{8, []NodeID{3, 4, 5}},
+	} {
+		req := AllocationRequest{Owner: "a", MinThreads: len(tc.want), AvoidSiblingsOf: []NodeID{tc.avoid}}
+		a1, err := alloc.Allocate(req)
+		if err != nil {
+			t.Fatalf("Allocate(%d, AvoidSiblingsOf(%d)) failed: %v\n", req.MinThreads, tc.avoid, err)
+		}
+		if !reflect.DeepEqual(a1.Threads, tc.want) {
+			t.Errorf("Allocate(%d, AvoidSiblingsOf(%d)) = %v; want %v", req.MinThreads, tc.avoid, a1.Threads, tc.want)
+		}
+		if err = alloc.Release("a"); err != nil {
+			t.Fatalf("Failed to release: %v\n", err)
+		}
+		req.MinThreads++
+		if _, err = alloc.Allocate(req); err == nil {
+			t.Errorf("Allocate(%d, AvoidSiblingsOf(%d)) succeeded", req.MinThreads, tc.avoid)
+		}
+	}
+}
+
+func TestAllocateNUMANodes(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+	a, err := alloc.Allocate(AllocationRequest{Owner: "a", MinThreads: 4, Policy: Spread, NUMANodes: []NodeID{22}})
+	if err != nil {
+		t.Fatalf("Failed to allocate 4 threads on NUMA node 22: %v\n", err)
+	}
+	for _, id := range a.Threads {
+		if id < 22 {
+			t.Errorf("Allocate(4, NUMANodes(22)) = %v", a.Threads)
+			break
+		}
+	}
+	_, err = alloc.Allocate(AllocationRequest{Owner: "b", MinThreads: 9, NUMANodes: []NodeID{22}})
+	if err == nil {
+		t.Errorf("Allocate(9, NUMANodes(22)) succeeded with only 8 threads left there")
+	}
+}
+
+func TestAllocateClosestNUMANodes(t *testing.T) {
+	// NUMA nodes 0 and 2 are closer to each other than to any other one,
+	// as are 1 and 3.
+	topo := &Topology{syntheticTree(1, 4, 2, 1)}
+	numaIDs := topo.NUMANodes()
+	for i, numaID := range numaIDs {
+		distances := make(map[uint32]uint32, len(numaIDs))
+		for j := range numaIDs {
+			switch {
+			case i == j:
+				distances[uint32(j)] = LocalDistance
+			case i%2 == j%2:
+				distances[uint32(j)] = 12
+			default:
+				distances[uint32(j)] = 32
+			}
+		}
+		topo.Nodes[numaID].Data.Memory = &MemoryAttributes{Distances: distances}
+	}
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+
+	a, err := alloc.Allocate(AllocationRequest{Owner: "a", MinThreads: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []NodeID{numaIDs[0]}; !reflect.DeepEqual(a.NUMANodes, want) || a.DistanceCost != 0 {
+		t.Errorf("got NUMA nodes %v at cost %d; expected %v at cost 0", a.NUMANodes, a.DistanceCost, want)
+	}
+	b, err := alloc.Allocate(AllocationRequest{Owner: "b", MinThreads: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []NodeID{numaIDs[1], numaIDs[3]}; !reflect.DeepEqual(b.NUMANodes, want) || b.DistanceCost != 12 {
+		t.Errorf("got NUMA nodes %v at cost %d; expected %v at cost 12", b.NUMANodes, b.DistanceCost, want)
+	}
+	// Only NUMA nodes 2 and 3 have free threads left, and they are far
+	// from each other.
+	c, err := alloc.Allocate(AllocationRequest{Owner: "c", MinThreads: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []NodeID{numaIDs[2], numaIDs[3]}; !reflect.DeepEqual(c.NUMANodes, want) || c.DistanceCost != 32 {
+		t.Errorf("got NUMA nodes %v at cost %d; expected %v at cost 32", c.NUMANodes, c.DistanceCost, want)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Query is an independent, read-only query on a Topology, to be evaluated by
+// BatchQuery.
+type Query func(t *Topology) (interface{}, error)
+
+// QueryResult holds the outcome of a Query evaluated by BatchQuery.
+type QueryResult struct {
+	// Value is the value returned by the Query.
+	Value interface{}
+	// Err is the error value returned by the Query.
+	Err error
+}
+
+// BatchQuery evaluates the provided Queries concurrently, on the provided
+// number of worker goroutines (or on runtime.GOMAXPROCS(0) of them, if workers
+// is not positive), and returns their results in the same order as the
+// Queries.
+//
+// Since the Topology is only read (see the package's concurrency contract),
+// the Queries must not modify it either.
+func (t *Topology) BatchQuery(workers int, queries []Query) []QueryResult {
+	results := make([]QueryResult, len(queries))
+	if len(queries) == 0 {
+		return results
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(queries) {
+		workers = len(queries)
+	}
+
+	// Build the indexes upfront, rather than have all workers wait on the
+	// first one that needs them.
+	if nil != t.Tree {
+		t.getIndexes()
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i].Value, results[i].Err = queries[i](t)
+			}
+		}()
+	}
+	for i := range queries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
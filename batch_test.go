@@ -0,0 +1,72 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBatchQuery(t *testing.T) {
+	topo := &Topology{Tree: syntheticTree(2, 2, 32, 2)}
+
+	// The cache path (i.e., the ancestor Caches) of every thread.
+	threads := topo.Threads()
+	queries := make([]Query, 0, len(threads)+1)
+	for _, id := range threads {
+		id := id
+		queries = append(queries, func(t *Topology) (interface{}, error) {
+			ancestors, err := t.Ancestors(id)
+			if err != nil {
+				return nil, err
+			}
+			path := make([]*Cache, 0)
+			for _, ancestor := range ancestors {
+				if ancestor.IsCache() {
+					path = append(path, ancestor.Cache)
+				}
+			}
+			return path, nil
+		})
+	}
+	queries = append(queries, func(t *Topology) (interface{}, error) {
+		return nil, fmt.Errorf("failing query")
+	})
+
+	for _, workers := range []int{0, 1, 7, 1000} {
+		results := topo.BatchQuery(workers, queries)
+		if len(results) != len(queries) {
+			t.Fatalf("got %d results for %d queries", len(results), len(queries))
+		}
+		for i, result := range results[:len(threads)] {
+			path, ok := result.Value.([]*Cache)
+			if result.Err != nil || !ok || len(path) != 3 {
+				t.Fatalf("workers=%d: got %v, %v for thread %d; want 3 caches", workers, result.Value, result.Err, threads[i])
+			}
+			if path[0].Level != L1 || path[2].Level != L3 {
+				t.Errorf("workers=%d: got cache path %v for thread %d", workers, path, threads[i])
+			}
+		}
+		if results[len(threads)].Err == nil {
+			t.Errorf("workers=%d: the error of the failing query was lost", workers)
+		}
+	}
+
+	if results := topo.BatchQuery(4, nil); len(results) != 0 {
+		t.Errorf("got %d results for no queries", len(results))
+	}
+}
@@ -0,0 +1,81 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "sort"
+
+// CacheReportEntry describes a Cache of a Topology along with the hardware
+// threads that share it (see Topology.CacheReport).
+type CacheReportEntry struct {
+	// Level is the level of the Cache.
+	Level CacheLevel `json:"level"`
+	// Size is the total size of the Cache, in bytes.
+	Size uint64 `json:"size"`
+	// Line is the size of the cache line, in bytes.
+	Line uint32 `json:"line"`
+	// Ways is the associativity of the Cache, in # ways.
+	Ways int32 `json:"ways"`
+	// SharingThreads lists the OS indices of the hardware threads that
+	// share the Cache, in ascending order.
+	SharingThreads []uint32 `json:"sharing_threads"`
+}
+
+// CacheReport returns a flat list describing all Caches of the Topology, for
+// consumers (e.g., inventory exporters) that have no use for the hierarchy.
+//
+// Entries are sorted by their level, and then by the OS indices of the
+// hardware threads that share them; Caches without attributes are reported
+// with zero attributes.
+func (t *Topology) CacheReport() []CacheReportEntry {
+	ret := make([]CacheReportEntry, 0)
+	if nil == t || nil == t.Tree {
+		return ret
+	}
+	for level := L1; level <= L5; level++ {
+		for _, id := range t.getIndexes().caches[level] {
+			entry := CacheReportEntry{Level: level, SharingThreads: make([]uint32, 0)}
+			if attrs := t.Nodes[id].Data.Attributes; nil != attrs {
+				entry.Size, entry.Line, entry.Ways = attrs.Size, attrs.Linesize, attrs.Associativity
+			}
+			// The subtree of the Cache is contiguous in pre-order.
+			start, end, err := t.SubtreeRange(id)
+			if err != nil {
+				continue
+			}
+			for _, node := range t.Nodes[start+1 : end] {
+				if node.Data.IsProcessing() && node.Data.Kind == Thread {
+					entry.SharingThreads = append(entry.SharingThreads, node.Data.ID)
+				}
+			}
+			sort.Slice(entry.SharingThreads, func(i, j int) bool { return entry.SharingThreads[i] < entry.SharingThreads[j] })
+			ret = append(ret, entry)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Level != ret[j].Level {
+			return ret[i].Level < ret[j].Level
+		}
+		a, b := ret[i].SharingThreads, ret[j].SharingThreads
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return ret
+}
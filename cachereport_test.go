@@ -0,0 +1,66 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCacheReport(t *testing.T) {
+	// Hardware threads are numbered across Cores first (see FromSpec).
+	topo, err := FromSpec(TopologySpec{
+		Packages: 1, NUMANodesPerPackage: 2, L3PerNUMANode: 1, Cores: 2, ThreadsPerCore: 2,
+		L3Size: 32 << 20, L2Size: 1 << 20, L1Size: 32 << 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := topo.CacheReport()
+	if len(report) != 4+4+2 {
+		t.Fatalf("got %d entries; expected 10", len(report))
+	}
+	want := []CacheReportEntry{
+		{Level: L1, Size: 32 << 10, Line: 64, Ways: 8, SharingThreads: []uint32{0, 4}},
+		{Level: L1, Size: 32 << 10, Line: 64, Ways: 8, SharingThreads: []uint32{1, 5}},
+	}
+	if !reflect.DeepEqual(report[:2], want) {
+		t.Errorf("got %v; expected %v", report[:2], want)
+	}
+	if got := report[len(report)-1]; got.Level != L3 || !reflect.DeepEqual(got.SharingThreads, []uint32{2, 3, 6, 7}) {
+		t.Errorf("got %v as the last entry", got)
+	}
+	for i := 1; i < len(report); i++ {
+		if report[i].Level < report[i-1].Level {
+			t.Errorf("entries %d and %d are not sorted by level", i-1, i)
+		}
+	}
+
+	data, err := json.Marshal(report[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"level":"L1","size":32768,"line":64,"ways":8,"sharing_threads":[0,4]}`; got != want {
+		t.Errorf("got %s; expected %s", got, want)
+	}
+
+	var nilTopo *Topology
+	if got := nilTopo.CacheReport(); len(got) != 0 {
+		t.Errorf("got %v for a nil Topology", got)
+	}
+}
@@ -0,0 +1,259 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TotalCacheSize returns the total size (in bytes) of all Caches of the
+// provided level in the Topology, or a non-nil error value in case of failure.
+//
+// Caches without attributes count as empty.
+func (t *Topology) TotalCacheSize(level CacheLevel) (uint64, error) {
+	if nil == t || nil == t.Tree {
+		return 0, ErrNilTree
+	}
+	if level > L5 {
+		return 0, fmt.Errorf("invalid cache level %d", level)
+	}
+	var total uint64
+	for _, id := range t.getIndexes().caches[level] {
+		if attrs := t.Nodes[id].Data.Attributes; nil != attrs {
+			total += attrs.Size
+		}
+	}
+	return total, nil
+}
+
+// PerPackageCacheSize returns the total size (in bytes) of the Caches of the
+// provided level in each Package of the Topology, indexed by the NodeIDs of
+// the Packages, or a non-nil error value in case of failure.
+//
+// Caches that are not in the subtree of any Package (e.g., a memory-side cache
+// shared by all Packages) are not accounted for, and Caches without attributes
+// count as empty. Every Package is present in the returned map, even if it
+// contains no Caches of the level.
+func (t *Topology) PerPackageCacheSize(level CacheLevel) (map[NodeID]uint64, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	if level > L5 {
+		return nil, fmt.Errorf("invalid cache level %d", level)
+	}
+	packages := t.getIndexes().processing[Package]
+	caches := t.getIndexes().caches[level]
+	ret := make(map[NodeID]uint64, len(packages))
+	for _, pkg := range packages {
+		start, end, err := t.SubtreeRange(pkg)
+		if err != nil {
+			return nil, err
+		}
+		ret[pkg] = 0
+		// The Caches are sorted by their NodeIDs, as the indexes are
+		// built in pre-order.
+		first := sort.Search(len(caches), func(i int) bool { return caches[i] > start })
+		for _, id := range caches[first:] {
+			if id >= end {
+				break
+			}
+			if attrs := t.Nodes[id].Data.Attributes; nil != attrs {
+				ret[pkg] += attrs.Size
+			}
+		}
+	}
+	return ret, nil
+}
+
+// CacheShare describes how a Cache is shared among the Cores and hardware
+// threads in its subtree (see CacheShares).
+type CacheShare struct {
+	// ID is the NodeID of the Cache.
+	ID NodeID `json:"id"`
+	// Size is the size of the Cache, in bytes.
+	Size uint64 `json:"size"`
+	// Cores and Threads are the numbers of Cores and hardware threads in
+	// the subtree of the Cache.
+	Cores   int `json:"cores"`
+	Threads int `json:"threads"`
+	// BytesPerCore and BytesPerThread are the effective shares of the
+	// Cache of each of its Cores and hardware threads, respectively, or 0
+	// if it has none.
+	BytesPerCore   float64 `json:"bytes_per_core"`
+	BytesPerThread float64 `json:"bytes_per_thread"`
+}
+
+// CacheShares returns a CacheShare for each Cache of the provided level in the
+// Topology (e.g., each L3, for the effective L3 bytes per Core and per hardware
+// thread of each group of Cores that shares one), in pre-order, or a non-nil
+// error value in case of failure.
+//
+// Since SMT siblings compete for the Caches of their Core, BytesPerThread is
+// smaller than BytesPerCore whenever SMT is enabled; on hybrid machines, where
+// only some Cores have SMT, it is an average over all hardware threads of the
+// Cache.
+func (t *Topology) CacheShares(level CacheLevel) ([]CacheShare, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	if level > L5 {
+		return nil, fmt.Errorf("invalid cache level %d", level)
+	}
+	caches := t.getIndexes().caches[level]
+	ret := make([]CacheShare, 0, len(caches))
+	for _, id := range caches {
+		start, end, err := t.SubtreeRange(id)
+		if err != nil {
+			return nil, err
+		}
+		share := CacheShare{ID: id}
+		if attrs := t.Nodes[id].Data.Attributes; nil != attrs {
+			share.Size = attrs.Size
+		}
+		for _, node := range t.Nodes[start:end] {
+			if node.Data.IsProcessing() {
+				switch node.Data.Kind {
+				case Core:
+					share.Cores++
+				case Thread:
+					share.Threads++
+				}
+			}
+		}
+		if share.Cores > 0 {
+			share.BytesPerCore = float64(share.Size) / float64(share.Cores)
+		}
+		if share.Threads > 0 {
+			share.BytesPerThread = float64(share.Size) / float64(share.Threads)
+		}
+		ret = append(ret, share)
+	}
+	return ret, nil
+}
+
+// NearestCacheAtLeast returns the NodeID of the closest Cache above the
+// hardware thread stored under the provided NodeID whose size is at least the
+// provided number of bytes, or a non-nil error value in case of failure (e.g.,
+// if no Cache above the thread is large enough).
+//
+// This allows sizing placements according to their working sets, without any
+// assumptions about the levels of the Caches (e.g., a large L2 may suffice on
+// some machines, while the L3 is needed on others). Caches without attributes
+// are never large enough.
+func (t *Topology) NearestCacheAtLeast(threadID NodeID, bytes uint64) (NodeID, error) {
+	if nil == t || nil == t.Tree {
+		return 0, ErrNilTree
+	}
+	data, err := t.Get(threadID)
+	if err != nil {
+		return 0, err
+	}
+	if !data.IsProcessing() || data.Kind != Thread {
+		return 0, fmt.Errorf("element %d is not a thread", threadID)
+	}
+	ancestorIDs, err := t.AncestorIDs(threadID)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ancestorIDs {
+		if ancestor := t.Nodes[id].Data; ancestor.IsCache() && nil != ancestor.Attributes && ancestor.Attributes.Size >= bytes {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("no cache of at least %d bytes above thread %d", bytes, threadID)
+}
+
+// VictimOf returns the NodeIDs of the Caches whose evicted lines fill the
+// Cache stored under the provided NodeID, if it is an Exclusive (i.e., victim)
+// Cache, or an empty list otherwise, or a non-nil error value in case of
+// failure.
+//
+// These are the closest Caches below it in the hierarchy (e.g., the L2s of the
+// Cores that share an L3), in ascending NodeID order.
+func (t *Topology) VictimOf(id NodeID) ([]NodeID, error) {
+	data, err := t.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !data.IsCache() {
+		return nil, fmt.Errorf("element %d is not a cache", id)
+	}
+	if nil == data.Attributes || data.Attributes.Inclusion != Exclusive {
+		return make([]NodeID, 0), nil
+	}
+	return t.closestCachesBelow(id)
+}
+
+// EffectiveCacheSize returns the number of distinct bytes that the Cache stored
+// under the provided NodeID can hold along with the Caches below it, or a
+// non-nil error value in case of failure.
+//
+// For Inclusive Caches, this is merely their size, since they hold copies of
+// all lines of the Caches below them. For Exclusive Caches, the effective sizes
+// of the closest Caches below them (see VictimOf) are added to their own; e.g.,
+// an exclusive 32MiB L3 that is shared by 8 Cores with 1MiB L2s effectively
+// holds 40MiB. NonInclusive Caches are conservatively treated as Inclusive, as
+// they may hold copies of any of the lines below them.
+func (t *Topology) EffectiveCacheSize(id NodeID) (uint64, error) {
+	data, err := t.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	if !data.IsCache() {
+		return 0, fmt.Errorf("element %d is not a cache", id)
+	}
+	if nil == data.Attributes {
+		return 0, nil
+	}
+	size := data.Attributes.Size
+	victimOf, err := t.VictimOf(id)
+	if err != nil {
+		return 0, err
+	}
+	for _, below := range victimOf {
+		belowSize, err := t.EffectiveCacheSize(below)
+		if err != nil {
+			return 0, err
+		}
+		size += belowSize
+	}
+	return size, nil
+}
+
+// closestCachesBelow returns the NodeIDs of the Caches in the subtree of the
+// element stored under the provided NodeID with no other Cache between them
+// and the element, in ascending NodeID order.
+func (t *Topology) closestCachesBelow(id NodeID) ([]NodeID, error) {
+	start, end, err := t.SubtreeRange(id)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]NodeID, 0)
+	for desc := start + 1; desc < end; {
+		if !t.Nodes[desc].Data.IsCache() {
+			desc++
+			continue
+		}
+		ret = append(ret, desc)
+		// Skip the subtree of the Cache, which is contiguous in pre-order.
+		if _, desc, err = t.SubtreeRange(desc); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,160 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCacheSize(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 2, 4, 2)}
+	for _, tc := range []struct {
+		level CacheLevel
+		want  uint64
+	}{
+		{L1, 16 * 32 << 10},
+		{L2, 16 << 20},
+		{L3, 4 * 32 << 20},
+		{L4, 0},
+	} {
+		if got, err := topo.TotalCacheSize(tc.level); err != nil || got != tc.want {
+			t.Errorf("TotalCacheSize(%s) = %d, %v; expected %d", tc.level, got, err, tc.want)
+		}
+		perPackage, err := topo.PerPackageCacheSize(tc.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(perPackage) != 2 {
+			t.Errorf("PerPackageCacheSize(%s) = %v; expected 2 Packages", tc.level, perPackage)
+		}
+		for pkg, got := range perPackage {
+			if got != tc.want/2 {
+				t.Errorf("PerPackageCacheSize(%s)[%d] = %d; expected %d", tc.level, pkg, got, tc.want/2)
+			}
+		}
+	}
+	if _, err := topo.TotalCacheSize(L5 + 1); err == nil {
+		t.Errorf("expected an error for an invalid cache level")
+	}
+
+	// t4_de has no L3 caches, and 12 L2 caches of 256KiB.
+	topo = loadTopology(t, "test_artifacts/t4_de.json")
+	if got, err := topo.TotalCacheSize(L2); err != nil || got != 12*256<<10 {
+		t.Errorf("TotalCacheSize(L2) = %d, %v; expected %d", got, err, 12*256<<10)
+	}
+}
+
+func TestCacheShares(t *testing.T) {
+	topo, err := FromSpec(TopologySpec{
+		Packages: 1, NUMANodesPerPackage: 1, L3PerNUMANode: 2, Cores: 4, ThreadsPerCore: 2,
+		L3Size: 16 << 20, L2Size: 1 << 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares, err := topo.CacheShares(L3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 2 {
+		t.Fatalf("got %d L3 shares; expected 2", len(shares))
+	}
+	for _, share := range shares {
+		if share.Cores != 4 || share.Threads != 8 || share.BytesPerCore != 4<<20 || share.BytesPerThread != 2<<20 {
+			t.Errorf("got %+v", share)
+		}
+	}
+
+	// Without SMT, the shares of Cores and threads are equal.
+	if topo, err = GenerateSynthetic("1:1:4:1"); err != nil {
+		t.Fatal(err)
+	}
+	if shares, err = topo.CacheShares(L2); err != nil || len(shares) != 4 {
+		t.Fatalf("got %v, %v; expected 4 L2 shares", shares, err)
+	}
+	if share := shares[0]; share.BytesPerCore != 1<<20 || share.BytesPerThread != share.BytesPerCore {
+		t.Errorf("got %+v", share)
+	}
+}
+
+func TestNearestCacheAtLeast(t *testing.T) {
+	topo := &Topology{syntheticTree(1, 2, 2, 2)}
+	thread := topo.Threads()[0]
+	for _, tc := range []struct {
+		bytes uint64
+		want  CacheLevel
+	}{
+		{0, L1},
+		{32 << 10, L1},
+		{32<<10 + 1, L2},
+		{1 << 20, L2},
+		{16 << 20, L3},
+		{32 << 20, L3},
+	} {
+		id, err := topo.NearestCacheAtLeast(thread, tc.bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := topo.Nodes[id].Data; !e.IsCache() || e.Level != tc.want {
+			t.Errorf("NearestCacheAtLeast(%d, %d) = %v; expected an %s cache", thread, tc.bytes, e, tc.want)
+		}
+		if start, end, _ := topo.SubtreeRange(id); thread <= start || thread >= end {
+			t.Errorf("NearestCacheAtLeast(%d, %d) = %d; not above the thread", thread, tc.bytes, id)
+		}
+	}
+	if _, err := topo.NearestCacheAtLeast(thread, 32<<20+1); err == nil {
+		t.Errorf("expected an error when no cache is large enough")
+	}
+	if _, err := topo.NearestCacheAtLeast(topo.Cores()[0], 0); err == nil {
+		t.Errorf("expected an error for a Core")
+	}
+}
+
+func TestEffectiveCacheSize(t *testing.T) {
+	topo := &Topology{syntheticTree(1, 1, 4, 2)}
+	l3 := topo.L3Caches()[0]
+	for _, tc := range []struct {
+		l3, l2 CacheInclusion
+		want   uint64
+	}{
+		{Inclusive, Inclusive, 32 << 20},
+		{NonInclusive, Exclusive, 32 << 20},
+		{Exclusive, Inclusive, 32<<20 + 4*1<<20},
+		{Exclusive, Exclusive, 32<<20 + 4*(1<<20+32<<10)},
+	} {
+		topo.Nodes[l3].Data.Attributes.Inclusion = tc.l3
+		for _, l2 := range topo.L2Caches() {
+			topo.Nodes[l2].Data.Attributes.Inclusion = tc.l2
+		}
+		if got, err := topo.EffectiveCacheSize(l3); err != nil || got != tc.want {
+			t.Errorf("EffectiveCacheSize(%s L3 over %s L2s) = %d, %v; expected %d", tc.l3, tc.l2, got, err, tc.want)
+		}
+		victimOf, err := topo.VictimOf(l3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tc.l3 == Exclusive; want != (len(victimOf) > 0) {
+			t.Errorf("VictimOf(%s L3) = %v", tc.l3, victimOf)
+		} else if want && !reflect.DeepEqual(victimOf, topo.L2Caches()) {
+			t.Errorf("VictimOf(%s L3) = %v; expected the L2 caches %v", tc.l3, victimOf, topo.L2Caches())
+		}
+	}
+	if _, err := topo.EffectiveCacheSize(topo.Threads()[0]); err == nil {
+		t.Errorf("expected an error for a thread")
+	}
+}
@@ -0,0 +1,155 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "fmt"
+
+// Capacity summarizes the hardware resources of one or more nodes that are
+// available to workloads (i.e., excluding any reserved hardware threads).
+type Capacity struct {
+	Nodes int `json:"nodes"`
+	// Threads is the number of available hardware threads, and
+	// ReservedThreads is the number of reserved ones.
+	Threads         int `json:"threads"`
+	ReservedThreads int `json:"reserved_threads,omitempty"`
+	// ExclusiveCores is the number of Cores none of whose hardware threads
+	// are reserved, which can thus be allocated exclusively.
+	ExclusiveCores int `json:"exclusive_cores"`
+	// L3Bytes is the total size of the L3 Caches that are shared by at
+	// least one available hardware thread.
+	L3Bytes uint64 `json:"l3_bytes"`
+}
+
+// add accumulates the provided Capacity into this one.
+func (c *Capacity) add(other Capacity) {
+	c.Nodes += other.Nodes
+	c.Threads += other.Threads
+	c.ReservedThreads += other.ReservedThreads
+	c.ExclusiveCores += other.ExclusiveCores
+	c.L3Bytes += other.L3Bytes
+}
+
+// Capacity returns the Capacity of the Topology, excluding the hardware threads
+// whose OS indices are included in the provided cpulist string (e.g., "0-1"),
+// or a non-nil error value if it cannot be parsed or includes unknown threads.
+func (t *Topology) Capacity(reserved string) (Capacity, error) {
+	osIDs, err := ParseCPUList(reserved)
+	if err != nil {
+		return Capacity{}, err
+	}
+	isReserved := make(map[uint32]bool, len(osIDs))
+	for _, osID := range osIDs {
+		isReserved[osID] = true
+	}
+	found := 0
+	for _, id := range t.Threads() {
+		if isReserved[t.Nodes[id].Data.ID] {
+			found++
+		}
+	}
+	if found != len(osIDs) {
+		return Capacity{}, fmt.Errorf("cannot reserve unknown threads in '%s'", reserved)
+	}
+
+	// available returns the numbers of available and reserved threads in
+	// the subtree of the provided element.
+	available := func(id NodeID) (avail, rsvd int) {
+		start, end, err := t.SubtreeRange(id)
+		if err != nil {
+			return 0, 0
+		}
+		for _, node := range t.Nodes[start:end] {
+			if node.Data.IsProcessing() && node.Data.Kind == Thread {
+				if isReserved[node.Data.ID] {
+					rsvd++
+				} else {
+					avail++
+				}
+			}
+		}
+		return
+	}
+	c := Capacity{Nodes: 1}
+	c.Threads, c.ReservedThreads = available(0)
+	for _, id := range t.Cores() {
+		if avail, rsvd := available(id); avail > 0 && rsvd == 0 {
+			c.ExclusiveCores++
+		}
+	}
+	for _, id := range t.L3Caches() {
+		if attrs := t.Nodes[id].Data.Attributes; nil != attrs {
+			if avail, _ := available(id); avail > 0 {
+				c.L3Bytes += attrs.Size
+			}
+		}
+	}
+	return c, nil
+}
+
+// ClassCapacity is the Capacity of the nodes of a HardwareClass.
+type ClassCapacity struct {
+	Fingerprint Fingerprint `json:"fingerprint"`
+	// Nodes are the names of the nodes of the class, sorted.
+	Nodes    []string `json:"nodes"`
+	Capacity Capacity `json:"capacity"`
+}
+
+// ClusterCapacity is the Capacity of the nodes of a ClusterTopology, both in
+// total and per HardwareClass.
+type ClusterCapacity struct {
+	Total Capacity `json:"total"`
+	// Classes are ordered as in the HomogeneityReport of the
+	// ClusterTopology.
+	Classes []ClassCapacity `json:"classes"`
+}
+
+// Capacity returns the ClusterCapacity of the ClusterTopology, or a non-nil
+// error value in case of failure.
+//
+// The reserved hardware threads of each node may be provided as a cpulist
+// string (see Topology.Capacity) under its name; a cpulist under the name "*"
+// applies to all nodes that have none of their own. The map may be nil.
+func (c *ClusterTopology) Capacity(reserved map[string]string) (*ClusterCapacity, error) {
+	report, err := c.Homogeneity()
+	if err != nil {
+		return nil, err
+	}
+	for name := range reserved {
+		if _, ok := c.Nodes[name]; !ok && name != "*" {
+			return nil, fmt.Errorf("node '%s' is not part of the ClusterTopology", name)
+		}
+	}
+
+	ret := &ClusterCapacity{Classes: make([]ClassCapacity, 0, len(report.Classes))}
+	for _, class := range report.Classes {
+		cc := ClassCapacity{Fingerprint: class.Fingerprint, Nodes: class.Nodes}
+		for _, name := range class.Nodes {
+			cpulist, ok := reserved[name]
+			if !ok {
+				cpulist = reserved["*"]
+			}
+			capacity, err := c.Nodes[name].Capacity(cpulist)
+			if err != nil {
+				return nil, fmt.Errorf("node '%s': %w", name, err)
+			}
+			cc.Capacity.add(capacity)
+		}
+		ret.Total.add(cc.Capacity)
+		ret.Classes = append(ret.Classes, cc)
+	}
+	return ret, nil
+}
@@ -0,0 +1,60 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "testing"
+
+func TestCapacity(t *testing.T) {
+	cluster := NewClusterTopology()
+	for name, tree := range map[string]*Tree{
+		"a": syntheticTree(1, 2, 2, 2),
+		"b": syntheticTree(1, 2, 2, 2),
+		"c": syntheticTree(1, 1, 2, 2),
+	} {
+		if err := cluster.Add(name, &Topology{tree}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	capacity, err := cluster.Capacity(map[string]string{"*": "0", "c": "0-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Capacity{Nodes: 3, Threads: 16, ReservedThreads: 4, ExclusiveCores: 7, L3Bytes: 160 << 20}
+	if capacity.Total != want {
+		t.Errorf("got total capacity %+v; expected %+v", capacity.Total, want)
+	}
+	if len(capacity.Classes) != 2 {
+		t.Fatalf("got %d classes; expected 2", len(capacity.Classes))
+	}
+	want = Capacity{Nodes: 2, Threads: 14, ReservedThreads: 2, ExclusiveCores: 6, L3Bytes: 128 << 20}
+	if class := capacity.Classes[0]; len(class.Nodes) != 2 || class.Capacity != want {
+		t.Errorf("got capacity %+v for class %v; expected %+v", class.Capacity, class.Nodes, want)
+	}
+
+	// Reserving a whole NUMA node excludes its L3 cache too.
+	a, _ := cluster.Get("a")
+	if got, err := a.Capacity("4-7"); err != nil || got.L3Bytes != 32<<20 || got.ExclusiveCores != 2 {
+		t.Errorf("got %+v (%v); expected 2 exclusive cores and a single L3", got, err)
+	}
+	if _, err = a.Capacity("99"); err == nil {
+		t.Errorf("expected an error for an unknown thread")
+	}
+	if _, err = cluster.Capacity(map[string]string{"d": "0"}); err == nil {
+		t.Errorf("expected an error for an unknown node")
+	}
+}
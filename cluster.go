@@ -0,0 +1,130 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ClusterTopology aggregates the Topologies of the nodes of a cluster, keyed by
+// the nodes' names (e.g., as known to Kubernetes), along with the Fabrics that
+// interconnect them, if known.
+type ClusterTopology struct {
+	// Nodes maps the names of the cluster's nodes to their Topologies.
+	Nodes map[string]*Topology `json:"nodes"`
+	// Fabrics maps names of interconnects (e.g., "ethernet" or "rdma") to
+	// the Fabrics that model them (see SetFabric).
+	Fabrics map[string]*Fabric `json:"fabrics,omitempty"`
+}
+
+// NewClusterTopology returns a new, empty ClusterTopology.
+func NewClusterTopology() *ClusterTopology {
+	return &ClusterTopology{Nodes: make(map[string]*Topology)}
+}
+
+// Size returns the number of nodes in the ClusterTopology.
+func (c *ClusterTopology) Size() int {
+	if nil == c {
+		return 0
+	}
+	return len(c.Nodes)
+}
+
+// Add adds the provided Topology to the ClusterTopology under the provided
+// node name, or returns a non-nil error value if the name is empty or already
+// taken, or if the Topology is nil or empty.
+func (c *ClusterTopology) Add(name string, topo *Topology) error {
+	if name == "" {
+		return fmt.Errorf("empty node name")
+	}
+	if nil == topo || topo.IsEmpty() {
+		return fmt.Errorf("node '%s': %w", name, ErrEmptyTree)
+	}
+	if _, exists := c.Nodes[name]; exists {
+		return fmt.Errorf("node '%s' already exists in the ClusterTopology", name)
+	}
+	if nil == c.Nodes {
+		c.Nodes = make(map[string]*Topology)
+	}
+	c.Nodes[name] = topo
+	return nil
+}
+
+// Get returns the Topology of the node with the provided name, if it exists in
+// the ClusterTopology.
+func (c *ClusterTopology) Get(name string) (*Topology, bool) {
+	if nil == c {
+		return nil, false
+	}
+	topo, ok := c.Nodes[name]
+	return topo, ok
+}
+
+// Names returns the names of all nodes in the ClusterTopology, sorted.
+func (c *ClusterTopology) Names() []string {
+	if nil == c {
+		return nil
+	}
+	names := make([]string, 0, len(c.Nodes))
+	for name := range c.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Merge adds all nodes and Fabrics of the provided ClusterTopology to this
+// one, or returns a non-nil error value if any of them already exists; nothing
+// is added in that case.
+func (c *ClusterTopology) Merge(other *ClusterTopology) error {
+	for _, name := range other.Names() {
+		if _, exists := c.Nodes[name]; exists {
+			return fmt.Errorf("node '%s' already exists in the ClusterTopology", name)
+		}
+	}
+	if nil != other {
+		for name, f := range other.Fabrics {
+			if _, exists := c.Fabrics[name]; exists {
+				return fmt.Errorf("fabric '%s' already exists in the ClusterTopology", name)
+			}
+			if err := f.Validate(); err != nil {
+				return fmt.Errorf("fabric '%s': %w", name, err)
+			}
+			for _, node := range f.Nodes {
+				if _, ok := other.Nodes[node]; !ok {
+					if _, ok = c.Nodes[node]; !ok {
+						return fmt.Errorf("fabric '%s': node '%s' is not part of the ClusterTopology", name, node)
+					}
+				}
+			}
+		}
+	}
+	for _, name := range other.Names() {
+		if err := c.Add(name, other.Nodes[name]); err != nil {
+			return err
+		}
+	}
+	if nil != other {
+		for name, f := range other.Fabrics {
+			if err := c.SetFabric(name, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestClusterTopology(t *testing.T) {
+	cluster := NewClusterTopology()
+	if err := cluster.Add("node-b", &Topology{syntheticTree(1, 1, 2, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.Add("node-a", &Topology{syntheticTree(2, 1, 2, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.Add("node-a", &Topology{syntheticTree(1, 1, 1, 1)}); err == nil {
+		t.Errorf("expected an error for a duplicate node")
+	}
+	if err := cluster.Add("node-c", &Topology{}); !errors.Is(err, ErrEmptyTree) {
+		t.Errorf("got %v for an empty Topology; want ErrEmptyTree", err)
+	}
+	if err := cluster.Add("", &Topology{syntheticTree(1, 1, 1, 1)}); err == nil {
+		t.Errorf("expected an error for an empty node name")
+	}
+	if names := cluster.Names(); !reflect.DeepEqual(names, []string{"node-a", "node-b"}) {
+		t.Errorf("got names %v", names)
+	}
+
+	data, err := json.Marshal(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ClusterTopology
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal ClusterTopology: %v\n", err)
+	}
+	if topo, ok := decoded.Get("node-a"); !ok || len(topo.Packages()) != 2 || decoded.Size() != 2 {
+		t.Errorf("unexpected ClusterTopology after a round-trip:\n%s", data)
+	}
+
+	other := NewClusterTopology()
+	if err = other.Add("node-c", &Topology{syntheticTree(1, 1, 1, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err = other.Add("node-a", &Topology{syntheticTree(1, 1, 1, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Merge(other); err == nil || cluster.Size() != 2 {
+		t.Errorf("got %v and %d nodes when merging a duplicate node", err, cluster.Size())
+	}
+	delete(other.Nodes, "node-a")
+	if err = cluster.Merge(other); err != nil || cluster.Size() != 3 {
+		t.Errorf("got %v and %d nodes after merging", err, cluster.Size())
+	}
+}
@@ -0,0 +1,124 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// allocationReport is the report of `actitopo allocate`.
+type allocationReport struct {
+	// CPUs is the cpulist of the OS indices of the allocated threads.
+	CPUs    string            `json:"cpus"`
+	Threads []actitopo.NodeID `json:"threads"`
+	// Covering is the NodeID of the lowest common ancestor of the
+	// allocated threads, and CoveringElement is the element itself.
+	Covering        actitopo.NodeID   `json:"covering"`
+	CoveringElement *actitopo.Element `json:"covering_element"`
+	// Score is the fraction of the threads under Covering that were
+	// allocated.
+	Score float64 `json:"score"`
+}
+
+// runAllocate implements `actitopo allocate`.
+func runAllocate(args []string, stdout io.Writer) error {
+	fs := newFlagSet("allocate", "[flags] <file|->\n\n"+
+		"Simulates an allocation on an otherwise idle machine, and reports the\n"+
+		"allocated cpuset, its covering element (i.e., the lowest common ancestor\n"+
+		"of the allocated threads) and its score (i.e., the fraction of the threads\n"+
+		"under the covering element that were allocated; 1 means that the\n"+
+		"allocation shares no caches or cores with any other workload).\n")
+	from := fs.String("from", "", "input format: json, yaml, binary or hwloc-xml (default: implied by the file extension)")
+	cpus := fs.Int("cpus", 0, "number of hardware threads to allocate (required)")
+	maxCPUs := fs.Int("max-cpus", 0, "maximum number of hardware threads to allocate (default: -cpus)")
+	policy := fs.String("policy", "pack", "allocation policy: pack or spread")
+	singleL3 := fs.Bool("single-l3", false, "require all threads to share the same L3 cache")
+	reserve := fs.String("reserve", "", "cpulist of hardware threads that must not be allocated")
+	jsonOutput := fs.Bool("json", false, "write the report in JSON")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	if *cpus <= 0 {
+		return usageError("-cpus must be positive")
+	}
+	allocationPolicy, err := actitopo.ParseAllocationPolicy(*policy)
+	if err != nil {
+		return usageError("%v", err)
+	}
+
+	topo, err := loadTopology(fs.Arg(0), *from)
+	if err != nil {
+		return err
+	}
+	allocator, err := actitopo.NewAllocator(topo)
+	if err != nil {
+		return err
+	}
+	if *reserve != "" {
+		if err = allocator.Reserve(*reserve); err != nil {
+			return err
+		}
+	}
+	alloc, err := allocator.Allocate(actitopo.AllocationRequest{
+		Owner:      "actitopo",
+		MinThreads: *cpus,
+		MaxThreads: *maxCPUs,
+		Policy:     allocationPolicy,
+		SingleL3:   *singleL3,
+	})
+	if err != nil {
+		return err
+	}
+
+	report := &allocationReport{Threads: alloc.Threads}
+	osIDs := make([]uint32, 0, len(alloc.Threads))
+	for _, id := range alloc.Threads {
+		osIDs = append(osIDs, topo.Nodes[id].Data.ID)
+	}
+	report.CPUs = actitopo.FormatCPUList(osIDs)
+	if report.Covering, err = topo.CommonAncestorID(alloc.Threads...); err != nil {
+		return err
+	}
+	report.CoveringElement = topo.Nodes[report.Covering].Data
+	available := 0
+	start, end, err := topo.SubtreeRange(report.Covering)
+	if err != nil {
+		return err
+	}
+	for _, node := range topo.Nodes[start:end] {
+		if node.Data.IsProcessing() && node.Data.Kind == actitopo.Thread {
+			available++
+		}
+	}
+	report.Score = float64(len(alloc.Threads)) / float64(available)
+
+	if *jsonOutput {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", out)
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "cpus:     %s\ncovering: %s (node %d)\nscore:    %.2f (%d of %d threads)\n",
+		report.CPUs, report.CoveringElement, report.Covering, report.Score, len(alloc.Threads), available)
+	return err
+}
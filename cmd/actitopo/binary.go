@@ -0,0 +1,254 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// binaryMagic prefixes documents in the binary format, whose trailing byte is
+// the version of the format.
+const binaryMagic = "ACTITOPO\x01"
+
+// Tags of the values in the binary format
+const (
+	binaryNull byte = iota
+	binaryFalse
+	binaryTrue
+	binaryInt
+	binaryFloat
+	binaryString
+	binaryArray
+	binaryObject
+)
+
+// maxBinaryDepth bounds the nesting of the documents that readBinary accepts.
+const maxBinaryDepth = 1024
+
+// errBinaryTruncated is returned for binary documents that end prematurely.
+var errBinaryTruncated = errors.New("truncated binary document")
+
+// writeBinary writes the provided document, as produced by unmarshalling JSON
+// (with numbers as json.Number) into an interface{}, to the provided
+// io.Writer in the binary format.
+//
+// The binary format is a compact encoding of the JSON document: each value is
+// a tag byte, followed by a zig-zag varint (integers), 8 little-endian bytes
+// (other numbers), or a uvarint length and the bytes of a string, the values
+// of an array or the key-value pairs of an object (sorted by key).
+func writeBinary(w io.Writer, doc interface{}) error {
+	buf := bytes.NewBufferString(binaryMagic)
+	if err := writeBinaryValue(buf, doc); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeBinaryValue appends the provided value to the provided buffer.
+func writeBinaryValue(buf *bytes.Buffer, v interface{}) error {
+	var scratch [binary.MaxVarintLen64]byte
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(binaryNull)
+	case bool:
+		if v {
+			buf.WriteByte(binaryTrue)
+		} else {
+			buf.WriteByte(binaryFalse)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			buf.WriteByte(binaryInt)
+			buf.Write(scratch[:binary.PutVarint(scratch[:], i)])
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(binaryFloat)
+		binary.LittleEndian.PutUint64(scratch[:8], math.Float64bits(f))
+		buf.Write(scratch[:8])
+	case string:
+		buf.WriteByte(binaryString)
+		writeBinaryString(buf, v)
+	case []interface{}:
+		buf.WriteByte(binaryArray)
+		buf.Write(scratch[:binary.PutUvarint(scratch[:], uint64(len(v)))])
+		for _, item := range v {
+			if err := writeBinaryValue(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteByte(binaryObject)
+		buf.Write(scratch[:binary.PutUvarint(scratch[:], uint64(len(v)))])
+		for _, key := range keys {
+			writeBinaryString(buf, key)
+			if err := writeBinaryValue(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unexpected value of type %T", v)
+	}
+	return nil
+}
+
+// writeBinaryString appends the length and the bytes of the provided string
+// to the provided buffer.
+func writeBinaryString(buf *bytes.Buffer, s string) {
+	var scratch [binary.MaxVarintLen64]byte
+	buf.Write(scratch[:binary.PutUvarint(scratch[:], uint64(len(s)))])
+	buf.WriteString(s)
+}
+
+// readBinary returns the document in the provided binary data, as if produced
+// by unmarshalling JSON (with numbers as json.Number) into an interface{}, or
+// a non-nil error value in case of failure.
+func readBinary(data []byte) (interface{}, error) {
+	if !bytes.HasPrefix(data, []byte(binaryMagic)) {
+		return nil, fmt.Errorf("not a binary topology document")
+	}
+	r := &binaryReader{data: data[len(binaryMagic):]}
+	doc, err := r.readValue(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.data) > 0 {
+		return nil, fmt.Errorf("%d trailing bytes after the binary document", len(r.data))
+	}
+	return doc, nil
+}
+
+// binaryReader reads values from binary data.
+type binaryReader struct {
+	data []byte
+}
+
+// readValue reads the next value.
+func (r *binaryReader) readValue(depth int) (interface{}, error) {
+	if depth > maxBinaryDepth {
+		return nil, fmt.Errorf("binary document nested too deeply")
+	}
+	if len(r.data) == 0 {
+		return nil, errBinaryTruncated
+	}
+	tag := r.data[0]
+	r.data = r.data[1:]
+	switch tag {
+	case binaryNull:
+		return nil, nil
+	case binaryFalse:
+		return false, nil
+	case binaryTrue:
+		return true, nil
+	case binaryInt:
+		i, n := binary.Varint(r.data)
+		if n <= 0 {
+			return nil, errBinaryTruncated
+		}
+		r.data = r.data[n:]
+		return json.Number(strconv.FormatInt(i, 10)), nil
+	case binaryFloat:
+		if len(r.data) < 8 {
+			return nil, errBinaryTruncated
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(r.data))
+		r.data = r.data[8:]
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("invalid number %v in binary document", f)
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case binaryString:
+		return r.readString()
+	case binaryArray:
+		// Each value takes at least one byte, which bounds the length.
+		n, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := r.readValue(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, item)
+		}
+		return arr, nil
+	case binaryObject:
+		n, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		obj := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			key, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := obj[key]; dup {
+				return nil, fmt.Errorf("duplicate key %q in binary document", key)
+			}
+			if obj[key], err = r.readValue(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	default:
+		return nil, fmt.Errorf("unknown tag %d in binary document", tag)
+	}
+}
+
+// readLength reads a length, which may not exceed the number of bytes left.
+func (r *binaryReader) readLength() (int, error) {
+	n, size := binary.Uvarint(r.data)
+	if size <= 0 {
+		return 0, errBinaryTruncated
+	}
+	r.data = r.data[size:]
+	if n > uint64(len(r.data)) {
+		return 0, errBinaryTruncated
+	}
+	return int(n), nil
+}
+
+// readString reads the length and the bytes of a string.
+func (r *binaryReader) readString() (string, error) {
+	n, err := r.readLength()
+	if err != nil {
+		return "", err
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s, nil
+}
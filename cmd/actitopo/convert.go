@@ -0,0 +1,48 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+
+	"github.com/ckatsak/actitopo-go/internal/yaml"
+)
+
+// runConvert implements `actitopo convert`.
+func runConvert(args []string, stdout io.Writer) error {
+	fs := newFlagSet("convert", "[flags] <file|->\n\n"+
+		"YAML input may use "+yaml.Subset+".\n")
+	from := fs.String("from", "", "input format: json, yaml, binary or hwloc-xml (default: implied by the file extension)")
+	to := fs.String("to", formatJSON, "output format: json, yaml, binary or dot")
+	output := fs.String("o", "-", "output file, or - for the standard output")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	switch *to {
+	case formatJSON, formatYAML, formatBinary, formatDOT:
+	default:
+		return usageError("unknown output format '%s'", *to)
+	}
+
+	topo, err := loadTopology(fs.Arg(0), *from)
+	if err != nil {
+		return err
+	}
+	return writeOutput(stdout, *output, func(w io.Writer) error {
+		return writeTopology(w, topo, *to)
+	})
+}
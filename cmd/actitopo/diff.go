@@ -0,0 +1,94 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// diffReport is the JSON report of `actitopo diff`.
+type diffReport struct {
+	Old     string            `json:"old"`
+	New     string            `json:"new"`
+	Added   int               `json:"added"`
+	Removed int               `json:"removed"`
+	Moved   int               `json:"moved"`
+	Changed int               `json:"modified"`
+	Changes []actitopo.Change `json:"changes"`
+}
+
+// runDiff implements `actitopo diff`.
+func runDiff(args []string, stdout io.Writer) error {
+	fs := newFlagSet("diff", "[flags] <old> <new>\n\n"+
+		"Elements are matched by their kind and OS index (processing elements) or\n"+
+		"by their level and logical index (caches), rather than by their NodeIDs.\n")
+	from := fs.String("from", "", "input format: json, yaml, binary or hwloc-xml (default: implied by the file extensions)")
+	jsonOutput := fs.Bool("json", false, "write the report in JSON")
+	exitCode := fs.Bool("exit-code", false, "exit with 1 if the topologies differ")
+	if err := parseFlags(fs, args, 2, 2); err != nil {
+		return err
+	}
+	before, err := loadTopology(fs.Arg(0), *from)
+	if err != nil {
+		return err
+	}
+	after, err := loadTopology(fs.Arg(1), *from)
+	if err != nil {
+		return err
+	}
+	changes, err := actitopo.Diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	report := &diffReport{Old: fs.Arg(0), New: fs.Arg(1), Changes: changes}
+	for _, change := range changes {
+		switch change.Kind {
+		case actitopo.Added:
+			report.Added++
+		case actitopo.Removed:
+			report.Removed++
+		case actitopo.Moved:
+			report.Moved++
+		case actitopo.Modified:
+			report.Changed++
+		}
+	}
+	if *jsonOutput {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s\n", out)
+	} else {
+		fmt.Fprintf(stdout, "--- %s\n+++ %s\n", report.Old, report.New)
+		for _, change := range changes {
+			fmt.Fprintf(stdout, "%s\n", change)
+		}
+		fmt.Fprintf(stdout, "%d added, %d removed, %d moved, %d modified\n",
+			report.Added, report.Removed, report.Moved, report.Changed)
+	}
+	if *exitCode && len(changes) > 0 {
+		// The report has already been written.
+		return &exitError{code: exitFailure}
+	}
+	return nil
+}
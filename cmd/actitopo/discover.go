@@ -0,0 +1,70 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// runDiscover implements `actitopo discover`.
+func runDiscover(args []string, stdout io.Writer) error {
+	fs := newFlagSet("discover", "[flags]\n\n"+
+		"Discovers the topology of the local machine through the Linux sysfs.\n")
+	sysfs := fs.String("sysfs", "/sys", "mount point of sysfs")
+	to := fs.String("to", formatJSON, "output format: json, yaml, binary or dot")
+	output := fs.String("o", "-", "output file, or - for the standard output")
+	envelope := fs.Bool("envelope", false, "wrap the topology in an envelope with the metadata of the capture (json only)")
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	switch *to {
+	case formatJSON, formatYAML, formatBinary, formatDOT:
+	default:
+		return usageError("unknown output format '%s'", *to)
+	}
+	if *envelope && *to != formatJSON {
+		return usageError("-envelope requires -to json")
+	}
+
+	capturedAt := time.Now().UTC()
+	topo, err := actitopo.DiscoverSysfs(os.DirFS(*sysfs))
+	if err != nil {
+		return err
+	}
+	if *envelope {
+		meta := actitopo.Metadata{CapturedAt: capturedAt, Collector: "actitopo"}
+		if info, ok := debug.ReadBuildInfo(); ok {
+			meta.CollectorVersion = info.Main.Version
+		}
+		meta.Hostname, _ = os.Hostname()
+		if release, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+			meta.KernelVersion = strings.TrimSpace(string(release))
+		}
+		return writeOutput(stdout, *output, func(w io.Writer) error {
+			return actitopo.WriteEnvelope(w, topo, meta)
+		})
+	}
+	return writeOutput(stdout, *output, func(w io.Writer) error {
+		return writeTopology(w, topo, *to)
+	})
+}
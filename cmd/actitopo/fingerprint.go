@@ -0,0 +1,73 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// fingerprintReport is the JSON report of `actitopo fingerprint` for a file.
+type fingerprintReport struct {
+	File      string `json:"file"`
+	Structure string `json:"structure"`
+	Full      string `json:"full"`
+}
+
+// runFingerprint implements `actitopo fingerprint`.
+func runFingerprint(args []string, stdout io.Writer) error {
+	fs := newFlagSet("fingerprint", "[flags] <file|->...\n\n"+
+		"Prints two canonical hashes of each topology, which do not depend on the\n"+
+		"order of the elements in the payload: the structure hash covers the kinds of\n"+
+		"the elements and the hierarchy only, while the full hash also covers the OS\n"+
+		"indices of processing elements and the attributes of caches and NUMA nodes.\n"+
+		"The output consists of one line per file: <structure> <full> <file>.\n")
+	from := fs.String("from", "", "input format: json, yaml, binary or hwloc-xml (default: implied by the file extensions)")
+	jsonOutput := fs.Bool("json", false, "write the report in JSON")
+	if err := parseFlags(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	reports := make([]fingerprintReport, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		topo, err := loadTopology(path, *from)
+		if err != nil {
+			return err
+		}
+		fp, err := topo.Fingerprint()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		reports = append(reports, fingerprintReport{File: path, Structure: fp.Structure.String(), Full: fp.Full.String()})
+	}
+
+	if *jsonOutput {
+		out, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", out)
+		return err
+	}
+	for _, report := range reports {
+		if _, err := fmt.Fprintf(stdout, "%s %s %s\n", report.Structure, report.Full, report.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ckatsak/actitopo-go"
+	"github.com/ckatsak/actitopo-go/internal/yaml"
+)
+
+// Input (and output) formats
+const (
+	formatJSON     = "json"
+	formatYAML     = "yaml"
+	formatBinary   = "binary"
+	formatHwlocXML = "hwloc-xml"
+)
+
+// Output formats
+const (
+	formatDOT = "dot"
+)
+
+// readInput returns the contents of the file at the provided path, or of the
+// standard input if the path is "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// inputFormat returns the provided input format, or the one implied by the
+// extension of the provided path if it is empty.
+func inputFormat(format, path string) string {
+	if format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		return formatHwlocXML
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".bin":
+		return formatBinary
+	default:
+		return formatJSON
+	}
+}
+
+// loadTopology returns the Topology read from the file at the provided path
+// (or from the standard input, if the path is "-"), in the provided format (or
+// in the one implied by the path's extension, if the format is empty).
+func loadTopology(path, format string) (*actitopo.Topology, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > actitopo.DefaultDecodeLimits.MaxBytes {
+		return nil, fmt.Errorf("%s: %w", path, actitopo.ErrLimitExceeded)
+	}
+	// YAML and binary documents are re-encoded in JSON, so that all
+	// Topologies but hwloc's go through the same (limited) Decoder.
+	var doc interface{}
+	switch inputFormat(format, path) {
+	case formatJSON:
+	case formatYAML:
+		if doc, err = yaml.Read(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case formatBinary:
+		if doc, err = readBinary(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case formatHwlocXML:
+		topo, err := actitopo.ParseHwlocXML(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return topo, nil
+	default:
+		return nil, usageError("unknown input format '%s'", format)
+	}
+	if nil != doc {
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	topo, err := actitopo.NewDecoder(actitopo.DefaultDecodeLimits).Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return topo, nil
+}
+
+// writeTopology writes the provided Topology to the provided io.Writer in the
+// provided output format.
+func writeTopology(w io.Writer, topo *actitopo.Topology, format string) error {
+	switch format {
+	case formatJSON:
+		data, err := json.MarshalIndent(topo, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case formatYAML, formatBinary:
+		data, err := json.Marshal(topo)
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var doc interface{}
+		if err = dec.Decode(&doc); err != nil {
+			return err
+		}
+		if format == formatYAML {
+			return writeYAML(w, doc)
+		}
+		return writeBinary(w, doc)
+	case formatDOT:
+		return topo.WriteDOT(w, nil)
+	default:
+		return usageError("unknown output format '%s'", format)
+	}
+}
+
+// writeOutput calls the provided function to write to the file at the provided
+// path, or to stdout if the path is "-".
+func writeOutput(stdout io.Writer, path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,189 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Command actitopo inspects, converts and manipulates the hierarchical
+// hardware topologies of the actitopo package, for operators who do not write
+// Go.
+//
+// Usage:
+//
+//	actitopo <command> [flags] [arguments]
+//
+// Run `actitopo help` for the list of commands, and `actitopo <command> -h`
+// for the flags of each command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes of actitopo.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// command is a subcommand of actitopo.
+type command struct {
+	name    string
+	summary string
+	// run runs the command with the provided arguments (excluding the
+	// command's name), writing its output to stdout.
+	run func(args []string, stdout io.Writer) error
+}
+
+// commands are all subcommands of actitopo, in the order they are listed in
+// the usage message.
+var commands = []command{
+	{"allocate", "simulate an allocation of hardware threads", runAllocate},
+	{"convert", "convert a topology between formats", runConvert},
+	{"diff", "report the elements added, removed or changed between two topologies", runDiff},
+	{"discover", "discover the topology of the local machine", runDiscover},
+	{"fingerprint", "print canonical hashes of topologies", runFingerprint},
+	{"merge", "merge the topologies of several nodes into a cluster document", runMerge},
+	{"query", "select elements of a topology", runQuery},
+	{"render", "visualize a topology as text, DOT, SVG or HTML", runRender},
+	{"serve", "serve a topology over HTTP", runServe},
+	{"validate", "validate a topology and report all problems found", runValidate},
+}
+
+// exitError is an error that results in a specific exit code. If err is nil,
+// the command has already reported the problem, and nothing is printed.
+type exitError struct {
+	code int
+	err  error
+}
+
+// Error returns the string representation of the exitError.
+func (e *exitError) Error() string {
+	if nil == e.err {
+		return fmt.Sprintf("exit code %d", e.code)
+	}
+	return e.err.Error()
+}
+
+// Unwrap returns the error wrapped by the exitError.
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// usageError returns an error that results in exitUsage.
+func usageError(format string, args ...interface{}) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs actitopo with the provided arguments (excluding the program's
+// name), and returns its exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(args[1:], stdout)
+		if nil == err {
+			return exitOK
+		}
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			if nil != exitErr.err {
+				fmt.Fprintf(stderr, "actitopo %s: %v\n", cmd.name, err)
+			}
+			return exitErr.code
+		}
+		fmt.Fprintf(stderr, "actitopo %s: %v\n", cmd.name, err)
+		return exitFailure
+	}
+	fmt.Fprintf(stderr, "actitopo: unknown command '%s'\n", args[0])
+	printUsage(stderr)
+	return exitUsage
+}
+
+// printUsage writes the usage message of actitopo to the provided io.Writer.
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: actitopo <command> [flags] [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// newFlagSet returns a new flag.FlagSet for the provided command, whose errors
+// are returned rather than handled.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: actitopo %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the provided arguments with the provided flag.FlagSet,
+// and makes sure that the number of the remaining positional arguments is
+// within [min, max] (where a negative max means no upper bound).
+//
+// Unlike flag.FlagSet.Parse, flags may follow positional arguments (up to a
+// "--" argument, after which all arguments are positional); fs.Args then
+// returns all positional arguments, in order.
+func parseFlags(fs *flag.FlagSet, args []string, min, max int) error {
+	var positionals []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return err
+			}
+			return &exitError{code: exitUsage, err: err}
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			positionals = append(positionals, rest...)
+			break
+		}
+		positionals = append(positionals, rest[0])
+		args = rest[1:]
+	}
+	// Parsing only the positional arguments (after "--") makes them the
+	// ones that fs.Args returns, without touching the flags.
+	if err := fs.Parse(append([]string{"--"}, positionals...)); err != nil {
+		return &exitError{code: exitUsage, err: err}
+	}
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		return usageError("invalid number of arguments")
+	}
+	return nil
+}
@@ -0,0 +1,583 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// runCommand runs actitopo with the provided arguments, and returns its exit
+// code along with everything it wrote to its standard output and error.
+func runCommand(args ...string) (code int, stdout, stderr string) {
+	var outBuf, errBuf bytes.Buffer
+	code = run(args, &outBuf, &errBuf)
+	return code, outBuf.String(), errBuf.String()
+}
+
+func TestUsage(t *testing.T) {
+	if code, _, stderr := runCommand(); code != exitUsage || !strings.Contains(stderr, "convert") {
+		t.Errorf("got exit code %d and usage %q", code, stderr)
+	}
+	if code, _, _ := runCommand("help"); code != exitOK {
+		t.Errorf("got exit code %d for help", code)
+	}
+	if code, _, _ := runCommand("frobnicate"); code != exitUsage {
+		t.Errorf("got exit code %d for an unknown command", code)
+	}
+}
+
+func TestFlagsAfterArguments(t *testing.T) {
+	const fixture = "../../test_artifacts/hwloc2_2pkg.xml"
+	want := "cpus:     0-3\ncovering: Machine (node 0)\nscore:    0.50 (4 of 8 threads)\n"
+	for _, args := range [][]string{
+		{"allocate", fixture, "--cpus=4", "--policy=spread"},
+		{"allocate", "-cpus", "4", fixture, "-policy", "spread"},
+	} {
+		if code, stdout, stderr := runCommand(args...); code != exitOK || stdout != want {
+			t.Errorf("%v: got exit code %d and output:\n%s%s\nwant:\n%s", args, code, stdout, stderr, want)
+		}
+	}
+	// Arguments that follow "--" are positional, even if they look like flags.
+	if code, _, _ := runCommand("allocate", "-cpus", "4", "--", fixture, "-json"); code != exitUsage {
+		t.Errorf("got exit code %d for a flag after --; want %d", code, exitUsage)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	const fixture = "../../test_artifacts/t4_de.json"
+	original, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("Error reading from file until EOF: %v\n", err)
+	}
+
+	code, stdout, stderr := runCommand("convert", fixture)
+	if code != exitOK {
+		t.Fatalf("got exit code %d: %s", code, stderr)
+	}
+	var compacted bytes.Buffer
+	if err = json.Compact(&compacted, []byte(stdout)); err != nil || !bytes.Equal(compacted.Bytes(), original) {
+		t.Errorf("JSON output differs from the input (%v)", err)
+	}
+
+	code, stdout, stderr = runCommand("convert", "-to", "yaml", fixture)
+	if code != exitOK || !strings.HasPrefix(stdout, "\"nodes\":\n  - \"data\": \"machine\"\n    \"desc\":\n      - 1\n") {
+		t.Errorf("got exit code %d and YAML output:\n%s%s", code, stdout, stderr)
+	}
+
+	output := filepath.Join(t.TempDir(), "topo.dot")
+	code, _, stderr = runCommand("convert", "-to", "dot", "-o", output, "../../test_artifacts/hwloc2_2pkg.xml")
+	if code != exitOK {
+		t.Fatalf("got exit code %d: %s", code, stderr)
+	}
+	if dot, err := os.ReadFile(output); err != nil || !strings.Contains(string(dot), "n0 -> n14;") {
+		t.Errorf("unexpected DOT output (%v):\n%s", err, dot)
+	}
+
+	for _, args := range [][]string{
+		{"convert"},
+		{"convert", "-to", "bson", fixture},
+		{"convert", "-from", "bson", fixture},
+	} {
+		if code, _, _ = runCommand(args...); code != exitUsage {
+			t.Errorf("%v: got exit code %d; want %d", args, code, exitUsage)
+		}
+	}
+	if code, _, _ = runCommand("convert", "does-not-exist.json"); code != exitFailure {
+		t.Errorf("got exit code %d for a missing file; want %d", code, exitFailure)
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	const fixture = "../../test_artifacts/t4_de.json"
+	original, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, tc := range []struct{ format, file string }{
+		{"yaml", "topo.yaml"},
+		{"binary", "topo.bin"},
+	} {
+		output := filepath.Join(dir, tc.file)
+		if code, _, stderr := runCommand("convert", "-to", tc.format, "-o", output, fixture); code != exitOK {
+			t.Fatalf("%s: got exit code %d: %s", tc.format, code, stderr)
+		}
+		code, stdout, stderr := runCommand("convert", output)
+		if code != exitOK {
+			t.Fatalf("%s: got exit code %d: %s", tc.format, code, stderr)
+		}
+		var compacted bytes.Buffer
+		if err = json.Compact(&compacted, []byte(stdout)); err != nil || !bytes.Equal(compacted.Bytes(), original) {
+			t.Errorf("%s: JSON output differs from the input (%v):\n%s", tc.format, err, stdout)
+		}
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	// A hand-written topology, with comments and flow collections.
+	file := filepath.Join(t.TempDir(), "topo.yaml")
+	input := `nodes:
+  - data: machine
+    desc: [1]
+  - data: {processing: {kind: core, id: 0}}  # the only core
+    desc: [2, 3]
+  - data:
+      processing:
+        kind: thread  # t
+        id: 0
+  - data: {processing: {kind: thread, id: 1}}
+`
+	if err := os.WriteFile(file, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	topo, err := loadTopology(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topo.Threads()) != 2 {
+		t.Errorf("got threads %v; want 2", topo.Threads())
+	}
+}
+
+func TestReadBinary(t *testing.T) {
+	var buf bytes.Buffer
+	doc := map[string]interface{}{"a": []interface{}{json.Number("-3"), json.Number("0.5"), "x", true, nil}, "b": map[string]interface{}{}}
+	if err := writeBinary(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readBinary(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, data := mustJSON(t, doc), mustJSON(t, got); want != data {
+		t.Errorf("got %s; want %s", data, want)
+	}
+	for _, input := range [][]byte{
+		nil,
+		[]byte("{}"),
+		buf.Bytes()[:buf.Len()-1],
+		append(append([]byte{}, buf.Bytes()...), 0),
+		append([]byte(binaryMagic), binaryArray, 0xff, 0xff, 0xff, 0xff, 0x0f),
+		append([]byte(binaryMagic), 0x42),
+	} {
+		if got, err = readBinary(input); err == nil {
+			t.Errorf("%q: got %v; want an error", input, got)
+		}
+	}
+}
+
+// mustJSON returns the provided value in JSON.
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestQuery(t *testing.T) {
+	const fixture = "../../test_artifacts/hwloc2_2pkg.xml"
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"threads"}, "7 8 12 13 20 21 25 26\n"},
+		{[]string{"-output", "os", "numanode:1/threads"}, "2 6 3 7\n"},
+		{[]string{"-output", "cpulist", "numanode:1/threads"}, "2-3,6-7\n"},
+		{[]string{"-output", "os", "caches:l2"}, "0 1 2 3\n"},
+		{[]string{"-output", "cpulist", "cache:l2:2"}, "2,6\n"},
+		{[]string{"-output", "cpulist", "package:*/core:1"}, "1,3,5,7\n"},
+		{[]string{"package:7"}, "\n"},
+	} {
+		args := append([]string{"query"}, tc.args[:len(tc.args)-1]...)
+		args = append(args, fixture, tc.args[len(tc.args)-1])
+		code, stdout, stderr := runCommand(args...)
+		if code != exitOK || stdout != tc.want {
+			t.Errorf("%v: got exit code %d and %q%s; want %q", tc.args, code, stdout, stderr, tc.want)
+		}
+	}
+
+	for _, selector := range []string{"gpu", "cache", "cache:l9", "thread:x", "core:1:2"} {
+		if code, _, _ := runCommand("query", fixture, selector); code != exitUsage {
+			t.Errorf("%s: got exit code %d; want %d", selector, code, exitUsage)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, payload string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(payload), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	repairable := write("repairable.json", `{"nodes":[{"data":"machine","desc":[1,5]},{"data":{"processing":{"kind":"Socket","id":0}}}]}`)
+	unrepairable := write("unrepairable.json", `{"nodes":[{"data":"machine","desc":[1,2]},`+
+		`{"data":{"processing":{"kind":"core","id":0}}},{"data":{"processing":{"kind":"core","id":0}}}]}`)
+
+	for _, tc := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"../../test_artifacts/t4_de.json"}, exitOK},
+		{[]string{repairable}, exitInvalid},
+		{[]string{unrepairable}, exitUnrepairable},
+		{[]string{"-max-nodes", "10", "../../test_artifacts/t4_de.json"}, exitLimitExceeded},
+		{[]string{"does-not-exist.json"}, exitFailure},
+	} {
+		code, stdout, stderr := runCommand(append([]string{"validate"}, tc.args...)...)
+		if code != tc.code {
+			t.Errorf("%v: got exit code %d; want %d\n%s%s", tc.args, code, tc.code, stdout, stderr)
+		}
+	}
+
+	code, stdout, _ := runCommand("validate", "-json", repairable)
+	var report validationReport
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("Failed to unmarshal the JSON report: %v\n%s", err, stdout)
+	}
+	if code != exitInvalid || report.Valid || !report.Repairable || report.ExitCode != exitInvalid ||
+		len(report.Findings) != 2 || nil == report.Node || *report.Node != 1 || nil == report.Offset {
+		t.Errorf("unexpected JSON report:\n%s", stdout)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	const (
+		before = "../../test_artifacts/t4_de.json"
+		after  = "../../test_artifacts/hwloc2_2pkg.xml"
+	)
+	code, stdout, stderr := runCommand("diff", "-exit-code", before, "../../test_artifacts/go_t4_de_topo.json")
+	if code != exitOK || !strings.HasSuffix(stdout, "0 added, 0 removed, 0 moved, 0 modified\n") {
+		t.Errorf("got exit code %d and report:\n%s%s", code, stdout, stderr)
+	}
+
+	code, stdout, stderr = runCommand("diff", "-exit-code", before, after)
+	if code != exitFailure || !strings.Contains(stdout, "- thread:8: Thread(8)\n") ||
+		!strings.HasSuffix(stdout, "10 added, 24 removed, 12 moved, 6 modified\n") {
+		t.Errorf("got exit code %d and report:\n%s%s", code, stdout, stderr)
+	}
+
+	code, stdout, _ = runCommand("diff", "-json", before, after)
+	var report diffReport
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("Failed to unmarshal the JSON report: %v\n%s", err, stdout)
+	}
+	if code != exitOK || report.Added != 10 || report.Removed != 24 || len(report.Changes) != 52 {
+		t.Errorf("unexpected JSON report:\n%s", stdout)
+	}
+
+	if code, _, _ = runCommand("diff", before); code != exitUsage {
+		t.Errorf("got exit code %d for a missing argument; want %d", code, exitUsage)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	if code, _, _ := runCommand("discover", "-sysfs", t.TempDir()); code != exitFailure {
+		t.Errorf("got exit code %d for an empty sysfs; want %d", code, exitFailure)
+	}
+	if code, _, _ := runCommand("discover", "-to", "bson"); code != exitUsage {
+		t.Errorf("got exit code %d for an unknown format; want %d", code, exitUsage)
+	}
+
+	code, stdout, stderr := runCommand("discover")
+	if code != exitOK {
+		t.Skipf("cannot discover the local machine: %s", stderr)
+	}
+	if _, err := actitopo.NewDecoder(actitopo.DecodeLimits{}).Decode(strings.NewReader(stdout)); err != nil {
+		t.Errorf("invalid topology discovered: %v\n%s", err, stdout)
+	}
+
+	if code, _, _ = runCommand("discover", "-envelope", "-to", "yaml"); code != exitUsage {
+		t.Errorf("got exit code %d for an envelope in YAML; want %d", code, exitUsage)
+	}
+	output := filepath.Join(t.TempDir(), "envelope.json")
+	if code, _, stderr = runCommand("discover", "-envelope", "-o", output); code != exitOK {
+		t.Fatalf("got exit code %d: %s", code, stderr)
+	}
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	envelope, err := actitopo.ReadEnvelope(f)
+	if err != nil || envelope.Metadata.Collector != "actitopo" || envelope.Metadata.CapturedAt.IsZero() {
+		t.Errorf("got %+v (%v)", envelope, err)
+	}
+	// Envelopes are accepted wherever bare topologies are.
+	if code, _, stderr = runCommand("convert", "-to", "dot", output); code != exitOK {
+		t.Errorf("got exit code %d when converting an envelope: %s", code, stderr)
+	}
+}
+
+func TestRender(t *testing.T) {
+	const fixture = "../../test_artifacts/hwloc2_2pkg.xml"
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-highlight", "0-1"}, "              Thread(0) (1.00)\n"},
+		{[]string{"-format", "dot"}, "\tn0 -> n1;\n"},
+		{[]string{"-format", "dot", "-highlight", "7"}, "fillcolor="},
+		{[]string{"-format", "svg", "-highlight", "7"}, "<text x=\"780\" y=\"46\">Package(1) (0.25)</text>\n"},
+		{[]string{"-format", "html"}, "<!DOCTYPE html>"},
+	} {
+		code, stdout, stderr := runCommand(append(append([]string{"render"}, tc.args...), fixture)...)
+		if code != exitOK || !strings.Contains(stdout, tc.want) {
+			t.Errorf("%v: got exit code %d and output:\n%s%s\nwant it to contain %q", tc.args, code, stdout, stderr, tc.want)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"render", "-format", "png", fixture},
+		{"render", "-highlight", "3-1", fixture},
+	} {
+		if code, _, _ := runCommand(args...); code != exitUsage {
+			t.Errorf("%v: got exit code %d; want %d", args, code, exitUsage)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	const fixture = "../../test_artifacts/hwloc2_2pkg.xml"
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-cpus", "4"}, "cpus:     0-1,4-5\ncovering: Cache{ L3(L#0), attrs: 8388608B/64B/16-way } (node 3)\nscore:    1.00 (4 of 4 threads)\n"},
+		{[]string{"-cpus", "4", "-policy", "spread"}, "cpus:     0-3\ncovering: Machine (node 0)\nscore:    0.50 (4 of 8 threads)\n"},
+	} {
+		code, stdout, stderr := runCommand(append(append([]string{"allocate"}, tc.args...), fixture)...)
+		if code != exitOK || stdout != tc.want {
+			t.Errorf("%v: got exit code %d and output:\n%s%s\nwant:\n%s", tc.args, code, stdout, stderr, tc.want)
+		}
+	}
+
+	code, stdout, _ := runCommand("allocate", "-cpus", "2", "-reserve", "0", "-json", fixture)
+	var report allocationReport
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("Failed to unmarshal the JSON report: %v\n%s", err, stdout)
+	}
+	if code != exitOK || report.CPUs != "1,5" || report.Covering != 11 || report.Score != 1 {
+		t.Errorf("unexpected JSON report:\n%s", stdout)
+	}
+
+	for _, args := range [][]string{
+		{"allocate", fixture},
+		{"allocate", "-cpus", "2", "-policy", "random", fixture},
+	} {
+		if code, _, _ := runCommand(args...); code != exitUsage {
+			t.Errorf("%v: got exit code %d; want %d", args, code, exitUsage)
+		}
+	}
+	if code, _, _ := runCommand("allocate", "-cpus", "9", fixture); code != exitFailure {
+		t.Errorf("got exit code %d for an unsatisfiable request; want %d", code, exitFailure)
+	}
+}
+
+func TestServe(t *testing.T) {
+	topo, err := loadTopology("../../test_artifacts/hwloc2_2pkg.xml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := newTopologyHandler(topo, "test", "/topology")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	get := func(path string, header http.Header) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+
+	resp, body := get("/topology", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("got %s (%s)", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	if decoded, err := actitopo.NewDecoder(actitopo.DecodeLimits{}).Decode(strings.NewReader(body)); err != nil ||
+		decoded.Size() != topo.Size() {
+		t.Errorf("served an invalid topology (%v)", err)
+	}
+	if resp, _ = get("/topology", http.Header{"If-None-Match": {resp.Header.Get("ETag")}}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("got %s for a cached topology; want 304", resp.Status)
+	}
+
+	for _, tc := range []struct {
+		path, contentType, want string
+	}{
+		{"/topology?format=yaml", "application/yaml", "\"nodes\":\n"},
+		{"/topology?format=text&highlight=0", "text/plain; charset=utf-8", "Thread(0) (1.00)\n"},
+		{"/topology?format=svg", "image/svg+xml", "<svg "},
+		{"/topology?format=html", "text/html; charset=utf-8", "<title>test</title>"},
+		{"/healthz", "text/plain; charset=utf-8", "ok\n"},
+	} {
+		resp, body = get(tc.path, nil)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != tc.contentType || !strings.Contains(body, tc.want) {
+			t.Errorf("%s: got %s (%s):\n%s", tc.path, resp.Status, resp.Header.Get("Content-Type"), body)
+		}
+	}
+	for _, path := range []string{"/topology?format=png", "/topology?format=text&highlight=x"} {
+		if resp, _ = get(path, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: got %s; want 400", path, resp.Status)
+		}
+	}
+
+	if code, _, _ := runCommand("serve"); code != exitUsage {
+		t.Errorf("got exit code %d without -file; want %d", code, exitUsage)
+	}
+	for _, path := range []string{"/healthz", "topology", ""} {
+		if code, _, _ := runCommand("serve", "-file", "../../test_artifacts/hwloc2_2pkg.xml", "-path", path); code != exitUsage {
+			t.Errorf("got exit code %d for -path %q; want %d", code, path, exitUsage)
+		}
+	}
+	// Patterns that would make http.ServeMux panic (e.g., "/{" in the
+	// syntax of Go 1.22) must be reported rather than panic.
+	if err := checkPath("/{"); nil != err && !strings.Contains(err.Error(), "{") {
+		t.Errorf("got %v for an invalid pattern", err)
+	}
+	if err := checkPath("/api/v1/topology"); nil != err {
+		t.Errorf("got %v for a valid path", err)
+	}
+}
+
+func TestServePath(t *testing.T) {
+	topo, err := loadTopology("../../test_artifacts/hwloc2_2pkg.xml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := newTopologyHandler(topo, "test", "/api/v1/topology")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]int{
+		"/api/v1/topology": http.StatusOK,
+		"/topology":        http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: got %d; want %d", path, rec.Code, want)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, payload string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(payload), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	pkg := func(id int, desc string) string {
+		return `{"data":{"processing":{"kind":"package","id":` + strconv.Itoa(id) + `}},"desc":[` + desc + `]}`
+	}
+	thread := func(id int) string {
+		return `{"data":{"processing":{"kind":"thread","id":` + strconv.Itoa(id) + `}}}`
+	}
+	original := write("original.json", `{"nodes":[{"data":"machine","desc":[1,3]},`+
+		pkg(0, "2")+`,`+thread(0)+`,`+pkg(1, "4")+`,`+thread(1)+`]}`)
+	reordered := write("reordered.json", `{"nodes":[{"data":"machine","desc":[1,3]},`+
+		pkg(1, "2")+`,`+thread(1)+`,`+pkg(0, "4")+`,`+thread(0)+`]}`)
+	renumbered := write("renumbered.json", `{"nodes":[{"data":"machine","desc":[1,3]},`+
+		pkg(0, "2")+`,`+thread(0)+`,`+pkg(1, "4")+`,`+thread(2)+`]}`)
+
+	code, stdout, stderr := runCommand("fingerprint", "-json", original, reordered, renumbered)
+	var reports []fingerprintReport
+	if err := json.Unmarshal([]byte(stdout), &reports); err != nil || code != exitOK || len(reports) != 3 {
+		t.Fatalf("got exit code %d and report (%v):\n%s%s", code, err, stdout, stderr)
+	}
+	if reports[0] != (fingerprintReport{original, reports[1].Structure, reports[1].Full}) {
+		t.Errorf("the order of the elements affects the fingerprints: %v", reports)
+	}
+	if reports[2].Structure != reports[0].Structure || reports[2].Full == reports[0].Full {
+		t.Errorf("the OS indices of the elements affect the wrong fingerprints: %v", reports)
+	}
+
+	code, stdout, _ = runCommand("fingerprint", original)
+	if fields := strings.Fields(stdout); code != exitOK || len(fields) != 3 || fields[0] != reports[0].Structure {
+		t.Errorf("got exit code %d and output %q", code, stdout)
+	}
+	if code, _, _ = runCommand("fingerprint"); code != exitUsage {
+		t.Errorf("got exit code %d without files; want %d", code, exitUsage)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	const (
+		node1 = "../../test_artifacts/t4_de.json"
+		node2 = "../../test_artifacts/hwloc2_2pkg.xml"
+	)
+	cluster := filepath.Join(t.TempDir(), "cluster.json")
+	if code, _, stderr := runCommand("merge", "-o", cluster, node1, "xml="+node2); code != exitOK {
+		t.Fatalf("got exit code %d: %s", code, stderr)
+	}
+
+	code, stdout, stderr := runCommand("merge", cluster, "../../test_artifacts/go_t4_de_topo.json")
+	if code != exitOK {
+		t.Fatalf("got exit code %d: %s", code, stderr)
+	}
+	var merged actitopo.ClusterTopology
+	if err := json.Unmarshal([]byte(stdout), &merged); err != nil {
+		t.Fatalf("Failed to unmarshal the cluster document: %v\n", err)
+	}
+	if names := strings.Join(merged.Names(), ","); names != "go_t4_de_topo,t4_de,xml" {
+		t.Errorf("got nodes %s", names)
+	}
+	if topo, ok := merged.Get("xml"); !ok || len(topo.Packages()) != 2 {
+		t.Errorf("unexpected topology of node 'xml'")
+	}
+
+	if code, _, _ = runCommand("merge", node1, node1); code != exitFailure {
+		t.Errorf("got exit code %d for duplicate nodes; want %d", code, exitFailure)
+	}
+	if code, _, _ = runCommand("merge", "renamed="+cluster); code != exitUsage {
+		t.Errorf("got exit code %d for a renamed cluster document; want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// runMerge implements `actitopo merge`.
+func runMerge(args []string, stdout io.Writer) error {
+	fs := newFlagSet("merge", "[flags] [<name>=]<file>...\n\n"+
+		"Merges the topologies of the provided nodes into a single cluster document.\n"+
+		"Each node is named after its file (without the extension), unless a name is\n"+
+		"provided explicitly. Files that are cluster documents themselves contribute\n"+
+		"all of their nodes.\n")
+	from := fs.String("from", "", "input format: json, yaml, binary or hwloc-xml (default: implied by the file extensions)")
+	output := fs.String("o", "-", "output file, or - for the standard output")
+	if err := parseFlags(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	cluster := actitopo.NewClusterTopology()
+	for _, arg := range fs.Args() {
+		name, path := "", arg
+		if i := strings.IndexByte(arg, '='); i >= 0 {
+			name, path = arg[:i], arg[i+1:]
+		}
+		if other, err := loadClusterTopology(path, *from); err != nil {
+			return err
+		} else if nil != other {
+			if name != "" {
+				return usageError("%s: cluster documents cannot be renamed", path)
+			}
+			if err = cluster.Merge(other); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+
+		topo, err := loadTopology(path, *from)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		if err = cluster.Add(name, topo); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return writeOutput(stdout, *output, func(w io.Writer) error {
+		data, err := json.MarshalIndent(cluster, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	})
+}
+
+// loadClusterTopology returns the ClusterTopology read from the file at the
+// provided path, or nil if the file is not a JSON cluster document (i.e., its
+// "nodes" are not a JSON object).
+func loadClusterTopology(path, format string) (*actitopo.ClusterTopology, error) {
+	if path == "-" || inputFormat(format, path) != formatJSON {
+		return nil, nil
+	}
+	data, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		Nodes json.RawMessage `json:"nodes"`
+	}
+	if err = json.Unmarshal(data, &probe); err != nil || !strings.HasPrefix(strings.TrimSpace(string(probe.Nodes)), "{") {
+		// Let loadTopology report any errors.
+		return nil, nil
+	}
+	cluster := actitopo.NewClusterTopology()
+	if err = json.Unmarshal(data, cluster); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cluster, nil
+}
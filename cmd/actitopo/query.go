@@ -0,0 +1,94 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// runQuery implements `actitopo query`.
+func runQuery(args []string, stdout io.Writer) error {
+	fs := newFlagSet("query", "[flags] <file|-> <selector>\n\n"+
+		"A selector is a list of steps separated by '/', each of which selects the\n"+
+		"descendants of the elements selected by the previous one (or of the root)\n"+
+		"that match it. A step is one of:\n"+
+		"  package[:<os index>|*]   numanode[:<os index>|*]   core[:<os index>|*]\n"+
+		"  thread[:<os index>|*]    cache:<level>[:<logical index>|*]\n"+
+		"Kinds may also be written in plural (e.g., 'threads', 'numanode:1/threads',\n"+
+		"or 'cache:l3:2/threads').\n")
+	from := fs.String("from", "", "input format: json, yaml, binary or hwloc-xml (default: implied by the file extension)")
+	output := fs.String("output", "ids", "output: ids (NodeIDs), os (OS indices, or logical indices of caches) or cpulist (OS indices of all threads under the selected elements)")
+	if err := parseFlags(fs, args, 2, 2); err != nil {
+		return err
+	}
+	switch *output {
+	case "ids", "os", "cpulist":
+	default:
+		return usageError("unknown output '%s'", *output)
+	}
+	selector, err := actitopo.ParseSelector(fs.Arg(1))
+	if err != nil {
+		return usageError("%v", err)
+	}
+
+	topo, err := loadTopology(fs.Arg(0), *from)
+	if err != nil {
+		return err
+	}
+	ids := selector.Select(topo)
+
+	switch *output {
+	case "ids":
+		strs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			strs = append(strs, strconv.FormatUint(uint64(id), 10))
+		}
+		_, err = fmt.Fprintln(stdout, strings.Join(strs, " "))
+	case "os":
+		strs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			data := topo.Nodes[id].Data
+			switch {
+			case data.IsProcessing():
+				strs = append(strs, strconv.FormatUint(uint64(data.ID), 10))
+			case data.IsCache():
+				strs = append(strs, strconv.FormatUint(uint64(data.LogicalIndex), 10))
+			}
+		}
+		_, err = fmt.Fprintln(stdout, strings.Join(strs, " "))
+	case "cpulist":
+		osIDs := make([]uint32, 0)
+		for _, id := range ids {
+			leafIDs, err := topo.LeafDescendantIDs(id)
+			if err != nil {
+				return err
+			}
+			for _, leafID := range leafIDs {
+				if data := topo.Nodes[leafID].Data; data.IsProcessing() && data.Kind == actitopo.Thread {
+					osIDs = append(osIDs, data.ID)
+				}
+			}
+		}
+		_, err = fmt.Fprintln(stdout, actitopo.FormatCPUList(osIDs))
+	}
+	return err
+}
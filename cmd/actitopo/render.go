@@ -0,0 +1,90 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// Render formats, in addition to formatDOT.
+const (
+	formatSVG  = "svg"
+	formatText = "text"
+	formatHTML = "html"
+)
+
+// runRender implements `actitopo render`.
+func runRender(args []string, stdout io.Writer) error {
+	fs := newFlagSet("render", "[flags] <file|->")
+	from := fs.String("from", "", "input format: json, yaml, binary or hwloc-xml (default: implied by the file extension)")
+	format := fs.String("format", formatText, "output format: text, dot, svg or html")
+	highlight := fs.String("highlight", "", "cpulist of hardware threads to highlight (e.g., 0-3,8)")
+	color := fs.Bool("color", false, "colorize the text output through ANSI escape sequences")
+	output := fs.String("o", "-", "output file, or - for the standard output")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	switch *format {
+	case formatText, formatDOT, formatSVG, formatHTML:
+	default:
+		return usageError("unknown output format '%s'", *format)
+	}
+	cpus, err := actitopo.ParseCPUList(*highlight)
+	if err != nil {
+		return usageError("invalid -highlight: %v", err)
+	}
+
+	topo, err := loadTopology(fs.Arg(0), *from)
+	if err != nil {
+		return err
+	}
+	var overlay *actitopo.Overlay
+	if len(cpus) > 0 {
+		overlay = topo.CPUListOverlay(cpus)
+	}
+	return writeOutput(stdout, *output, func(w io.Writer) error {
+		return renderTopology(w, topo, *format, overlay, *color, fs.Arg(0))
+	})
+}
+
+// renderTopology writes the provided Topology to the provided io.Writer in the
+// provided render format, annotated with the provided Overlay (which may be
+// nil). The text format is colorized if color is true, and the HTML document is
+// given the provided title.
+func renderTopology(
+	w io.Writer,
+	topo *actitopo.Topology,
+	format string,
+	overlay *actitopo.Overlay,
+	color bool,
+	title string,
+) error {
+	switch format {
+	case formatText:
+		return topo.WriteTree(w, actitopo.RenderOptions{Color: color, Overlay: overlay})
+	case formatDOT:
+		return topo.WriteDOT(w, overlay)
+	case formatSVG:
+		return topo.WriteSVG(w, overlay)
+	case formatHTML:
+		return topo.WriteHTMLOverlay(w, title, overlay)
+	default:
+		return usageError("unknown output format '%s'", format)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// runServe implements `actitopo serve`.
+func runServe(args []string, stdout io.Writer) error {
+	fs := newFlagSet("serve", "-file <path> [flags]\n\n"+
+		"Serves a static topology over HTTP, until interrupted:\n"+
+		"  GET <path>[?format=json|yaml|text|dot|svg|html][&highlight=<cpulist>]\n"+
+		"  GET /healthz\n\n"+
+		"The JSON format is the payload that the Aggregator expects from the agents'\n"+
+		"endpoint (see AgentURL). Only HTTP is served; a gRPC endpoint is not available\n"+
+		"yet, since it requires an external dependency.\n")
+	file := fs.String("file", "", "topology to serve (required)")
+	from := fs.String("from", "", "input format: json, yaml, binary or hwloc-xml (default: implied by the file extension)")
+	listen := fs.String("listen", ":8080", "address to listen on")
+	path := fs.String("path", "/topology", "path of the topology endpoint")
+	if err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *file == "" {
+		fs.Usage()
+		return usageError("-file is required")
+	}
+	if err := checkPath(*path); err != nil {
+		return usageError("invalid -path '%s': %v", *path, err)
+	}
+
+	topo, err := loadTopology(*file, *from)
+	if err != nil {
+		return err
+	}
+	handler, err := newTopologyHandler(topo, *file, *path)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: *listen, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ListenAndServe() }()
+	fmt.Fprintf(stdout, "serving %s on %s\n", *file, *listen)
+	select {
+	case err = <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err = server.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err = <-errCh; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// checkPath returns a non-nil error value if the provided path cannot serve the
+// topology endpoint, i.e., if it does not start with a slash, or if it is not
+// a valid http.ServeMux pattern alongside /healthz (which would make the
+// registration panic).
+func checkPath(path string) (err error) {
+	if !strings.HasPrefix(path, "/") {
+		return errors.New("must start with '/'")
+	}
+	defer func() {
+		if r := recover(); nil != r {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc(path, func(http.ResponseWriter, *http.Request) {})
+	return nil
+}
+
+// topologyHandler serves a static Topology over HTTP.
+type topologyHandler struct {
+	mux   *http.ServeMux
+	topo  *actitopo.Topology
+	title string
+	// payload is the Topology in JSON, and etag is its entity tag.
+	payload []byte
+	etag    string
+}
+
+// newTopologyHandler returns a new topologyHandler for the provided Topology,
+// served at the provided path, whose HTML documents are given the provided
+// title.
+func newTopologyHandler(topo *actitopo.Topology, title, path string) (*topologyHandler, error) {
+	payload, err := json.Marshal(topo)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(payload)
+	h := &topologyHandler{
+		mux:     http.NewServeMux(),
+		topo:    topo,
+		title:   title,
+		payload: payload,
+		etag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+	h.mux.HandleFunc(path, h.serveTopology)
+	h.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *topologyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// contentTypes maps the formats served by topologyHandler to their media
+// types.
+var contentTypes = map[string]string{
+	formatJSON: "application/json",
+	formatYAML: "application/yaml",
+	formatText: "text/plain; charset=utf-8",
+	formatDOT:  "text/vnd.graphviz",
+	formatSVG:  "image/svg+xml",
+	formatHTML: "text/html; charset=utf-8",
+}
+
+// serveTopology serves the Topology in the requested format.
+func (h *topologyHandler) serveTopology(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = formatJSON
+	}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown format '%s'", format), http.StatusBadRequest)
+		return
+	}
+	cpus, err := actitopo.ParseCPUList(query.Get("highlight"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid highlight: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if format == formatJSON {
+		// The payload is static, so clients may cache it.
+		w.Header().Set("ETag", h.etag)
+		w.Header().Set("Content-Type", contentType)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(h.payload))
+		return
+	}
+	var overlay *actitopo.Overlay
+	if len(cpus) > 0 {
+		overlay = h.topo.CPUListOverlay(cpus)
+	}
+	var buf bytes.Buffer
+	if format == formatYAML {
+		err = writeTopology(&buf, h.topo, format)
+	} else {
+		err = renderTopology(&buf, h.topo, format, overlay, false, h.title)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buf.Bytes())
+}
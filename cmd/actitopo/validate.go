@@ -0,0 +1,147 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// Exit codes of `actitopo validate`, in addition to exitOK (valid topology),
+// exitFailure (e.g., unreadable file) and exitUsage.
+const (
+	// exitInvalid means that the topology is invalid, but all problems
+	// found can be repaired through actitopo.Sanitize.
+	exitInvalid = 3
+	// exitUnrepairable means that the topology is invalid, and some of the
+	// problems found cannot be repaired.
+	exitUnrepairable = 4
+	// exitLimitExceeded means that the payload exceeds the provided limits.
+	exitLimitExceeded = 5
+)
+
+// validationReport is the report of `actitopo validate`.
+type validationReport struct {
+	File  string `json:"file"`
+	Valid bool   `json:"valid"`
+	// Error is the first problem found by the strict decoder, if any.
+	Error string `json:"error,omitempty"`
+	// Node, Kind and Offset locate the Error, if it concerns a specific
+	// element.
+	Node   *actitopo.NodeID `json:"node,omitempty"`
+	Kind   string           `json:"kind,omitempty"`
+	Offset *int64           `json:"offset,omitempty"`
+	// Findings are all problems found by actitopo.Sanitize.
+	Findings []actitopo.Issue `json:"findings,omitempty"`
+	// Repairable is true if all Findings can be repaired.
+	Repairable bool `json:"repairable"`
+	// ExitCode is the exit code of the command.
+	ExitCode int `json:"exit_code"`
+}
+
+// runValidate implements `actitopo validate`.
+func runValidate(args []string, stdout io.Writer) error {
+	fs := newFlagSet("validate", "[flags] <file|->\n\n"+
+		"Exit codes: 0 (valid), 1 (failure), 2 (usage), 3 (invalid, but repairable),\n"+
+		"4 (invalid and unrepairable), 5 (limits exceeded).\n")
+	jsonOutput := fs.Bool("json", false, "write the report in JSON")
+	maxBytes := fs.Int64("max-bytes", 0, "maximum payload size in bytes (0 for no limit)")
+	maxNodes := fs.Int("max-nodes", 0, "maximum number of elements (0 for no limit)")
+	maxDepth := fs.Int("max-depth", 0, "maximum depth of the tree (0 for no limit)")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	report := validateJSON(data, actitopo.DecodeLimits{MaxBytes: *maxBytes, MaxNodes: *maxNodes, MaxDepth: *maxDepth})
+	report.File = fs.Arg(0)
+	if *jsonOutput {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s\n", out)
+	} else {
+		writeValidationReport(stdout, report)
+	}
+	if report.ExitCode != exitOK {
+		// The report has already been written.
+		return &exitError{code: report.ExitCode}
+	}
+	return nil
+}
+
+// validateJSON validates the provided JSON payload against the provided
+// limits.
+func validateJSON(data []byte, limits actitopo.DecodeLimits) *validationReport {
+	report := &validationReport{}
+	_, err := actitopo.NewDecoder(limits).Decode(bytes.NewReader(data))
+	if nil == err {
+		report.Valid, report.Repairable = true, true
+		return report
+	}
+
+	report.Error = err.Error()
+	var nodeErr *actitopo.NodeError
+	if errors.As(err, &nodeErr) {
+		report.Node, report.Kind = &nodeErr.ID, nodeErr.Kind
+		if nodeErr.Offset >= 0 {
+			report.Offset = &nodeErr.Offset
+		}
+	}
+	if errors.Is(err, actitopo.ErrLimitExceeded) {
+		report.ExitCode = exitLimitExceeded
+		return report
+	}
+	var sanitizeErr error
+	_, report.Findings, sanitizeErr = actitopo.Sanitize(data)
+	report.Repairable = nil == sanitizeErr
+	if report.Repairable {
+		report.ExitCode = exitInvalid
+	} else {
+		report.ExitCode = exitUnrepairable
+		report.Findings = append(report.Findings, actitopo.Issue{Description: sanitizeErr.Error()})
+	}
+	return report
+}
+
+// writeValidationReport writes the provided validationReport to the provided
+// io.Writer in a human-readable form.
+func writeValidationReport(w io.Writer, report *validationReport) {
+	if report.Valid {
+		fmt.Fprintf(w, "%s: valid\n", report.File)
+		return
+	}
+	fmt.Fprintf(w, "%s: invalid: %s\n", report.File, report.Error)
+	if len(report.Findings) > 0 {
+		fmt.Fprintf(w, "Findings:\n")
+		for _, issue := range report.Findings {
+			fmt.Fprintf(w, "  - %s\n", issue)
+		}
+	}
+	if report.Repairable {
+		fmt.Fprintf(w, "All findings can be repaired.\n")
+	}
+}
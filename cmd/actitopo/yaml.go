@@ -0,0 +1,92 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writeYAML writes the provided document, as produced by unmarshalling JSON
+// into an interface{}, to the provided io.Writer in YAML (block style, with
+// all strings quoted, so that no YAML-specific scalar rules apply).
+func writeYAML(w io.Writer, doc interface{}) error {
+	var sb strings.Builder
+	writeYAMLValue(&sb, doc, 0, false)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeYAMLValue writes the provided value at the provided indentation level;
+// inline is true if the value follows a key or a sequence dash on the same
+// line.
+func writeYAMLValue(w *strings.Builder, v interface{}, indent int, inline bool) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			w.WriteString(" {}\n")
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if inline {
+			w.WriteString("\n")
+		}
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s%s:", pad, strconv.Quote(key))
+			writeYAMLValue(w, v[key], indent+1, true)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			w.WriteString(" []\n")
+			return
+		}
+		if inline {
+			w.WriteString("\n")
+		}
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok && len(m) > 0 {
+				// Start the mapping on the same line as the dash.
+				var sb strings.Builder
+				writeYAMLValue(&sb, m, indent+1, false)
+				fmt.Fprintf(w, "%s- %s", pad, strings.TrimPrefix(sb.String(), pad+"  "))
+				continue
+			}
+			fmt.Fprintf(w, "%s-", pad)
+			writeYAMLValue(w, item, indent+1, true)
+		}
+	default:
+		// JSON scalars (i.e., strings, numbers, booleans and null) are
+		// valid YAML flow scalars, too.
+		data, _ := json.Marshal(v)
+		if inline {
+			w.WriteString(" ")
+		} else {
+			w.WriteString(pad)
+		}
+		w.Write(data)
+		w.WriteString("\n")
+	}
+}
@@ -0,0 +1,187 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Package compat checks the compatibility of this package's serialization
+// format with that of the Rust implementation of actitopo, against a corpus of
+// golden payloads that the latter produced.
+package compat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// ErrIncompatible is returned (wrapped) by the checks of this package when a
+// golden payload and the respective Topology do not match.
+var ErrIncompatible = errors.New("incompatible payloads")
+
+// Golden is a golden payload of the corpus.
+type Golden struct {
+	// Name is the name of the payload's file, without its extension.
+	Name string
+	// Payload is the JSON payload, as produced by the Rust implementation.
+	Payload []byte
+}
+
+// LoadCorpus returns the golden payloads of all JSON files (i.e., "*.json") in
+// the provided directory, sorted by their names, or a non-nil error value in
+// case of failure.
+func LoadCorpus(dir string) ([]Golden, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	corpus := make([]Golden, 0, len(paths))
+	for _, path := range paths {
+		payload, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load golden payload: %w", err)
+		}
+		corpus = append(corpus, Golden{
+			Name:    strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+			Payload: payload,
+		})
+	}
+	return corpus, nil
+}
+
+// CheckCompatibility makes sure that the provided Topology and golden payload
+// describe the same Topology in both directions: the golden payload must
+// decode into a Topology that is identical to the provided one, and the
+// provided Topology must be encoded into a payload that is equivalent to the
+// golden one (i.e., the same JSON values, regardless of insignificant
+// whitespace or the order of object members). It returns a non-nil error
+// value describing the first mismatch found, if any, which wraps
+// ErrIncompatible.
+func CheckCompatibility(topology *actitopo.Topology, golden []byte) error {
+	decoded, err := actitopo.NewDecoder(actitopo.DecodeLimits{}).Decode(bytes.NewReader(golden))
+	if err != nil {
+		return fmt.Errorf("%w: failed to decode golden payload: %v", ErrIncompatible, err)
+	}
+	if topology.Size() != decoded.Size() {
+		return fmt.Errorf("%w: golden payload decodes into %d elements instead of %d",
+			ErrIncompatible, decoded.Size(), topology.Size())
+	}
+	for id := range topology.Nodes {
+		want, got := topology.Nodes[id], decoded.Nodes[id]
+		if !reflect.DeepEqual(want.Data, got.Data) {
+			return fmt.Errorf("%w: golden payload decodes node %d into %v instead of %v",
+				ErrIncompatible, id, got.Data, want.Data)
+		}
+		if !equalChildren(want.Children, got.Children) {
+			return fmt.Errorf("%w: golden payload decodes the children of node %d into %v instead of %v",
+				ErrIncompatible, id, got.Children, want.Children)
+		}
+	}
+
+	encoded, err := json.Marshal(topology)
+	if err != nil {
+		return fmt.Errorf("failed to encode Topology: %w", err)
+	}
+	var goValue, rustValue interface{}
+	if err = json.Unmarshal(encoded, &goValue); err != nil {
+		return fmt.Errorf("failed to decode Topology: %w", err)
+	}
+	if err = json.Unmarshal(golden, &rustValue); err != nil {
+		return fmt.Errorf("%w: failed to decode golden payload: %v", ErrIncompatible, err)
+	}
+	if path, ok := firstDifference("$", goValue, rustValue); !ok {
+		return fmt.Errorf("%w: encoded Topology differs from golden payload at %s", ErrIncompatible, path)
+	}
+	return nil
+}
+
+// CheckRoundTrip makes sure that the provided golden payload decodes into a
+// Topology that is encoded back into an equivalent payload (see
+// CheckCompatibility).
+func CheckRoundTrip(golden []byte) error {
+	topology, err := actitopo.NewDecoder(actitopo.DecodeLimits{}).Decode(bytes.NewReader(golden))
+	if err != nil {
+		return fmt.Errorf("%w: failed to decode golden payload: %v", ErrIncompatible, err)
+	}
+	return CheckCompatibility(topology, golden)
+}
+
+// equalChildren returns true if the provided lists of children are equal,
+// where nil and empty lists are considered equal.
+func equalChildren(a, b []actitopo.NodeID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// firstDifference compares the provided decoded JSON values and returns true
+// if they are equal, or false along with the JSONPath-like path (rooted at the
+// provided one) of the first difference found.
+func firstDifference(path string, a, b interface{}) (string, bool) {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			return path, false
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for key := range a {
+			keys = append(keys, key)
+		}
+		for key := range b {
+			if _, ok := a[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if path, ok := firstDifference(path+"."+key, a[key], b[key]); !ok {
+				return path, false
+			}
+		}
+		return path, true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok {
+			return path, false
+		}
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if path, ok := firstDifference(fmt.Sprintf("%s[%d]", path, i), a[i], b[i]); !ok {
+				return path, false
+			}
+		}
+		if len(a) < len(b) {
+			return fmt.Sprintf("%s[%d]", path, len(a)), false
+		} else if len(a) > len(b) {
+			return fmt.Sprintf("%s[%d]", path, len(b)), false
+		}
+		return path, true
+	default:
+		return path, a == b
+	}
+}
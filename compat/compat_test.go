@@ -0,0 +1,83 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package compat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+func TestCorpus(t *testing.T) {
+	corpus, err := LoadCorpus("testdata/rust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corpus) != 2 || corpus[0].Name != "t4_de" {
+		t.Fatalf("got %d golden payloads; expected t4_de and topo__immutree", len(corpus))
+	}
+	for _, golden := range corpus {
+		if err = CheckRoundTrip(golden.Payload); err != nil {
+			t.Errorf("%s: %v", golden.Name, err)
+		}
+	}
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	corpus, err := LoadCorpus("testdata/rust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := corpus[0].Payload
+	decode := func() *actitopo.Topology {
+		topo, err := actitopo.NewDecoder(actitopo.DecodeLimits{}).Decode(bytes.NewReader(golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return topo
+	}
+
+	// Insignificant whitespace is not a mismatch.
+	var indented bytes.Buffer
+	if err = json.Indent(&indented, golden, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	if err = CheckCompatibility(decode(), indented.Bytes()); err != nil {
+		t.Errorf("got %v for an indented golden payload", err)
+	}
+
+	// Different Elements are.
+	topo := decode()
+	topo.Nodes[4].Data.Processing.ID = 99
+	if err = CheckCompatibility(topo, golden); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("got %v for a different Topology; expected ErrIncompatible", err)
+	}
+
+	// So are fields that Go does not know about.
+	drifted := bytes.Replace(golden, []byte(`"ways":8`), []byte(`"ways":8,"inclusive":true`), 1)
+	err = CheckCompatibility(decode(), drifted)
+	if !errors.Is(err, ErrIncompatible) {
+		t.Fatalf("got %v for a drifted golden payload; expected ErrIncompatible", err)
+	}
+	if want := "$.nodes[3].data.cache.attrs.inclusive"; !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v; expected the mismatch at %s", err, want)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentReads runs all read-only queries of a freshly decoded Topology
+// from multiple goroutines at once; it is only meaningful with -race.
+func TestConcurrentReads(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for id := range topo.Nodes {
+				if _, err := topo.LeafDescendantIDs(NodeID(id)); err != nil {
+					t.Error(err)
+				}
+				if _, err := topo.AncestorIDs(NodeID(id)); err != nil {
+					t.Error(err)
+				}
+				if _, _, err := topo.SubtreeRange(NodeID(id)); err != nil {
+					t.Error(err)
+				}
+				_, _ = topo.ParentID(NodeID(id))
+			}
+			_ = topo.Packages()
+			_ = topo.L2Caches()
+			if _, err := topo.Interleave(g+1, nil); err != nil {
+				t.Error(err)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// TestConcurrentAllocations allocates and releases threads from multiple
+// goroutines at once, making sure that no thread is ever handed out twice.
+func TestConcurrentAllocations(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		owner = make(map[NodeID]string)
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				name := fmt.Sprintf("owner-%d-%d", g, i)
+				allocation, err := alloc.Allocate(AllocationRequest{Owner: name, MinThreads: 1, MaxThreads: 3})
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				for _, id := range allocation.Threads {
+					if other, taken := owner[id]; taken {
+						t.Errorf("thread %d allocated to both %s and %s", id, other, name)
+					}
+					owner[id] = name
+				}
+				mu.Unlock()
+				_ = alloc.Available()
+				_, _ = alloc.MarshalJSON()
+
+				mu.Lock()
+				for _, id := range allocation.Threads {
+					delete(owner, id)
+				}
+				mu.Unlock()
+				if err = alloc.Release(name); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := len(alloc.Owners()); n != 0 {
+		t.Errorf("%d owners still hold an Allocation", n)
+	}
+}
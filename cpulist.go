@@ -0,0 +1,146 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxCPUListSize is the maximum number of OS indices that ParseCPUList expands
+// a cpulist into, counting those of overlapping ranges repeatedly; it is well
+// above the number of CPUs that the Linux kernel supports (i.e., NR_CPUS).
+const MaxCPUListSize = 1 << 16
+
+// ParseCPUList returns the list of OS indices parsed from the provided string,
+// which is expected in the Linux kernel's "cpulist" format (e.g., "0-3,8,10-11",
+// as found in cpuset.cpus or /sys/devices/system/cpu/online), or a non-nil
+// error value if parsing fails or the cpulist expands into more than
+// MaxCPUListSize OS indices.
+//
+// Ranges may also be strided, like the kernel allows: "a-b:used/group" selects
+// the first used OS indices of every group of group consecutive ones in the
+// range (e.g., "0-31:2/4" selects 0, 1, 4, 5, ..., 28 and 29).
+//
+// The returned list is sorted in ascending order and contains no duplicates.
+func ParseCPUList(str string) ([]uint32, error) {
+	set := make(map[uint32]struct{})
+	total := uint64(0)
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return []uint32{}, nil
+	}
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		rng, stride, strided := strings.Cut(part, ":")
+		bounds := strings.SplitN(rng, "-", 2)
+		first, err := strconv.ParseUint(bounds[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpulist entry '%s': %v", part, err)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.ParseUint(bounds[1], 10, 32); err != nil {
+				return nil, fmt.Errorf("invalid cpulist entry '%s': %v", part, err)
+			}
+			if last < first {
+				return nil, fmt.Errorf("invalid cpulist entry '%s': decreasing range", part)
+			}
+		}
+		used, group := uint64(1), uint64(1)
+		if strided {
+			if len(bounds) != 2 {
+				return nil, fmt.Errorf("invalid cpulist entry '%s': stride without a range", part)
+			}
+			if used, group, err = parseStride(stride); err != nil {
+				return nil, fmt.Errorf("invalid cpulist entry '%s': %v", part, err)
+			}
+		}
+		// Each full group contributes used OS indices, and the last partial
+		// one contributes at most as many.
+		span := last - first + 1
+		selected := span / group * used
+		if rem := span % group; rem < used {
+			selected += rem
+		} else {
+			selected += used
+		}
+		if total += selected; total > MaxCPUListSize {
+			return nil, fmt.Errorf("invalid cpulist entry '%s': cpulist exceeds %d entries", part, MaxCPUListSize)
+		}
+		for start := first; used > 0 && start <= last; start += group {
+			for id := start; id < start+used && id <= last; id++ {
+				set[uint32(id)] = struct{}{}
+			}
+		}
+	}
+
+	ret := make([]uint32, 0, len(set))
+	for id := range set {
+		ret = append(ret, id)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret, nil
+}
+
+// parseStride parses the "used/group" stride of a cpulist range, where group
+// must be positive and no less than used, or returns a non-nil error value in
+// case of failure.
+func parseStride(stride string) (used, group uint64, err error) {
+	usedStr, groupStr, ok := strings.Cut(stride, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid stride '%s': expected 'used/group'", stride)
+	}
+	if used, err = strconv.ParseUint(usedStr, 10, 32); err != nil {
+		return 0, 0, fmt.Errorf("invalid stride '%s': %v", stride, err)
+	}
+	if group, err = strconv.ParseUint(groupStr, 10, 32); err != nil {
+		return 0, 0, fmt.Errorf("invalid stride '%s': %v", stride, err)
+	}
+	if group == 0 || used > group {
+		return 0, 0, fmt.Errorf("invalid stride '%s': group must be positive and no less than used", stride)
+	}
+	return used, group, nil
+}
+
+// FormatCPUList returns the string representation of the provided OS indices
+// in the Linux kernel's "cpulist" format (e.g., "0-3,8,10-11").
+func FormatCPUList(ids []uint32) string {
+	sorted := make([]uint32, len(ids))
+	copy(sorted, ids)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sb strings.Builder
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		if sorted[i] == sorted[j] {
+			fmt.Fprintf(&sb, "%d", sorted[i])
+		} else {
+			fmt.Fprintf(&sb, "%d-%d", sorted[i], sorted[j])
+		}
+		i = j + 1
+	}
+	return sb.String()
+}
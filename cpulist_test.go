@@ -0,0 +1,79 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCPUList(t *testing.T) {
+	for _, tc := range []struct {
+		in        string
+		want      []uint32
+		formatted string
+	}{
+		{"", []uint32{}, ""},
+		{"5", []uint32{5}, "5"},
+		{"0-3,8,10-11\n", []uint32{0, 1, 2, 3, 8, 10, 11}, "0-3,8,10-11"},
+		{"7, 3-4 ,4-5", []uint32{3, 4, 5, 7}, "3-5,7"},
+		{"0-15:2/4", []uint32{0, 1, 4, 5, 8, 9, 12, 13}, "0-1,4-5,8-9,12-13"},
+		{"2-10:1/3,0", []uint32{0, 2, 5, 8}, "0,2,5,8"},
+		{"0-4:2/2", []uint32{0, 1, 2, 3, 4}, "0-4"},
+		{"0-4:0/2", []uint32{}, ""},
+	} {
+		got, err := ParseCPUList(tc.in)
+		if err != nil {
+			t.Errorf("ParseCPUList(%q) failed: %v", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseCPUList(%q) = %v; want %v", tc.in, got, tc.want)
+		}
+		if formatted := FormatCPUList(got); formatted != tc.formatted {
+			t.Errorf("FormatCPUList(%v) = %q; want %q", got, formatted, tc.formatted)
+		}
+	}
+
+	for _, in := range []string{"a", "1-", "-1", "3-1", "1,,2", "4:1/2", "0-7:1", "0-7:1/0", "0-7:3/2", "0-7:a/2"} {
+		if _, err := ParseCPUList(in); err == nil {
+			t.Errorf("ParseCPUList(%q) succeeded", in)
+		}
+	}
+
+	// Oversized cpulists are rejected without expanding them.
+	for _, in := range []string{"0-4294967295", fmt.Sprintf("0-%d", MaxCPUListSize), "0-40000,0-40000"} {
+		if _, err := ParseCPUList(in); err == nil {
+			t.Errorf("ParseCPUList(%q) succeeded", in)
+		}
+	}
+	if ids, err := ParseCPUList(fmt.Sprintf("0-%d", MaxCPUListSize-1)); err != nil || len(ids) != MaxCPUListSize {
+		t.Errorf("got %d OS indices (%v) for a cpulist of MaxCPUListSize entries", len(ids), err)
+	}
+
+	// Strided ranges only count the OS indices they select.
+	if ids, err := ParseCPUList(fmt.Sprintf("0-%d:1/2", 2*MaxCPUListSize-1)); err != nil || len(ids) != MaxCPUListSize {
+		t.Errorf("got %d OS indices (%v) for a strided cpulist of MaxCPUListSize entries", len(ids), err)
+	}
+	if _, err := ParseCPUList(fmt.Sprintf("0-%d:1/2", 2*MaxCPUListSize)); err == nil {
+		t.Errorf("ParseCPUList succeeded for a strided cpulist of MaxCPUListSize+1 entries")
+	}
+	if ids, err := ParseCPUList("0-4294967295:0/1"); err != nil || len(ids) != 0 {
+		t.Errorf("ParseCPUList(\"0-4294967295:0/1\") = %v, %v; want no OS indices", ids, err)
+	}
+}
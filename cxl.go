@@ -0,0 +1,106 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MemoryNodesOf returns the NodeIDs of the NUMA nodes that consist of memory
+// contributed by the Device element stored under the provided NodeID (e.g., a
+// CXL memory expander), in ascending order, or a non-nil error value if it is
+// not a Device.
+func (t *Topology) MemoryNodesOf(deviceID NodeID) ([]NodeID, error) {
+	data, err := t.Get(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if !data.IsDevice() {
+		return nil, fmt.Errorf("element %d is not a device", deviceID)
+	}
+	ret := make([]NodeID, 0, len(data.MemoryNodes))
+	if len(data.MemoryNodes) == 0 {
+		return ret, nil
+	}
+	contributed := make(map[uint32]bool, len(data.MemoryNodes))
+	for _, osID := range data.MemoryNodes {
+		contributed[osID] = true
+	}
+	for _, numaID := range t.NUMANodes() {
+		if contributed[t.Nodes[numaID].Data.ID] {
+			ret = append(ret, numaID)
+		}
+	}
+	return ret, nil
+}
+
+// MemoryDeviceOf returns the NodeID of the Device element that contributes the
+// memory of the NUMA node stored under the provided NodeID, along with true,
+// or false if its memory is not contributed by any Device (e.g., if it is
+// DRAM), or a non-nil error value if it is not a NUMA node.
+func (t *Topology) MemoryDeviceOf(numaID NodeID) (NodeID, bool, error) {
+	data, err := t.Get(numaID)
+	if err != nil {
+		return 0, false, err
+	}
+	if !data.IsProcessing() || data.Kind != NUMANode {
+		return 0, false, fmt.Errorf("element %d is not a NUMA node", numaID)
+	}
+	for _, deviceID := range t.Devices() {
+		for _, osID := range t.Nodes[deviceID].Data.MemoryNodes {
+			if osID == data.ID {
+				return deviceID, true, nil
+			}
+		}
+	}
+	return 0, false, nil
+}
+
+// CXLMemoryNode describes a NUMA node whose memory is contributed by a CXL
+// memory expander, along with the CXL link that it is accessed through, as
+// reported by CXLMemory.
+type CXLMemoryNode struct {
+	// NUMANode is the NodeID of the NUMA node.
+	NUMANode NodeID `json:"numa"`
+	// Device is the NodeID of the Device that contributes its memory.
+	Device NodeID `json:"device"`
+	// Bytes is the amount of memory of the NUMA node, or zero if it is
+	// unknown.
+	Bytes uint64 `json:"bytes,omitempty"`
+	// Link is the CXL link of the Device, if known.
+	Link *CXLLink `json:"link,omitempty"`
+}
+
+// CXLMemory returns the NUMA nodes of the Topology whose memory is contributed
+// by MemoryExpander Devices, in ascending NodeID order.
+func (t *Topology) CXLMemory() []CXLMemoryNode {
+	ret := make([]CXLMemoryNode, 0)
+	for _, deviceID := range t.Devices(MemoryExpander) {
+		device := t.Nodes[deviceID].Data
+		numaIDs, _ := t.MemoryNodesOf(deviceID)
+		for _, numaID := range numaIDs {
+			node := CXLMemoryNode{NUMANode: numaID, Device: deviceID, Link: device.CXLLink}
+			if mem := t.Nodes[numaID].Data.Memory; nil != mem {
+				node.Bytes = mem.Bytes
+			}
+			ret = append(ret, node)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].NUMANode < ret[j].NUMANode })
+	return ret
+}
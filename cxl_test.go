@@ -0,0 +1,104 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// cxlTree returns deviceTree with its memory-only NUMA node (NodeID 14)
+// backed by a CXL memory expander, attached under the Machine (NodeID 17).
+func cxlTree() *Tree {
+	tree := deviceTree()
+	tree.Nodes[14].Data.Memory = &MemoryAttributes{Bytes: 64 << 30, Tier: CXL}
+	tree.Nodes = append(tree.Nodes, TreeNode{Data: &Element{Device: &Device{
+		Type:        MemoryExpander,
+		BusID:       "0000:c0:00.0",
+		CXLLink:     &CXLLink{Version: "2.0", Width: 8, Bandwidth: 32e9, Latency: 250},
+		MemoryNodes: []uint32{2},
+	}}})
+	tree.Nodes[0].Children = append(tree.Nodes[0].Children, 17)
+	return tree
+}
+
+func TestCXLMemory(t *testing.T) {
+	const (
+		numa     NodeID = 10
+		cxlNUMA  NodeID = 14
+		expander NodeID = 17
+	)
+	topo := &Topology{cxlTree()}
+	if err := topo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := topo.MemoryNodesOf(expander); err != nil || !reflect.DeepEqual(got, []NodeID{cxlNUMA}) {
+		t.Errorf("got memory nodes %v (%v) of the expander; expected [%d]", got, err, cxlNUMA)
+	}
+	if got, err := topo.MemoryNodesOf(15); err != nil || len(got) != 0 {
+		t.Errorf("got memory nodes %v (%v) of the GPU; expected none", got, err)
+	}
+	if _, err := topo.MemoryNodesOf(numa); err == nil {
+		t.Error("MemoryNodesOf a NUMA node succeeded")
+	}
+	if got, ok, err := topo.MemoryDeviceOf(cxlNUMA); err != nil || !ok || got != expander {
+		t.Errorf("got device %d (%t, %v) of the CXL NUMA node; expected %d", got, ok, err, expander)
+	}
+	if _, ok, err := topo.MemoryDeviceOf(numa); err != nil || ok {
+		t.Errorf("got a device (%t, %v) of a DRAM NUMA node", ok, err)
+	}
+
+	want := []CXLMemoryNode{{NUMANode: cxlNUMA, Device: expander, Bytes: 64 << 30, Link: topo.Nodes[expander].Data.CXLLink}}
+	if got := topo.CXLMemory(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+
+	data, err := json.Marshal(topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Topology
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.Nodes[expander].Data.Device, topo.Nodes[expander].Data.Device; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after a round trip; expected %v", got, want)
+	}
+
+	// Dropping the NUMA node removes it from the memory nodes of the
+	// expander.
+	filtered, err := topo.Filter(func(e *Element) bool { return !e.IsProcessing() || e.Kind != NUMANode })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range filtered.Devices(MemoryExpander) {
+		if got := filtered.Nodes[id].Data.MemoryNodes; len(got) != 0 {
+			t.Errorf("got memory nodes %v after filtering out NUMA nodes", got)
+		}
+	}
+
+	for _, memoryNodes := range [][]uint32{{7}, {2, 2}} {
+		clone := topo.clone()
+		clone.Nodes[expander].Data.MemoryNodes = memoryNodes
+		if err := clone.Validate(); !errors.Is(err, ErrInvalidElement) {
+			t.Errorf("got %v for memory nodes %v; expected ErrInvalidElement", err, memoryNodes)
+		}
+	}
+}
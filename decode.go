@@ -0,0 +1,386 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DecodeLimits bounds the resources that a Decoder may spend on a single
+// payload. A zero value for any of its fields means that the corresponding
+// quantity is not limited.
+type DecodeLimits struct {
+	// MaxBytes is the maximum size of a payload, in bytes.
+	MaxBytes int64
+	// MaxNodes is the maximum number of TreeNodes in a payload.
+	MaxNodes int
+	// MaxDepth is the maximum depth of the decoded Tree, where the root
+	// Element is at depth 0.
+	MaxDepth int
+}
+
+// DefaultDecodeLimits are the DecodeLimits that the package enforces on
+// payloads from untrusted sources (e.g., the Topologies in an EtcdStore),
+// unless configured otherwise. They are generous enough for the Topologies of
+// the largest machines, yet bound the work that a hostile payload can cause.
+var DefaultDecodeLimits = DecodeLimits{MaxBytes: 64 << 20, MaxNodes: 1 << 20, MaxDepth: 64}
+
+// Decoder decodes Topologies from JSON payloads while enforcing the configured
+// DecodeLimits, to protect services that accept payloads from untrusted
+// sources against memory exhaustion.
+//
+// A Decoder may be reused to decode any number of payloads, and it is safe for
+// concurrent use by multiple goroutines. The intermediate buffers that it
+// needs for each payload are drawn from (and returned to) a pool that is
+// shared by all Decoders, to reduce the steady-state allocations of services
+// that decode payloads at a high rate.
+type Decoder struct {
+	// Limits are the DecodeLimits enforced on each payload.
+	Limits DecodeLimits
+	// Arena, if true, makes the Decoder store the Elements (and their
+	// Processing, Cache and CacheAttributes) and the children lists of each
+	// decoded Tree in a few contiguous slices, instead of one heap object
+	// per pointer, which considerably reduces the number of objects that
+	// the garbage collector has to scan for long-lived Topologies (e.g., in
+	// services that keep the Topologies of a whole cluster in memory).
+	//
+	// A Tree decoded in this mode behaves exactly like any other Tree.
+	Arena bool
+	// Reference, if non-nil, is a previous Topology of the same machine,
+	// against which each decoded Topology is renumbered, so that its
+	// unchanged Elements keep their NodeIDs (see Topology.RenumberLike).
+	//
+	// The Reference must not be modified while the Decoder is in use.
+	Reference *Topology
+}
+
+// NewDecoder returns a new Decoder that enforces the provided DecodeLimits.
+func NewDecoder(limits DecodeLimits) *Decoder {
+	return &Decoder{Limits: limits}
+}
+
+// Decode reads a JSON payload from the provided io.Reader and returns the
+// Topology decoded from it, or a non-nil error value if decoding fails, if
+// the Topology is not valid (see Tree.Validate), or if any of the Decoder's
+// DecodeLimits is exceeded (in which case the error wraps ErrLimitExceeded).
+//
+// The payload may be either a bare Tree or an Envelope, whose Metadata are
+// ignored (see DecodeEnvelope).
+func (d *Decoder) Decode(r io.Reader) (*Topology, error) {
+	topo, _, err := d.decode(r, false)
+	return topo, err
+}
+
+// decode implements Decode and DecodeEnvelope; the Metadata of the payload are
+// only decoded if withMetadata is true, and are nil for bare Tree payloads.
+func (d *Decoder) decode(r io.Reader, withMetadata bool) (*Topology, *Metadata, error) {
+	scratch := scratchPool.Get().(*decodeScratch)
+	defer scratch.release()
+
+	if d.Limits.MaxBytes > 0 {
+		r = io.LimitReader(r, d.Limits.MaxBytes+1)
+	}
+	if _, err := scratch.buf.ReadFrom(r); err != nil {
+		return nil, nil, err
+	}
+	data := scratch.buf.Bytes()
+	if d.Limits.MaxBytes > 0 && int64(len(data)) > d.Limits.MaxBytes {
+		return nil, nil, fmt.Errorf("%w: payload is larger than %d bytes", ErrLimitExceeded, d.Limits.MaxBytes)
+	}
+
+	nodes, offsets, err := decodeTreeNodes(data, d.Limits.MaxNodes, scratch.offsets[:0])
+	scratch.offsets = offsets
+	if err != nil {
+		return nil, nil, err
+	}
+	tree := NewTree(nodes)
+	if err = tree.validate(offsets); err != nil {
+		return nil, nil, err
+	}
+	if d.Limits.MaxDepth > 0 {
+		if depth := tree.depth(); depth > d.Limits.MaxDepth {
+			return nil, nil, fmt.Errorf("%w: Tree depth %d is greater than %d", ErrLimitExceeded, depth, d.Limits.MaxDepth)
+		}
+	}
+	if nil != d.Reference {
+		renumbered, err := (&Topology{Tree: tree}).RenumberLike(d.Reference)
+		if err != nil {
+			return nil, nil, err
+		}
+		tree = renumbered.Tree
+	}
+	if d.Arena {
+		tree.compact()
+	}
+	if !withMetadata {
+		return &Topology{Tree: tree}, nil, nil
+	}
+	var envelope struct {
+		Metadata *Metadata `json:"metadata"`
+	}
+	if err = json.Unmarshal(data, &envelope); err != nil {
+		return nil, nil, err
+	}
+	return &Topology{Tree: tree}, envelope.Metadata, nil
+}
+
+// decodeScratch holds the intermediate buffers that a Decoder needs for each
+// payload, none of which is referenced by the decoded Topology.
+type decodeScratch struct {
+	// buf holds the raw payload.
+	buf bytes.Buffer
+	// offsets holds the byte offset of each TreeNode in the payload.
+	offsets []int64
+}
+
+// maxPooledScratch is the maximum payload size, in bytes, whose buffers are
+// returned to scratchPool, so that a few huge payloads do not pin memory.
+const maxPooledScratch = 4 << 20
+
+// scratchPool is shared by all Decoders.
+var scratchPool = sync.Pool{
+	New: func() interface{} { return new(decodeScratch) },
+}
+
+// release returns the decodeScratch to scratchPool, unless it has grown too
+// large.
+func (s *decodeScratch) release() {
+	if s.buf.Cap() > maxPooledScratch {
+		return
+	}
+	s.buf.Reset()
+	s.offsets = s.offsets[:0]
+	scratchPool.Put(s)
+}
+
+// compact moves all Elements of the Tree, along with the objects they point
+// to and the children lists of the TreeNodes, into contiguous slices that are
+// allocated once per type.
+func (t *Tree) compact() {
+	var nProcessing, nCache, nAttrs, nDevices, nChildren int
+	for id := range t.Nodes {
+		data := t.Nodes[id].Data
+		if nil != data.Processing {
+			nProcessing++
+		}
+		if nil != data.Cache {
+			nCache++
+			if nil != data.Attributes {
+				nAttrs++
+			}
+		}
+		if nil != data.Device {
+			nDevices++
+		}
+		nChildren += len(t.Nodes[id].Children)
+	}
+
+	elements := make([]Element, len(t.Nodes))
+	processing := make([]Processing, 0, nProcessing)
+	caches := make([]Cache, 0, nCache)
+	attrs := make([]CacheAttributes, 0, nAttrs)
+	devices := make([]Device, 0, nDevices)
+	children := make([]NodeID, 0, nChildren)
+	for id := range t.Nodes {
+		data := t.Nodes[id].Data
+		if nil != data.Processing {
+			processing = append(processing, *data.Processing)
+			elements[id].Processing = &processing[len(processing)-1]
+		}
+		if nil != data.Cache {
+			caches = append(caches, *data.Cache)
+			elements[id].Cache = &caches[len(caches)-1]
+			if nil != data.Attributes {
+				attrs = append(attrs, *data.Attributes)
+				elements[id].Cache.Attributes = &attrs[len(attrs)-1]
+			}
+		}
+		if nil != data.Device {
+			devices = append(devices, *data.Device)
+			elements[id].Device = &devices[len(devices)-1]
+		}
+		t.Nodes[id].Data = &elements[id]
+
+		if len(t.Nodes[id].Children) > 0 {
+			start := len(children)
+			children = append(children, t.Nodes[id].Children...)
+			t.Nodes[id].Children = children[start:len(children):len(children)]
+		}
+	}
+}
+
+// decodeTreeNodes decodes the TreeNodes of the provided JSON payload one by
+// one, so that the node count limit (if positive) is enforced before the whole
+// payload has been materialized in memory. It also returns the byte offset of
+// each TreeNode in the payload, reusing the memory of the provided scratch
+// slice (which may be nil).
+//
+// Errors that concern a specific TreeNode are reported as a *NodeError.
+func decodeTreeNodes(data []byte, maxNodes int, scratch []int64) (nodes []TreeNode, offsets []int64, err error) {
+	offsets = scratch[:0]
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err = expectDelim(dec, '{'); err != nil {
+		return
+	}
+
+	for dec.More() {
+		var tok json.Token
+		if tok, err = dec.Token(); err != nil {
+			return
+		}
+		switch key, _ := tok.(string); key {
+		case "nodes":
+		case "topology":
+			// The payload is an Envelope; decode the Tree within.
+			if nodes, offsets, err = decodeEnvelopedTreeNodes(dec, data, maxNodes, offsets); err != nil {
+				return
+			}
+			continue
+		default:
+			// Skip the value of any unknown field.
+			var skipped json.RawMessage
+			if err = dec.Decode(&skipped); err != nil {
+				return
+			}
+			continue
+		}
+
+		// A null list of nodes is what an empty Tree is marshalled to.
+		if tok, err = dec.Token(); err != nil {
+			return
+		}
+		if tok == nil {
+			nodes, offsets = nil, offsets[:0]
+			continue
+		}
+		if d, ok := tok.(json.Delim); !ok || d != '[' {
+			err = fmt.Errorf("invalid JSON payload: expected '[' at offset %d", dec.InputOffset())
+			return
+		}
+		nodes, offsets = make([]TreeNode, 0), offsets[:0]
+		var raw json.RawMessage
+		for dec.More() {
+			id := NodeID(len(nodes))
+			if maxNodes > 0 && len(nodes) == maxNodes {
+				err = fmt.Errorf("%w: payload contains more than %d nodes", ErrLimitExceeded, maxNodes)
+				return
+			}
+			offset := dec.InputOffset()
+			if err = dec.Decode(&raw); err != nil {
+				err = &NodeError{ID: id, Offset: offset, Err: err}
+				return
+			}
+			// Account for any whitespace and separators preceding the
+			// TreeNode.
+			offset += int64(bytes.Index(data[offset:], raw))
+
+			nodes = append(nodes, TreeNode{})
+			offsets = append(offsets, offset)
+			if err = json.Unmarshal(raw, &nodes[id]); err != nil {
+				err = &NodeError{ID: id, Kind: peekElementKind(raw), Offset: offset, Err: err}
+				return
+			}
+		}
+		if err = expectDelim(dec, ']'); err != nil {
+			return
+		}
+	}
+	err = expectDelim(dec, '}')
+	return
+}
+
+// decodeEnvelopedTreeNodes decodes the TreeNodes of the Tree within the
+// "topology" field of an Envelope, which the provided json.Decoder is about to
+// decode, as decodeTreeNodes does; the returned offsets, including those of any
+// NodeError, refer to the whole payload.
+func decodeEnvelopedTreeNodes(
+	dec *json.Decoder,
+	data []byte,
+	maxNodes int,
+	scratch []int64,
+) (nodes []TreeNode, offsets []int64, err error) {
+	base := dec.InputOffset()
+	var raw json.RawMessage
+	if err = dec.Decode(&raw); err != nil {
+		return
+	}
+	base += int64(bytes.Index(data[base:], raw))
+
+	nodes, offsets, err = decodeTreeNodes(raw, maxNodes, scratch)
+	var nodeErr *NodeError
+	if errors.As(err, &nodeErr) && nodeErr.Offset >= 0 {
+		nodeErr.Offset += base
+	}
+	for i := range offsets {
+		offsets[i] += base
+	}
+	return
+}
+
+// peekElementKind returns a description of the kind of the Element in the
+// provided raw TreeNode (i.e., "Machine", "Processing", "Cache" or "Device"),
+// on a best effort basis, to be used as the Kind of a NodeError when the
+// Element itself could not be unmarshalled.
+func peekElementKind(raw json.RawMessage) string {
+	var node struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &node); err != nil {
+		return ""
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(node.Data, &obj); err != nil {
+		if bytes.EqualFold(bytes.TrimSpace(node.Data), []byte(`"machine"`)) {
+			return "Machine"
+		}
+		return ""
+	}
+	switch {
+	case obj["processing"] != nil:
+		return "Processing"
+	case obj["cache"] != nil:
+		return "Cache"
+	case obj["device"] != nil:
+		return "Device"
+	default:
+		return ""
+	}
+}
+
+// expectDelim reads the next JSON token from the provided json.Decoder, and
+// returns a non-nil error value if it is not the expected delimiter.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("invalid JSON payload: expected '%s' at offset %d", delim, dec.InputOffset())
+	}
+	return nil
+}
+
+// depth returns the depth of the Tree (i.e., the maximum depth of any of its
+// Elements, where the root Element is at depth 0).
+func (t *Tree) depth() int {
+	return t.Hierarchy.getIndexes().height
+}
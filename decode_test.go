@@ -0,0 +1,141 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestDecoderLimits(t *testing.T) {
+	const IN_FILE_PATH = "test_artifacts/t4_de.json"
+
+	data, err := os.ReadFile(IN_FILE_PATH)
+	if err != nil {
+		t.Fatalf("Error reading from file until EOF: %v\n", err)
+	}
+
+	topo, err := NewDecoder(DecodeLimits{}).Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode without limits: %v\n", err)
+	}
+	if topo.Size() != 41 {
+		t.Errorf("decoded %d nodes; want 41", topo.Size())
+	}
+	if _, err = NewDecoder(DecodeLimits{MaxBytes: int64(len(data)), MaxNodes: 41, MaxDepth: 4}).
+		Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("Failed to decode within limits: %v\n", err)
+	}
+
+	for name, limits := range map[string]DecodeLimits{
+		"bytes": {MaxBytes: int64(len(data)) - 1},
+		"nodes": {MaxNodes: 40},
+		"depth": {MaxDepth: 3},
+	} {
+		_, err = NewDecoder(limits).Decode(bytes.NewReader(data))
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%s: got %v; want ErrLimitExceeded", name, err)
+		}
+	}
+
+	if _, err = NewDecoder(DecodeLimits{}).Decode(bytes.NewReader([]byte(`["nodes"]`))); err == nil {
+		t.Errorf("decoding a JSON array succeeded")
+	}
+}
+
+func TestDecoderArena(t *testing.T) {
+	data, err := json.Marshal(syntheticTree(2, 2, 8, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	topo, err := (&Decoder{Arena: true}).Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode in arena mode: %v\n", err)
+	}
+	remarshaled, err := json.Marshal(topo)
+	if err != nil {
+		t.Fatalf("Failed to remarshal the Topology: %v\n", err)
+	}
+	if !bytes.Equal(remarshaled, data) {
+		t.Errorf("arena-decoded Topology differs from the original:\n%s", remarshaled)
+	}
+
+	// Appending to a children list must not clobber its neighbour's.
+	children := topo.Nodes[1].Children
+	next := topo.Nodes[children[0]].Children[0]
+	topo.Nodes[1].Children = append(children, 12345)
+	if topo.Nodes[children[0]].Children[0] != next {
+		t.Errorf("appending to a children list modified another one")
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data, err := json.Marshal(syntheticTree(2, 2, 32, 2))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, arena := range []bool{false, true} {
+		b.Run(fmt.Sprintf("arena=%t", arena), func(b *testing.B) {
+			dec := &Decoder{Arena: arena}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := dec.Decode(bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoderConcurrentUse(t *testing.T) {
+	payloads := make([][]byte, 0, 4)
+	for cores := 1; cores <= 4; cores++ {
+		data, err := json.Marshal(syntheticTree(1, 2, cores, 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, data)
+	}
+
+	dec := NewDecoder(DecodeLimits{})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				data := payloads[(g+i)%len(payloads)]
+				topo, err := dec.Decode(bytes.NewReader(data))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				remarshaled, err := json.Marshal(topo)
+				if err != nil || !bytes.Equal(remarshaled, data) {
+					t.Errorf("decoded Topology differs from its payload (%v)", err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
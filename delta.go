@@ -0,0 +1,201 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// Delta describes the changes that turn a snapshot of a Topology into a newer
+// one, so that periodic snapshots, which are identical to the previous ones
+// most of the time, can be published compactly (see EncodeDelta).
+type Delta struct {
+	// Base and Result are the hashes of the serialized Topologies that the
+	// Delta applies to and results in, respectively.
+	Base   Hash `json:"base"`
+	Result Hash `json:"result"`
+	// Size is the number of Elements of the resulting Topology.
+	Size int `json:"size"`
+	// Nodes maps the NodeIDs of all TreeNodes of the resulting Topology
+	// that are not identical to the TreeNodes stored under the same
+	// NodeIDs in the base one.
+	Nodes map[NodeID]TreeNode `json:"nodes,omitempty"`
+}
+
+// IsEmpty returns true if the Delta does not change its base Topology.
+func (d *Delta) IsEmpty() bool {
+	return d.Base == d.Result
+}
+
+// EncodeDelta returns the Delta that turns the Topology before into the
+// Topology after, or a non-nil error value in case of failure.
+//
+// The Delta consists of the TreeNodes that differ between the Topologies,
+// position by position, so it is compact as long as the NodeIDs of unchanged
+// Elements are stable across snapshots, as they are for snapshots of the same
+// machine by the same collector. The Delta between identical Topologies
+// consists of their hashes only.
+func EncodeDelta(before, after *Topology) (*Delta, error) {
+	base, err := before.contentHash()
+	if err != nil {
+		return nil, fmt.Errorf("base Topology: %w", err)
+	}
+	result, err := after.contentHash()
+	if err != nil {
+		return nil, fmt.Errorf("resulting Topology: %w", err)
+	}
+	delta := &Delta{Base: base, Result: result, Size: after.Size()}
+	if base == result {
+		return delta, nil
+	}
+
+	delta.Nodes = make(map[NodeID]TreeNode)
+	for id := range after.Nodes {
+		if id < before.Size() && equalTreeNodes(&before.Nodes[id], &after.Nodes[id]) {
+			continue
+		}
+		delta.Nodes[NodeID(id)] = cloneTreeNode(&after.Nodes[id])
+	}
+	return delta, nil
+}
+
+// ApplyDelta returns the Topology that results from applying the provided
+// Delta to the provided base Topology, which is not modified, or a non-nil
+// error value if the Delta does not apply to the base Topology or does not
+// result in a valid Topology.
+func ApplyDelta(before *Topology, delta *Delta) (*Topology, error) {
+	base, err := before.contentHash()
+	if err != nil {
+		return nil, fmt.Errorf("base Topology: %w", err)
+	}
+	if base != delta.Base {
+		return nil, fmt.Errorf("Delta does not apply to the base Topology: expected %s, got %s", delta.Base, base)
+	}
+	// Every Element of the resulting Topology is either carried over from
+	// the base one or listed in the Delta, so a larger Size (e.g., from a
+	// crafted Delta) is rejected before allocating anything.
+	if delta.Size < 0 || delta.Size > before.Size()+len(delta.Nodes) {
+		return nil, fmt.Errorf("invalid Delta size %d: expected at most %d", delta.Size, before.Size()+len(delta.Nodes))
+	}
+
+	for id := range delta.Nodes {
+		if int(id) >= delta.Size {
+			return nil, ErrInvalidNodeID{ID: id}
+		}
+	}
+	nodes := make([]TreeNode, delta.Size)
+	for id := range nodes {
+		if node, ok := delta.Nodes[NodeID(id)]; ok {
+			nodes[id] = cloneTreeNode(&node)
+		} else if id < before.Size() {
+			nodes[id] = cloneTreeNode(&before.Nodes[id])
+		} else {
+			return nil, fmt.Errorf("Delta lacks the new element %d", id)
+		}
+	}
+
+	after := &Topology{Tree: NewTree(nodes)}
+	if err = after.Validate(); err != nil {
+		return nil, fmt.Errorf("Delta results in an invalid Topology: %w", err)
+	}
+	result, err := after.contentHash()
+	if err != nil {
+		return nil, err
+	}
+	if result != delta.Result {
+		return nil, fmt.Errorf("Delta results in an unexpected Topology: expected %s, got %s", delta.Result, result)
+	}
+	return after, nil
+}
+
+// contentHash returns the hash of the Topology serialized in JSON, which,
+// unlike its Fingerprint, depends on the exact order of its Elements.
+func (t *Topology) contentHash() (Hash, error) {
+	if nil == t || nil == t.Tree {
+		return Hash{}, ErrNilTree
+	}
+	data, err := json.Marshal(t.Tree)
+	if err != nil {
+		return Hash{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// equalTreeNodes returns true if the provided TreeNodes are serialized
+// identically.
+func equalTreeNodes(a, b *TreeNode) bool {
+	if len(a.Children) != len(b.Children) {
+		return false
+	}
+	for i := range a.Children {
+		if a.Children[i] != b.Children[i] {
+			return false
+		}
+	}
+	if nil == a.Data || nil == b.Data {
+		return a.Data == b.Data
+	}
+	aData, aErr := a.Data.MarshalJSON()
+	bData, bErr := b.Data.MarshalJSON()
+	return nil == aErr && nil == bErr && bytes.Equal(aData, bData)
+}
+
+// cloneTreeNode returns a deep copy of the provided TreeNode, so that
+// Topologies related through a Delta never share any memory.
+func cloneTreeNode(node *TreeNode) TreeNode {
+	clone := TreeNode{Children: append([]NodeID(nil), node.Children...)}
+	if nil == node.Data {
+		return clone
+	}
+	clone.Data = &Element{}
+	if nil != node.Data.Processing {
+		processing := *node.Data.Processing
+		if nil != processing.Memory {
+			mem := *processing.Memory
+			if nil != mem.Distances {
+				mem.Distances = make(map[uint32]uint32, len(processing.Memory.Distances))
+				for osID, dist := range processing.Memory.Distances {
+					mem.Distances[osID] = dist
+				}
+			}
+			processing.Memory = &mem
+		}
+		clone.Data.Processing = &processing
+	}
+	if nil != node.Data.Cache {
+		cache := *node.Data.Cache
+		if nil != cache.Attributes {
+			attrs := *cache.Attributes
+			cache.Attributes = &attrs
+		}
+		clone.Data.Cache = &cache
+	}
+	if nil != node.Data.Device {
+		device := *node.Data.Device
+		device.Links = append([]DeviceLink(nil), device.Links...)
+		device.MemoryNodes = append([]uint32(nil), device.MemoryNodes...)
+		if nil != device.CXLLink {
+			link := *device.CXLLink
+			device.CXLLink = &link
+		}
+		clone.Data.Device = &device
+	}
+	return clone
+}
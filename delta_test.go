@@ -0,0 +1,94 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDelta(t *testing.T) {
+	before := &Topology{syntheticTree(2, 1, 4, 2)}
+	same := &Topology{syntheticTree(2, 1, 4, 2)}
+	delta, err := EncodeDelta(before, same)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !delta.IsEmpty() || len(delta.Nodes) != 0 {
+		t.Errorf("got a non-empty Delta between identical Topologies: %+v", delta)
+	}
+	if data, _ := json.Marshal(delta); len(data) > 200 {
+		t.Errorf("the empty Delta is %d bytes long:\n%s", len(data), data)
+	}
+	if result, err := ApplyDelta(before, delta); err != nil || result.Size() != before.Size() {
+		t.Errorf("got %v when applying an empty Delta", err)
+	}
+
+	// Resize an L3 cache and add a Core (with its caches and threads).
+	after := &Topology{syntheticTree(2, 1, 5, 2)}
+	after.Nodes[3].Data.Attributes.Size *= 2
+	if delta, err = EncodeDelta(before, after); err != nil {
+		t.Fatal(err)
+	}
+	if delta.IsEmpty() || len(delta.Nodes) >= after.Size() {
+		t.Errorf("got a Delta of %d nodes for a Topology of %d", len(delta.Nodes), after.Size())
+	}
+	data, err := json.Marshal(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Delta
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal Delta: %v\n%s", err, data)
+	}
+	result, err := ApplyDelta(before, &decoded)
+	if err != nil {
+		t.Fatalf("Failed to apply Delta: %v\n", err)
+	}
+	resultData, _ := json.Marshal(result)
+	afterData, _ := json.Marshal(after)
+	if string(resultData) != string(afterData) {
+		t.Errorf("the Delta does not result in the new Topology")
+	}
+	if before.Nodes[3].Data.Attributes.Size == after.Nodes[3].Data.Attributes.Size {
+		t.Errorf("applying the Delta modified the base Topology")
+	}
+
+	// Deltas only apply to their base Topology, and must be consistent.
+	if _, err = ApplyDelta(after, &decoded); err == nil {
+		t.Errorf("expected an error when applying a Delta to another Topology")
+	}
+	decoded.Result[0]++
+	if _, err = ApplyDelta(before, &decoded); err == nil {
+		t.Errorf("expected an error when the result of a Delta is unexpected")
+	}
+	decoded.Size = 3
+	if _, err = ApplyDelta(before, &decoded); err == nil {
+		t.Errorf("expected an error for a Delta with nodes beyond its size")
+	}
+
+	// Hostile sizes are rejected before allocating the resulting Topology.
+	for _, size := range []int{-1, before.Size() + len(decoded.Nodes) + 1, math.MaxInt} {
+		hostile := decoded
+		hostile.Size = size
+		if _, err = ApplyDelta(before, &hostile); err == nil || !strings.Contains(err.Error(), "invalid Delta size") {
+			t.Errorf("got %v for a Delta of size %d; expected an invalid size", err, size)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Devices returns a list of all NodeIDs that correspond to a Device element of
+// any of the provided types in the hierarchical hardware topology, or of any
+// type at all if none is provided.
+//
+// The list is served from the Tree's secondary indexes (see
+// InvalidateIndexes), and is owned by the caller.
+func (t *Topology) Devices(types ...DeviceType) []NodeID {
+	ret := make([]NodeID, 0)
+	for _, id := range t.getIndexes().devices {
+		if len(types) == 0 {
+			ret = append(ret, id)
+			continue
+		}
+		for _, typ := range types {
+			if t.Nodes[id].Data.Type == typ {
+				ret = append(ret, id)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// LocalThreads returns the NodeIDs of the hardware threads that are closest to
+// the Device element stored under the provided NodeID (i.e., the threads in
+// the subtree of its closest ancestor that has any), in ascending order, or a
+// non-nil error value in case of failure.
+//
+// Devices whose locality is unknown are attached under the Machine, and hence
+// all threads of the Topology are local to them.
+func (t *Topology) LocalThreads(deviceID NodeID) ([]NodeID, error) {
+	ancestorIDs, err := t.deviceAncestorIDs(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	threadIDs := t.getIndexes().processing[Thread]
+	for _, ancestorID := range ancestorIDs {
+		start, end, err := t.SubtreeRange(ancestorID)
+		if err != nil {
+			return nil, err
+		}
+		first := sort.Search(len(threadIDs), func(i int) bool { return threadIDs[i] >= start })
+		last := sort.Search(len(threadIDs), func(i int) bool { return threadIDs[i] >= end })
+		if first < last {
+			return append(make([]NodeID, 0, last-first), threadIDs[first:last]...), nil
+		}
+	}
+	return nil, fmt.Errorf("no hardware threads are local to device %d", deviceID)
+}
+
+// LocalPackage returns the NodeID of the Package that the Device element
+// stored under the provided NodeID is local to, or a non-nil error value in
+// case of failure (e.g., if its locality is unknown).
+func (t *Topology) LocalPackage(deviceID NodeID) (NodeID, error) {
+	ancestorIDs, err := t.deviceAncestorIDs(deviceID)
+	if err != nil {
+		return 0, err
+	}
+	for _, ancestorID := range ancestorIDs {
+		if ancestor := t.Nodes[ancestorID].Data; ancestor.IsProcessing() && ancestor.Kind == Package {
+			return ancestorID, nil
+		}
+	}
+	return 0, fmt.Errorf("device %d is not local to any Package", deviceID)
+}
+
+// VirtualFunctions returns the NodeIDs of the SR-IOV virtual functions of the
+// Device element stored under the provided NodeID, in ascending order, or a
+// non-nil error value if it is not a Device.
+func (t *Topology) VirtualFunctions(pfID NodeID) ([]NodeID, error) {
+	data, err := t.Get(pfID)
+	if err != nil {
+		return nil, err
+	}
+	if !data.IsDevice() {
+		return nil, fmt.Errorf("element %d is not a device", pfID)
+	}
+	ret := make([]NodeID, 0)
+	for _, child := range t.Nodes[pfID].Children {
+		if vf := t.Nodes[child].Data; vf.IsDevice() && vf.IsVirtualFunction() && vf.PhysicalFunction == data.BusID {
+			ret = append(ret, child)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret, nil
+}
+
+// PhysicalFunctionID returns the NodeID of the SR-IOV physical function of the
+// virtual function stored under the provided NodeID, or a non-nil error value
+// if it is not a virtual function.
+func (t *Topology) PhysicalFunctionID(vfID NodeID) (NodeID, error) {
+	data, err := t.Get(vfID)
+	if err != nil {
+		return 0, err
+	}
+	if !data.IsDevice() || !data.IsVirtualFunction() {
+		return 0, fmt.Errorf("element %d is not a virtual function", vfID)
+	}
+	pfID, err := t.ParentID(vfID)
+	if err != nil {
+		return 0, err
+	}
+	if pf := t.Nodes[pfID].Data; !pf.IsDevice() || pf.BusID != data.PhysicalFunction {
+		return 0, fmt.Errorf("virtual function %d is not attached under its physical function %s", vfID, data.PhysicalFunction)
+	}
+	return pfID, nil
+}
+
+// VFLocality describes the locality of an SR-IOV virtual function, as reported
+// by Topology.VFLocalities (e.g., to a device plugin that assigns virtual
+// functions to Pods).
+type VFLocality struct {
+	// VF is the NodeID of the virtual function.
+	VF NodeID `json:"vf"`
+	// BusID is the PCI bus ID of the virtual function.
+	BusID string `json:"bus"`
+	// PF is the NodeID of its physical function.
+	PF NodeID `json:"pf"`
+	// NUMANodes lists the NodeIDs of its local NUMA nodes (see
+	// LocalNUMANodes).
+	NUMANodes []NodeID `json:"numa_nodes"`
+	// Threads lists the NodeIDs of its local hardware threads (see
+	// LocalThreads).
+	Threads []NodeID `json:"threads"`
+}
+
+// VFLocalities returns the locality of all SR-IOV virtual functions of the
+// Topology, in ascending NodeID order, or a non-nil error value in case of
+// failure.
+func (t *Topology) VFLocalities() ([]VFLocality, error) {
+	ret := make([]VFLocality, 0)
+	for _, id := range t.getIndexes().devices {
+		if !t.Nodes[id].Data.IsVirtualFunction() {
+			continue
+		}
+		vf := VFLocality{VF: id, BusID: t.Nodes[id].Data.BusID}
+		var err error
+		if vf.PF, err = t.PhysicalFunctionID(id); err != nil {
+			return nil, err
+		}
+		if vf.NUMANodes, err = t.LocalNUMANodes(id); err != nil {
+			return nil, err
+		}
+		if vf.Threads, err = t.LocalThreads(id); err != nil {
+			return nil, err
+		}
+		ret = append(ret, vf)
+	}
+	return ret, nil
+}
+
+// deviceAncestorIDs returns the NodeIDs of the ancestors of the Device element
+// stored under the provided NodeID that are not Devices themselves (i.e.,
+// starting from the element it is attached to), or a non-nil error value if it
+// is not a Device.
+func (t *Topology) deviceAncestorIDs(deviceID NodeID) ([]NodeID, error) {
+	data, err := t.Get(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if !data.IsDevice() {
+		return nil, fmt.Errorf("element %d is not a device", deviceID)
+	}
+	ancestorIDs, err := t.AncestorIDs(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	for i, ancestorID := range ancestorIDs {
+		if !t.Nodes[ancestorID].Data.IsDevice() {
+			return ancestorIDs[i:], nil
+		}
+	}
+	return nil, ErrOrphan
+}
+
+// LocalNUMANodes returns the NodeIDs of the NUMA nodes that are closest to the
+// Device element stored under the provided NodeID, in ascending order, or a
+// non-nil error value in case of failure: the NUMA node that it is attached
+// to, if any, or otherwise the NUMA nodes in the subtree of its closest
+// ancestor that has any (e.g., all NUMA nodes of its Package).
+func (t *Topology) LocalNUMANodes(deviceID NodeID) ([]NodeID, error) {
+	ancestorIDs, err := t.deviceAncestorIDs(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	numaIDs := t.getIndexes().processing[NUMANode]
+	for _, ancestorID := range ancestorIDs {
+		if ancestor := t.Nodes[ancestorID].Data; ancestor.IsProcessing() && ancestor.Kind == NUMANode {
+			return []NodeID{ancestorID}, nil
+		}
+		start, end, err := t.SubtreeRange(ancestorID)
+		if err != nil {
+			return nil, err
+		}
+		first := sort.Search(len(numaIDs), func(i int) bool { return numaIDs[i] >= start })
+		last := sort.Search(len(numaIDs), func(i int) bool { return numaIDs[i] >= end })
+		if first < last {
+			return append(make([]NodeID, 0, last-first), numaIDs[first:last]...), nil
+		}
+	}
+	return nil, fmt.Errorf("no NUMA nodes are local to device %d", deviceID)
+}
+
+// DeviceEdge is a direct interconnect link between two Device elements of a
+// Topology (see Topology.DeviceLinks).
+type DeviceEdge struct {
+	// From is the NodeID of the Device that lists the link.
+	From NodeID `json:"from"`
+	// To is the NodeID of the Device at the other end of the link.
+	To NodeID `json:"to"`
+	// Interconnect is the kind of the link (see DeviceLink).
+	Interconnect string `json:"type,omitempty"`
+	// Bandwidth is the bandwidth of the link, in bytes per second, or zero
+	// if it is unknown.
+	Bandwidth uint64 `json:"bw,omitempty"`
+}
+
+// DeviceLinks returns the edge list of the direct interconnect links among the
+// Device elements of the Topology (see Device), in ascending order of the
+// NodeIDs of the Devices that list them. Links to peers that are not part of
+// the Topology are omitted.
+func (t *Topology) DeviceLinks() []DeviceEdge {
+	byBusID := t.devicesByBusID()
+	ret := make([]DeviceEdge, 0)
+	for _, id := range t.getIndexes().devices {
+		for _, link := range t.Nodes[id].Data.Links {
+			if peer, ok := byBusID[link.Peer]; ok {
+				ret = append(ret, DeviceEdge{From: id, To: peer, Interconnect: link.Interconnect, Bandwidth: link.Bandwidth})
+			}
+		}
+	}
+	return ret
+}
+
+// PeerDevices returns the NodeIDs of the Device elements that are directly
+// linked to the Device element stored under the provided NodeID (see
+// DeviceLinks), through links listed by either end, in ascending order, or a
+// non-nil error value if it is not a Device.
+func (t *Topology) PeerDevices(deviceID NodeID) ([]NodeID, error) {
+	data, err := t.Get(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if !data.IsDevice() {
+		return nil, fmt.Errorf("element %d is not a device", deviceID)
+	}
+	seen := make(map[NodeID]bool)
+	ret := make([]NodeID, 0)
+	for _, edge := range t.DeviceLinks() {
+		peer := edge.To
+		switch {
+		case edge.To == deviceID:
+			peer = edge.From
+		case edge.From != deviceID:
+			continue
+		}
+		if !seen[peer] {
+			seen[peer] = true
+			ret = append(ret, peer)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret, nil
+}
+
+// LinkBandwidth returns the total bandwidth (in bytes per second) of the direct
+// interconnect links between the Device elements stored under the provided
+// NodeIDs, which is zero if there are none, or a non-nil error value if either
+// is not a Device.
+func (t *Topology) LinkBandwidth(a, b NodeID) (uint64, error) {
+	for _, id := range []NodeID{a, b} {
+		data, err := t.Get(id)
+		if err != nil {
+			return 0, err
+		}
+		if !data.IsDevice() {
+			return 0, fmt.Errorf("element %d is not a device", id)
+		}
+	}
+	var bandwidth uint64
+	for _, edge := range t.DeviceLinks() {
+		if (edge.From == a && edge.To == b) || (edge.From == b && edge.To == a) {
+			bandwidth += edge.Bandwidth
+		}
+	}
+	return bandwidth, nil
+}
+
+// devicesByBusID maps the PCI bus IDs of the Device elements of the Topology to
+// their NodeIDs.
+func (t *Topology) devicesByBusID() map[string]NodeID {
+	ret := make(map[string]NodeID)
+	for _, id := range t.getIndexes().devices {
+		if busID := t.Nodes[id].Data.BusID; busID != "" {
+			ret[busID] = id
+		}
+	}
+	return ret
+}
@@ -0,0 +1,240 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// deviceTree returns a Tree of two Packages with a NUMA node each, plus a
+// memory-only NUMA node on the second one, with a NIC local to the first NUMA
+// node (NodeID 6) along with two SR-IOV virtual functions of it (NodeIDs 7 and
+// 8), a GPU local to the memory-only NUMA node (NodeID 15) and a storage
+// device of unknown locality (NodeID 16).
+func deviceTree() *Tree {
+	tree := NewTree([]TreeNode{{Data: &Element{}}})
+	add := func(parent NodeID, data *Element) NodeID {
+		id := NodeID(len(tree.Nodes))
+		tree.Nodes = append(tree.Nodes, TreeNode{Data: data})
+		tree.Nodes[parent].Children = append(tree.Nodes[parent].Children, id)
+		return id
+	}
+	processing := func(kind ProcessingKind, id uint32) *Element {
+		return &Element{Processing: &Processing{Kind: kind, ID: id}}
+	}
+	device := func(typ DeviceType, name, busID string) *Element {
+		return &Element{Device: &Device{Type: typ, Name: name, BusID: busID}}
+	}
+
+	for p := uint32(0); p < 2; p++ {
+		pkg := add(0, processing(Package, p))
+		numa := add(pkg, processing(NUMANode, p))
+		core := add(numa, processing(Core, 0))
+		add(core, processing(Thread, 2*p))
+		add(core, processing(Thread, 2*p+1))
+		if p == 0 {
+			nic := add(numa, device(NIC, "eth0", "0000:18:00.0"))
+			for _, busID := range []string{"0000:18:00.1", "0000:18:00.2"} {
+				vf := device(NIC, "", busID)
+				vf.PhysicalFunction = "0000:18:00.0"
+				add(nic, vf)
+			}
+			continue
+		}
+		numa = add(pkg, processing(NUMANode, 2))
+		add(numa, device(GPU, "nvidia0", "0000:af:00.0"))
+	}
+	add(0, device(Storage, "nvme0n1", ""))
+	return tree
+}
+
+func TestDeviceLocality(t *testing.T) {
+	topo := &Topology{deviceTree()}
+	if err := topo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	const nic, gpu, storage = 6, 15, 16
+	if got, want := topo.Devices(), []NodeID{nic, 7, 8, gpu, storage}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Devices() = %v; expected %v", got, want)
+	}
+	if got, want := topo.Devices(GPU, Storage), []NodeID{gpu, storage}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Devices(GPU, Storage) = %v; expected %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		id      NodeID
+		threads []NodeID
+		pkg     NodeID
+	}{
+		{nic, []NodeID{4, 5}, 1},
+		// The memory-only NUMA node of the GPU has no threads.
+		{gpu, []NodeID{12, 13}, 9},
+		{storage, topo.Threads(), 0},
+	} {
+		if got, err := topo.LocalThreads(tc.id); err != nil || !reflect.DeepEqual(got, tc.threads) {
+			t.Errorf("LocalThreads(%d) = %v, %v; expected %v", tc.id, got, err, tc.threads)
+		}
+		got, err := topo.LocalPackage(tc.id)
+		if tc.pkg == 0 {
+			if err == nil {
+				t.Errorf("LocalPackage(%d) = %d; expected an error for unknown locality", tc.id, got)
+			}
+		} else if err != nil || got != tc.pkg {
+			t.Errorf("LocalPackage(%d) = %d, %v; expected %d", tc.id, got, err, tc.pkg)
+		}
+	}
+	if _, err := topo.LocalThreads(4); err == nil {
+		t.Errorf("expected an error for a thread")
+	}
+
+	// Devices survive a round trip through JSON and a clone, and are
+	// identified by their bus ID.
+	data, err := json.Marshal(topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Topology
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.Nodes[gpu].Data.String(), "GPU(nvidia0, 0000:af:00.0)"; got != want {
+		t.Errorf("got %s after a round trip; expected %s", got, want)
+	}
+	if key, err := decoded.ElementKey(gpu); err != nil || key != "gpu:0000:af:00.0" {
+		t.Errorf("ElementKey(%d) = %q, %v; expected \"gpu:0000:af:00.0\"", gpu, key, err)
+	}
+	clone := topo.clone()
+	clone.Nodes[nic].Data.Name = "eth1"
+	if topo.Nodes[nic].Data.Name != "eth0" {
+		t.Errorf("modifying a clone modified the original")
+	}
+
+	// Devices may only have other Devices as children.
+	topo.Nodes[nic].Children = []NodeID{4}
+	if err = topo.Validate(); !errors.Is(err, ErrInvalidElement) {
+		t.Errorf("got %v for a thread under a device; expected ErrInvalidElement", err)
+	}
+}
+
+func TestVirtualFunctions(t *testing.T) {
+	topo := &Topology{deviceTree()}
+	const nic, vf1, vf2, gpu = 6, 7, 8, 15
+	if got, err := topo.VirtualFunctions(nic); err != nil || !reflect.DeepEqual(got, []NodeID{vf1, vf2}) {
+		t.Errorf("VirtualFunctions(%d) = %v, %v; expected [%d %d]", nic, got, err, vf1, vf2)
+	}
+	if got, err := topo.VirtualFunctions(gpu); err != nil || len(got) != 0 {
+		t.Errorf("VirtualFunctions(%d) = %v, %v; expected none", gpu, got, err)
+	}
+	if got, err := topo.PhysicalFunctionID(vf2); err != nil || got != nic {
+		t.Errorf("PhysicalFunctionID(%d) = %d, %v; expected %d", vf2, got, err, nic)
+	}
+	if _, err := topo.PhysicalFunctionID(nic); err == nil {
+		t.Errorf("expected an error for a physical function")
+	}
+
+	localities, err := topo.VFLocalities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VFLocality{
+		{VF: vf1, BusID: "0000:18:00.1", PF: nic, NUMANodes: []NodeID{2}, Threads: []NodeID{4, 5}},
+		{VF: vf2, BusID: "0000:18:00.2", PF: nic, NUMANodes: []NodeID{2}, Threads: []NodeID{4, 5}},
+	}
+	if !reflect.DeepEqual(localities, want) {
+		t.Errorf("VFLocalities() = %+v; expected %+v", localities, want)
+	}
+	if got, want := topo.Nodes[vf1].Data.String(), "NIC(0000:18:00.1, VF of 0000:18:00.0)"; got != want {
+		t.Errorf("got %s; expected %s", got, want)
+	}
+
+	// The owner link survives a round trip through JSON, and must refer
+	// to the parent of the virtual function.
+	data, err := json.Marshal(topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Topology
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Nodes[vf2].Data.PhysicalFunction; got != "0000:18:00.0" {
+		t.Errorf("got physical function %q after a round trip; expected \"0000:18:00.0\"", got)
+	}
+	topo.Nodes[vf2].Data.PhysicalFunction = "0000:18:00.7"
+	if err = topo.Validate(); !errors.Is(err, ErrInvalidElement) {
+		t.Errorf("got %v for a virtual function of another device; expected ErrInvalidElement", err)
+	}
+}
+
+func TestPeerDevices(t *testing.T) {
+	const (
+		nic     NodeID = 6
+		vf1     NodeID = 7
+		gpu     NodeID = 15
+		storage NodeID = 16
+	)
+	topo := &Topology{deviceTree()}
+	topo.Nodes[gpu].Data.Links = []DeviceLink{
+		{Peer: "0000:18:00.0", Interconnect: "pcie-p2p", Bandwidth: 16e9},
+		{Peer: "0000:18:00.0", Interconnect: "pcie-p2p", Bandwidth: 16e9},
+		{Peer: "0000:ff:00.0", Interconnect: "nvlink", Bandwidth: 25e9},
+	}
+	topo.Nodes[nic].Data.Links = []DeviceLink{{Peer: "0000:18:00.1"}}
+	if err := topo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []DeviceEdge{
+		{From: nic, To: vf1},
+		{From: gpu, To: nic, Interconnect: "pcie-p2p", Bandwidth: 16e9},
+		{From: gpu, To: nic, Interconnect: "pcie-p2p", Bandwidth: 16e9},
+	}
+	if got := topo.DeviceLinks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got links %v; expected %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		id   NodeID
+		want []NodeID
+	}{{nic, []NodeID{vf1, gpu}}, {vf1, []NodeID{nic}}, {gpu, []NodeID{nic}}, {storage, []NodeID{}}} {
+		got, err := topo.PeerDevices(tc.id)
+		if err != nil {
+			t.Errorf("PeerDevices(%d): %v", tc.id, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("got peers %v of device %d; expected %v", got, tc.id, tc.want)
+		}
+	}
+	if _, err := topo.PeerDevices(4); err == nil {
+		t.Error("PeerDevices of a thread succeeded")
+	}
+
+	if bw, err := topo.LinkBandwidth(nic, gpu); err != nil || bw != 32e9 {
+		t.Errorf("got bandwidth %d (%v) between the NIC and the GPU; expected 32e9", bw, err)
+	}
+	if bw, err := topo.LinkBandwidth(gpu, storage); err != nil || bw != 0 {
+		t.Errorf("got bandwidth %d (%v) between the GPU and the storage device; expected 0", bw, err)
+	}
+
+	clone := topo.clone()
+	clone.Nodes[gpu].Data.Links[0].Bandwidth = 0
+	if topo.Nodes[gpu].Data.Links[0].Bandwidth != 16e9 {
+		t.Error("modifying the links of a clone modified the original")
+	}
+}
@@ -0,0 +1,242 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind enumerates the kinds of Changes between two Topologies.
+type ChangeKind byte
+
+const (
+	// Added means that the Element only exists in the new Topology.
+	Added ChangeKind = iota + 1
+	// Removed means that the Element only exists in the old Topology.
+	Removed
+	// Moved means that the Element exists in both Topologies, but under
+	// different parents.
+	Moved
+	// Modified means that the Element exists in both Topologies, but its
+	// attributes (e.g., the size of a Cache) differ.
+	Modified
+)
+
+// String returns the string representation of the ChangeKind.
+func (ck ChangeKind) String() string {
+	switch ck {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Moved:
+		return "moved"
+	case Modified:
+		return "modified"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", byte(ck))
+	}
+}
+
+// MarshalText returns the ChangeKind marshalled as text (e.g., in JSON).
+func (ck ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(ck.String()), nil
+}
+
+// UnmarshalText unmarshals the ChangeKind from text (e.g., in JSON).
+func (ck *ChangeKind) UnmarshalText(text []byte) error {
+	for kind := Added; kind <= Modified; kind++ {
+		if kind.String() == string(text) {
+			*ck = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown change kind '%s'", text)
+}
+
+// Change describes a difference of an Element between two Topologies.
+type Change struct {
+	// Kind is the kind of the Change.
+	Kind ChangeKind `json:"change"`
+	// Key identifies the Element in both Topologies (see ElementKey).
+	Key string `json:"key"`
+	// OldID and Old are the NodeID and the Element in the old Topology;
+	// they are nil if the Element was Added.
+	OldID *NodeID  `json:"old_id,omitempty"`
+	Old   *Element `json:"old,omitempty"`
+	// NewID and New are the NodeID and the Element in the new Topology;
+	// they are nil if the Element was Removed.
+	NewID *NodeID  `json:"new_id,omitempty"`
+	New   *Element `json:"new,omitempty"`
+	// OldParent and NewParent are the Keys of the Element's parents in the
+	// old and in the new Topology, respectively, if the Element Moved.
+	OldParent string `json:"old_parent,omitempty"`
+	NewParent string `json:"new_parent,omitempty"`
+}
+
+// String returns the string representation of the Change.
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", c.Key, c.New)
+	case Removed:
+		return fmt.Sprintf("- %s: %s", c.Key, c.Old)
+	case Moved:
+		return fmt.Sprintf("> %s: moved from %s to %s", c.Key, c.OldParent, c.NewParent)
+	case Modified:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Key, c.Old, c.New)
+	default:
+		return fmt.Sprintf("? %s", c.Key)
+	}
+}
+
+// ElementKey returns a key that identifies the element stored in the Topology
+// under the provided NodeID across Topologies of the same machine, regardless
+// of its NodeID, or a non-nil error value in case of failure.
+//
+// The key of a Processing node consists of its kind and OS index (e.g.,
+// "numanode:1"), prefixed by the key of its Package in the case of Cores,
+// whose OS indices are only unique within their Package (e.g.,
+// "package:0/core:3"). The key of a Cache consists of its level and logical
+// index (e.g., "l3:2"), the key of a Device consists of its type and its bus ID
+// (or its name, in the absence of a bus ID; e.g., "gpu:0000:3b:00.0"), except
+// for OSDevices, several of which may share a bus ID, whose key consists of
+// their name (or their path, in the absence of a name; e.g., "osdevice:dma0"),
+// and the key of the root Element is "machine".
+func (t *Topology) ElementKey(id NodeID) (string, error) {
+	data, err := t.Get(id)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case data.IsRoot():
+		return "machine", nil
+	case data.IsCache():
+		return fmt.Sprintf("%s:%d", strings.ToLower(data.Level.String()), data.LogicalIndex), nil
+	case data.IsProcessing():
+		key := fmt.Sprintf("%s:%d", strings.ToLower(data.Kind.String()), data.ID)
+		if data.Kind == Core {
+			ancestors, err := t.AncestorIDs(id)
+			if err != nil {
+				return "", err
+			}
+			for _, ancestorID := range ancestors {
+				if ancestor := t.Nodes[ancestorID].Data; ancestor.IsProcessing() && ancestor.Kind == Package {
+					key = fmt.Sprintf("package:%d/%s", ancestor.ID, key)
+					break
+				}
+			}
+		}
+		return key, nil
+	case data.IsDevice() && data.Type == OSDevice:
+		for _, id := range []string{data.Name, data.Path, data.BusID} {
+			if id != "" {
+				return fmt.Sprintf("%s:%s", strings.ToLower(data.Type.String()), id), nil
+			}
+		}
+		return strings.ToLower(data.Type.String()) + ":", nil
+	case data.IsDevice():
+		if data.BusID != "" {
+			return fmt.Sprintf("%s:%s", strings.ToLower(data.Type.String()), data.BusID), nil
+		}
+		return fmt.Sprintf("%s:%s", strings.ToLower(data.Type.String()), data.Name), nil
+	default:
+		return "", fmt.Errorf("%w: more than one of Processing, Cache and Device are set", ErrInvalidElement)
+	}
+}
+
+// Diff returns the Changes that turn the Topology before into the Topology after, sorted
+// by the Keys of the Elements, or a non-nil error value in case of failure.
+//
+// Elements are matched between the Topologies by their keys (see ElementKey),
+// rather than by their NodeIDs, which are not stable across Topologies.
+func Diff(before, after *Topology) ([]Change, error) {
+	oldKeys, err := before.elementKeys()
+	if err != nil {
+		return nil, fmt.Errorf("old Topology: %w", err)
+	}
+	newKeys, err := after.elementKeys()
+	if err != nil {
+		return nil, fmt.Errorf("new Topology: %w", err)
+	}
+	oldByKey := make(map[string]NodeID, len(oldKeys))
+	for id, key := range oldKeys {
+		oldByKey[key] = NodeID(id)
+	}
+	newByKey := make(map[string]NodeID, len(newKeys))
+	for id, key := range newKeys {
+		newByKey[key] = NodeID(id)
+	}
+	parentKey := func(t *Topology, keys []string, id NodeID) string {
+		parentID, err := t.ParentID(id)
+		if err != nil {
+			return ""
+		}
+		return keys[parentID]
+	}
+
+	changes := make([]Change, 0)
+	for i, key := range oldKeys {
+		oldID := NodeID(i)
+		newID, ok := newByKey[key]
+		if !ok {
+			changes = append(changes, Change{Kind: Removed, Key: key, OldID: &oldID, Old: before.Nodes[oldID].Data})
+			continue
+		}
+		oldData, newData := before.Nodes[oldID].Data, after.Nodes[newID].Data
+		if oldParent, newParent := parentKey(before, oldKeys, oldID), parentKey(after, newKeys, newID); oldParent != newParent {
+			changes = append(changes, Change{Kind: Moved, Key: key, OldID: &oldID, Old: oldData,
+				NewID: &newID, New: newData, OldParent: oldParent, NewParent: newParent})
+		}
+		if oldData.String() != newData.String() {
+			changes = append(changes, Change{Kind: Modified, Key: key, OldID: &oldID, Old: oldData, NewID: &newID, New: newData})
+		}
+	}
+	for i, key := range newKeys {
+		if _, ok := oldByKey[key]; !ok {
+			newID := NodeID(i)
+			changes = append(changes, Change{Kind: Added, Key: key, NewID: &newID, New: after.Nodes[newID].Data})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+// elementKeys returns the keys of all Elements of the Topology (see
+// ElementKey), indexed by their NodeIDs, or a non-nil error value if any two
+// of them share the same key.
+func (t *Topology) elementKeys() ([]string, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	keys := make([]string, len(t.Nodes))
+	seen := make(map[string]NodeID, len(t.Nodes))
+	for id := range t.Nodes {
+		key, err := t.ElementKey(NodeID(id))
+		if err != nil {
+			return nil, err
+		}
+		if other, dup := seen[key]; dup {
+			return nil, fmt.Errorf("%w: elements %d and %d share the key '%s'", ErrDuplicateID, other, id, key)
+		}
+		seen[key] = NodeID(id)
+		keys[id] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,63 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := &Topology{syntheticTree(1, 1, 2, 2)}
+	after := &Topology{syntheticTree(1, 1, 3, 2)}
+	// Resize the L3, and move thread 1 from core 0 to core 1.
+	after.Nodes[3].Data.Attributes.Size = 64 << 20
+	after.Nodes[6].Children = after.Nodes[6].Children[:1]
+	after.Nodes[11].Children = append(after.Nodes[11].Children, 8)
+	after.InvalidateIndexes()
+
+	changes, err := Diff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	want := []string{
+		"+ l1:2: Cache{ L1(L#2), attrs: 32768B/64B/8-way }",
+		"+ l2:2: Cache{ L2(L#2), attrs: 1048576B/64B/8-way }",
+		"~ l3:0: Cache{ L3(L#0), attrs: 33554432B/64B/8-way } -> Cache{ L3(L#0), attrs: 67108864B/64B/8-way }",
+		"+ package:0/core:2: Core(2)",
+		"> thread:1: moved from package:0/core:0 to package:0/core:1",
+		"+ thread:4: Thread(4)",
+		"+ thread:5: Thread(5)",
+	}
+	if got := strings.Join(lines, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("got changes:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	if changes, err = Diff(after, before); err != nil || len(changes) != 7 || changes[0].Kind != Removed {
+		t.Errorf("got %v (%v) when diffing in reverse", changes, err)
+	}
+	if changes, err = Diff(before, before); err != nil || len(changes) != 0 {
+		t.Errorf("got %v (%v) when diffing a Topology against itself", changes, err)
+	}
+	if _, err = Diff(before, nil); err == nil {
+		t.Errorf("expected an error when diffing against a nil Topology")
+	}
+}
@@ -0,0 +1,347 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Discover returns the Topology of the local machine, as exposed by the Linux
+// kernel through sysfs, or a non-nil error value in case of failure (e.g., on
+// other operating systems). See DiscoverSysfs for the details.
+func Discover() (*Topology, error) {
+	return DiscoverSysfs(os.DirFS("/sys"))
+}
+
+// DiscoverSysfs returns the Topology exposed by the provided file system, which
+// is expected to be rooted at the mount point of a Linux sysfs (i.e., /sys), or
+// a non-nil error value in case of failure.
+//
+// The Topology consists of the Packages, Cores and Threads of all online CPUs
+// (devices/system/cpu), their data and unified Caches (cpu*/cache), and the
+// NUMA nodes that contain any of them (devices/system/node), along with the
+// size of their memory (node*/meminfo) and their distances (node*/distance);
+// NUMA nodes without CPUs (e.g., memory-only nodes) are omitted. Each element is placed under the
+// smallest one whose CPUs are a superset of its own, with ties broken in the
+// order Package, NUMANode, L5 to L1 Caches, Core and Thread. Caches are given
+// logical indices per level, in pre-order.
+func DiscoverSysfs(fsys fs.FS) (*Topology, error) {
+	const cpuDir = "devices/system/cpu"
+
+	online, err := readSysfs(fsys, path.Join(cpuDir, "online"))
+	if err != nil {
+		return nil, err
+	}
+	cpus, err := ParseCPUList(online)
+	if err != nil {
+		return nil, fmt.Errorf("invalid list of online CPUs: %w", err)
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("no online CPUs found")
+	}
+
+	objects := make(map[string]*sysfsObject)
+	add := func(key string, rank int, data *Element, cpu uint32) {
+		obj, ok := objects[key]
+		if !ok {
+			obj = &sysfsObject{rank: rank, data: data, cpus: new(big.Int)}
+			objects[key] = obj
+		}
+		obj.cpus.SetBit(obj.cpus, int(cpu), 1)
+	}
+	for _, cpu := range cpus {
+		dir := path.Join(cpuDir, fmt.Sprintf("cpu%d", cpu))
+		pkgID, err := readSysfsInt(fsys, path.Join(dir, "topology/physical_package_id"))
+		if err != nil {
+			return nil, err
+		}
+		coreID, err := readSysfsInt(fsys, path.Join(dir, "topology/core_id"))
+		if err != nil {
+			return nil, err
+		}
+		// Either is -1 on platforms that do not expose them.
+		if pkgID < 0 {
+			pkgID = 0
+		}
+		if coreID < 0 {
+			coreID = int64(cpu)
+		}
+		add(fmt.Sprintf("package:%d", pkgID), sysfsRankPackage,
+			&Element{Processing: &Processing{Kind: Package, ID: uint32(pkgID)}}, cpu)
+		add(fmt.Sprintf("package:%d/core:%d", pkgID, coreID), sysfsRankCore,
+			&Element{Processing: &Processing{Kind: Core, ID: uint32(coreID)}}, cpu)
+		add(fmt.Sprintf("thread:%d", cpu), sysfsRankThread,
+			&Element{Processing: &Processing{Kind: Thread, ID: cpu}}, cpu)
+
+		if err = discoverCaches(fsys, path.Join(dir, "cache"), cpu, add); err != nil {
+			return nil, err
+		}
+	}
+
+	// NUMA nodes are optional (e.g., kernels built without CONFIG_NUMA).
+	const nodeDir = "devices/system/node"
+	if entries, err := fs.ReadDir(fsys, nodeDir); err == nil {
+		// The rows of the NUMA distances are ordered by the online
+		// NUMA nodes.
+		var onlineNodes []uint32
+		if online, err := readSysfs(fsys, path.Join(nodeDir, "online")); err == nil {
+			if onlineNodes, err = ParseCPUList(online); err != nil {
+				return nil, fmt.Errorf("invalid list of online NUMA nodes: %w", err)
+			}
+		}
+		for _, entry := range entries {
+			nodeID, err := strconv.ParseUint(strings.TrimPrefix(entry.Name(), "node"), 10, 32)
+			if err != nil || !strings.HasPrefix(entry.Name(), "node") {
+				continue
+			}
+			cpuList, err := readSysfs(fsys, path.Join(nodeDir, entry.Name(), "cpulist"))
+			if err != nil {
+				return nil, err
+			}
+			nodeCPUs, err := ParseCPUList(cpuList)
+			if err != nil {
+				return nil, fmt.Errorf("invalid list of CPUs of NUMA node %d: %w", nodeID, err)
+			}
+			data := &Element{Processing: &Processing{Kind: NUMANode, ID: uint32(nodeID)}}
+			// The memory of the NUMA node is optional, too, and so are
+			// its distances.
+			if meminfo, err := readSysfs(fsys, path.Join(nodeDir, entry.Name(), "meminfo")); err == nil {
+				bytes, err := parseMemTotal(meminfo)
+				if err != nil {
+					return nil, fmt.Errorf("invalid memory information of NUMA node %d: %w", nodeID, err)
+				}
+				data.Memory = &MemoryAttributes{Bytes: bytes}
+			}
+			if distance, err := readSysfs(fsys, path.Join(nodeDir, entry.Name(), "distance")); err == nil && len(onlineNodes) > 0 {
+				row := strings.Fields(distance)
+				if len(row) != len(onlineNodes) {
+					return nil, fmt.Errorf("invalid distances of NUMA node %d: got %d for %d online NUMA nodes",
+						nodeID, len(row), len(onlineNodes))
+				}
+				if nil == data.Memory {
+					data.Memory = &MemoryAttributes{}
+				}
+				data.Memory.Distances = make(map[uint32]uint32, len(row))
+				for i, field := range row {
+					dist, err := strconv.ParseUint(field, 10, 32)
+					if err != nil {
+						return nil, fmt.Errorf("invalid distances of NUMA node %d: %w", nodeID, err)
+					}
+					data.Memory.Distances[onlineNodes[i]] = uint32(dist)
+				}
+			}
+			for _, cpu := range nodeCPUs {
+				if _, ok := objects[fmt.Sprintf("thread:%d", cpu)]; ok {
+					add(entry.Name(), sysfsRankNUMANode, data, cpu)
+				}
+			}
+		}
+	}
+
+	return buildSysfsTopology(objects)
+}
+
+// Ranks of the elements found in sysfs, which break ties between elements
+// that contain the same CPUs; lower ranks are placed higher in the Tree.
+const (
+	sysfsRankPackage = iota
+	sysfsRankNUMANode
+	sysfsRankCache // sysfsRankCache + (L5 - level)
+	sysfsRankCore  = sysfsRankCache + int(L5-L1) + 1
+	sysfsRankThread
+)
+
+// sysfsObject is an element found in sysfs, along with the CPUs it contains.
+type sysfsObject struct {
+	rank     int
+	data     *Element
+	cpus     *big.Int
+	children []*sysfsObject
+}
+
+// discoverCaches adds the data and unified caches of the provided CPU, found in
+// the provided sysfs directory.
+func discoverCaches(
+	fsys fs.FS,
+	dir string,
+	cpu uint32,
+	add func(key string, rank int, data *Element, cpu uint32),
+) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		// Some platforms expose no caches at all.
+		return nil
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "index") {
+			continue
+		}
+		index := path.Join(dir, entry.Name())
+		cacheType, err := readSysfs(fsys, path.Join(index, "type"))
+		if err != nil {
+			return err
+		}
+		if cacheType == "Instruction" {
+			continue
+		}
+		levelNum, err := readSysfsInt(fsys, path.Join(index, "level"))
+		if err != nil {
+			return err
+		}
+		level, err := ParseCacheLevel(fmt.Sprintf("L%d", levelNum))
+		if err != nil {
+			return fmt.Errorf("%s: %w", index, err)
+		}
+		shared, err := readSysfs(fsys, path.Join(index, "shared_cpu_list"))
+		if err != nil {
+			return err
+		}
+
+		attrs := &CacheAttributes{}
+		if size, err := readSysfs(fsys, path.Join(index, "size")); err == nil {
+			if attrs.Size, err = parseSysfsSize(size); err != nil {
+				return fmt.Errorf("%s: invalid size '%s'", index, size)
+			}
+		}
+		if line, err := readSysfsInt(fsys, path.Join(index, "coherency_line_size")); err == nil && line > 0 {
+			attrs.Linesize = uint32(line)
+		}
+		if ways, err := readSysfsInt(fsys, path.Join(index, "ways_of_associativity")); err == nil {
+			attrs.Associativity = int32(ways)
+		}
+		add(fmt.Sprintf("%s:%s", level, shared), sysfsRankCache+int(L5-level),
+			&Element{Cache: &Cache{Level: level, Attributes: attrs}}, cpu)
+	}
+	return nil
+}
+
+// buildSysfsTopology nests the provided sysfsObjects under each other, and
+// returns the resulting Topology.
+func buildSysfsTopology(objects map[string]*sysfsObject) (*Topology, error) {
+	sorted := make([]*sysfsObject, 0, len(objects))
+	for _, obj := range objects {
+		sorted = append(sorted, obj)
+	}
+	lowestCPU := func(obj *sysfsObject) uint { return obj.cpus.TrailingZeroBits() }
+	sort.Slice(sorted, func(i, j int) bool {
+		ci, cj := popCount(sorted[i].cpus), popCount(sorted[j].cpus)
+		if ci != cj {
+			return ci > cj
+		}
+		if sorted[i].rank != sorted[j].rank {
+			return sorted[i].rank < sorted[j].rank
+		}
+		return lowestCPU(sorted[i]) < lowestCPU(sorted[j])
+	})
+
+	// Since the objects are sorted from the largest to the smallest, the
+	// last one inserted that contains an object is the smallest such one.
+	root := &sysfsObject{data: &Element{}}
+	for i, obj := range sorted {
+		parent := root
+		for j := i - 1; j >= 0; j-- {
+			if new(big.Int).AndNot(obj.cpus, sorted[j].cpus).Sign() == 0 {
+				parent = sorted[j]
+				break
+			}
+		}
+		parent.children = append(parent.children, obj)
+	}
+
+	tree := &Tree{}
+	var cacheLI [L5 + 1]uint32
+	var addNode func(obj *sysfsObject) NodeID
+	addNode = func(obj *sysfsObject) NodeID {
+		id := NodeID(len(tree.Nodes))
+		if obj.data.IsCache() {
+			obj.data.LogicalIndex = cacheLI[obj.data.Level]
+			cacheLI[obj.data.Level]++
+		}
+		tree.Nodes = append(tree.Nodes, TreeNode{Data: obj.data})
+		sort.SliceStable(obj.children, func(i, j int) bool {
+			return lowestCPU(obj.children[i]) < lowestCPU(obj.children[j])
+		})
+		for _, child := range obj.children {
+			childID := addNode(child)
+			tree.Nodes[id].Children = append(tree.Nodes[id].Children, childID)
+		}
+		return id
+	}
+	addNode(root)
+	if err := tree.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid topology discovered: %w", err)
+	}
+	return &Topology{Tree: tree}, nil
+}
+
+// popCount returns the number of bits set in the provided big.Int.
+func popCount(x *big.Int) int {
+	count := 0
+	for _, word := range x.Bits() {
+		for ; word != 0; word &= word - 1 {
+			count++
+		}
+	}
+	return count
+}
+
+// readSysfs returns the contents of the provided sysfs file, trimmed.
+func readSysfs(fsys fs.FS, name string) (string, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readSysfsInt returns the integer stored in the provided sysfs file.
+func readSysfsInt(fsys fs.FS, name string) (int64, error) {
+	str, err := readSysfs(fsys, name)
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return val, nil
+}
+
+// parseSysfsSize parses a size as found in sysfs (e.g., "32K" or "2048K").
+func parseSysfsSize(str string) (uint64, error) {
+	multiplier := uint64(1)
+	switch {
+	case strings.HasSuffix(str, "K"):
+		multiplier, str = 1<<10, strings.TrimSuffix(str, "K")
+	case strings.HasSuffix(str, "M"):
+		multiplier, str = 1<<20, strings.TrimSuffix(str, "M")
+	case strings.HasSuffix(str, "G"):
+		multiplier, str = 1<<30, strings.TrimSuffix(str, "G")
+	}
+	size, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return size * multiplier, nil
+}
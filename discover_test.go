@@ -0,0 +1,101 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"testing/fstest"
+)
+
+// sysfsFixture returns a sysfs with a single Package of two Cores with two
+// Threads each (numbered as Linux does on x86), a NUMA node with all CPUs and
+// a memory-only NUMA node.
+func sysfsFixture() fstest.MapFS {
+	fsys := fstest.MapFS{
+		"devices/system/cpu/online":         {Data: []byte("0-3\n")},
+		"devices/system/node/node0/cpulist": {Data: []byte("0-3\n")},
+		"devices/system/node/node1/cpulist": {Data: []byte("\n")},
+		"devices/system/node/possible":      {Data: []byte("0-1\n")},
+	}
+	file := func(cpu int, name, data string) {
+		fsys[fmt.Sprintf("devices/system/cpu/cpu%d/%s", cpu, name)] = &fstest.MapFile{Data: []byte(data + "\n")}
+	}
+	cache := func(cpu, index, level int, typ, size, shared string) {
+		dir := fmt.Sprintf("cache/index%d/", index)
+		file(cpu, dir+"level", fmt.Sprint(level))
+		file(cpu, dir+"type", typ)
+		file(cpu, dir+"size", size)
+		file(cpu, dir+"coherency_line_size", "64")
+		file(cpu, dir+"ways_of_associativity", "8")
+		file(cpu, dir+"shared_cpu_list", shared)
+	}
+	for cpu := 0; cpu < 4; cpu++ {
+		siblings := fmt.Sprintf("%d,%d", cpu%2, cpu%2+2)
+		file(cpu, "topology/physical_package_id", "0")
+		file(cpu, "topology/core_id", fmt.Sprint(cpu%2))
+		cache(cpu, 0, 1, "Data", "32K", siblings)
+		cache(cpu, 1, 1, "Instruction", "32K", siblings)
+		cache(cpu, 2, 2, "Unified", "1024K", siblings)
+		cache(cpu, 3, 3, "Unified", "16M", "0-3")
+	}
+	return fsys
+}
+
+func TestDiscoverSysfs(t *testing.T) {
+	topo, err := DiscoverSysfs(sysfsFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = topo.WriteTree(&buf, RenderOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	const want = `Machine
+  Package(0)
+    NUMANode(0)
+      Cache{ L3(L#0), attrs: 16777216B/64B/8-way }
+        Cache{ L2(L#0), attrs: 1048576B/64B/8-way }
+          Cache{ L1(L#0), attrs: 32768B/64B/8-way }
+            Core(0)
+              Thread(0)
+              Thread(2)
+        Cache{ L2(L#1), attrs: 1048576B/64B/8-way }
+          Cache{ L1(L#1), attrs: 32768B/64B/8-way }
+            Core(1)
+              Thread(1)
+              Thread(3)
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	broken := sysfsFixture()
+	delete(broken, "devices/system/cpu/cpu2/topology/core_id")
+	if _, err = DiscoverSysfs(broken); err == nil {
+		t.Errorf("expected an error for a missing core_id")
+	}
+	broken = sysfsFixture()
+	broken["devices/system/cpu/cpu1/cache/index2/size"].Data = []byte("1 MiB")
+	if _, err = DiscoverSysfs(broken); err == nil {
+		t.Errorf("expected an error for a malformed cache size")
+	}
+	if _, err = DiscoverSysfs(fstest.MapFS{}); err == nil {
+		t.Errorf("expected an error for an empty sysfs")
+	}
+}
@@ -0,0 +1,67 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+// ElementDistance is an explicit distance between two Elements of a Topology
+// (e.g., as measured), which overrides the one derived from their locality
+// (see Topology.Distance).
+type ElementDistance struct {
+	// A is the NodeID of the first Element.
+	A NodeID `json:"a"`
+	// B is the NodeID of the second Element.
+	B NodeID `json:"b"`
+	// Distance is the distance between them, in either direction.
+	Distance uint32 `json:"distance"`
+}
+
+// Distance returns how far apart the elements stored under the provided
+// NodeIDs are, regardless of their kinds (e.g., between a hardware thread and
+// a Device, or between a Cache and a NUMA node), or a non-nil error value in
+// case of failure.
+//
+// The distance is derived from their locality, as the number of levels between
+// their lowest common ancestor (see CommonAncestorID) and the deepest level of
+// the Tree; hence, the deeper the hierarchy they share, the closer they are,
+// and an element is at distance zero only from itself. Any explicit distance
+// between them that is provided (in either order) overrides the derived one,
+// in which case it is up to the caller to keep the units of all distances
+// consistent.
+//
+// It is served from the Tree's secondary indexes (see InvalidateIndexes) in
+// O(depth), without any heap allocations.
+func (t *Topology) Distance(a, b NodeID, explicit []ElementDistance) (uint32, error) {
+	for _, id := range []NodeID{a, b} {
+		if _, err := t.Get(id); err != nil {
+			return 0, err
+		}
+	}
+	for _, d := range explicit {
+		if (d.A == a && d.B == b) || (d.A == b && d.B == a) {
+			return d.Distance, nil
+		}
+	}
+	if a == b {
+		return 0, nil
+	}
+
+	indexes := t.Hierarchy.getIndexes()
+	lca, err := indexes.lowestCommonAncestor(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(t.depth() - indexes.depths[lca]), nil
+}
@@ -0,0 +1,88 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	topo := &Topology{deviceTree()}
+	explicit := []ElementDistance{{A: 15, B: 12, Distance: 7}}
+	for _, tc := range []struct {
+		a, b NodeID
+		want uint32
+	}{
+		{4, 4, 0},   // itself
+		{4, 5, 1},   // threads of the same Core
+		{4, 6, 2},   // thread and NIC of the same NUMA node
+		{7, 4, 2},   // virtual function and thread of the same NUMA node
+		{2, 3, 2},   // NUMA node and its Core
+		{13, 15, 3}, // thread and GPU of the same Package
+		{4, 15, 4},  // thread and GPU of different Packages
+		{4, 16, 4},  // thread and storage device of unknown locality
+		{12, 15, 7}, // explicit distance, in reverse order
+	} {
+		got, err := topo.Distance(tc.a, tc.b, explicit)
+		if err != nil {
+			t.Errorf("Distance(%d, %d): %v", tc.a, tc.b, err)
+		} else if got != tc.want {
+			t.Errorf("got distance %d between %d and %d; expected %d", got, tc.a, tc.b, tc.want)
+		}
+	}
+	if _, err := topo.Distance(4, NodeID(topo.Size()), nil); err == nil {
+		t.Error("Distance to an invalid NodeID succeeded")
+	}
+}
+
+func TestDistanceFromIndexes(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 2, 4, 2)}
+	height := 0
+	for _, depth := range topo.Depths() {
+		if depth > height {
+			height = depth
+		}
+	}
+	for a := range topo.Nodes {
+		for b := range topo.Nodes {
+			lca, err := topo.CommonAncestorID(NodeID(a), NodeID(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			depth, _ := topo.Depth(lca)
+			want := uint32(height - depth)
+			if a == b {
+				want = 0
+			}
+			if got, err := topo.Distance(NodeID(a), NodeID(b), nil); err != nil || got != want {
+				t.Errorf("Distance(%d, %d) = %d, %v; want %d", a, b, got, err, want)
+			}
+		}
+	}
+	last := NodeID(topo.Size() - 1)
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = topo.Distance(1, last, nil) }); allocs != 0 {
+		t.Errorf("Distance allocated %v times per call; want 0", allocs)
+	}
+
+	// Elements that are not reachable from the root have no distance.
+	topo.Nodes[0].Children = topo.Nodes[0].Children[:1]
+	topo.InvalidateIndexes()
+	if _, err := topo.Distance(1, last, nil); !errors.Is(err, ErrOrphan) {
+		t.Errorf("got %v for a detached Element; want ErrOrphan", err)
+	}
+}
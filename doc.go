@@ -17,10 +17,27 @@
 // Package to easily serialize, deserialize and work with the hierarchical
 // hardware topology of a physical machine, as examined by the ActiK8s project.
 //
-// The package does not provide any synchronization guarantees, since the
-// entities it exposes (i.e., a physical machine's hierarchical hardware
-// topology), after their deserialization, are expected to only be read in the
-// vast majority of the package's use cases.
-// Therefore, concurrency should be catered for externally in cases heavy on
-// concurrent modifications.
+// # Concurrency
+//
+// The entities that the package exposes (i.e., a physical machine's
+// hierarchical hardware topology) are expected to only be read after their
+// deserialization, in the vast majority of the package's use cases. Hence:
+//
+//   - All methods of Tree and Topology that do not modify them are safe for
+//     concurrent use by multiple goroutines. Any secondary indexes that they
+//     build internally are built lazily, exactly once, under a sync.Once, no
+//     matter how many goroutines query them at the same time.
+//   - Modifying a Tree (i.e., its Nodes, or the Elements stored in them) is
+//     not synchronized: it requires exclusive access to the Tree, and must be
+//     followed by a call to InvalidateIndexes before the Tree is queried again.
+//   - An Allocator synchronizes access to its own state, and is safe for
+//     concurrent use, as long as its Topology is not modified.
+//   - A FrozenTopology (see Topology.Freeze) enforces the above at the type
+//     level: it cannot be modified at all, and its mutating methods return
+//     modified copies instead.
+//   - A SyncTopology (see NewSyncTopology) guards a Topology that needs to be
+//     modified while it is being queried, so that callers do not have to.
+//
+// The package's tests are run with the race detector (see the "race" target of
+// the Makefile) to verify the above.
 package actitopo
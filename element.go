@@ -20,6 +20,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -32,7 +35,7 @@ import (
 // Element represents a node in the hierarchy of the hardware topology.
 //
 // Apart from the special case of Machine, which is the root node in the
-// hierarchy, an Element can be either a Processing node or a Cache.
+// hierarchy, an Element can be either a Processing node, a Cache or a Device.
 type Element struct {
 	// Processing is non-nil if the Element represents a computation unit
 	// in the hierarchical hardware topology.
@@ -40,26 +43,37 @@ type Element struct {
 	// Cache is non-nil if the Element represents a caching element in the
 	// hierarchical hardware topology.
 	*Cache `json:"cache,omitempty"`
+	// Device is non-nil if the Element represents an I/O device (e.g., a
+	// GPU or a NIC) attached to the hierarchical hardware topology.
+	*Device `json:"device,omitempty"`
 }
 
 // IsRoot returns true if the Element is the root node in the hierarchy (i.e.,
 // the Machine) and false otherwise.
 func (e *Element) IsRoot() bool {
-	return nil == e.Processing && nil == e.Cache
+	return nil == e.Processing && nil == e.Cache && nil == e.Device
 }
 
 // IsProcessing returns true if the Element is a Processing node and false
 // otherwise.
 func (e *Element) IsProcessing() bool {
-	return nil == e.Cache && nil != e.Processing
+	return nil == e.Cache && nil == e.Device && nil != e.Processing
 }
 
 // IsCache returns true if the Element is a Cache and false otherwise.
 func (e *Element) IsCache() bool {
-	return nil == e.Processing && nil != e.Cache
+	return nil == e.Processing && nil == e.Device && nil != e.Cache
+}
+
+// IsDevice returns true if the Element is a Device and false otherwise.
+func (e *Element) IsDevice() bool {
+	return nil == e.Processing && nil == e.Cache && nil != e.Device
 }
 
 // String returns the string representation of the Element.
+//
+// An Element that is more than one of a Processing node, a Cache and a Device
+// is invalid, and is represented as "InvalidElement".
 func (e *Element) String() string {
 	switch {
 	case e.IsRoot():
@@ -68,29 +82,165 @@ func (e *Element) String() string {
 		return fmt.Sprintf("%s", e.Cache)
 	case e.IsProcessing():
 		return fmt.Sprintf("%s", e.Processing)
+	case e.IsDevice():
+		return fmt.Sprintf("%s", e.Device)
 	default:
-		panic("UNREACHABLE") // XXX(ckatsak)
+		return "InvalidElement"
 	}
 }
 
 // MarshalJSON returns the Element marshalled in JSON, or a non-nil error value
 // in case of failure.
+//
+// The JSON representation is written directly into a byte slice, since this is
+// on the hot path of marshalling large Trees.
 func (e *Element) MarshalJSON() ([]byte, error) {
-	raw := make(map[string]interface{})
 	switch {
 	case e.IsRoot():
 		return []byte(`"machine"`), nil
 	case e.IsCache():
-		raw["cache"] = e.Cache
-		return json.Marshal(raw)
+		buf := make([]byte, 0, 96)
+		buf = append(buf, `{"cache":{"lvl":"`...)
+		buf = append(buf, e.Level.String()...)
+		buf = append(buf, `","li":`...)
+		buf = strconv.AppendUint(buf, uint64(e.LogicalIndex), 10)
+		if nil == e.Attributes {
+			buf = append(buf, `,"attrs":null}}`...)
+			return buf, nil
+		}
+		buf = append(buf, `,"attrs":{"size":`...)
+		buf = strconv.AppendUint(buf, e.Attributes.Size, 10)
+		buf = append(buf, `,"line":`...)
+		buf = strconv.AppendUint(buf, uint64(e.Attributes.Linesize), 10)
+		buf = append(buf, `,"ways":`...)
+		buf = strconv.AppendInt(buf, int64(e.Attributes.Associativity), 10)
+		if e.Attributes.Inclusion != Inclusive {
+			buf = append(buf, `,"incl":"`...)
+			buf = append(buf, strings.ToLower(e.Attributes.Inclusion.String())...)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, `}}}`...)
+		return buf, nil
 	case e.IsProcessing():
-		raw["processing"] = e.Processing
-		return json.Marshal(raw)
+		buf := make([]byte, 0, 48)
+		buf = append(buf, `{"processing":{"kind":"`...)
+		buf = append(buf, strings.ToLower(e.Kind.String())...)
+		buf = append(buf, `","id":`...)
+		buf = strconv.AppendUint(buf, uint64(e.ID), 10)
+		if nil != e.Memory {
+			buf = append(buf, `,"mem":{"bytes":`...)
+			buf = strconv.AppendUint(buf, e.Memory.Bytes, 10)
+			if e.Memory.Tier != DRAM {
+				buf = append(buf, `,"tier":"`...)
+				buf = append(buf, strings.ToLower(e.Memory.Tier.String())...)
+				buf = append(buf, '"')
+			}
+			if len(e.Memory.Distances) > 0 {
+				buf = append(buf, `,"dist":{`...)
+				for i, osID := range e.Memory.distanceIDs() {
+					if i > 0 {
+						buf = append(buf, ',')
+					}
+					buf = append(buf, '"')
+					buf = strconv.AppendUint(buf, uint64(osID), 10)
+					buf = append(buf, `":`...)
+					buf = strconv.AppendUint(buf, uint64(e.Memory.Distances[osID]), 10)
+				}
+				buf = append(buf, '}')
+			}
+			buf = append(buf, '}')
+		}
+		buf = append(buf, `}}`...)
+		return buf, nil
+	case e.IsDevice():
+		buf := make([]byte, 0, 64)
+		buf = append(buf, `{"device":{"type":"`...)
+		buf = append(buf, strings.ToLower(e.Type.String())...)
+		buf = append(buf, '"')
+		if e.Name != "" {
+			buf = append(buf, `,"name":`...)
+			buf = appendJSONString(buf, e.Name)
+		}
+		if e.BusID != "" {
+			buf = append(buf, `,"bus":`...)
+			buf = appendJSONString(buf, e.BusID)
+		}
+		if e.Class != "" {
+			buf = append(buf, `,"class":`...)
+			buf = appendJSONString(buf, e.Class)
+		}
+		if e.Path != "" {
+			buf = append(buf, `,"path":`...)
+			buf = appendJSONString(buf, e.Path)
+		}
+		if e.PhysicalFunction != "" {
+			buf = append(buf, `,"pf":`...)
+			buf = appendJSONString(buf, e.PhysicalFunction)
+		}
+		if len(e.Links) > 0 {
+			buf = append(buf, `,"links":[`...)
+			for i, link := range e.Links {
+				if i > 0 {
+					buf = append(buf, ',')
+				}
+				buf = append(buf, `{"peer":`...)
+				buf = appendJSONString(buf, link.Peer)
+				if link.Interconnect != "" {
+					buf = append(buf, `,"type":`...)
+					buf = appendJSONString(buf, link.Interconnect)
+				}
+				if link.Bandwidth != 0 {
+					buf = append(buf, `,"bw":`...)
+					buf = strconv.AppendUint(buf, link.Bandwidth, 10)
+				}
+				buf = append(buf, '}')
+			}
+			buf = append(buf, ']')
+		}
+		if nil != e.CXLLink {
+			buf = append(buf, `,"cxl":{`...)
+			sep := ""
+			if e.CXLLink.Version != "" {
+				buf = append(buf, `"ver":`...)
+				buf = appendJSONString(buf, e.CXLLink.Version)
+				sep = ","
+			}
+			for _, field := range []struct {
+				key string
+				val uint64
+			}{{"width", uint64(e.CXLLink.Width)}, {"bw", e.CXLLink.Bandwidth}, {"lat", uint64(e.CXLLink.Latency)}} {
+				if field.val != 0 {
+					buf = append(buf, sep+`"`+field.key+`":`...)
+					buf = strconv.AppendUint(buf, field.val, 10)
+					sep = ","
+				}
+			}
+			buf = append(buf, '}')
+		}
+		if len(e.MemoryNodes) > 0 {
+			buf = append(buf, `,"mem":[`...)
+			for i, osID := range e.MemoryNodes {
+				if i > 0 {
+					buf = append(buf, ',')
+				}
+				buf = strconv.AppendUint(buf, uint64(osID), 10)
+			}
+			buf = append(buf, ']')
+		}
+		buf = append(buf, `}}`...)
+		return buf, nil
 	default:
-		return nil, fmt.Errorf("Invalid Element")
+		return nil, fmt.Errorf("%w: more than one of Processing, Cache and Device are set", ErrInvalidElement)
 	}
 }
 
+// appendJSONString appends the provided string to buf, quoted and escaped as a
+// JSON string.
+func appendJSONString(buf []byte, str string) []byte {
+	quoted, _ := json.Marshal(str)
+	return append(buf, quoted...)
+}
+
 // UnmarshalJSON attempts to unmarshal the Element from the provided byte slice
 // and returns a non-nil error if it fails.
 func (e *Element) UnmarshalJSON(data []byte) (err error) {
@@ -98,6 +248,7 @@ func (e *Element) UnmarshalJSON(data []byte) (err error) {
 	if bytes.HasPrefix(bytes.ToLower(data), []byte(`"machine"`)) {
 		e.Processing = nil
 		e.Cache = nil
+		e.Device = nil
 		return nil
 	}
 
@@ -110,42 +261,65 @@ func (e *Element) UnmarshalJSON(data []byte) (err error) {
 	// Make sure the root is map[string]..
 	root, rootOk := raw.(map[string]interface{})
 	if !rootOk {
-		return fmt.Errorf("failed to unmarshal Element")
+		return fmt.Errorf("%w: expected \"machine\" or an object", ErrInvalidElement)
 	}
 
 	if content, contentOk := root["processing"]; contentOk {
 		// If it is a Processing element:
 		e.Cache = nil
+		e.Device = nil
 		processing, processingOk := content.(map[string]interface{})
 		if !processingOk {
-			return fmt.Errorf("failed to unmarshal Processing")
+			return fmt.Errorf("%w: failed to unmarshal Processing: expected an object", ErrInvalidElement)
 		}
 		kindStr, kindOk := processing["kind"].(string)
 		idF64, idOk := processing["id"].(float64)
 		if kindOk && idOk {
 			var kind ProcessingKind
 			if kind, err = ParseProcessingKind(kindStr); err != nil {
-				return fmt.Errorf("failed to unmarshal Processing: failed to unmarshal ProcessingKind: %v", err)
+				return fmt.Errorf("%w: failed to unmarshal Processing: failed to unmarshal ProcessingKind: %v", ErrInvalidElement, err)
 			}
 			e.Processing = &Processing{
 				Kind: kind,
 				ID:   uint32(idF64),
 			}
+			// The memory attributes are optional, and only found on
+			// NUMA nodes.
+			if memVal, memOk := processing["mem"]; memOk {
+				mem, _ := memVal.(map[string]interface{})
+				bytesF64, bytesOk := mem["bytes"].(float64)
+				if !bytesOk {
+					return fmt.Errorf("%w: failed to unmarshal Processing: malformed 'mem'", ErrInvalidElement)
+				}
+				e.Processing.Memory = &MemoryAttributes{Bytes: uint64(bytesF64)}
+				if tierVal, tierOk := mem["tier"]; tierOk {
+					tierStr, _ := tierVal.(string)
+					if e.Processing.Memory.Tier, err = ParseMemoryTier(tierStr); err != nil {
+						return fmt.Errorf("%w: failed to unmarshal Processing: failed to unmarshal MemoryTier: %v", ErrInvalidElement, err)
+					}
+				}
+				if distVal, distOk := mem["dist"]; distOk {
+					if e.Processing.Memory.Distances, err = parseDistances(distVal); err != nil {
+						return fmt.Errorf("%w: failed to unmarshal Processing: %v", ErrInvalidElement, err)
+					}
+				}
+			}
 		} else {
-			err = fmt.Errorf("failed to unmarshal Processing")
+			err = fmt.Errorf("%w: failed to unmarshal Processing: missing or malformed 'kind' or 'id'", ErrInvalidElement)
 		}
 	} else if content, contentOk := root["cache"]; contentOk {
 		// If it is a Cache element:
 		e.Processing = nil
+		e.Device = nil
 		cache, cacheOk := content.(map[string]interface{})
 		if !cacheOk {
-			return fmt.Errorf("failed to unmarshal Cache")
+			return fmt.Errorf("%w: failed to unmarshal Cache: expected an object", ErrInvalidElement)
 		}
 		levelStr, levelOk := cache["lvl"].(string)
 		liF64, liOk := cache["li"].(float64)
 		attrsVal, attrsOk := cache["attrs"].(map[string]interface{})
 		if !attrsOk {
-			return fmt.Errorf("failed to unmarshal Cache")
+			return fmt.Errorf("%w: failed to unmarshal Cache: missing or malformed 'attrs'", ErrInvalidElement)
 		}
 		sizeF64, sizeOk := attrsVal["size"].(float64)
 		lineF64, lineOk := attrsVal["line"].(float64)
@@ -153,7 +327,16 @@ func (e *Element) UnmarshalJSON(data []byte) (err error) {
 		if levelOk && liOk && sizeOk && lineOk && waysOk {
 			var cacheLevel CacheLevel
 			if cacheLevel, err = ParseCacheLevel(levelStr); err != nil {
-				return fmt.Errorf("failed to unmarshal Cache: failed to unmarshal CacheLevel: %v", err)
+				return fmt.Errorf("%w: failed to unmarshal Cache: failed to unmarshal CacheLevel: %v", ErrInvalidElement, err)
+			}
+			// The inclusion policy is optional, and is omitted for
+			// inclusive Caches.
+			var inclusion CacheInclusion
+			if inclVal, inclOk := attrsVal["incl"]; inclOk {
+				inclStr, _ := inclVal.(string)
+				if inclusion, err = ParseCacheInclusion(inclStr); err != nil {
+					return fmt.Errorf("%w: failed to unmarshal Cache: failed to unmarshal CacheInclusion: %v", ErrInvalidElement, err)
+				}
 			}
 			e.Cache = &Cache{
 				Level:        cacheLevel,
@@ -162,13 +345,63 @@ func (e *Element) UnmarshalJSON(data []byte) (err error) {
 					Size:          uint64(sizeF64),
 					Linesize:      uint32(lineF64),
 					Associativity: int32(waysF64),
+					Inclusion:     inclusion,
 				},
 			}
 		} else {
-			err = fmt.Errorf("failed to unmarshal Cache")
+			err = fmt.Errorf("%w: failed to unmarshal Cache: missing or malformed 'lvl', 'li', 'size', 'line' or 'ways'", ErrInvalidElement)
+		}
+	} else if content, contentOk := root["device"]; contentOk {
+		// If it is a Device element:
+		e.Processing = nil
+		e.Cache = nil
+		device, deviceOk := content.(map[string]interface{})
+		if !deviceOk {
+			return fmt.Errorf("%w: failed to unmarshal Device: expected an object", ErrInvalidElement)
+		}
+		typeStr, typeOk := device["type"].(string)
+		if !typeOk {
+			return fmt.Errorf("%w: failed to unmarshal Device: missing or malformed 'type'", ErrInvalidElement)
+		}
+		e.Device = &Device{}
+		if e.Device.Type, err = ParseDeviceType(typeStr); err != nil {
+			return fmt.Errorf("%w: failed to unmarshal Device: failed to unmarshal DeviceType: %v", ErrInvalidElement, err)
+		}
+		// The name, the bus ID, the class, the path and the physical
+		// function are optional.
+		for _, field := range []struct {
+			key string
+			dst *string
+		}{
+			{"name", &e.Device.Name},
+			{"bus", &e.Device.BusID},
+			{"class", &e.Device.Class},
+			{"path", &e.Device.Path},
+			{"pf", &e.Device.PhysicalFunction},
+		} {
+			if val, ok := device[field.key]; ok {
+				if *field.dst, ok = val.(string); !ok {
+					return fmt.Errorf("%w: failed to unmarshal Device: malformed '%s'", ErrInvalidElement, field.key)
+				}
+			}
+		}
+		if linksVal, linksOk := device["links"]; linksOk {
+			if e.Device.Links, err = parseDeviceLinks(linksVal); err != nil {
+				return fmt.Errorf("%w: failed to unmarshal Device: %v", ErrInvalidElement, err)
+			}
+		}
+		if cxlVal, cxlOk := device["cxl"]; cxlOk {
+			if e.Device.CXLLink, err = parseCXLLink(cxlVal); err != nil {
+				return fmt.Errorf("%w: failed to unmarshal Device: %v", ErrInvalidElement, err)
+			}
+		}
+		if memVal, memOk := device["mem"]; memOk {
+			if e.Device.MemoryNodes, err = parseMemoryNodes(memVal); err != nil {
+				return fmt.Errorf("%w: failed to unmarshal Device: %v", ErrInvalidElement, err)
+			}
 		}
 	} else {
-		err = fmt.Errorf("failed to unmarshal Element")
+		err = fmt.Errorf("%w: expected a 'processing', a 'cache' or a 'device' object", ErrInvalidElement)
 	}
 	return
 }
@@ -187,13 +420,158 @@ type Processing struct {
 	// ID is the index of the computation unit, assigned by the operating
 	// system.
 	ID uint32 `json:"id"`
+	// Memory contains the characteristics of the memory that is local to
+	// the computation unit, if they have been detected; it is only set for
+	// NUMA nodes.
+	Memory *MemoryAttributes `json:"mem,omitempty"`
 }
 
 // String returns the string representation of the Processing.
 func (p *Processing) String() string {
+	if nil != p.Memory {
+		return fmt.Sprintf("%s(%d, %s)", p.Kind, p.ID, p.Memory)
+	}
 	return fmt.Sprintf("%s(%d)", p.Kind, p.ID)
 }
 
+// MemoryAttributes represents various characteristics of the memory of a NUMA
+// node that may have been detected.
+type MemoryAttributes struct {
+	// Bytes is the total size of the memory, in bytes.
+	Bytes uint64 `json:"bytes"`
+	// Tier is the kind of the memory, which determines its access latency
+	// and bandwidth; it is omitted for DRAM.
+	Tier MemoryTier `json:"tier,omitempty"`
+	// Distances maps the OS indices of NUMA nodes to their relative
+	// distance from this one, as reported by the firmware (i.e., the row
+	// of this NUMA node in the ACPI SLIT), where 10 is the distance of
+	// every NUMA node to itself; it is omitted if unknown.
+	Distances map[uint32]uint32 `json:"dist,omitempty"`
+}
+
+// String returns the string representation of the MemoryAttributes.
+func (ma *MemoryAttributes) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%dB", ma.Bytes)
+	if ma.Tier != DRAM {
+		fmt.Fprintf(&sb, "/%s", ma.Tier)
+	}
+	for i, osID := range ma.distanceIDs() {
+		if i == 0 {
+			sb.WriteString("/dist:")
+		} else {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, "%d=%d", osID, ma.Distances[osID])
+	}
+	return sb.String()
+}
+
+// distanceIDs returns the OS indices of the NUMA nodes in Distances, in
+// ascending order.
+func (ma *MemoryAttributes) distanceIDs() []uint32 {
+	ret := make([]uint32, 0, len(ma.Distances))
+	for osID := range ma.Distances {
+		ret = append(ret, osID)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+// parseDistances returns the Distances of MemoryAttributes parsed from the
+// provided JSON object, as decoded by encoding/json, or a non-nil error value
+// if it is malformed.
+func parseDistances(val interface{}) (map[uint32]uint32, error) {
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("malformed 'dist': expected an object")
+	}
+	ret := make(map[uint32]uint32, len(obj))
+	for key, distVal := range obj {
+		osID, err := strconv.ParseUint(key, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed 'dist': invalid NUMA node '%s'", key)
+		}
+		dist, ok := distVal.(float64)
+		if !ok || dist < 0 || dist > math.MaxUint32 || dist != math.Trunc(dist) {
+			return nil, fmt.Errorf("malformed 'dist': invalid distance '%v' of NUMA node %d", distVal, osID)
+		}
+		ret[uint32(osID)] = uint32(dist)
+	}
+	return ret, nil
+}
+
+// MemoryTier represents the kind of the memory of a NUMA node, on machines
+// with tiered memory.
+type MemoryTier byte
+
+const (
+	// DRAM is the main memory of the machine.
+	//
+	// This is the default, as it has always been assumed by this package.
+	DRAM MemoryTier = iota
+	// HBM is high-bandwidth memory that is packaged along with the
+	// processor (e.g., MCDRAM on Xeon Phi, or HBM on Xeon Max).
+	HBM
+	// CXL is memory that is attached through a CXL link (i.e., a memory
+	// expander), which is slower than DRAM.
+	CXL
+	// PMEM is persistent memory (e.g., Optane DC), which is slower than
+	// DRAM.
+	PMEM
+)
+
+// String returns the string representation of the MemoryTier.
+func (mt MemoryTier) String() string {
+	switch mt {
+	case DRAM:
+		return "DRAM"
+	case HBM:
+		return "HBM"
+	case CXL:
+		return "CXL"
+	case PMEM:
+		return "PMEM"
+	default:
+		return fmt.Sprintf("Unknown memory tier %d", mt)
+	}
+}
+
+// ParseMemoryTier returns a MemoryTier parsed from the provided string
+// representation (e.g., "hbm", or hwloc's NUMA node subtypes, such as
+// "MCDRAM" or "NVM"), or a non-nil error value if parsing fails.
+func ParseMemoryTier(str string) (MemoryTier, error) {
+	switch strings.ToLower(str) {
+	case "dram":
+		return DRAM, nil
+	case "hbm", "mcdram":
+		return HBM, nil
+	case "cxl", "cxl-dram":
+		return CXL, nil
+	case "pmem", "nvm", "nvdimm":
+		return PMEM, nil
+	default:
+		return DRAM, fmt.Errorf("unknown memory tier: '%s'", str)
+	}
+}
+
+// MarshalJSON returns the MemoryTier marshalled in JSON, or a non-nil error
+// value in case of failure.
+func (mt MemoryTier) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToLower(mt.String()))
+}
+
+// UnmarshalJSON attempts to unmarshal the MemoryTier from the provided byte
+// slice and returns a non-nil error if it fails.
+func (mt *MemoryTier) UnmarshalJSON(data []byte) (err error) {
+	var str string
+	if err = json.Unmarshal(data, &str); err != nil {
+		return
+	}
+	*mt, err = ParseMemoryTier(str)
+	return
+}
+
 // ProcessingKind enumerates all types of computation units that can be used by
 // by this package.
 type ProcessingKind byte
@@ -259,8 +637,13 @@ func (pk ProcessingKind) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON attempts to unmarshal the ProcessingKind from the provided
 // byte slice and returns a non-nil error if it fails.
-func (pk *ProcessingKind) UnmarshalJSON(data []byte) error {
-	return json.Unmarshal(data, pk)
+func (pk *ProcessingKind) UnmarshalJSON(data []byte) (err error) {
+	var str string
+	if err = json.Unmarshal(data, &str); err != nil {
+		return
+	}
+	*pk, err = ParseProcessingKind(str)
+	return
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -325,9 +708,10 @@ func (cl CacheLevel) String() string {
 }
 
 // ParseCacheLevel returns a CacheLevel parsed from the provided string
-// representation, or a non-nil error value if parsing fails.
+// representation (e.g., "L3" or "l3"), or a non-nil error value if parsing
+// fails.
 func ParseCacheLevel(level string) (CacheLevel, error) {
-	switch level {
+	switch strings.ToUpper(level) {
 	case "L1":
 		return L1, nil
 	case "L2":
@@ -351,8 +735,13 @@ func (cl CacheLevel) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON attempts to unmarshal the CacheLevel from the provided byte
 // slice and returns a non-nil error if it fails.
-func (cl *CacheLevel) UnmarshalJSON(data []byte) error {
-	return json.Unmarshal(data, cl)
+func (cl *CacheLevel) UnmarshalJSON(data []byte) (err error) {
+	var str string
+	if err = json.Unmarshal(data, &str); err != nil {
+		return
+	}
+	*cl, err = ParseCacheLevel(str)
+	return
 }
 
 // CacheAttributes represents various characteristics of the cache that may
@@ -364,9 +753,455 @@ type CacheAttributes struct {
 	Linesize uint32 `json:"line"`
 	// Associativity is the associativity of the cache, in # ways.
 	Associativity int32 `json:"ways"`
+	// Inclusion is the relationship of the Cache with the Caches below it
+	// in the hierarchy (e.g., Exclusive for victim caches); it is omitted
+	// for inclusive Caches.
+	Inclusion CacheInclusion `json:"incl,omitempty"`
 }
 
 // String returns the string representation of the CacheAttributes.
 func (ca *CacheAttributes) String() string {
+	if ca.Inclusion != Inclusive {
+		return fmt.Sprintf("%dB/%dB/%d-way/%s", ca.Size, ca.Linesize, ca.Associativity, ca.Inclusion)
+	}
 	return fmt.Sprintf("%dB/%dB/%d-way", ca.Size, ca.Linesize, ca.Associativity)
 }
+
+// CacheInclusion represents the relationship of a Cache with the Caches below
+// it in the hierarchy (e.g., an L3 with the L2s of the Cores that share it).
+type CacheInclusion byte
+
+const (
+	// Inclusive Caches hold copies of all lines that the Caches below them
+	// hold; hence, the Caches below them add nothing to their capacity.
+	//
+	// This is the default, as it has always been assumed by this package.
+	Inclusive CacheInclusion = iota
+	// NonInclusive Caches may or may not hold copies of the lines that the
+	// Caches below them hold (e.g., "NINE" L3s of recent Intel server
+	// processors).
+	NonInclusive
+	// Exclusive Caches never hold copies of the lines that the Caches below
+	// them hold, as they are only filled by the lines evicted from them
+	// (i.e., they are victim caches, like the L3s of AMD Zen processors).
+	Exclusive
+)
+
+// String returns the string representation of the CacheInclusion.
+func (ci CacheInclusion) String() string {
+	switch ci {
+	case Inclusive:
+		return "Inclusive"
+	case NonInclusive:
+		return "NonInclusive"
+	case Exclusive:
+		return "Exclusive"
+	default:
+		return fmt.Sprintf("Unknown cache inclusion %d", ci)
+	}
+}
+
+// ParseCacheInclusion returns a CacheInclusion parsed from the provided string
+// representation (e.g., "exclusive" or "victim"), or a non-nil error value if
+// parsing fails.
+func ParseCacheInclusion(str string) (CacheInclusion, error) {
+	switch strings.ToLower(str) {
+	case "inclusive":
+		return Inclusive, nil
+	case "noninclusive", "non-inclusive", "non_inclusive":
+		return NonInclusive, nil
+	case "exclusive", "victim":
+		return Exclusive, nil
+	default:
+		return Inclusive, fmt.Errorf("unknown cache inclusion: '%s'", str)
+	}
+}
+
+// MarshalJSON returns the CacheInclusion marshalled in JSON, or a non-nil
+// error value in case of failure.
+func (ci CacheInclusion) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToLower(ci.String()))
+}
+
+// UnmarshalJSON attempts to unmarshal the CacheInclusion from the provided
+// byte slice and returns a non-nil error if it fails.
+func (ci *CacheInclusion) UnmarshalJSON(data []byte) (err error) {
+	var str string
+	if err = json.Unmarshal(data, &str); err != nil {
+		return
+	}
+	*ci, err = ParseCacheInclusion(str)
+	return
+}
+
+// FullyAssociative is the Associativity of fully associative caches, as
+// reported by hwloc.
+const FullyAssociative = -1
+
+// Sets returns the number of sets of the cache (i.e., its size divided by the
+// size of its lines and its associativity), 1 for fully associative caches, or
+// 0 if any of the attributes it depends on is unknown (i.e., 0).
+//
+// See Validate for the attributes that are consistent with each other.
+func (ca *CacheAttributes) Sets() uint64 {
+	if nil == ca || ca.Size == 0 || ca.Linesize == 0 || ca.Associativity == 0 {
+		return 0
+	}
+	if ca.Associativity == FullyAssociative {
+		return 1
+	}
+	if ca.Associativity < 0 {
+		return 0
+	}
+	return ca.Size / (uint64(ca.Linesize) * uint64(ca.Associativity))
+}
+
+// Validate makes sure that the CacheAttributes are consistent with each other,
+// and returns a non-nil error value, which wraps ErrInvalidElement, describing
+// the first impossible combination found, if any; such combinations indicate
+// bugs of the collector that reported them.
+//
+// Attributes that are 0 are considered unknown, and are not checked. The
+// others must satisfy the following:
+//   - the line size is a power of 2;
+//   - the associativity is either positive or FullyAssociative;
+//   - the size is a multiple of the line size times the associativity (i.e.,
+//     the cache consists of a whole number of sets), or a multiple of the
+//     line size for fully associative caches.
+func (ca *CacheAttributes) Validate() error {
+	if nil == ca {
+		return nil
+	}
+	if ca.Linesize&(ca.Linesize-1) != 0 {
+		return fmt.Errorf("%w: line size %d is not a power of 2", ErrInvalidElement, ca.Linesize)
+	}
+	if ca.Associativity < FullyAssociative {
+		return fmt.Errorf("%w: invalid associativity %d", ErrInvalidElement, ca.Associativity)
+	}
+	if ca.Size == 0 || ca.Linesize == 0 || ca.Associativity == 0 {
+		return nil
+	}
+	setSize := uint64(ca.Linesize)
+	if ca.Associativity != FullyAssociative {
+		setSize *= uint64(ca.Associativity)
+	}
+	if ca.Size%setSize != 0 {
+		return fmt.Errorf("%w: size %d is not a multiple of %d (i.e., line size times associativity)",
+			ErrInvalidElement, ca.Size, setSize)
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+////
+////	Device
+////
+///////////////////////////////////////////////////////////////////////////////
+
+// Device represents an I/O device (e.g., a GPU or a NIC) in the hardware
+// topology. Devices are attached under the Element that they are local to
+// (e.g., the NUMA node or the Package of their PCI root complex, or the
+// Machine if their locality is unknown), and may only have other Devices as
+// children.
+type Device struct {
+	// Type indicates the type of device that this Device is.
+	Type DeviceType `json:"type"`
+	// Name is the name of the device, as assigned by the operating system
+	// (e.g., "eth0" or "nvidia0"), if any.
+	Name string `json:"name,omitempty"`
+	// BusID is the PCI bus ID of the device (e.g., "0000:3b:00.0"), if
+	// any.
+	BusID string `json:"bus,omitempty"`
+	// Class is the class of the device, as reported by the operating
+	// system or the collector (e.g., "dma" or "infiniband"), if any; it
+	// is mostly useful for OSDevices.
+	Class string `json:"class,omitempty"`
+	// Path is the sysfs path of the device (e.g.,
+	// "/sys/class/dma/dma0chan0"), if known.
+	Path string `json:"path,omitempty"`
+	// PhysicalFunction is the PCI bus ID of the SR-IOV physical function
+	// that the device is a virtual function of, if any; virtual functions
+	// are attached under their physical function.
+	PhysicalFunction string `json:"pf,omitempty"`
+	// Links lists the direct interconnect links (e.g., NVLink or xGMI) of
+	// the device to its peers, if any; each link is listed by only one of
+	// its two ends.
+	Links []DeviceLink `json:"links,omitempty"`
+	// CXLLink describes the CXL link that the device is attached through,
+	// if any.
+	CXLLink *CXLLink `json:"cxl,omitempty"`
+	// MemoryNodes lists the OS indices of the NUMA nodes that consist of
+	// memory contributed by the device (e.g., by a CXL memory expander),
+	// if any.
+	MemoryNodes []uint32 `json:"mem,omitempty"`
+}
+
+// CXLLink describes the Compute Express Link (CXL) that a Device is attached
+// through.
+type CXLLink struct {
+	// Version is the CXL version that the link operates at (e.g., "2.0"),
+	// if known.
+	Version string `json:"ver,omitempty"`
+	// Width is the number of lanes of the link, or zero if it is unknown.
+	Width uint32 `json:"width,omitempty"`
+	// Bandwidth is the bandwidth of the link, in bytes per second, or
+	// zero if it is unknown.
+	Bandwidth uint64 `json:"bw,omitempty"`
+	// Latency is the access latency through the link, in nanoseconds, or
+	// zero if it is unknown.
+	Latency uint32 `json:"lat,omitempty"`
+}
+
+// String returns the string representation of the CXLLink.
+func (l *CXLLink) String() string {
+	if nil == l {
+		return "<nil>"
+	}
+	var sb strings.Builder
+	sb.WriteString("CXL")
+	if l.Version != "" {
+		sb.WriteString(" " + l.Version)
+	}
+	if l.Width != 0 {
+		fmt.Fprintf(&sb, " x%d", l.Width)
+	}
+	if l.Bandwidth != 0 {
+		fmt.Fprintf(&sb, " %dB/s", l.Bandwidth)
+	}
+	if l.Latency != 0 {
+		fmt.Fprintf(&sb, " %dns", l.Latency)
+	}
+	return sb.String()
+}
+
+// DeviceLink is a direct interconnect link between two Devices, which bypasses
+// the hierarchy of the hardware topology.
+type DeviceLink struct {
+	// Peer is the PCI bus ID of the Device at the other end of the link.
+	Peer string `json:"peer"`
+	// Interconnect is the kind of the link (e.g., "nvlink" or "xgmi"),
+	// if known.
+	Interconnect string `json:"type,omitempty"`
+	// Bandwidth is the bandwidth of the link, in bytes per second, or
+	// zero if it is unknown.
+	Bandwidth uint64 `json:"bw,omitempty"`
+}
+
+// String returns the string representation of the Device.
+func (d *Device) String() string {
+	ids := make([]string, 0, 3)
+	for _, id := range []string{d.Name, d.BusID} {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if d.Class != "" {
+		ids = append(ids, "class "+d.Class)
+	}
+	if d.Path != "" {
+		ids = append(ids, d.Path)
+	}
+	if d.PhysicalFunction != "" {
+		ids = append(ids, "VF of "+d.PhysicalFunction)
+	}
+	for _, link := range d.Links {
+		if link.Interconnect != "" {
+			ids = append(ids, fmt.Sprintf("%s link to %s", link.Interconnect, link.Peer))
+		} else {
+			ids = append(ids, "link to "+link.Peer)
+		}
+	}
+	if nil != d.CXLLink {
+		ids = append(ids, d.CXLLink.String()+" link")
+	}
+	if len(d.MemoryNodes) > 0 {
+		ids = append(ids, "memory of NUMA nodes "+FormatCPUList(d.MemoryNodes))
+	}
+	return fmt.Sprintf("%s(%s)", d.Type, strings.Join(ids, ", "))
+}
+
+// parseDeviceLinks returns the DeviceLinks parsed from the provided value of
+// the "links" member of a Device's JSON object, or a non-nil error value if it
+// is malformed.
+func parseDeviceLinks(val interface{}) ([]DeviceLink, error) {
+	raw, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("malformed 'links'")
+	}
+	links := make([]DeviceLink, 0, len(raw))
+	for _, rawLink := range raw {
+		obj, _ := rawLink.(map[string]interface{})
+		peer, peerOk := obj["peer"].(string)
+		if !peerOk || peer == "" {
+			return nil, fmt.Errorf("missing or malformed link 'peer'")
+		}
+		link := DeviceLink{Peer: peer}
+		if typ, ok := obj["type"]; ok {
+			if link.Interconnect, ok = typ.(string); !ok {
+				return nil, fmt.Errorf("malformed link 'type' '%v'", typ)
+			}
+		}
+		if bw, ok := obj["bw"]; ok {
+			f, ok := bw.(float64)
+			if !ok || f < 0 || f > math.MaxUint64 || f != math.Trunc(f) {
+				return nil, fmt.Errorf("malformed link 'bw' '%v'", bw)
+			}
+			link.Bandwidth = uint64(f)
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// parseCXLLink returns the CXLLink parsed from the provided value of the "cxl"
+// member of a Device's JSON object, or a non-nil error value if it is
+// malformed.
+func parseCXLLink(val interface{}) (*CXLLink, error) {
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("malformed 'cxl': expected an object")
+	}
+	link := &CXLLink{}
+	if ver, ok := obj["ver"]; ok {
+		if link.Version, ok = ver.(string); !ok {
+			return nil, fmt.Errorf("malformed CXL link 'ver' '%v'", ver)
+		}
+	}
+	for _, field := range []struct {
+		key string
+		max float64
+	}{{"width", math.MaxUint32}, {"bw", math.MaxUint64}, {"lat", math.MaxUint32}} {
+		raw, ok := obj[field.key]
+		if !ok {
+			continue
+		}
+		f, ok := raw.(float64)
+		if !ok || f < 0 || f > field.max || f != math.Trunc(f) {
+			return nil, fmt.Errorf("malformed CXL link '%s' '%v'", field.key, raw)
+		}
+		switch field.key {
+		case "width":
+			link.Width = uint32(f)
+		case "bw":
+			link.Bandwidth = uint64(f)
+		case "lat":
+			link.Latency = uint32(f)
+		}
+	}
+	return link, nil
+}
+
+// parseMemoryNodes returns the OS indices of NUMA nodes parsed from the
+// provided value of the "mem" member of a Device's JSON object, or a non-nil
+// error value if it is malformed.
+func parseMemoryNodes(val interface{}) ([]uint32, error) {
+	raw, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("malformed 'mem': expected an array")
+	}
+	ret := make([]uint32, 0, len(raw))
+	for _, rawID := range raw {
+		f, ok := rawID.(float64)
+		if !ok || f < 0 || f > math.MaxUint32 || f != math.Trunc(f) {
+			return nil, fmt.Errorf("malformed 'mem': invalid NUMA node '%v'", rawID)
+		}
+		ret = append(ret, uint32(f))
+	}
+	return ret, nil
+}
+
+// IsVirtualFunction returns true if the Device is an SR-IOV virtual function
+// and false otherwise.
+func (d *Device) IsVirtualFunction() bool {
+	return d.PhysicalFunction != ""
+}
+
+// DeviceType enumerates all types of I/O devices that can be used by this
+// package.
+type DeviceType byte
+
+const (
+	// UnknownDeviceType is employed to represent any unknown DeviceType
+	// found in the wild.
+	UnknownDeviceType DeviceType = iota
+	// GPU represents a graphics processing unit.
+	GPU
+	// NIC represents a network interface controller.
+	NIC
+	// Accelerator represents any other compute accelerator (e.g., an FPGA
+	// or a co-processor).
+	Accelerator
+	// Storage represents a storage controller or a block device.
+	Storage
+	// MemoryExpander represents a CXL type-3 memory expander, whose
+	// memory is exposed as one or more (memory-only) NUMA nodes.
+	MemoryExpander
+	// OSDevice represents any other device that the operating system
+	// exposes (e.g., a DMA engine), described by its Class and Path, so
+	// that its locality is preserved even though it does not warrant a
+	// dedicated DeviceType.
+	OSDevice
+)
+
+// String returns the string representation of the DeviceType.
+func (dt DeviceType) String() string {
+	switch dt {
+	case GPU:
+		return "GPU"
+	case NIC:
+		return "NIC"
+	case Accelerator:
+		return "Accelerator"
+	case Storage:
+		return "Storage"
+	case MemoryExpander:
+		return "MemoryExpander"
+	case OSDevice:
+		return "OSDevice"
+	default:
+		return "UnknownDeviceType"
+	}
+}
+
+// ParseDeviceType returns a DeviceType parsed from the provided string
+// representation, or a non-nil error value if parsing fails.
+//
+// UnknownDeviceType is parsed from its own (lower-case) representation, so
+// that Devices of unknown type survive a round trip through JSON.
+func ParseDeviceType(str string) (DeviceType, error) {
+	switch strings.ToLower(str) {
+	case "unknowndevicetype":
+		return UnknownDeviceType, nil
+	case "gpu":
+		return GPU, nil
+	case "nic", "network", "net":
+		return NIC, nil
+	case "accelerator", "coproc":
+		return Accelerator, nil
+	case "storage", "block":
+		return Storage, nil
+	case "memoryexpander", "cxl", "cxl-mem":
+		return MemoryExpander, nil
+	case "osdevice", "osdev", "other":
+		return OSDevice, nil
+	default:
+		return UnknownDeviceType, fmt.Errorf("unknown device type: '%s'", str)
+	}
+}
+
+// MarshalJSON returns the DeviceType marshalled in JSON, or a non-nil error
+// value in case of failure.
+func (dt DeviceType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToLower(dt.String()))
+}
+
+// UnmarshalJSON attempts to unmarshal the DeviceType from the provided byte
+// slice and returns a non-nil error if it fails.
+func (dt *DeviceType) UnmarshalJSON(data []byte) (err error) {
+	var str string
+	if err = json.Unmarshal(data, &str); err != nil {
+		return
+	}
+	*dt, err = ParseDeviceType(str)
+	return
+}
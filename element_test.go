@@ -0,0 +1,191 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestStandaloneEnumUnmarshal(t *testing.T) {
+	var s struct {
+		Kind  ProcessingKind `json:"kind"`
+		Level CacheLevel     `json:"lvl"`
+	}
+	if err := json.Unmarshal([]byte(`{"kind":"numa_node","lvl":"l3"}`), &s); err != nil {
+		t.Fatalf("Error unmarshaling JSON: %v\n", err)
+	}
+	if s.Kind != NUMANode || s.Level != L3 {
+		t.Errorf("got (%s, %s); want (%s, %s)", s.Kind, s.Level, NUMANode, L3)
+	}
+
+	// Round-trip through MarshalJSON.
+	data, err := json.Marshal(&s)
+	if err != nil {
+		t.Fatalf("Error marshaling JSON: %v\n", err)
+	}
+	if err = json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("Error unmarshaling remarshaled JSON %s: %v\n", data, err)
+	}
+
+	var pk ProcessingKind
+	if err = json.Unmarshal([]byte(`"socket"`), &pk); err == nil {
+		t.Errorf("unmarshaling an unknown ProcessingKind succeeded")
+	}
+	var cl CacheLevel
+	if err = json.Unmarshal([]byte(`3`), &cl); err == nil {
+		t.Errorf("unmarshaling a non-string CacheLevel succeeded")
+	}
+	var dt DeviceType
+	if err = json.Unmarshal([]byte(`"modem"`), &dt); err == nil {
+		t.Errorf("unmarshaling an unknown DeviceType succeeded")
+	}
+}
+
+func TestDeviceTypeRoundTrip(t *testing.T) {
+	for _, dt := range []DeviceType{UnknownDeviceType, GPU, NIC, Accelerator, Storage, MemoryExpander, OSDevice} {
+		data, err := json.Marshal(dt)
+		if err != nil {
+			t.Fatalf("Failed to marshal %s: %v", dt, err)
+		}
+		var got DeviceType
+		if err = json.Unmarshal(data, &got); err != nil || got != dt {
+			t.Errorf("got %s (%v) after a round trip of %s; want %s", got, err, data, dt)
+		}
+
+		element := &Element{Device: &Device{Type: dt, Name: "dev0"}}
+		if data, err = json.Marshal(element); err != nil {
+			t.Fatalf("Failed to marshal %s: %v", element, err)
+		}
+		var e Element
+		if err = json.Unmarshal(data, &e); err != nil || !e.IsDevice() || e.Device.Type != dt || e.Name != "dev0" {
+			t.Errorf("got %s (%v) after a round trip of %s; want %s", &e, err, data, element)
+		}
+	}
+}
+
+func TestElementMarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		element *Element
+		want    string
+	}{
+		{&Element{}, `"machine"`},
+		{&Element{Processing: &Processing{Kind: NUMANode, ID: 1}}, `{"processing":{"kind":"numanode","id":1}}`},
+		{&Element{Processing: &Processing{Kind: NUMANode, ID: 1, Memory: &MemoryAttributes{Bytes: 1 << 30}}},
+			`{"processing":{"kind":"numanode","id":1,"mem":{"bytes":1073741824}}}`},
+		{&Element{Processing: &Processing{Kind: NUMANode, ID: 2, Memory: &MemoryAttributes{Bytes: 1 << 30, Tier: CXL}}},
+			`{"processing":{"kind":"numanode","id":2,"mem":{"bytes":1073741824,"tier":"cxl"}}}`},
+		{&Element{Cache: &Cache{Level: L2, LogicalIndex: 3, Attributes: &CacheAttributes{Size: 1 << 20, Linesize: 64, Associativity: -1}}},
+			`{"cache":{"lvl":"L2","li":3,"attrs":{"size":1048576,"line":64,"ways":-1}}}`},
+		{&Element{Cache: &Cache{Level: L1}}, `{"cache":{"lvl":"L1","li":0,"attrs":null}}`},
+		{&Element{Device: &Device{Type: GPU, Name: "nvidia0", BusID: "0000:3b:00.0"}},
+			`{"device":{"type":"gpu","name":"nvidia0","bus":"0000:3b:00.0"}}`},
+		{&Element{Device: &Device{Type: NIC, Name: "eth\"0\""}}, `{"device":{"type":"nic","name":"eth\"0\""}}`},
+		{&Element{Device: &Device{Type: OSDevice, Name: "dma0chan0", Class: "dma", Path: "/sys/class/dma/dma0chan0"}},
+			`{"device":{"type":"osdevice","name":"dma0chan0","class":"dma","path":"/sys/class/dma/dma0chan0"}}`},
+		{&Element{Device: &Device{Type: MemoryExpander, CXLLink: &CXLLink{Width: 16, Latency: 200}, MemoryNodes: []uint32{2, 3}}},
+			`{"device":{"type":"memoryexpander","cxl":{"width":16,"lat":200},"mem":[2,3]}}`},
+		{&Element{Device: &Device{Type: GPU, BusID: "0000:3b:00.0", Links: []DeviceLink{{Peer: "0000:86:00.0", Interconnect: "nvlink", Bandwidth: 25e9}, {Peer: "0000:af:00.0"}}}},
+			`{"device":{"type":"gpu","bus":"0000:3b:00.0","links":[{"peer":"0000:86:00.0","type":"nvlink","bw":25000000000},{"peer":"0000:af:00.0"}]}}`},
+		{&Element{Cache: &Cache{Level: L3, Attributes: &CacheAttributes{Size: 32 << 20, Linesize: 64, Associativity: 16, Inclusion: Exclusive}}},
+			`{"cache":{"lvl":"L3","li":0,"attrs":{"size":33554432,"line":64,"ways":16,"incl":"exclusive"}}}`},
+	} {
+		got, err := json.Marshal(tc.element)
+		if err != nil {
+			t.Errorf("Failed to marshal %s: %v", tc.element, err)
+		} else if string(got) != tc.want {
+			t.Errorf("got %s; want %s", got, tc.want)
+		}
+		// The hand-written encoding must agree with the decoding.
+		if !tc.element.IsCache() || nil == tc.element.Attributes {
+			continue
+		}
+		var e Element
+		if err = json.Unmarshal(got, &e); err != nil {
+			t.Errorf("Failed to unmarshal %s: %v", got, err)
+		} else if *e.Attributes != *tc.element.Attributes {
+			t.Errorf("got %s after a round trip; want %s", e.Attributes, tc.element.Attributes)
+		}
+	}
+	var e Element
+	if err := json.Unmarshal([]byte(`{"cache":{"lvl":"L3","li":0,"attrs":{"size":1,"line":1,"ways":1,"incl":"sometimes"}}}`), &e); !errors.Is(err, ErrInvalidElement) {
+		t.Errorf("got %v for an unknown cache inclusion; want ErrInvalidElement", err)
+	}
+	if err := json.Unmarshal([]byte(`{"device":{"type":"gpu","name":7}}`), &e); !errors.Is(err, ErrInvalidElement) {
+		t.Errorf("got %v for a malformed device name; want ErrInvalidElement", err)
+	}
+	if _, err := json.Marshal(&Element{Processing: &Processing{}, Cache: &Cache{}}); !errors.Is(err, ErrInvalidElement) {
+		t.Errorf("got %v for an invalid Element; want ErrInvalidElement", err)
+	}
+}
+
+func BenchmarkTreeMarshalJSON(b *testing.B) {
+	tree := syntheticTree(2, 2, 32, 2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(tree); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkElementMarshalJSON(b *testing.B) {
+	elements := []*Element{
+		{},
+		{Processing: &Processing{Kind: NUMANode, ID: 1}},
+		{Cache: &Cache{Level: L2, LogicalIndex: 3, Attributes: &CacheAttributes{Size: 1 << 20, Linesize: 64, Associativity: 16}}},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range elements {
+			if _, err := e.MarshalJSON(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestCacheAttributesSets(t *testing.T) {
+	for _, tc := range []struct {
+		attrs *CacheAttributes
+		sets  uint64
+		valid bool
+	}{
+		{&CacheAttributes{Size: 32 << 10, Linesize: 64, Associativity: 8}, 64, true},
+		{&CacheAttributes{Size: 60 << 20, Linesize: 64, Associativity: 12}, 81920, true},
+		{&CacheAttributes{Size: 4 << 10, Linesize: 64, Associativity: FullyAssociative}, 1, true},
+		{&CacheAttributes{Size: 32 << 10, Linesize: 64}, 0, true},
+		{&CacheAttributes{}, 0, true},
+		{nil, 0, true},
+		{&CacheAttributes{Size: 1280 << 10, Linesize: 64, Associativity: 12}, 1706, false},
+		{&CacheAttributes{Size: 32 << 10, Linesize: 48, Associativity: 8}, 85, false},
+		{&CacheAttributes{Size: 32 << 10, Linesize: 64, Associativity: -2}, 0, false},
+		{&CacheAttributes{Size: 100, Linesize: 64, Associativity: FullyAssociative}, 1, false},
+	} {
+		if sets := tc.attrs.Sets(); sets != tc.sets {
+			t.Errorf("%v: got %d sets; expected %d", tc.attrs, sets, tc.sets)
+		}
+		if err := tc.attrs.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%v: got %v; expected valid = %t", tc.attrs, err, tc.valid)
+		} else if err != nil && !errors.Is(err, ErrInvalidElement) {
+			t.Errorf("%v: got %v; expected ErrInvalidElement", tc.attrs, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Metadata describes the capture of a Topology.
+type Metadata struct {
+	// CapturedAt is the time that the Topology was captured at.
+	CapturedAt time.Time `json:"captured_at"`
+	// Collector and CollectorVersion identify the software that captured
+	// the Topology.
+	Collector        string `json:"collector,omitempty"`
+	CollectorVersion string `json:"collector_version,omitempty"`
+	// Hostname is the name of the machine that the Topology was captured
+	// on, and KernelVersion is the version of its kernel at the time.
+	Hostname      string `json:"hostname,omitempty"`
+	KernelVersion string `json:"kernel_version,omitempty"`
+	// Fingerprint is the Fingerprint of the Topology.
+	Fingerprint *Fingerprint `json:"fingerprint,omitempty"`
+}
+
+// Envelope wraps a Topology along with the Metadata of its capture.
+//
+// It is marshalled in JSON as an object with the fields "metadata" and
+// "topology", the latter of which holds the Topology as a bare Tree payload
+// would; every decoding API of the package accepts both forms, so producers
+// may switch to Envelopes before all of their consumers have been upgraded.
+type Envelope struct {
+	Metadata Metadata  `json:"metadata"`
+	Topology *Topology `json:"topology"`
+}
+
+// NewEnvelope returns a new Envelope for the provided Topology and Metadata,
+// filling in the Fingerprint of the Topology if the Metadata lack it, or a
+// non-nil error value in case of failure.
+func NewEnvelope(topo *Topology, meta Metadata) (*Envelope, error) {
+	fp, err := topo.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	if nil == meta.Fingerprint {
+		meta.Fingerprint = &fp
+	} else if *meta.Fingerprint != fp {
+		return nil, ErrFingerprintMismatch
+	}
+	return &Envelope{Metadata: meta, Topology: topo}, nil
+}
+
+// WriteEnvelope writes the provided Topology to the provided io.Writer in JSON,
+// wrapped in an Envelope along with the provided Metadata (see NewEnvelope), or
+// returns a non-nil error value in case of failure.
+func WriteEnvelope(w io.Writer, topo *Topology, meta Metadata) error {
+	envelope, err := NewEnvelope(topo, meta)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(envelope)
+}
+
+// ReadEnvelope reads a JSON payload from the provided io.Reader and returns the
+// Envelope decoded from it, through a Decoder without any DecodeLimits (see
+// Decoder.DecodeEnvelope).
+func ReadEnvelope(r io.Reader) (*Envelope, error) {
+	return NewDecoder(DecodeLimits{}).DecodeEnvelope(r)
+}
+
+// DecodeEnvelope reads a JSON payload from the provided io.Reader and returns
+// the Envelope decoded from it, or a non-nil error value in case of failure,
+// much like Decode does.
+//
+// For backwards compatibility, the payload may also be a bare Tree, in which
+// case the Metadata of the returned Envelope are zero. If the Metadata record
+// a Fingerprint, it must match the Topology, or else ErrFingerprintMismatch is
+// returned.
+func (d *Decoder) DecodeEnvelope(r io.Reader) (*Envelope, error) {
+	topo, meta, err := d.decode(r, true)
+	if err != nil {
+		return nil, err
+	}
+	envelope := &Envelope{Topology: topo}
+	if nil == meta {
+		return envelope, nil
+	}
+	envelope.Metadata = *meta
+	if nil != meta.Fingerprint {
+		fp, err := topo.Fingerprint()
+		if err != nil {
+			return nil, err
+		}
+		if fp != *meta.Fingerprint {
+			return nil, fmt.Errorf("%w: recorded %s, computed %s", ErrFingerprintMismatch, meta.Fingerprint.Full, fp.Full)
+		}
+	}
+	return envelope, nil
+}
+
+// UnmarshalJSON attempts to unmarshal the Envelope from the provided byte
+// slice (see Decoder.DecodeEnvelope) and returns a non-nil error if it fails.
+func (e *Envelope) UnmarshalJSON(data []byte) error {
+	envelope, err := ReadEnvelope(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	*e = *envelope
+	return nil
+}
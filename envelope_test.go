@@ -0,0 +1,91 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvelope(t *testing.T) {
+	topo := &Topology{syntheticTree(1, 1, 2, 2)}
+	meta := Metadata{
+		CapturedAt:       time.Date(2022, 7, 17, 12, 36, 38, 0, time.UTC),
+		Collector:        "actitopo",
+		CollectorVersion: "v0.1.0",
+		Hostname:         "node-a",
+		KernelVersion:    "5.15.0",
+	}
+	var buf bytes.Buffer
+	if err := WriteEnvelope(&buf, topo, meta); err != nil {
+		t.Fatalf("Failed to write Envelope: %v\n", err)
+	}
+	payload := buf.Bytes()
+
+	envelope, err := ReadEnvelope(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Failed to read Envelope: %v\n", err)
+	}
+	fp, _ := topo.Fingerprint()
+	if nil == envelope.Metadata.Fingerprint || *envelope.Metadata.Fingerprint != fp {
+		t.Errorf("the Envelope does not record the Fingerprint of the Topology")
+	}
+	envelope.Metadata.Fingerprint = nil
+	if envelope.Metadata != meta || envelope.Topology.Size() != topo.Size() {
+		t.Errorf("got %+v after a round-trip; want %+v", envelope.Metadata, meta)
+	}
+
+	// Consumers that are not aware of Envelopes get the Topology.
+	decoded, err := NewDecoder(DecodeLimits{}).Decode(bytes.NewReader(payload))
+	if err != nil || decoded.Size() != topo.Size() {
+		t.Errorf("got %v when decoding an Envelope as a bare Tree", err)
+	}
+	// Consumers that are aware of Envelopes accept bare Trees.
+	bare, err := os.ReadFile("test_artifacts/t4_de.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromBare Envelope
+	if err = json.Unmarshal(bare, &fromBare); err != nil || fromBare.Metadata != (Metadata{}) || fromBare.Topology.Size() != 41 {
+		t.Errorf("got %+v (%v) for a bare Tree", fromBare.Metadata, err)
+	}
+
+	tampered := strings.Replace(string(payload), `"id":3`, `"id":9`, 1)
+	if _, err = ReadEnvelope(strings.NewReader(tampered)); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("got %v for a tampered Topology; want ErrFingerprintMismatch", err)
+	}
+	other := &Topology{syntheticTree(1, 1, 1, 1)}
+	if _, err = NewEnvelope(topo, Metadata{Fingerprint: &fp}); err != nil {
+		t.Errorf("got %v for a matching Fingerprint", err)
+	}
+	if _, err = NewEnvelope(other, Metadata{Fingerprint: &fp}); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("got %v for a mismatching Fingerprint; want ErrFingerprintMismatch", err)
+	}
+
+	// Offsets refer to the whole payload.
+	broken := `{"metadata":{"hostname":"x"},"topology":{"nodes":[{"data":"machine","desc":[1]},{"data":{"processing":{"kind":"gpu","id":0}}}]}}`
+	var nodeErr *NodeError
+	if _, err = ReadEnvelope(strings.NewReader(broken)); !errors.As(err, &nodeErr) ||
+		!strings.HasPrefix(broken[nodeErr.Offset:], `{"data":{"processing"`) {
+		t.Errorf("got %v for a malformed Element", err)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNilTree is returned when a method is called on a nil Tree.
+	ErrNilTree = errors.New("Tree is nil")
+	// ErrEmptyTree is returned when a method that requires at least one
+	// Element is called on an empty Tree.
+	ErrEmptyTree = errors.New("Tree is empty")
+	// ErrNoParent is returned when querying for the parent of the root
+	// Element of the Tree.
+	ErrNoParent = errors.New("Root element does not have a parent")
+	// ErrOrphan is returned when querying for the parent of an Element
+	// that is not the root of the Tree, but is not listed as a child of
+	// any other Element either (which is only possible with malformed
+	// input).
+	ErrOrphan = errors.New("Element is not a child of any other element")
+	// ErrInvalidElement is returned when an Element is malformed or cannot
+	// be unmarshalled.
+	ErrInvalidElement = errors.New("invalid Element")
+	// ErrMultipleParents is returned when the Tree contains an Element that
+	// is listed as a child more than once (i.e., the hierarchy is a DAG
+	// rather than a tree).
+	ErrMultipleParents = errors.New("Element has multiple parents")
+	// ErrDuplicateID is returned when the Tree contains two Processing
+	// elements of the same kind with the same OS index, or two Caches of
+	// the same level with the same logical index.
+	ErrDuplicateID = errors.New("Tree contains duplicate IDs")
+	// ErrLimitExceeded is returned when a payload being decoded exceeds
+	// one of the configured DecodeLimits.
+	ErrLimitExceeded = errors.New("decode limit exceeded")
+	// ErrCycle is returned when the Tree contains an Element that lists
+	// itself or one of its ancestors as a child.
+	ErrCycle = errors.New("Tree contains a cycle")
+	// ErrNotPreOrder is returned when the Elements of the Tree are not
+	// stored in pre-order (see Tree).
+	ErrNotPreOrder = errors.New("Tree is not stored in pre-order")
+	// ErrFingerprintMismatch is returned when the Fingerprint recorded in
+	// the Metadata of an Envelope does not match its Topology.
+	ErrFingerprintMismatch = errors.New("Fingerprint does not match the Topology")
+	// ErrNodeNotFound is returned when a Store holds no Topology for the
+	// requested node.
+	ErrNodeNotFound = errors.New("node not found")
+	// ErrNotApplicable is returned when a Perturbation cannot be applied
+	// to a Topology (e.g., dropping an L3 cache of a Topology without any).
+	ErrNotApplicable = errors.New("perturbation is not applicable")
+	// ErrSignatureMismatch is returned when the HMAC of a signed payload
+	// is missing or does not match its Topology (see Verify).
+	ErrSignatureMismatch = errors.New("HMAC does not match the Topology")
+)
+
+// ErrInvalidNodeID is returned when a NodeID that does not correspond to any
+// Element in the Tree is provided.
+//
+// Any two ErrInvalidNodeID values match each other through errors.Is,
+// regardless of their ID; use errors.As to retrieve the offending NodeID.
+type ErrInvalidNodeID struct {
+	// ID is the offending NodeID.
+	ID NodeID
+}
+
+// Error returns the string representation of the ErrInvalidNodeID.
+func (e ErrInvalidNodeID) Error() string {
+	return fmt.Sprintf("Invalid NodeID %d", e.ID)
+}
+
+// Is returns true if the target error is an ErrInvalidNodeID too.
+func (e ErrInvalidNodeID) Is(target error) bool {
+	_, ok := target.(ErrInvalidNodeID)
+	return ok
+}
+
+// NodeError provides context about the TreeNode that caused a decoding or
+// validation error.
+//
+// It wraps the underlying error, so that callers can still match it against
+// the package's sentinel errors through errors.Is and errors.As.
+type NodeError struct {
+	// ID is the NodeID of the offending TreeNode.
+	ID NodeID
+	// Kind is a description of the kind of the offending Element (e.g.,
+	// "Machine", "Thread", "L3", "Cache"), or empty if it is unknown.
+	Kind string
+	// Offset is the byte offset of the offending TreeNode in the JSON
+	// payload, or -1 if it is not available (e.g., for Trees that were not
+	// decoded from JSON).
+	Offset int64
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the string representation of the NodeError.
+func (e *NodeError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "node %d", e.ID)
+	if e.Kind != "" {
+		fmt.Fprintf(&sb, " (%s)", e.Kind)
+	}
+	if e.Offset >= 0 {
+		fmt.Fprintf(&sb, " at offset %d", e.Offset)
+	}
+	fmt.Fprintf(&sb, ": %v", e.Err)
+	return sb.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// elementKind returns a description of the kind of the provided Element, to be
+// used as the Kind of a NodeError.
+func elementKind(e *Element) string {
+	switch {
+	case nil == e:
+		return ""
+	case e.IsRoot():
+		return "Machine"
+	case e.IsProcessing():
+		return e.Kind.String()
+	case e.IsCache():
+		return e.Level.String()
+	case e.IsDevice():
+		return e.Type.String()
+	default:
+		return "InvalidElement"
+	}
+}
@@ -0,0 +1,105 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	var nilTree *Tree
+	if _, err := nilTree.Get(0); !errors.Is(err, ErrNilTree) {
+		t.Errorf("nil Tree: got %v; want ErrNilTree", err)
+	}
+	if _, err := (&Tree{}).Root(); !errors.Is(err, ErrEmptyTree) {
+		t.Errorf("empty Tree: got %v; want ErrEmptyTree", err)
+	}
+
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+	if _, err := topo.ParentID(0); !errors.Is(err, ErrNoParent) {
+		t.Errorf("ParentID(0): got %v; want ErrNoParent", err)
+	}
+
+	_, err := topo.AncestorIDs(4242)
+	if !errors.Is(err, ErrInvalidNodeID{}) {
+		t.Errorf("AncestorIDs(4242): got %v; want ErrInvalidNodeID", err)
+	}
+	var invalid ErrInvalidNodeID
+	if !errors.As(err, &invalid) || invalid.ID != 4242 {
+		t.Errorf("AncestorIDs(4242): got %v; want ErrInvalidNodeID{ID: 4242}", err)
+	}
+	if errors.Is(err, ErrNoParent) {
+		t.Errorf("AncestorIDs(4242): %v matches ErrNoParent", err)
+	}
+}
+
+func TestNoPanicsOnMalformedInput(t *testing.T) {
+	// Element 2 is not listed as a child of any other element.
+	tree := NewTree([]TreeNode{
+		{Data: &Element{}, Children: []NodeID{1}},
+		{Data: &Element{Processing: &Processing{Kind: Thread, ID: 0}}},
+		{Data: &Element{Processing: &Processing{Kind: Thread, ID: 1}}},
+	})
+	if _, err := tree.ParentID(2); !errors.Is(err, ErrOrphan) {
+		t.Errorf("ParentID(2): got %v; want ErrOrphan", err)
+	}
+	if _, err := tree.Parent(2); !errors.Is(err, ErrOrphan) {
+		t.Errorf("Parent(2): got %v; want ErrOrphan", err)
+	}
+
+	invalid := &Element{
+		Processing: &Processing{Kind: Core, ID: 0},
+		Cache:      &Cache{Level: L1, Attributes: &CacheAttributes{}},
+	}
+	if s := invalid.String(); s != "InvalidElement" {
+		t.Errorf("String() = %q; want \"InvalidElement\"", s)
+	}
+}
+
+func TestNodeErrorContext(t *testing.T) {
+	const badNode = `{"data":{"cache":{"lvl":"L2","li":0}}}`
+	payload := `{"nodes":[{"data":"machine","desc":[1]},` + "\n  " +
+		`{"data":{"processing":{"kind":"core","id":0}},"desc":[2]}, ` + badNode + `]}`
+
+	var topo Topology
+	err := json.Unmarshal([]byte(payload), &topo)
+	if !errors.Is(err, ErrInvalidElement) {
+		t.Fatalf("got %v; want ErrInvalidElement", err)
+	}
+	var nodeErr *NodeError
+	if !errors.As(err, &nodeErr) {
+		t.Fatalf("got %v; want a *NodeError", err)
+	}
+	t.Logf("%v", err)
+	if want := int64(strings.Index(payload, badNode)); nodeErr.ID != 2 || nodeErr.Kind != "Cache" || nodeErr.Offset != want {
+		t.Errorf("got NodeError{ID: %d, Kind: %q, Offset: %d}; want NodeError{ID: 2, Kind: \"Cache\", Offset: %d}",
+			nodeErr.ID, nodeErr.Kind, nodeErr.Offset, want)
+	}
+
+	// Validation errors carry the offending node too.
+	payload = `{"nodes":[{"data":"machine","desc":[1]},{"data":{"processing":{"kind":"core","id":0}},"desc":[1]}]}`
+	err = json.Unmarshal([]byte(payload), &topo)
+	if !errors.As(err, &nodeErr) || !errors.Is(err, ErrCycle) {
+		t.Fatalf("got %v; want a *NodeError wrapping ErrCycle", err)
+	}
+	if nodeErr.ID != 1 || nodeErr.Kind != "Core" || nodeErr.Offset != int64(strings.Index(payload, `{"data":{"processing"`)) {
+		t.Errorf("got %+v", nodeErr)
+	}
+}
@@ -0,0 +1,331 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// EtcdStore is a Store backed by an etcd (v3) cluster, which it accesses
+// through etcd's JSON gateway (i.e., the "/v3/kv/*" and "/v3/watch" HTTP
+// endpoints) rather than through etcd's client library, so that the module
+// has no external dependencies.
+//
+// Each Topology is stored as a bare Tree JSON payload under the key that
+// consists of the EtcdStore's prefix followed by the node's name.
+type EtcdStore struct {
+	// Limits are the DecodeLimits enforced on the payloads read from etcd,
+	// which may have been written by any producer; NewEtcdStore sets them
+	// to DefaultDecodeLimits. Their MaxBytes also bounds the messages of
+	// watches (see Watch).
+	Limits DecodeLimits
+
+	endpoint string
+	prefix   string
+	client   *http.Client
+}
+
+// NewEtcdStore returns a new EtcdStore that accesses the etcd cluster at the
+// provided endpoint (e.g., "http://127.0.0.1:2379") through the provided
+// http.Client (or http.DefaultClient, if nil), and stores the Topologies under
+// the provided key prefix (e.g., "/actik8s/topologies/").
+func NewEtcdStore(endpoint, prefix string, client *http.Client) *EtcdStore {
+	if nil == client {
+		client = http.DefaultClient
+	}
+	return &EtcdStore{Limits: DefaultDecodeLimits, endpoint: strings.TrimSuffix(endpoint, "/"), prefix: prefix, client: client}
+}
+
+// etcdKeyValue is a key-value pair, as returned by etcd's JSON gateway, which
+// encodes all keys and values in base64 (as encoding/json does for []byte).
+type etcdKeyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value,omitempty"`
+}
+
+// etcdRangeRequest is the body of requests to the range and deleterange
+// endpoints of etcd's JSON gateway, as well as of watch creation requests.
+type etcdRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+// etcdRangeResponse is the body of responses of the range and deleterange
+// endpoints of etcd's JSON gateway.
+type etcdRangeResponse struct {
+	KVs     []etcdKeyValue `json:"kvs"`
+	Deleted int64          `json:"deleted,string"`
+}
+
+// etcdWatchResponse is each of the messages that the watch endpoint of etcd's
+// JSON gateway streams.
+type etcdWatchResponse struct {
+	Result *etcdWatchResult `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// etcdWatchResult is the result of a message of the watch endpoint of etcd's
+// JSON gateway.
+type etcdWatchResult struct {
+	Canceled bool             `json:"canceled,omitempty"`
+	Events   []etcdWatchEvent `json:"events,omitempty"`
+}
+
+// etcdWatchEvent is an event of a message of the watch endpoint of etcd's JSON
+// gateway; its Type is empty for PUT events.
+type etcdWatchEvent struct {
+	Type string       `json:"type,omitempty"`
+	KV   etcdKeyValue `json:"kv"`
+}
+
+// Put stores the Topology of the node with the provided name, replacing any
+// previous one.
+func (s *EtcdStore) Put(ctx context.Context, name string, topo *Topology) error {
+	if name == "" {
+		return fmt.Errorf("empty node name")
+	}
+	if nil == topo || topo.IsEmpty() {
+		return fmt.Errorf("node '%s': %w", name, ErrEmptyTree)
+	}
+	value, err := json.Marshal(topo)
+	if err != nil {
+		return err
+	}
+	return s.call(ctx, "/v3/kv/put", etcdKeyValue{Key: []byte(s.prefix + name), Value: value}, nil)
+}
+
+// Get returns the Topology of the node with the provided name, or
+// ErrNodeNotFound if etcd holds none.
+func (s *EtcdStore) Get(ctx context.Context, name string) (*Topology, error) {
+	var resp etcdRangeResponse
+	if err := s.call(ctx, "/v3/kv/range", etcdRangeRequest{Key: []byte(s.prefix + name)}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.KVs) == 0 {
+		return nil, fmt.Errorf("node '%s': %w", name, ErrNodeNotFound)
+	}
+	topo, err := s.decode(resp.KVs[0].Value)
+	if err != nil {
+		return nil, fmt.Errorf("node '%s': %w", name, err)
+	}
+	return topo, nil
+}
+
+// List returns the Topologies of all nodes under the prefix of the EtcdStore.
+func (s *EtcdStore) List(ctx context.Context) (*ClusterTopology, error) {
+	var resp etcdRangeResponse
+	if err := s.call(ctx, "/v3/kv/range", s.prefixRange(), &resp); err != nil {
+		return nil, err
+	}
+	cluster := NewClusterTopology()
+	for _, kv := range resp.KVs {
+		name := strings.TrimPrefix(string(kv.Key), s.prefix)
+		topo, err := s.decode(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("node '%s': %w", name, err)
+		}
+		if err = cluster.Add(name, topo); err != nil {
+			return nil, err
+		}
+	}
+	return cluster, nil
+}
+
+// Delete removes the Topology of the node with the provided name, or returns
+// ErrNodeNotFound if etcd holds none.
+func (s *EtcdStore) Delete(ctx context.Context, name string) error {
+	var resp etcdRangeResponse
+	if err := s.call(ctx, "/v3/kv/deleterange", etcdRangeRequest{Key: []byte(s.prefix + name)}, &resp); err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return fmt.Errorf("node '%s': %w", name, ErrNodeNotFound)
+	}
+	return nil
+}
+
+// Watch returns a channel that receives a StoreEvent for every subsequent
+// change under the prefix of the EtcdStore, until the provided context is done
+// or the watch fails (e.g., if the connection to etcd is lost); callers that
+// need to tell these apart should check the context when the channel closes.
+//
+// The gateway streams the watch's messages as newline-delimited JSON objects.
+// Unless Limits.MaxBytes is not positive, messages larger than four times it
+// (which leaves room for the base64 encoding of the values, and for batched
+// events) are discarded without being buffered, like values that cannot be
+// decoded as Topologies.
+func (s *EtcdStore) Watch(ctx context.Context) (<-chan StoreEvent, error) {
+	body, err := json.Marshal(struct {
+		CreateRequest etcdRangeRequest `json:"create_request"`
+	}{s.prefixRange()})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/v3/watch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, etcdError(resp)
+	}
+
+	events := make(chan StoreEvent)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		r := bufio.NewReader(resp.Body)
+		maxBytes := s.Limits.MaxBytes * etcdWatchMessageFactor
+		for {
+			line, err := readLine(r, maxBytes)
+			if errors.Is(err, ErrLimitExceeded) {
+				continue
+			}
+			if err != nil && (err != io.EOF || len(line) == 0) {
+				return
+			}
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var msg etcdWatchResponse
+			if err := json.Unmarshal(line, &msg); err != nil || nil != msg.Error || nil == msg.Result || msg.Result.Canceled {
+				return
+			}
+			for _, ev := range msg.Result.Events {
+				event := StoreEvent{Name: strings.TrimPrefix(string(ev.KV.Key), s.prefix)}
+				if ev.Type != "DELETE" {
+					topo, err := s.decode(ev.KV.Value)
+					if err != nil {
+						continue
+					}
+					event.Topology = topo
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// etcdWatchMessageFactor is the ratio of the maximum size of the messages of
+// watches to Limits.MaxBytes (see EtcdStore.Watch).
+const etcdWatchMessageFactor = 4
+
+// readLine returns the next line from the provided bufio.Reader (without its
+// trailing newline), or ErrLimitExceeded, after discarding the line, if it is
+// longer than maxBytes (unless maxBytes is not positive).
+func readLine(r *bufio.Reader, maxBytes int64) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		// The limit excludes the trailing newline.
+		if maxBytes > 0 && int64(len(line)+len(chunk)) > maxBytes+1 {
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = r.ReadSlice('\n')
+			}
+			if err == nil {
+				err = ErrLimitExceeded
+			}
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return bytes.TrimSuffix(line, []byte("\n")), err
+		}
+	}
+}
+
+// prefixRange returns the range of all keys under the prefix of the EtcdStore.
+func (s *EtcdStore) prefixRange() etcdRangeRequest {
+	key := []byte(s.prefix)
+	end := append([]byte(nil), key...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return etcdRangeRequest{Key: key, RangeEnd: end[:i+1]}
+		}
+	}
+	// All keys (i.e., from an empty or all-0xff prefix onwards).
+	return etcdRangeRequest{Key: key, RangeEnd: []byte{0}}
+}
+
+// decode returns the Topology decoded from the provided value under the
+// DecodeLimits of the EtcdStore (see Decoder.Decode), which may also be an
+// Envelope written by another producer.
+func (s *EtcdStore) decode(value []byte) (*Topology, error) {
+	return NewDecoder(s.Limits).Decode(bytes.NewReader(value))
+}
+
+// call posts the provided request to the provided endpoint of etcd's JSON
+// gateway and decodes the response into the provided value, unless it is nil.
+func (s *EtcdStore) call(ctx context.Context, path string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return etcdError(resp)
+	}
+	if nil == response {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err = json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("etcd: invalid response: %w", err)
+	}
+	return nil
+}
+
+// etcdError returns an error value that describes the provided unsuccessful
+// response of etcd's JSON gateway.
+func etcdError(resp *http.Response) error {
+	var msg struct {
+		Message string `json:"message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(data, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(data))
+	}
+	return fmt.Errorf("etcd: %s: %s", resp.Status, msg.Message)
+}
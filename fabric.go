@@ -0,0 +1,203 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Fabric models an interconnect among the nodes of a cluster (e.g., their
+// Ethernet network, organized in racks and switches, or an RDMA fabric) as
+// node-to-node distance and bandwidth matrices, much like the distances that
+// the firmware reports among the NUMA nodes of a machine.
+type Fabric struct {
+	// Nodes lists the names of the nodes that the Fabric connects, sorted
+	// and without duplicates; they index the rows and the columns of the
+	// matrices.
+	Nodes []string `json:"nodes"`
+	// Distances holds the relative distance (e.g., the number of switch
+	// hops) from each node to each other one, where 0 is the distance of
+	// every node to itself; it may be empty, if unknown.
+	Distances [][]uint32 `json:"distances,omitempty"`
+	// Bandwidths holds the bandwidth (in bytes per second) from each node
+	// to each other one, where 0 means unknown; it may be empty.
+	Bandwidths [][]uint64 `json:"bandwidths,omitempty"`
+}
+
+// FabricFromLocality returns a new Fabric whose distances are derived from the
+// provided locations of the nodes, each of which is a path in the hierarchy of
+// the fabric from the outermost domain to the innermost (e.g., {"zone-a",
+// "rack-3", "switch-7"}). The distance between two nodes is the number of hops
+// from one to the other through their innermost common domain; i.e., twice the
+// number of domains that either of them is nested into below it, plus two.
+func FabricFromLocality(locations map[string][]string) *Fabric {
+	f := &Fabric{Nodes: make([]string, 0, len(locations))}
+	for name := range locations {
+		f.Nodes = append(f.Nodes, name)
+	}
+	sort.Strings(f.Nodes)
+	f.Distances = make([][]uint32, len(f.Nodes))
+	for i, a := range f.Nodes {
+		f.Distances[i] = make([]uint32, len(f.Nodes))
+		for j, b := range f.Nodes {
+			if i == j {
+				continue
+			}
+			pathA, pathB := locations[a], locations[b]
+			common := 0
+			for common < len(pathA) && common < len(pathB) && pathA[common] == pathB[common] {
+				common++
+			}
+			f.Distances[i][j] = uint32(len(pathA)+len(pathB)-2*common) + 2
+		}
+	}
+	return f
+}
+
+// Validate returns a non-nil error value if the Fabric is malformed.
+func (f *Fabric) Validate() error {
+	for i := range f.Nodes {
+		if f.Nodes[i] == "" {
+			return fmt.Errorf("empty node name")
+		}
+		if i > 0 && f.Nodes[i-1] >= f.Nodes[i] {
+			return fmt.Errorf("node names are not sorted or not unique ('%s', '%s')", f.Nodes[i-1], f.Nodes[i])
+		}
+	}
+	n := len(f.Nodes)
+	if f.Distances != nil {
+		if len(f.Distances) != n {
+			return fmt.Errorf("distance matrix has %d rows for %d nodes", len(f.Distances), n)
+		}
+		for i, row := range f.Distances {
+			if len(row) != n {
+				return fmt.Errorf("distance matrix row %d has %d columns for %d nodes", i, len(row), n)
+			}
+			if row[i] != 0 {
+				return fmt.Errorf("non-zero distance from node '%s' to itself", f.Nodes[i])
+			}
+		}
+	}
+	if f.Bandwidths != nil {
+		if len(f.Bandwidths) != n {
+			return fmt.Errorf("bandwidth matrix has %d rows for %d nodes", len(f.Bandwidths), n)
+		}
+		for i, row := range f.Bandwidths {
+			if len(row) != n {
+				return fmt.Errorf("bandwidth matrix row %d has %d columns for %d nodes", i, len(row), n)
+			}
+		}
+	}
+	return nil
+}
+
+// index returns the index of the node with the provided name in the Fabric.
+func (f *Fabric) index(name string) (int, bool) {
+	i := sort.SearchStrings(f.Nodes, name)
+	return i, i < len(f.Nodes) && f.Nodes[i] == name
+}
+
+// Distance returns the distance from node a to node b, if it is known.
+func (f *Fabric) Distance(a, b string) (uint32, bool) {
+	i, okA := f.index(a)
+	j, okB := f.index(b)
+	if !okA || !okB || len(f.Distances) == 0 {
+		return 0, false
+	}
+	return f.Distances[i][j], true
+}
+
+// Bandwidth returns the bandwidth (in bytes per second) from node a to node b,
+// if it is known.
+func (f *Fabric) Bandwidth(a, b string) (uint64, bool) {
+	i, okA := f.index(a)
+	j, okB := f.index(b)
+	if !okA || !okB || len(f.Bandwidths) == 0 || f.Bandwidths[i][j] == 0 {
+		return 0, false
+	}
+	return f.Bandwidths[i][j], true
+}
+
+// MaxDistance returns the maximum distance between any two of the provided
+// nodes (e.g., to compare candidate placements of a gang of Pods), or a non-nil
+// error value if any of the distances is unknown.
+func (f *Fabric) MaxDistance(names ...string) (uint32, error) {
+	var max uint32
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			d, ok := f.Distance(names[i], names[j])
+			if !ok {
+				return 0, fmt.Errorf("unknown distance between nodes '%s' and '%s'", names[i], names[j])
+			}
+			if d > max {
+				max = d
+			}
+		}
+	}
+	return max, nil
+}
+
+// Nearest returns the provided candidate nodes (or all nodes of the Fabric, if
+// none are provided) sorted by their distance from the provided node, nearest
+// first, and by name among equidistant ones; nodes at an unknown distance are
+// omitted, and so is the provided node itself.
+func (f *Fabric) Nearest(name string, candidates ...string) []string {
+	if len(candidates) == 0 {
+		candidates = f.Nodes
+	}
+	distances := make(map[string]uint32, len(candidates))
+	ret := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if d, ok := f.Distance(name, candidate); ok && candidate != name {
+			if _, dup := distances[candidate]; !dup {
+				ret = append(ret, candidate)
+			}
+			distances[candidate] = d
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if distances[ret[i]] != distances[ret[j]] {
+			return distances[ret[i]] < distances[ret[j]]
+		}
+		return ret[i] < ret[j]
+	})
+	return ret
+}
+
+// SetFabric adds the provided Fabric to the ClusterTopology under the provided
+// name (e.g., "ethernet" or "rdma"), replacing any previous one, or returns a
+// non-nil error value if the Fabric is malformed or connects any nodes that
+// are not part of the ClusterTopology.
+func (c *ClusterTopology) SetFabric(name string, f *Fabric) error {
+	if name == "" {
+		return fmt.Errorf("empty fabric name")
+	}
+	if err := f.Validate(); err != nil {
+		return fmt.Errorf("fabric '%s': %w", name, err)
+	}
+	for _, node := range f.Nodes {
+		if _, ok := c.Nodes[node]; !ok {
+			return fmt.Errorf("fabric '%s': node '%s' is not part of the ClusterTopology", name, node)
+		}
+	}
+	if nil == c.Fabrics {
+		c.Fabrics = make(map[string]*Fabric)
+	}
+	c.Fabrics[name] = f
+	return nil
+}
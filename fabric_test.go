@@ -0,0 +1,100 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFabric(t *testing.T) {
+	cluster := NewClusterTopology()
+	for _, name := range []string{"a1", "a2", "b1", "c1"} {
+		if err := cluster.Add(name, &Topology{syntheticTree(1, 1, 2, 2)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ethernet := FabricFromLocality(map[string][]string{
+		"a1": {"zone-1", "rack-a"},
+		"a2": {"zone-1", "rack-a"},
+		"b1": {"zone-1", "rack-b"},
+		"c1": {"zone-2", "rack-c"},
+	})
+	if err := cluster.SetFabric("ethernet", ethernet); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		a, b string
+		want uint32
+	}{{"a1", "a1", 0}, {"a1", "a2", 2}, {"a1", "b1", 4}, {"b1", "c1", 6}} {
+		if got, ok := ethernet.Distance(tc.a, tc.b); !ok || got != tc.want {
+			t.Errorf("got distance %d (%v) between %s and %s; expected %d", got, ok, tc.a, tc.b, tc.want)
+		}
+	}
+	if got := ethernet.Nearest("a1"); len(got) != 3 || got[0] != "a2" || got[1] != "b1" || got[2] != "c1" {
+		t.Errorf("got nearest nodes %v; expected [a2 b1 c1]", got)
+	}
+	if d, err := ethernet.MaxDistance("a1", "a2", "b1"); err != nil || d != 4 {
+		t.Errorf("got maximum distance %d (%v); expected 4", d, err)
+	}
+	if _, err := ethernet.MaxDistance("a1", "z9"); err == nil {
+		t.Errorf("expected an error for an unknown node")
+	}
+
+	rdma := &Fabric{
+		Nodes:      []string{"a1", "a2"},
+		Bandwidths: [][]uint64{{0, 25e9}, {25e9, 0}},
+	}
+	if err := cluster.SetFabric("rdma", rdma); err != nil {
+		t.Fatal(err)
+	}
+	if bw, ok := cluster.Fabrics["rdma"].Bandwidth("a2", "a1"); !ok || bw != 25e9 {
+		t.Errorf("got bandwidth %d (%v); expected 25e9", bw, ok)
+	}
+	if _, ok := rdma.Distance("a1", "a2"); ok {
+		t.Errorf("got a distance from a Fabric without distances")
+	}
+	for _, bad := range []*Fabric{
+		{Nodes: []string{"a2", "a1"}},
+		{Nodes: []string{"a1", "z9"}},
+		{Nodes: []string{"a1", "a2"}, Distances: [][]uint32{{0, 1}}},
+		{Nodes: []string{"a1", "a2"}, Distances: [][]uint32{{0, 1}, {1, 1}}},
+	} {
+		if err := cluster.SetFabric("bad", bad); err == nil {
+			t.Errorf("expected an error for Fabric %+v", bad)
+		}
+	}
+
+	data, err := json.Marshal(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ClusterTopology
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	merged := NewClusterTopology()
+	if err = merged.Merge(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := merged.Fabrics["ethernet"].Distance("c1", "a2"); d != 6 || len(merged.Fabrics) != 2 {
+		t.Errorf("the Fabrics were not preserved across JSON and Merge")
+	}
+	if err = merged.Merge(&ClusterTopology{Fabrics: map[string]*Fabric{"rdma": rdma}}); err == nil {
+		t.Errorf("expected an error when merging a Fabric that already exists")
+	}
+}
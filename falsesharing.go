@@ -0,0 +1,136 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "fmt"
+
+// DefaultLineSize is the cache line size (in bytes) that AdviseFalseSharing
+// assumes if the Topology reports none.
+const DefaultLineSize = 64
+
+// FalseSharingAdvice describes how data that is written by cooperating
+// hardware threads should be laid out to avoid false sharing (see
+// AdviseFalseSharing).
+type FalseSharingAdvice struct {
+	// Threads are the NodeIDs of the cooperating hardware threads.
+	Threads []NodeID `json:"threads"`
+	// SharedCaches are the NodeIDs of the Caches that are shared by at
+	// least two of the Threads, in pre-order.
+	SharedCaches []NodeID `json:"shared_caches,omitempty"`
+	// Granularity is the coherence granularity (in bytes), i.e. the size
+	// of the blocks in which data written by different Threads may
+	// interfere; data written by different Threads should be aligned to,
+	// and padded to a multiple of, it.
+	Granularity uint32 `json:"granularity"`
+	// Assumed is true if the Topology reports no line sizes at all for the
+	// Caches of the Threads, so that Granularity is DefaultLineSize.
+	Assumed bool `json:"assumed,omitempty"`
+}
+
+// Pad returns the provided size (in bytes) rounded up to a multiple of the
+// Granularity, i.e. the size that each per-thread object should occupy.
+func (a *FalseSharingAdvice) Pad(size uint64) uint64 {
+	g := uint64(a.Granularity)
+	if g == 0 {
+		return size
+	}
+	return (size + g - 1) / g * g
+}
+
+// String returns a human-readable suggestion for the layout of the data.
+func (a *FalseSharingAdvice) String() string {
+	source := "the largest line size among their shared caches"
+	switch {
+	case a.Assumed:
+		source = "assumed, since no line sizes are known"
+	case len(a.SharedCaches) == 0:
+		source = "the largest line size among their caches, none of which is shared"
+	}
+	return fmt.Sprintf("align data written by different threads to %d bytes and pad it to a multiple of "+
+		"%d bytes (%s)", a.Granularity, a.Granularity, source)
+}
+
+// AdviseFalseSharing returns a FalseSharingAdvice for the hardware threads
+// stored under the provided NodeIDs, or a non-nil error value in case of
+// failure.
+//
+// The Granularity is the largest line size among the Caches that are shared by
+// at least two of the threads, since that is where their writes meet; if they
+// share no Caches with known line sizes (e.g., threads on different Packages),
+// it is the largest
+// line size among all of their Caches instead, and DefaultLineSize if no line
+// sizes are known at all.
+func (t *Topology) AdviseFalseSharing(threads []NodeID) (*FalseSharingAdvice, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	if len(threads) == 0 {
+		return nil, fmt.Errorf("no threads provided")
+	}
+
+	// users counts the threads in the subtree of each Cache.
+	users := make(map[NodeID]int)
+	for _, id := range threads {
+		data, err := t.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if !data.IsProcessing() || data.Kind != Thread {
+			return nil, fmt.Errorf("element %d is not a thread", id)
+		}
+		ancestorIDs, err := t.AncestorIDs(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, ancestorID := range ancestorIDs {
+			if t.Nodes[ancestorID].Data.IsCache() {
+				users[ancestorID]++
+			}
+		}
+	}
+
+	advice := &FalseSharingAdvice{Threads: append([]NodeID(nil), threads...)}
+	var sharedLine, anyLine uint32
+	for id := range t.Nodes {
+		n, ok := users[NodeID(id)]
+		if !ok {
+			continue
+		}
+		var line uint32
+		if attrs := t.Nodes[id].Data.Attributes; nil != attrs {
+			line = attrs.Linesize
+		}
+		if line > anyLine {
+			anyLine = line
+		}
+		if n >= 2 {
+			advice.SharedCaches = append(advice.SharedCaches, NodeID(id))
+			if line > sharedLine {
+				sharedLine = line
+			}
+		}
+	}
+	switch {
+	case sharedLine > 0:
+		advice.Granularity = sharedLine
+	case anyLine > 0:
+		advice.Granularity = anyLine
+	default:
+		advice.Granularity, advice.Assumed = DefaultLineSize, true
+	}
+	return advice, nil
+}
@@ -0,0 +1,66 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "testing"
+
+func TestAdviseFalseSharing(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 1, 2, 2)}
+	threads := topo.Threads()
+	l3 := topo.L3Caches()[0]
+	topo.Nodes[l3].Data.Attributes.Linesize = 128
+
+	// Threads of different Cores of the same L3 meet in the L3.
+	advice, err := topo.AdviseFalseSharing([]NodeID{threads[0], threads[2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if advice.Granularity != 128 || len(advice.SharedCaches) != 1 || advice.SharedCaches[0] != l3 {
+		t.Errorf("got %+v; expected a granularity of 128 bytes due to L3 %d", advice, l3)
+	}
+	if got := advice.Pad(130); got != 256 {
+		t.Errorf("Pad(130) = %d; expected 256", got)
+	}
+
+	// Threads of different Packages share no caches.
+	if advice, err = topo.AdviseFalseSharing([]NodeID{threads[0], threads[7]}); err != nil {
+		t.Fatal(err)
+	}
+	if advice.Granularity != 128 || len(advice.SharedCaches) != 0 {
+		t.Errorf("got %+v; expected the largest line size among all caches", advice)
+	}
+	if advice.String() == "" {
+		t.Errorf("got an empty suggestion")
+	}
+
+	// Without any line sizes, the default is assumed.
+	bare, err := FromSpec(TopologySpec{Packages: 1, NUMANodesPerPackage: 1, Cores: 2, ThreadsPerCore: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if advice, err = bare.AdviseFalseSharing(bare.Threads()); err != nil || advice.Granularity != DefaultLineSize ||
+		!advice.Assumed {
+		t.Errorf("got %+v, %v; expected the default line size", advice, err)
+	}
+
+	if _, err = topo.AdviseFalseSharing(topo.Cores()); err == nil {
+		t.Errorf("expected an error for Cores")
+	}
+	if _, err = topo.AdviseFalseSharing(nil); err == nil {
+		t.Errorf("expected an error for no threads")
+	}
+}
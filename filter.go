@@ -0,0 +1,91 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "fmt"
+
+// Filter returns a reduced deep copy of the Topology that only contains the
+// Elements for which keep returns true (e.g., to publish a variant without
+// Caches or Devices), or a non-nil error value in case of failure.
+//
+// The root Element is always kept. The children of every Element that is not
+// kept are attached to its closest kept ancestor, and the Elements of the copy
+// are renumbered to remain in pre-order (see Tree). References of Devices are
+// kept consistent: links to Devices that are not kept are removed, virtual
+// functions whose physical function is not kept become standalone Devices,
+// and NUMA nodes that are not kept are removed from their memory nodes.
+func (t *Topology) Filter(keep func(*Element) bool) (*Topology, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	if len(t.Nodes) == 0 {
+		return nil, ErrEmptyTree
+	}
+
+	splice := make(map[NodeID]bool)
+	dropped := make(map[string]bool)
+	droppedNUMA := make(map[uint32]bool)
+	for id := 1; id < len(t.Nodes); id++ {
+		data := t.Nodes[id].Data
+		if keep(data) {
+			continue
+		}
+		splice[NodeID(id)] = true
+		if data.IsDevice() && data.BusID != "" {
+			dropped[data.BusID] = true
+		}
+		if data.IsProcessing() && data.Kind == NUMANode {
+			droppedNUMA[data.ID] = true
+		}
+	}
+	ret := t.rebuild(nil, splice)
+
+	for _, node := range ret.Nodes {
+		data := node.Data
+		if !data.IsDevice() {
+			continue
+		}
+		if dropped[data.PhysicalFunction] {
+			data.PhysicalFunction = ""
+		}
+		links := data.Links[:0]
+		for _, link := range data.Links {
+			if !dropped[link.Peer] {
+				links = append(links, link)
+			}
+		}
+		if len(links) == 0 {
+			links = nil
+		}
+		data.Links = links
+		memoryNodes := data.MemoryNodes[:0]
+		for _, osID := range data.MemoryNodes {
+			if !droppedNUMA[osID] {
+				memoryNodes = append(memoryNodes, osID)
+			}
+		}
+		if len(memoryNodes) == 0 {
+			memoryNodes = nil
+		}
+		data.MemoryNodes = memoryNodes
+	}
+
+	if err := ret.Validate(); err != nil {
+		return nil, fmt.Errorf("filtered Topology is invalid: %w", err)
+	}
+	return ret, nil
+}
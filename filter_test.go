@@ -0,0 +1,84 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 2, 2, 2)}
+	slim, err := topo.Filter(func(e *Element) bool { return !e.IsCache() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(slim.L3Caches()) + len(slim.L2Caches()) + len(slim.L1Caches()); got != 0 {
+		t.Errorf("got %d caches after filtering them out", got)
+	}
+	for _, ids := range [][2][]NodeID{
+		{topo.Packages(), slim.Packages()},
+		{topo.NUMANodes(), slim.NUMANodes()},
+		{topo.Cores(), slim.Cores()},
+		{topo.Threads(), slim.Threads()},
+	} {
+		if len(ids[0]) != len(ids[1]) {
+			t.Errorf("got %d processing elements after filtering; expected %d", len(ids[1]), len(ids[0]))
+		}
+	}
+	if len(topo.L3Caches()) == 0 {
+		t.Error("filtering modified the original Topology")
+	}
+
+	const (
+		nic NodeID = 6
+		vf1 NodeID = 7
+		gpu NodeID = 15
+	)
+	topo = &Topology{deviceTree()}
+	topo.Nodes[gpu].Data.Links = []DeviceLink{{Peer: "0000:18:00.0"}, {Peer: "0000:18:00.1"}}
+	noDevices, err := topo.Filter(func(e *Element) bool { return !e.IsDevice() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := noDevices.Devices(); len(got) != 0 {
+		t.Errorf("got devices %v after filtering them out", got)
+	}
+	if noDevices.Size() != topo.Size()-len(topo.Devices()) {
+		t.Errorf("got %d elements after filtering out devices; expected %d", noDevices.Size(), topo.Size()-len(topo.Devices()))
+	}
+
+	// Dropping a physical function turns its virtual functions into
+	// standalone Devices, and removes the links to it.
+	noPF, err := topo.Filter(func(e *Element) bool { return !e.IsDevice() || e.BusID != "0000:18:00.0" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	vf, peer := vf1-1, gpu-1
+	if data := noPF.Nodes[vf].Data; !data.IsDevice() || data.BusID != "0000:18:00.1" || data.IsVirtualFunction() {
+		t.Errorf("got %s in place of the first virtual function; expected a standalone Device", data)
+	}
+	if parent, err := noPF.ParentID(vf); err != nil || parent != 2 {
+		t.Errorf("got parent %d (%v) of the first virtual function; expected 2", parent, err)
+	}
+	if peers, err := noPF.PeerDevices(peer); err != nil || !reflect.DeepEqual(peers, []NodeID{vf}) {
+		t.Errorf("got peers %v (%v) of the GPU; expected [%d]", peers, err, vf)
+	}
+	if got := topo.Nodes[nic].Data; got.BusID != "0000:18:00.0" || !topo.Nodes[vf1].Data.IsVirtualFunction() {
+		t.Error("filtering modified the original Topology")
+	}
+}
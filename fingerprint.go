@@ -0,0 +1,149 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Hash is a SHA-256 digest.
+type Hash [sha256.Size]byte
+
+// String returns the Hash in hexadecimal.
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// MarshalText returns the Hash marshalled as text (e.g., in JSON).
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText unmarshals the Hash from text (e.g., in JSON).
+func (h *Hash) UnmarshalText(text []byte) error {
+	if hex.DecodedLen(len(text)) != len(h) {
+		return fmt.Errorf("invalid hash length: %d", len(text))
+	}
+	_, err := hex.Decode(h[:], text)
+	return err
+}
+
+// Fingerprint consists of two stable hashes of a Topology.
+type Fingerprint struct {
+	// Structure covers the kinds of the Elements and the hierarchy only,
+	// so that it is shared by all machines of the same hardware class.
+	Structure Hash `json:"structure"`
+	// Full also covers the OS indices of the Processing nodes and the
+	// attributes of the Caches.
+	Full Hash `json:"full"`
+}
+
+// Fingerprint returns the Fingerprint of the Topology, or a non-nil error value
+// in case of failure.
+//
+// Both hashes are Merkle hashes over the Tree: the digest of each Element
+// covers its own label along with the digests of its children, sorted, so that
+// neither the NodeIDs nor the order of the children affect them; they are
+// therefore stable across collectors and serializations of the same machine.
+func (t *Topology) Fingerprint() (Fingerprint, error) {
+	if nil == t || nil == t.Tree {
+		return Fingerprint{}, ErrNilTree
+	}
+	if t.IsEmpty() {
+		return Fingerprint{}, ErrEmptyTree
+	}
+
+	structure, full := make([]Hash, len(t.Nodes)), make([]Hash, len(t.Nodes))
+	// Relying on the pre-order layout of the Tree, children are hashed
+	// before their parents.
+	for id := len(t.Nodes) - 1; id >= 0; id-- {
+		children := t.Nodes[id].Children
+		for _, child := range children {
+			if int(child) <= id || int(child) >= len(t.Nodes) {
+				return Fingerprint{}, ErrNotPreOrder
+			}
+		}
+		data := t.Nodes[id].Data
+		structure[id] = merkleDigest(structureLabel(data), children, structure)
+		full[id] = merkleDigest(fullLabel(data), children, full)
+	}
+	return Fingerprint{Structure: structure[0], Full: full[0]}, nil
+}
+
+// merkleDigest returns the digest of an Element with the provided label and
+// children, whose digests have already been computed.
+func merkleDigest(label string, children []NodeID, digests []Hash) Hash {
+	childDigests := make([]Hash, 0, len(children))
+	for _, child := range children {
+		childDigests = append(childDigests, digests[child])
+	}
+	sort.Slice(childDigests, func(i, j int) bool { return bytes.Compare(childDigests[i][:], childDigests[j][:]) < 0 })
+
+	h := sha256.New()
+	io.WriteString(h, label)
+	h.Write([]byte{0})
+	for i := range childDigests {
+		h.Write(childDigests[i][:])
+	}
+	var ret Hash
+	h.Sum(ret[:0])
+	return ret
+}
+
+// structureLabel returns the label of the provided Element in the structure
+// hash, which only consists of its kind.
+func structureLabel(data *Element) string {
+	switch {
+	case data.IsRoot():
+		return "machine"
+	case data.IsCache():
+		return strings.ToLower(data.Level.String())
+	case data.IsDevice():
+		return strings.ToLower(data.Type.String())
+	default:
+		return strings.ToLower(data.Kind.String())
+	}
+}
+
+// fullLabel returns the label of the provided Element in the full hash, which
+// also consists of the OS index of Processing nodes, the attributes of Caches
+// and NUMA nodes, and the bus ID, name, class, path, links and memory nodes of
+// Devices.
+func fullLabel(data *Element) string {
+	switch {
+	case data.IsDevice() && (data.Class != "" || data.Path != ""):
+		return fmt.Sprintf("%s:%s:%s:%s:%s:%s:%v:%v:%v", structureLabel(data), data.BusID, data.Name, data.Class, data.Path,
+			data.PhysicalFunction, data.Links, data.CXLLink, data.MemoryNodes)
+	case data.IsDevice() && (data.IsVirtualFunction() || len(data.Links) > 0 || nil != data.CXLLink || len(data.MemoryNodes) > 0):
+		return fmt.Sprintf("%s:%s:%s:%s:%v:%v:%v", structureLabel(data), data.BusID, data.Name, data.PhysicalFunction,
+			data.Links, data.CXLLink, data.MemoryNodes)
+	case data.IsProcessing() && nil != data.Memory:
+		return fmt.Sprintf("%s:%d:%s", structureLabel(data), data.ID, data.Memory)
+	case data.IsProcessing():
+		return fmt.Sprintf("%s:%d", structureLabel(data), data.ID)
+	case data.IsDevice():
+		return fmt.Sprintf("%s:%s:%s", structureLabel(data), data.BusID, data.Name)
+	default:
+		return shapeLabel(data)
+	}
+}
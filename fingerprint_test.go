@@ -0,0 +1,67 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(tree *Tree) Fingerprint {
+		t.Helper()
+		fp, err := (&Topology{tree}).Fingerprint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fp
+	}
+	original := fingerprint(syntheticTree(2, 1, 2, 2))
+
+	reordered := syntheticTree(2, 1, 2, 2)
+	children := reordered.Nodes[0].Children
+	children[0], children[1] = children[1], children[0]
+	if fp := fingerprint(reordered); fp != original {
+		t.Errorf("the order of the children affects the Fingerprint")
+	}
+
+	resized := syntheticTree(2, 1, 2, 2)
+	resized.Nodes[3].Data.Attributes.Size /= 2
+	if fp := fingerprint(resized); fp.Structure != original.Structure || fp.Full == original.Full {
+		t.Errorf("the size of a cache affects the wrong hashes")
+	}
+
+	if fp := fingerprint(syntheticTree(2, 1, 2, 1)); fp.Structure == original.Structure {
+		t.Errorf("disabling SMT does not affect the structure hash")
+	}
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Fingerprint
+	if err = json.Unmarshal(data, &decoded); err != nil || decoded != original {
+		t.Errorf("got %v (%v) after a round-trip of %s", decoded, err, data)
+	}
+	if err = json.Unmarshal([]byte(`{"full":"abc"}`), &decoded); err == nil {
+		t.Errorf("expected an error for a malformed hash")
+	}
+
+	if _, err = (&Topology{}).Fingerprint(); err == nil {
+		t.Errorf("expected an error for a nil Tree")
+	}
+}
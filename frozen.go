@@ -0,0 +1,211 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// FrozenTopology is an immutable view of a Topology, which can be shared among
+// any number of goroutines and caches without further synchronization.
+//
+// Its query methods mirror those of Topology, but never expose the Elements or
+// the lists that it stores internally; Elements are returned by value, and
+// lists of NodeIDs are owned by the caller. Its mutating methods (e.g.,
+// WithElement and Update) leave it intact and return modified copies instead.
+//
+// The zero value is not usable; FrozenTopologies are obtained through Freeze.
+type FrozenTopology struct {
+	topo *Topology
+}
+
+// Freeze returns an immutable copy of the Topology, or a non-nil error value
+// if the Topology is not valid (see Tree.Validate). Subsequent modifications
+// of the Topology do not affect the returned FrozenTopology.
+func (t *Topology) Freeze() (*FrozenTopology, error) {
+	if nil == t {
+		return nil, ErrNilTree
+	}
+	return freeze(t.clone())
+}
+
+// freeze returns a FrozenTopology that takes ownership of the provided
+// Topology, after validating it and building its indexes.
+func freeze(t *Topology) (*FrozenTopology, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	t.getIndexes()
+	return &FrozenTopology{topo: t}, nil
+}
+
+// clone returns a deep copy of the Topology, without its indexes.
+func (t *Topology) clone() *Topology {
+	if nil == t.Tree {
+		return &Topology{}
+	}
+	nodes := make([]TreeNode, len(t.Nodes))
+	for id := range t.Nodes {
+		nodes[id] = cloneTreeNode(&t.Nodes[id])
+	}
+	return &Topology{NewTree(nodes)}
+}
+
+// Thaw returns a mutable deep copy of the FrozenTopology.
+func (f *FrozenTopology) Thaw() *Topology {
+	return f.topo.clone()
+}
+
+// Update returns a new FrozenTopology that results from applying the provided
+// function to a mutable copy of this one (see Thaw), or a non-nil error value
+// if the function fails or the result is not valid. The function does not need
+// to call InvalidateIndexes.
+func (f *FrozenTopology) Update(fn func(t *Topology) error) (*FrozenTopology, error) {
+	t := f.Thaw()
+	if err := fn(t); err != nil {
+		return nil, err
+	}
+	t.InvalidateIndexes()
+	return freeze(t)
+}
+
+// WithElement returns a new FrozenTopology in which the element stored under
+// the provided NodeID is replaced by the provided one, or a non-nil error value
+// if the NodeID is invalid or the result is not valid.
+func (f *FrozenTopology) WithElement(id NodeID, e Element) (*FrozenTopology, error) {
+	if int(id) >= f.topo.Size() {
+		return nil, ErrInvalidNodeID{ID: id}
+	}
+	data := cloneTreeNode(&TreeNode{Data: &e}).Data
+	return f.Update(func(t *Topology) error {
+		t.Nodes[id].Data = data
+		return nil
+	})
+}
+
+// Size returns the number of Elements of the FrozenTopology.
+func (f *FrozenTopology) Size() int {
+	return f.topo.Size()
+}
+
+// Get returns a copy of the Element stored under the provided NodeID, or a
+// non-nil error value in case of failure.
+func (f *FrozenTopology) Get(id NodeID) (Element, error) {
+	if int(id) >= f.topo.Size() {
+		return Element{}, ErrInvalidNodeID{ID: id}
+	}
+	return *cloneTreeNode(&TreeNode{Data: f.topo.Nodes[id].Data}).Data, nil
+}
+
+// ImmediateDescendantIDs returns the NodeIDs of the children of the element
+// stored under the provided NodeID (see Tree.ImmediateDescendantIDs).
+func (f *FrozenTopology) ImmediateDescendantIDs(id NodeID) ([]NodeID, error) {
+	children, err := f.topo.ImmediateDescendantIDs(id)
+	return append([]NodeID(nil), children...), err
+}
+
+// ParentID returns the NodeID of the parent of the element stored under the
+// provided NodeID (see Tree.ParentID).
+func (f *FrozenTopology) ParentID(id NodeID) (NodeID, error) {
+	return f.topo.ParentID(id)
+}
+
+// AncestorIDs returns the NodeIDs of the ancestors of the element stored under
+// the provided NodeID (see Tree.AncestorIDs).
+func (f *FrozenTopology) AncestorIDs(id NodeID) ([]NodeID, error) {
+	return f.topo.AncestorIDs(id)
+}
+
+// LeafDescendantIDs returns the NodeIDs of the leaves under the element stored
+// under the provided NodeID (see Tree.LeafDescendantIDs).
+func (f *FrozenTopology) LeafDescendantIDs(id NodeID) ([]NodeID, error) {
+	return f.topo.LeafDescendantIDs(id)
+}
+
+// SubtreeRange returns the range of the NodeIDs of the subtree of the element
+// stored under the provided NodeID (see Tree.SubtreeRange).
+func (f *FrozenTopology) SubtreeRange(id NodeID) (start, end NodeID, err error) {
+	return f.topo.SubtreeRange(id)
+}
+
+// Depth returns the depth of the element stored under the provided NodeID (see
+// Tree.Depth).
+func (f *FrozenTopology) Depth(id NodeID) (int, error) {
+	return f.topo.Depth(id)
+}
+
+// Packages returns the NodeIDs of all Packages (see Topology.Packages).
+func (f *FrozenTopology) Packages() []NodeID {
+	return f.topo.Packages()
+}
+
+// NUMANodes returns the NodeIDs of all NUMA nodes (see Topology.NUMANodes).
+func (f *FrozenTopology) NUMANodes() []NodeID {
+	return f.topo.NUMANodes()
+}
+
+// Cores returns the NodeIDs of all physical cores (see Topology.Cores).
+func (f *FrozenTopology) Cores() []NodeID {
+	return f.topo.Cores()
+}
+
+// Threads returns the NodeIDs of all hardware threads (see Topology.Threads).
+func (f *FrozenTopology) Threads() []NodeID {
+	return f.topo.Threads()
+}
+
+// Caches returns the NodeIDs of all caches of the provided level (see
+// Topology.L1Caches, Topology.L2Caches, etc.).
+func (f *FrozenTopology) Caches(level CacheLevel) []NodeID {
+	return f.topo.getAllCacheLevel(level)
+}
+
+// Select returns the NodeIDs of the Elements that the provided selector selects
+// (see Topology.Select).
+func (f *FrozenTopology) Select(selector string) ([]NodeID, error) {
+	return f.topo.Select(selector)
+}
+
+// Descendants returns the NodeIDs of the descendants of the element stored
+// under the provided NodeID (see Topology.Descendants).
+func (f *FrozenTopology) Descendants(id NodeID, opts ...Option) ([]NodeID, error) {
+	return f.topo.Descendants(id, opts...)
+}
+
+// Fingerprint returns the Fingerprint of the FrozenTopology (see
+// Topology.Fingerprint).
+func (f *FrozenTopology) Fingerprint() (Fingerprint, error) {
+	return f.topo.Fingerprint()
+}
+
+// Render writes a textual representation of the FrozenTopology to the provided
+// io.Writer (see Topology.Render).
+func (f *FrozenTopology) Render(w io.Writer, opts ...Option) error {
+	return f.topo.Render(w, opts...)
+}
+
+// Marshal returns the FrozenTopology marshalled in JSON (see Topology.Marshal).
+func (f *FrozenTopology) Marshal(opts ...Option) ([]byte, error) {
+	return f.topo.Marshal(opts...)
+}
+
+// MarshalJSON returns the FrozenTopology marshalled in JSON, exactly like the
+// Topology that it was frozen from would be.
+func (f *FrozenTopology) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.topo)
+}
@@ -0,0 +1,112 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	topo := &Topology{syntheticTree(1, 2, 2, 2)}
+	frozen, err := topo.Freeze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l3 := topo.L3Caches()[0]
+
+	// Modifying the original Topology, or the values returned by the
+	// FrozenTopology, does not affect it.
+	topo.Nodes[l3].Data.Attributes.Size = 1
+	e, err := frozen.Get(l3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Attributes.Size != 32<<20 {
+		t.Errorf("modifying the original Topology affected the FrozenTopology")
+	}
+	e.Attributes.Size = 2
+	children, _ := frozen.ImmediateDescendantIDs(0)
+	children[0] = 42
+	if again, _ := frozen.Get(l3); again.Attributes.Size != 32<<20 {
+		t.Errorf("modifying a returned Element affected the FrozenTopology")
+	}
+	if again, _ := frozen.ImmediateDescendantIDs(0); again[0] == 42 {
+		t.Errorf("modifying a returned list affected the FrozenTopology")
+	}
+
+	// Mutations return modified copies.
+	e.Attributes.Size = 64 << 20
+	bigger, err := frozen.WithElement(l3, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := bigger.Get(l3); got.Attributes.Size != 64<<20 {
+		t.Errorf("got an L3 of %d bytes in the modified copy", got.Attributes.Size)
+	}
+	if got, _ := frozen.Get(l3); got.Attributes.Size != 32<<20 {
+		t.Errorf("WithElement modified the FrozenTopology")
+	}
+	if _, err = frozen.WithElement(NodeID(frozen.Size()), e); err == nil {
+		t.Errorf("expected an error for an invalid NodeID")
+	}
+	smaller, err := frozen.Update(func(t *Topology) error {
+		// Remove the last Thread of the last Core.
+		last := NodeID(len(t.Nodes) - 1)
+		parent, _ := t.ParentID(last)
+		t.Nodes[parent].Children = t.Nodes[parent].Children[:1]
+		t.Nodes = t.Nodes[:last]
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(smaller.Threads()) != 7 || len(frozen.Threads()) != 8 {
+		t.Errorf("got %d and %d threads; expected 7 and 8", len(smaller.Threads()), len(frozen.Threads()))
+	}
+	broken := errors.New("broken")
+	if _, err = frozen.Update(func(*Topology) error { return broken }); err != broken {
+		t.Errorf("got %v; expected the error of the update function", err)
+	}
+	if _, err = frozen.Update(func(t *Topology) error { t.Nodes[0].Children = nil; return nil }); err == nil {
+		t.Errorf("expected an error for an invalid update")
+	}
+
+	// A FrozenTopology can be shared freely.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ids, err := frozen.Select("numanode:1/threads"); err != nil || len(ids) != 4 {
+				t.Errorf("got %v (%v)", ids, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := json.Marshal(frozen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var thawed Topology
+	if err = json.Unmarshal(data, &thawed); err != nil || thawed.Size() != frozen.Size() {
+		t.Errorf("got %d elements (%v) from a marshalled FrozenTopology", thawed.Size(), err)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func addFuzzSeeds(f *testing.F) {
+	for _, path := range []string{
+		"test_artifacts/t4_de.json",
+		"test_artifacts/topo__immutree.json",
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatalf("Error reading from file until EOF: %v\n", err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte(`{"nodes":[{"data":"machine","desc":[1]},{"data":{"processing":{"kind":"core","id":0}},"desc":[0]}]}`))
+	f.Add([]byte(`{"nodes":[{"data":"machine","desc":[1,9]},{"data":{"cache":{"lvl":"l2","li":0}}}]}`))
+}
+
+// exercise runs all queries of the Tree on every Element, to make sure that
+// none of them panics or loops forever on a Tree that passed validation.
+func exercise(tree *Tree) {
+	topo := &Topology{Tree: tree}
+	for id := range tree.Nodes {
+		_, _ = tree.LeafDescendantIDs(NodeID(id))
+		_, _ = tree.LeafDescendants(NodeID(id))
+		_, _ = tree.AncestorIDs(NodeID(id))
+		_, _ = tree.Ancestors(NodeID(id))
+		_, _ = tree.ParentID(NodeID(id))
+		_, _, _ = tree.SubtreeRange(NodeID(id))
+		_ = tree.Nodes[id].Data.String()
+	}
+	_ = topo.Threads()
+	_ = topo.L3Caches()
+}
+
+func FuzzUnmarshalTree(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var tree Tree
+		if err := json.Unmarshal(data, &tree); err != nil {
+			return
+		}
+		exercise(&tree)
+
+		remarshaled, err := json.Marshal(&tree)
+		if err != nil {
+			t.Fatalf("Failed to remarshal a valid Tree: %v\n", err)
+		}
+		var again Tree
+		if err = json.Unmarshal(remarshaled, &again); err != nil {
+			t.Fatalf("Failed to unmarshal remarshaled Tree %s: %v\n", remarshaled, err)
+		}
+	})
+}
+
+func FuzzSanitize(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		clean, _, err := Sanitize(data)
+		if err != nil {
+			return
+		}
+		var tree Tree
+		if err = json.Unmarshal(clean, &tree); err != nil {
+			t.Fatalf("Failed to unmarshal sanitized payload %s: %v\n", clean, err)
+		}
+		exercise(&tree)
+	})
+}
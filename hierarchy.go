@@ -0,0 +1,498 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Node is a node of a Hierarchy (or of a Tree; see TreeNode), which contains
+// some data along with the NodeIDs of its immediate descendants.
+type Node[T any] struct {
+	// Data is the data contained in this Node.
+	Data T `json:"data"`
+	// Children is a list of the NodeIDs that correspond to the immediate
+	// descendants (i.e., children) of this Node.
+	Children []NodeID `json:"desc,omitempty"`
+}
+
+// Hierarchy is a generic tree, which provides the traversal and structural
+// indexing machinery of Tree (which is a Hierarchy of Elements) for any other
+// kind of data (e.g., the hierarchies of cgroups or of devices).
+//
+// Like the Elements of a Tree, the Nodes of a valid Hierarchy are stored in
+// pre-order, starting from the root, and are indexed by their NodeIDs; the
+// same concurrency contract applies too (see the package's documentation).
+type Hierarchy[T any] struct {
+	// Nodes contains all Nodes that constitute the Hierarchy, and is
+	// indexed by their NodeIDs.
+	Nodes []Node[T] `json:"nodes"`
+
+	// indexes are built lazily; see InvalidateIndexes.
+	indexes structureIndexes
+}
+
+// structureIndexes contains the secondary indexes over the structure of a
+// Hierarchy, which are built lazily, the first time they are needed.
+type structureIndexes struct {
+	once sync.Once
+	// parents contains the NodeID of the parent of each Node, indexed by
+	// the Node's NodeID, or noParent for the root and any orphans.
+	parents []NodeID
+	// depths contains the depth of each Node (where the root Node is at
+	// depth 0), indexed by the Node's NodeID, or -1 for Nodes that are not
+	// reachable from the root.
+	depths []int
+	// sizes contains the number of Nodes in the subtree of each Node
+	// (including itself), indexed by the Node's NodeID.
+	sizes []int
+	// height is the maximum depth of any Node.
+	height int
+}
+
+// Size returns the number of Nodes currently stored in the Hierarchy.
+func (h *Hierarchy[T]) Size() int {
+	if nil == h {
+		return 0
+	}
+	return len(h.Nodes)
+}
+
+// IsEmpty returns true if there are no Nodes currently stored in the
+// Hierarchy.
+func (h *Hierarchy[T]) IsEmpty() bool {
+	return h.Size() == 0
+}
+
+// Get returns the data of the Node stored under the provided NodeID, or a
+// non-nil error value if it does not exist.
+func (h *Hierarchy[T]) Get(id NodeID) (data T, err error) {
+	if nil == h {
+		return data, ErrNilTree
+	}
+	if int(id) >= len(h.Nodes) {
+		return data, ErrInvalidNodeID{ID: id}
+	}
+	return h.Nodes[id].Data, nil
+}
+
+// ImmediateDescendantIDs returns the NodeIDs of the children of the Node
+// stored under the provided NodeID (see Tree.ImmediateDescendantIDs).
+func (h *Hierarchy[T]) ImmediateDescendantIDs(id NodeID) ([]NodeID, error) {
+	if nil == h {
+		return nil, ErrNilTree
+	}
+	if int(id) >= len(h.Nodes) {
+		return nil, ErrInvalidNodeID{ID: id}
+	}
+	return h.Nodes[id].Children, nil
+}
+
+// SubtreeRange returns the range [start, end) of the NodeIDs of the subtree of
+// the Node stored under the provided NodeID (see Tree.SubtreeRange).
+func (h *Hierarchy[T]) SubtreeRange(id NodeID) (start, end NodeID, err error) {
+	if nil == h {
+		return 0, 0, ErrNilTree
+	}
+	return subtreeRange(h.Nodes, id)
+}
+
+// LeafDescendantIDs returns the NodeIDs of the leaves of the subtree of the
+// Node stored under the provided NodeID (see Tree.LeafDescendantIDs).
+func (h *Hierarchy[T]) LeafDescendantIDs(id NodeID) ([]NodeID, error) {
+	return h.AppendLeafDescendantIDs(nil, id)
+}
+
+// AppendLeafDescendantIDs appends the NodeIDs of the leaves of the subtree of
+// the Node stored under the provided NodeID to dst (see
+// Tree.AppendLeafDescendantIDs).
+func (h *Hierarchy[T]) AppendLeafDescendantIDs(dst []NodeID, id NodeID) ([]NodeID, error) {
+	if nil == h {
+		return dst, ErrNilTree
+	}
+	return appendLeafDescendantIDs(dst, h.Nodes, id)
+}
+
+// ParentID returns the NodeID of the parent of the Node stored under the
+// provided NodeID (see Tree.ParentID).
+func (h *Hierarchy[T]) ParentID(id NodeID) (NodeID, error) {
+	if nil == h {
+		return 0, ErrNilTree
+	}
+	if int(id) >= len(h.Nodes) {
+		return 0, ErrInvalidNodeID{ID: id}
+	}
+	return parentID(h.getIndexes().parents, id)
+}
+
+// AncestorIDs returns the NodeIDs of the ancestors of the Node stored under
+// the provided NodeID, all the way up to the root (see Tree.AncestorIDs).
+func (h *Hierarchy[T]) AncestorIDs(id NodeID) ([]NodeID, error) {
+	if nil == h {
+		return nil, ErrNilTree
+	}
+	if int(id) >= len(h.Nodes) {
+		return nil, ErrInvalidNodeID{ID: id}
+	}
+	return ancestorIDs(h.getIndexes().parents, id)
+}
+
+// CommonAncestorID returns the NodeID of the lowest common ancestor of the
+// Nodes stored under the provided NodeIDs (see Tree.CommonAncestorID).
+func (h *Hierarchy[T]) CommonAncestorID(ids ...NodeID) (NodeID, error) {
+	return commonAncestorID(h.AncestorIDs, ids)
+}
+
+// Depth returns the depth of the Node stored under the provided NodeID, where
+// the root is at depth 0 (see Tree.Depth).
+func (h *Hierarchy[T]) Depth(id NodeID) (int, error) {
+	if nil == h {
+		return 0, ErrNilTree
+	}
+	if int(id) >= len(h.Nodes) {
+		return 0, ErrInvalidNodeID{ID: id}
+	}
+	if depth := h.getIndexes().depths[id]; depth >= 0 {
+		return depth, nil
+	}
+	return 0, ErrOrphan
+}
+
+// SubtreeSize returns the number of Nodes in the subtree of the Node stored
+// under the provided NodeID, including itself (see Tree.SubtreeSize).
+func (h *Hierarchy[T]) SubtreeSize(id NodeID) (int, error) {
+	if nil == h {
+		return 0, ErrNilTree
+	}
+	if int(id) >= len(h.Nodes) {
+		return 0, ErrInvalidNodeID{ID: id}
+	}
+	return h.getIndexes().sizes[id], nil
+}
+
+// Depths returns a table of the depths of all Nodes of the Hierarchy (see
+// Depth), indexed by their NodeIDs, with -1 for any Node that is not reachable
+// from the root. The table is owned by the caller.
+func (h *Hierarchy[T]) Depths() []int {
+	if nil == h {
+		return nil
+	}
+	return append([]int(nil), h.getIndexes().depths...)
+}
+
+// SubtreeSizes returns a table of the subtree sizes of all Nodes of the
+// Hierarchy (see SubtreeSize), indexed by their NodeIDs. The table is owned by
+// the caller.
+func (h *Hierarchy[T]) SubtreeSizes() []int {
+	if nil == h {
+		return nil
+	}
+	return append([]int(nil), h.getIndexes().sizes...)
+}
+
+// InvalidateIndexes discards the secondary indexes of the Hierarchy (see
+// Tree.InvalidateIndexes).
+func (h *Hierarchy[T]) InvalidateIndexes() {
+	if nil == h {
+		return
+	}
+	h.indexes = structureIndexes{}
+}
+
+// getIndexes returns the secondary indexes of the Hierarchy, building them
+// first if needed. It is safe to call it concurrently.
+func (h *Hierarchy[T]) getIndexes() *structureIndexes {
+	h.indexes.once.Do(func() {
+		h.indexes.parents, h.indexes.depths, h.indexes.sizes = buildStructure(h.Nodes)
+		for _, depth := range h.indexes.depths {
+			if depth > h.indexes.height {
+				h.indexes.height = depth
+			}
+		}
+	})
+	return &h.indexes
+}
+
+// Validate checks the structural integrity of the Hierarchy (i.e., everything
+// that Tree.Validate checks, except for the Elements themselves), and returns
+// a non-nil error value describing the first problem found, if any.
+//
+// Problems that concern a specific Node are reported as a *NodeError.
+func (h *Hierarchy[T]) Validate() error {
+	if nil == h {
+		return ErrNilTree
+	}
+	return validateStructure(h.Nodes, func(id NodeID, err error) error {
+		return &NodeError{ID: id, Offset: -1, Err: err}
+	})
+}
+
+// subtreeRange implements SubtreeRange for Hierarchies.
+func subtreeRange[T any](nodes []Node[T], id NodeID) (start, end NodeID, err error) {
+	if int(id) >= len(nodes) {
+		return 0, 0, ErrInvalidNodeID{ID: id}
+	}
+
+	// The subtree ends right after the subtree of the last child.
+	last := id
+	for steps := 0; len(nodes[last].Children) > 0; steps++ {
+		children := nodes[last].Children
+		if steps == len(nodes) || children[len(children)-1] <= last ||
+			int(children[len(children)-1]) >= len(nodes) {
+			return 0, 0, ErrNotPreOrder
+		}
+		last = children[len(children)-1]
+	}
+	return id, last + 1, nil
+}
+
+// appendLeafDescendantIDs implements AppendLeafDescendantIDs for Hierarchies.
+func appendLeafDescendantIDs[T any](dst []NodeID, nodes []Node[T], id NodeID) ([]NodeID, error) {
+	if int(id) >= len(nodes) {
+		return dst, ErrInvalidNodeID{ID: id}
+	}
+
+	var buf [leafStackSize]NodeID
+	stack := append(buf[:0], id)
+	for len(stack) > 0 {
+		last := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if children := nodes[last].Children; len(children) > 0 {
+			stack = append(stack, children...)
+		} else {
+			dst = append(dst, last)
+		}
+	}
+	return dst, nil
+}
+
+// buildStructure returns the parent of each Node (or noParent, for the root
+// and any orphans), its depth and the size of its subtree, for the structural
+// indexes of Hierarchies.
+func buildStructure[T any](nodes []Node[T]) (parents []NodeID, depths, sizes []int) {
+	parents = make([]NodeID, len(nodes))
+	for id := range parents {
+		parents[id] = noParent
+	}
+	for id := range nodes {
+		for _, child := range nodes[id].Children {
+			if int(child) < len(nodes) && parents[child] == noParent {
+				parents[child] = NodeID(id)
+			}
+		}
+	}
+	depths, sizes = buildDepthsAndSizes(nodes)
+	return
+}
+
+// buildDepthsAndSizes returns the depth of each Node and the size of its
+// subtree, through an iterative DFS from the root that never visits a Node
+// twice, so that it terminates even if the Tree is malformed.
+func buildDepthsAndSizes[T any](nodes []Node[T]) (depths, sizes []int) {
+	depths, sizes = make([]int, len(nodes)), make([]int, len(nodes))
+	for id := range depths {
+		depths[id] = -1
+	}
+	if len(nodes) == 0 {
+		return
+	}
+	type frame struct {
+		id   NodeID
+		next int
+	}
+	stack := []frame{{id: 0}}
+	depths[0] = 0
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		children := nodes[top.id].Children
+		if top.next == len(children) {
+			sizes[top.id]++
+			if len(stack) > 1 {
+				sizes[stack[len(stack)-2].id] += sizes[top.id]
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		child := children[top.next]
+		top.next++
+		if int(child) < len(nodes) && depths[child] == -1 {
+			depths[child] = len(stack)
+			stack = append(stack, frame{id: child})
+		}
+	}
+	return
+}
+
+// parentID implements ParentID for Hierarchies, through their parent index;
+// the NodeID is assumed to be valid.
+func parentID(parents []NodeID, id NodeID) (NodeID, error) {
+	if id == 0 {
+		return 0, ErrNoParent
+	}
+	if parents[id] == noParent {
+		return 0, ErrOrphan
+	}
+	return parents[id], nil
+}
+
+// ancestorIDs implements AncestorIDs for Hierarchies, through their parent
+// index; the NodeID is assumed to be valid.
+func ancestorIDs(parents []NodeID, id NodeID) ([]NodeID, error) {
+	ret := make([]NodeID, 0, 8)
+	for ; id != NodeID(0); id = parents[id] {
+		if parents[id] == noParent {
+			return nil, ErrOrphan
+		}
+		if len(ret) == len(parents) {
+			return nil, ErrCycle
+		}
+		ret = append(ret, parents[id])
+	}
+	return ret, nil
+}
+
+// lowestCommonAncestor returns the NodeID of the lowest common ancestor of the
+// provided NodeIDs, which are assumed to be valid, through the parent and depth
+// indexes, in O(depth) and without any heap allocations.
+func (idx *structureIndexes) lowestCommonAncestor(a, b NodeID) (NodeID, error) {
+	for steps := 0; a != b; steps++ {
+		if idx.depths[a] < 0 || idx.depths[b] < 0 {
+			return 0, ErrOrphan
+		}
+		if steps == 2*len(idx.parents) {
+			return 0, ErrCycle
+		}
+		// Step up from the deeper one; the root has depth 0, hence it is
+		// never stepped up from.
+		if idx.depths[a] >= idx.depths[b] {
+			a = idx.parents[a]
+		} else {
+			b = idx.parents[b]
+		}
+		if a == noParent || b == noParent {
+			return 0, ErrOrphan
+		}
+	}
+	return a, nil
+}
+
+// commonAncestorID implements CommonAncestorID for Hierarchies, through their
+// AncestorIDs method.
+func commonAncestorID(ancestorsOf func(NodeID) ([]NodeID, error), ids []NodeID) (NodeID, error) {
+	if len(ids) == 0 {
+		return 0, fmt.Errorf("no elements provided")
+	}
+	ancestorIDs, err := ancestorsOf(ids[0])
+	if err != nil {
+		return 0, err
+	}
+	// chain holds the candidates, from the deepest to the root.
+	chain := append([]NodeID{ids[0]}, ancestorIDs...)
+	position := make(map[NodeID]int, len(chain))
+	for i, id := range chain {
+		position[id] = i
+	}
+	lowest := 0
+	for _, id := range ids[1:] {
+		if lowest == len(chain)-1 {
+			break
+		}
+		if ancestorIDs, err = ancestorsOf(id); err != nil {
+			return 0, err
+		}
+		for _, candidate := range append([]NodeID{id}, ancestorIDs...) {
+			if i, ok := position[candidate]; ok {
+				if i > lowest {
+					lowest = i
+				}
+				break
+			}
+		}
+	}
+	return chain[lowest], nil
+}
+
+// validateStructure implements the structural checks of Validate for Trees and
+// Hierarchies, wrapping the problems it finds through nodeError.
+func validateStructure[T any](nodes []Node[T], nodeError func(id NodeID, err error) error) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	// Iterative DFS from the root; a Node is "open" while its subtree is
+	// being visited, and a child reference to an open Node closes a cycle,
+	// whereas a child reference to a "closed" Node (i.e., one whose
+	// subtree has already been visited) is a second parent.
+	const (
+		unvisited = iota
+		open
+		closed
+	)
+	state := make([]byte, len(nodes))
+	parents := make([]NodeID, len(nodes))
+	type frame struct {
+		id   NodeID
+		next int
+	}
+	stack := []frame{{id: 0}}
+	state[0] = open
+	// The first Node found out of pre-order is only reported after all
+	// other structural problems, which would also break the order.
+	preOrder, outOfOrder := NodeID(1), NodeID(0)
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		children := nodes[top.id].Children
+		if top.next == len(children) {
+			state[top.id] = closed
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		child := children[top.next]
+		top.next++
+		if int(child) >= len(nodes) {
+			return nodeError(top.id, fmt.Errorf("non-existent child: %w", ErrInvalidNodeID{ID: child}))
+		}
+		switch state[child] {
+		case open:
+			if child == top.id {
+				return nodeError(child, fmt.Errorf("%w: element lists itself as a child", ErrCycle))
+			}
+			return nodeError(top.id, fmt.Errorf("%w: element lists its ancestor %d as a child", ErrCycle, child))
+		case closed:
+			return nodeError(child, fmt.Errorf("%w: element is a child of both %d and %d",
+				ErrMultipleParents, parents[child], top.id))
+		case unvisited:
+			if child != preOrder && outOfOrder == 0 {
+				outOfOrder = child
+			}
+			preOrder++
+			state[child] = open
+			parents[child] = top.id
+			stack = append(stack, frame{id: child})
+		}
+	}
+	for id := range nodes {
+		if state[id] == unvisited {
+			return nodeError(NodeID(id), fmt.Errorf("%w: element is not reachable from the root", ErrOrphan))
+		}
+	}
+	if outOfOrder != 0 {
+		return nodeError(outOfOrder, fmt.Errorf("%w: element is stored out of order", ErrNotPreOrder))
+	}
+	return nil
+}
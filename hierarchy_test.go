@@ -0,0 +1,143 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// cgroupHierarchy returns a small Hierarchy of cgroup paths:
+//
+//	/
+//	├── kubepods
+//	│   ├── besteffort
+//	│   │   └── pod1
+//	│   └── burstable
+//	└── system.slice
+func cgroupHierarchy() *Hierarchy[string] {
+	return &Hierarchy[string]{Nodes: []Node[string]{
+		{Data: "/", Children: []NodeID{1, 5}},
+		{Data: "kubepods", Children: []NodeID{2, 4}},
+		{Data: "besteffort", Children: []NodeID{3}},
+		{Data: "pod1"},
+		{Data: "burstable"},
+		{Data: "system.slice"},
+	}}
+}
+
+func TestHierarchy(t *testing.T) {
+	h := cgroupHierarchy()
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := h.Get(3); err != nil || data != "pod1" {
+		t.Errorf("Get(3) = %q, %v; want \"pod1\"", data, err)
+	}
+	if start, end, err := h.SubtreeRange(1); err != nil || start != 1 || end != 5 {
+		t.Errorf("SubtreeRange(1) = [%d, %d), %v; want [1, 5)", start, end, err)
+	}
+	if leafIDs, err := h.LeafDescendantIDs(0); err != nil || len(leafIDs) != 3 {
+		t.Errorf("LeafDescendantIDs(0) = %v, %v; want 3 leaves", leafIDs, err)
+	}
+	if parentID, err := h.ParentID(4); err != nil || parentID != 1 {
+		t.Errorf("ParentID(4) = %d, %v; want 1", parentID, err)
+	}
+	if _, err := h.ParentID(0); !errors.Is(err, ErrNoParent) {
+		t.Errorf("got %v for the parent of the root; want ErrNoParent", err)
+	}
+	if ancestorIDs, err := h.AncestorIDs(3); err != nil || !reflect.DeepEqual(ancestorIDs, []NodeID{2, 1, 0}) {
+		t.Errorf("AncestorIDs(3) = %v, %v; want [2 1 0]", ancestorIDs, err)
+	}
+	if id, err := h.CommonAncestorID(3, 4); err != nil || id != 1 {
+		t.Errorf("CommonAncestorID(3, 4) = %d, %v; want 1", id, err)
+	}
+	if depth, err := h.Depth(3); err != nil || depth != 3 {
+		t.Errorf("Depth(3) = %d, %v; want 3", depth, err)
+	}
+	if size, err := h.SubtreeSize(1); err != nil || size != 4 {
+		t.Errorf("SubtreeSize(1) = %d, %v; want 4", size, err)
+	}
+	if depths := h.Depths(); !reflect.DeepEqual(depths, []int{0, 1, 2, 3, 2, 1}) {
+		t.Errorf("Depths() = %v; want [0 1 2 3 2 1]", depths)
+	}
+	if sizes := h.SubtreeSizes(); !reflect.DeepEqual(sizes, []int{6, 4, 2, 1, 1, 1}) {
+		t.Errorf("SubtreeSizes() = %v; want [6 4 2 1 1 1]", sizes)
+	}
+	if _, err := h.Get(NodeID(h.Size())); err == nil {
+		t.Errorf("expected an error for an invalid NodeID")
+	}
+
+	// The indexes follow modifications, once invalidated.
+	h.Nodes[0].Children = h.Nodes[0].Children[:1]
+	h.InvalidateIndexes()
+	if _, err := h.Depth(5); !errors.Is(err, ErrOrphan) {
+		t.Errorf("got %v for a detached Node; want ErrOrphan", err)
+	}
+	var nodeErr *NodeError
+	if err := h.Validate(); !errors.As(err, &nodeErr) || nodeErr.ID != 5 || !errors.Is(err, ErrOrphan) {
+		t.Errorf("got %v for a detached Node; want ErrOrphan for node 5", err)
+	}
+}
+
+func TestTreeIsHierarchy(t *testing.T) {
+	tree := syntheticTree(2, 2, 4, 2)
+	h := &tree.Hierarchy
+	if !reflect.DeepEqual(h.Depths(), tree.Depths()) || !reflect.DeepEqual(h.SubtreeSizes(), tree.SubtreeSizes()) {
+		t.Errorf("the Tree's indexes differ from those of its Hierarchy")
+	}
+
+	// Invalidating the Tree's indexes invalidates those of its Hierarchy.
+	last := NodeID(len(tree.Nodes) - 1)
+	tree.Nodes[0].Children = tree.Nodes[0].Children[:1]
+	tree.InvalidateIndexes()
+	if _, err := h.Depth(last); !errors.Is(err, ErrOrphan) {
+		t.Errorf("got %v for a detached Element; want ErrOrphan", err)
+	}
+
+	var nilTree *Tree
+	if nilTree.Size() != 0 || nilTree.Depths() != nil {
+		t.Errorf("expected a nil Tree to be empty")
+	}
+	if _, err := nilTree.AncestorIDs(0); !errors.Is(err, ErrNilTree) {
+		t.Errorf("got %v for a nil Tree; want ErrNilTree", err)
+	}
+}
+
+func TestHierarchyValidateRejectsCycles(t *testing.T) {
+	h := cgroupHierarchy()
+	h.Nodes[3].Children = []NodeID{1}
+	if err := h.Validate(); !errors.Is(err, ErrCycle) {
+		t.Errorf("got %v; want ErrCycle", err)
+	}
+}
+
+func TestHierarchyJSON(t *testing.T) {
+	data, err := json.Marshal(cgroupHierarchy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h Hierarchy[string]
+	if err = json.Unmarshal(data, &h); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h.Nodes, cgroupHierarchy().Nodes) {
+		t.Errorf("got %v after a round trip; want %v", h.Nodes, cgroupHierarchy().Nodes)
+	}
+}
@@ -0,0 +1,252 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snapshot is a Topology, as captured at a specific time.
+type Snapshot struct {
+	Time     time.Time
+	Topology *Topology
+}
+
+// History retains timestamped Snapshots of the Topology of a machine, bounded
+// by their number and age, so that the Topology that was current at any time
+// in the past can be looked up (e.g., during post-mortems on decisions that
+// were based on stale data).
+//
+// A History is safe for concurrent use by multiple goroutines.
+type History struct {
+	mu sync.Mutex
+	// maxSnapshots and maxAge bound the retained Snapshots; zero values
+	// mean no bound.
+	maxSnapshots int
+	maxAge       time.Duration
+	// snapshots are sorted by their Time, and consecutive identical
+	// Topologies share the same object.
+	snapshots []Snapshot
+	// hashes holds the content hash of each Snapshot's Topology.
+	hashes []Hash
+}
+
+// NewHistory returns a new, empty History that retains at most maxSnapshots
+// Snapshots, none older than maxAge relative to the most recent one; a zero
+// value for either means no bound.
+func NewHistory(maxSnapshots int, maxAge time.Duration) *History {
+	return &History{maxSnapshots: maxSnapshots, maxAge: maxAge}
+}
+
+// Len returns the number of Snapshots currently retained.
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.snapshots)
+}
+
+// Record adds a Snapshot of the provided Topology, captured at the provided
+// time, to the History, and discards any Snapshots that fall out of its
+// bounds as a result. It returns a non-nil error value in case of failure.
+//
+// Snapshots are normally recorded in chronological order, but they may also be
+// recorded out of order. The Topology must not be modified afterwards.
+func (h *History) Record(at time.Time, topo *Topology) error {
+	hash, err := topo.contentHash()
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := sort.Search(len(h.snapshots), func(i int) bool { return h.snapshots[i].Time.After(at) })
+	if i > 0 && h.hashes[i-1] == hash {
+		// Share the Topology with the identical previous Snapshot.
+		topo = h.snapshots[i-1].Topology
+	}
+	h.snapshots = append(h.snapshots, Snapshot{})
+	copy(h.snapshots[i+1:], h.snapshots[i:])
+	h.snapshots[i] = Snapshot{Time: at, Topology: topo}
+	h.hashes = append(h.hashes, Hash{})
+	copy(h.hashes[i+1:], h.hashes[i:])
+	h.hashes[i] = hash
+	h.prune()
+	return nil
+}
+
+// prune discards the Snapshots that fall out of the bounds of the History.
+func (h *History) prune() {
+	drop := 0
+	if h.maxSnapshots > 0 && len(h.snapshots) > h.maxSnapshots {
+		drop = len(h.snapshots) - h.maxSnapshots
+	}
+	if h.maxAge > 0 && len(h.snapshots) > 0 {
+		oldest := h.snapshots[len(h.snapshots)-1].Time.Add(-h.maxAge)
+		for drop < len(h.snapshots) && h.snapshots[drop].Time.Before(oldest) {
+			drop++
+		}
+	}
+	h.snapshots = append(h.snapshots[:0], h.snapshots[drop:]...)
+	h.hashes = append(h.hashes[:0], h.hashes[drop:]...)
+}
+
+// At returns the Snapshot that was current at the provided time (i.e., the
+// most recent one captured at or before it), if the History retains it.
+func (h *History) At(at time.Time) (Snapshot, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := sort.Search(len(h.snapshots), func(i int) bool { return h.snapshots[i].Time.After(at) })
+	if i == 0 {
+		return Snapshot{}, false
+	}
+	return h.snapshots[i-1], true
+}
+
+// Snapshots returns all Snapshots currently retained, in chronological order.
+func (h *History) Snapshots() []Snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Snapshot(nil), h.snapshots...)
+}
+
+// historyFile is the JSON representation of a History, in which each
+// Snapshot but the first is stored as a Delta from the previous one.
+type historyFile struct {
+	MaxSnapshots int             `json:"max_snapshots,omitempty"`
+	MaxAge       string          `json:"max_age,omitempty"`
+	Snapshots    []snapshotEntry `json:"snapshots"`
+}
+
+// snapshotEntry is the JSON representation of a Snapshot in a historyFile.
+type snapshotEntry struct {
+	Time     time.Time `json:"time"`
+	Topology *Topology `json:"topology,omitempty"`
+	Delta    *Delta    `json:"delta,omitempty"`
+}
+
+// MarshalJSON returns the History marshalled in JSON, or a non-nil error value
+// in case of failure. All Snapshots but the first are stored as Deltas from
+// the previous ones (see EncodeDelta), so that a long History of a machine
+// whose Topology rarely changes remains compact.
+func (h *History) MarshalJSON() ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	file := historyFile{MaxSnapshots: h.maxSnapshots, Snapshots: make([]snapshotEntry, 0, len(h.snapshots))}
+	if h.maxAge > 0 {
+		file.MaxAge = h.maxAge.String()
+	}
+	for i, snapshot := range h.snapshots {
+		entry := snapshotEntry{Time: snapshot.Time}
+		if i == 0 {
+			entry.Topology = snapshot.Topology
+		} else {
+			delta, err := EncodeDelta(h.snapshots[i-1].Topology, snapshot.Topology)
+			if err != nil {
+				return nil, err
+			}
+			entry.Delta = delta
+		}
+		file.Snapshots = append(file.Snapshots, entry)
+	}
+	return json.Marshal(file)
+}
+
+// UnmarshalJSON attempts to unmarshal the History from the provided byte slice
+// and returns a non-nil error if it fails.
+func (h *History) UnmarshalJSON(data []byte) error {
+	var file historyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	history := NewHistory(file.MaxSnapshots, 0)
+	if file.MaxAge != "" {
+		maxAge, err := time.ParseDuration(file.MaxAge)
+		if err != nil {
+			return fmt.Errorf("invalid max_age: %w", err)
+		}
+		history.maxAge = maxAge
+	}
+
+	var previous *Topology
+	for i, entry := range file.Snapshots {
+		topo := entry.Topology
+		switch {
+		case nil != entry.Delta && nil != previous:
+			var err error
+			if topo, err = ApplyDelta(previous, entry.Delta); err != nil {
+				return fmt.Errorf("snapshot %d: %w", i, err)
+			}
+		case nil == topo:
+			return fmt.Errorf("snapshot %d: neither a Topology nor a Delta from a previous one", i)
+		}
+		if err := history.Record(entry.Time, topo); err != nil {
+			return fmt.Errorf("snapshot %d: %w", i, err)
+		}
+		previous = topo
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxSnapshots, h.maxAge = history.maxSnapshots, history.maxAge
+	h.snapshots, h.hashes = history.snapshots, history.hashes
+	return nil
+}
+
+// WriteFile writes the History to the file with the provided name in JSON,
+// atomically replacing any existing file, or returns a non-nil error value in
+// case of failure.
+func (h *History) WriteFile(name string) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// ReadHistoryFile returns the History read from the file with the provided
+// name (see History.WriteFile), or a non-nil error value in case of failure.
+func ReadHistoryFile(name string) (*History, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	history := &History{}
+	if err = json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return history, nil
+}
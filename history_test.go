@@ -0,0 +1,83 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	history := NewHistory(3, time.Hour)
+	for i, cores := range []int{4, 4, 6, 6} {
+		topo := &Topology{syntheticTree(1, 1, cores, 2)}
+		if err := history.Record(start.Add(time.Duration(i)*10*time.Minute), topo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if history.Len() != 3 {
+		t.Fatalf("got %d snapshots retained; expected 3", history.Len())
+	}
+	if _, ok := history.At(start.Add(5 * time.Minute)); ok {
+		t.Errorf("got a snapshot that should have been discarded")
+	}
+	snapshot, ok := history.At(start.Add(15 * time.Minute))
+	if !ok || !snapshot.Time.Equal(start.Add(10*time.Minute)) || len(snapshot.Topology.Cores()) != 4 {
+		t.Errorf("got snapshot %v (%v); expected the one at %v", snapshot.Time, ok, start.Add(10*time.Minute))
+	}
+	if snapshot, _ = history.At(start.Add(2 * time.Hour)); len(snapshot.Topology.Cores()) != 6 {
+		t.Errorf("got %d cores in the latest snapshot; expected 6", len(snapshot.Topology.Cores()))
+	}
+	if snapshots := history.Snapshots(); snapshots[1].Topology != snapshots[2].Topology {
+		t.Errorf("identical consecutive snapshots do not share their Topology")
+	}
+
+	// Snapshots that are too old, relative to the latest one, are discarded.
+	if err := history.Record(start.Add(2*time.Hour), &Topology{syntheticTree(1, 1, 6, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if history.Len() != 1 {
+		t.Errorf("got %d snapshots retained; expected 1", history.Len())
+	}
+	if err := history.Record(start.Add(90*time.Minute), &Topology{syntheticTree(1, 1, 4, 2)}); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "history.json")
+	if err := history.WriteFile(name); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadHistoryFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := read.Snapshots(), history.Snapshots()
+	if len(got) != len(want) {
+		t.Fatalf("got %d snapshots from the file; expected %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || len(got[i].Topology.Cores()) != len(want[i].Topology.Cores()) {
+			t.Errorf("snapshot %d: got %v with %d cores; expected %v with %d", i, got[i].Time,
+				len(got[i].Topology.Cores()), want[i].Time, len(want[i].Topology.Cores()))
+		}
+	}
+	if read.maxSnapshots != 3 || read.maxAge != time.Hour {
+		t.Errorf("got bounds %d and %v from the file", read.maxSnapshots, read.maxAge)
+	}
+}
@@ -0,0 +1,265 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HardwareProfile summarizes a Topology in terms of the numbers of its
+// Elements, to explain how Topologies of different hardware classes differ.
+type HardwareProfile struct {
+	Packages  int `json:"packages"`
+	NUMANodes int `json:"numa_nodes"`
+	Cores     int `json:"cores"`
+	Threads   int `json:"threads"`
+	// ThreadsPerCore is the maximum number of hardware threads of any
+	// Core; it is 1 if SMT is disabled.
+	ThreadsPerCore int `json:"threads_per_core"`
+	// Caches summarizes the Caches of each level that the Topology has.
+	Caches []CacheProfile `json:"caches,omitempty"`
+}
+
+// CacheProfile summarizes the Caches of a level in a HardwareProfile.
+type CacheProfile struct {
+	Level CacheLevel `json:"lvl"`
+	Count int        `json:"count"`
+	// TotalSize is the sum of the sizes of all Caches of the level.
+	TotalSize uint64 `json:"total_size"`
+}
+
+// cache returns the CacheProfile of the provided level, if the
+// HardwareProfile has any Caches of that level.
+func (hp *HardwareProfile) cache(level CacheLevel) (CacheProfile, bool) {
+	for _, cp := range hp.Caches {
+		if cp.Level == level {
+			return cp, true
+		}
+	}
+	return CacheProfile{}, false
+}
+
+// Profile returns the HardwareProfile of the Topology.
+func (t *Topology) Profile() HardwareProfile {
+	hp := HardwareProfile{
+		Packages:  len(t.Packages()),
+		NUMANodes: len(t.NUMANodes()),
+		Cores:     len(t.Cores()),
+		Threads:   len(t.Threads()),
+	}
+	for _, coreID := range t.Cores() {
+		if n := t.countThreads(coreID); n > hp.ThreadsPerCore {
+			hp.ThreadsPerCore = n
+		}
+	}
+	for level := L1; level <= L5; level++ {
+		caches := t.getAllCacheLevel(level)
+		if len(caches) == 0 {
+			continue
+		}
+		cp := CacheProfile{Level: level, Count: len(caches)}
+		for _, id := range caches {
+			if attrs := t.Nodes[id].Data.Attributes; nil != attrs {
+				cp.TotalSize += attrs.Size
+			}
+		}
+		hp.Caches = append(hp.Caches, cp)
+	}
+	return hp
+}
+
+// Difference describes a property in which a HardwareClass differs from the
+// reference one.
+type Difference struct {
+	// Property is the name of the property (e.g., "threads_per_core" or
+	// "L3.size").
+	Property string `json:"property"`
+	// Reference and Actual are the values of the property in the reference
+	// HardwareClass and in this one, respectively.
+	Reference string `json:"reference"`
+	Actual    string `json:"actual"`
+	// Description is a human-readable description of the difference (e.g.,
+	// "has SMT disabled" or "has half the L3").
+	Description string `json:"desc"`
+}
+
+// HardwareClass is a set of nodes whose Topologies share the same Fingerprint.
+type HardwareClass struct {
+	Fingerprint Fingerprint     `json:"fingerprint"`
+	Profile     HardwareProfile `json:"profile"`
+	// Nodes are the names of the nodes of the class, sorted.
+	Nodes []string `json:"nodes"`
+	// Differences explain how the class differs from the reference one; it
+	// is empty for the reference class itself.
+	Differences []Difference `json:"differences,omitempty"`
+}
+
+// HomogeneityReport partitions the nodes of a ClusterTopology into
+// HardwareClasses.
+type HomogeneityReport struct {
+	// Classes are sorted by their number of nodes, in descending order;
+	// the first one is the reference class, which the rest are compared
+	// against.
+	Classes []HardwareClass `json:"classes"`
+}
+
+// Homogeneous returns true if all nodes belong to the same HardwareClass.
+func (hr *HomogeneityReport) Homogeneous() bool {
+	return len(hr.Classes) <= 1
+}
+
+// String returns a human-readable representation of the HomogeneityReport.
+func (hr *HomogeneityReport) String() string {
+	var sb strings.Builder
+	nodes := 0
+	for _, class := range hr.Classes {
+		nodes += len(class.Nodes)
+	}
+	fmt.Fprintf(&sb, "%d hardware class(es) among %d node(s)\n", len(hr.Classes), nodes)
+	for i, class := range hr.Classes {
+		fmt.Fprintf(&sb, "class %d: %s", i+1, strings.Join(class.Nodes, ", "))
+		if i == 0 {
+			sb.WriteString(" (reference)")
+		}
+		sb.WriteByte('\n')
+		for _, diff := range class.Differences {
+			fmt.Fprintf(&sb, "  - %s\n", diff.Description)
+		}
+	}
+	return sb.String()
+}
+
+// Homogeneity partitions the nodes of the ClusterTopology into HardwareClasses
+// by the Fingerprints of their Topologies (see Topology.Fingerprint), and
+// explains how each class differs from the largest one (e.g., "has SMT
+// disabled" or "has half the L3"), or returns a non-nil error value in case of
+// failure.
+//
+// Classes that only differ in their Fingerprints (e.g., in the OS indices of
+// their Elements) are explained as such.
+func (c *ClusterTopology) Homogeneity() (*HomogeneityReport, error) {
+	byFingerprint := make(map[Fingerprint]*HardwareClass)
+	for _, name := range c.Names() {
+		topo := c.Nodes[name]
+		fp, err := topo.Fingerprint()
+		if err != nil {
+			return nil, fmt.Errorf("node '%s': %w", name, err)
+		}
+		class, ok := byFingerprint[fp]
+		if !ok {
+			class = &HardwareClass{Fingerprint: fp, Profile: topo.Profile()}
+			byFingerprint[fp] = class
+		}
+		class.Nodes = append(class.Nodes, name)
+	}
+
+	report := &HomogeneityReport{Classes: make([]HardwareClass, 0, len(byFingerprint))}
+	for _, class := range byFingerprint {
+		report.Classes = append(report.Classes, *class)
+	}
+	sort.Slice(report.Classes, func(i, j int) bool {
+		ci, cj := report.Classes[i], report.Classes[j]
+		if len(ci.Nodes) != len(cj.Nodes) {
+			return len(ci.Nodes) > len(cj.Nodes)
+		}
+		return ci.Nodes[0] < cj.Nodes[0]
+	})
+	for i := 1; i < len(report.Classes); i++ {
+		report.Classes[i].Differences = compareClasses(&report.Classes[0], &report.Classes[i])
+	}
+	return report, nil
+}
+
+// compareClasses returns the Differences of the provided HardwareClass from the
+// provided reference one.
+func compareClasses(ref, class *HardwareClass) []Difference {
+	diffs := make([]Difference, 0)
+	add := func(property string, reference, actual interface{}, format string, args ...interface{}) {
+		diffs = append(diffs, Difference{
+			Property:    property,
+			Reference:   fmt.Sprint(reference),
+			Actual:      fmt.Sprint(actual),
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+	count := func(property, name string, reference, actual int) {
+		if reference != actual {
+			add(property, reference, actual, "has %d %s instead of %d", actual, name, reference)
+		}
+	}
+
+	rp, cp := &ref.Profile, &class.Profile
+	count("packages", "Packages", rp.Packages, cp.Packages)
+	count("numa_nodes", "NUMA nodes", rp.NUMANodes, cp.NUMANodes)
+	count("cores", "Cores", rp.Cores, cp.Cores)
+	switch {
+	case rp.ThreadsPerCore == cp.ThreadsPerCore:
+	case cp.ThreadsPerCore == 1:
+		add("threads_per_core", rp.ThreadsPerCore, cp.ThreadsPerCore, "has SMT disabled")
+	case rp.ThreadsPerCore == 1:
+		add("threads_per_core", rp.ThreadsPerCore, cp.ThreadsPerCore, "has SMT enabled")
+	default:
+		add("threads_per_core", rp.ThreadsPerCore, cp.ThreadsPerCore,
+			"has %d threads per Core instead of %d", cp.ThreadsPerCore, rp.ThreadsPerCore)
+	}
+	// A different number of threads is only worth mentioning on its own if
+	// it is not explained by a different number of threads per Core.
+	if rp.Cores != cp.Cores || rp.ThreadsPerCore == cp.ThreadsPerCore {
+		count("threads", "Threads", rp.Threads, cp.Threads)
+	}
+
+	for level := L1; level <= L5; level++ {
+		rc, rok := rp.cache(level)
+		cc, cok := cp.cache(level)
+		switch {
+		case !rok && !cok:
+			continue
+		case !cok:
+			add(level.String()+".count", rc.Count, 0, "has no %s caches", level)
+			continue
+		case !rok:
+			add(level.String()+".count", 0, cc.Count, "has %d %s caches, unlike the reference", cc.Count, level)
+			continue
+		}
+		count(level.String()+".count", level.String()+" caches", rc.Count, cc.Count)
+		if rc.TotalSize == cc.TotalSize {
+			continue
+		}
+		switch {
+		case 2*cc.TotalSize == rc.TotalSize:
+			add(level.String()+".size", rc.TotalSize, cc.TotalSize, "has half the %s", level)
+		case cc.TotalSize == 2*rc.TotalSize:
+			add(level.String()+".size", rc.TotalSize, cc.TotalSize, "has twice the %s", level)
+		default:
+			add(level.String()+".size", rc.TotalSize, cc.TotalSize, "has %s of %s instead of %s",
+				formatBytes(cc.TotalSize), level, formatBytes(rc.TotalSize))
+		}
+	}
+
+	if len(diffs) == 0 {
+		if ref.Fingerprint.Structure != class.Fingerprint.Structure {
+			add("structure", ref.Fingerprint.Structure, class.Fingerprint.Structure,
+				"differs in the hierarchy of its elements")
+		} else {
+			add("full", ref.Fingerprint.Full, class.Fingerprint.Full,
+				"differs in the OS indices of its elements or in the attributes of its caches")
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,88 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHomogeneity(t *testing.T) {
+	cluster := NewClusterTopology()
+	add := func(name string, tree *Tree) {
+		if err := cluster.Add(name, &Topology{tree}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("node-a", syntheticTree(2, 1, 4, 2))
+	add("node-b", syntheticTree(2, 1, 4, 2))
+	add("node-c", syntheticTree(2, 1, 4, 2))
+	add("node-d", syntheticTree(2, 1, 4, 1))
+	halfL3 := syntheticTree(2, 1, 4, 2)
+	for _, node := range halfL3.Nodes {
+		if node.Data.IsCache() && node.Data.Level == L3 {
+			node.Data.Attributes.Size /= 2
+		}
+	}
+	add("node-e", halfL3)
+	renumbered := syntheticTree(2, 1, 4, 2)
+	renumbered.Nodes[len(renumbered.Nodes)-1].Data.ID = 100
+	add("node-f", renumbered)
+
+	report, err := cluster.Homogeneity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Report:\n%s", report)
+	if report.Homogeneous() || len(report.Classes) != 4 {
+		t.Fatalf("got %d classes; want 4", len(report.Classes))
+	}
+	if ref := report.Classes[0]; !reflect.DeepEqual(ref.Nodes, []string{"node-a", "node-b", "node-c"}) ||
+		len(ref.Differences) != 0 || ref.Profile.Threads != 16 || ref.Profile.ThreadsPerCore != 2 {
+		t.Errorf("unexpected reference class: %+v", ref)
+	}
+	for i, want := range []struct {
+		node     string
+		property string
+		desc     string
+	}{
+		{"node-d", "threads_per_core", "has SMT disabled"},
+		{"node-e", "L3.size", "has half the L3"},
+		{"node-f", "full", "differs in the OS indices of its elements or in the attributes of its caches"},
+	} {
+		class := report.Classes[i+1]
+		if len(class.Nodes) != 1 || class.Nodes[0] != want.node || len(class.Differences) != 1 ||
+			class.Differences[0].Property != want.property || class.Differences[0].Description != want.desc {
+			t.Errorf("unexpected class %d: %+v", i+1, class)
+		}
+	}
+
+	if _, err = json.Marshal(report); err != nil {
+		t.Errorf("Failed to marshal the report: %v", err)
+	}
+
+	homogeneous := NewClusterTopology()
+	for _, name := range []string{"x", "y"} {
+		if err = homogeneous.Add(name, &Topology{syntheticTree(1, 1, 2, 2)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if report, err = homogeneous.Homogeneity(); err != nil || !report.Homogeneous() {
+		t.Errorf("got %v (%v) for a homogeneous cluster", report, err)
+	}
+}
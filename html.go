@@ -0,0 +1,169 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"html/template"
+	"io"
+)
+
+// WriteHTML writes a self-contained HTML document to the provided io.Writer,
+// which embeds the Topology in JSON along with a small JavaScript viewer that
+// presents it as a tree of collapsible subtrees and supports searching for
+// Processing nodes by their OS index. It returns a non-nil error value in case
+// of failure.
+//
+// The document does not load any external resources, so that it can be
+// attached to tickets and opened offline.
+func (t *Topology) WriteHTML(w io.Writer, title string) error {
+	return t.WriteHTMLOverlay(w, title, nil)
+}
+
+// WriteHTMLOverlay is like WriteHTML, but also embeds the provided Overlay
+// (which may be nil) in the document, so that the viewer colors the Elements
+// with a value as a heatmap and shows their labels.
+func (t *Topology) WriteHTMLOverlay(w io.Writer, title string, overlay *Overlay) error {
+	if nil == t || nil == t.Tree {
+		return ErrNilTree
+	}
+	if t.IsEmpty() {
+		return ErrEmptyTree
+	}
+	if nil == overlay {
+		overlay = &Overlay{}
+	}
+	return htmlTemplate.Execute(w, struct {
+		Title    string
+		Topology *Topology
+		Overlay  *Overlay
+	}{title, t, overlay})
+}
+
+// htmlTemplate is the template of the document written by WriteHTML; the
+// Topology is marshalled in JSON by html/template itself, which escapes it
+// properly for the <script> context.
+var htmlTemplate = template.Must(template.New("topology").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: monospace; margin: 1em; }
+details { margin-left: 1.5em; }
+summary { cursor: pointer; }
+.leaf { margin-left: 2.7em; }
+.processing { color: #1a5fb4; }
+.cache { color: #26a269; }
+.match { outline: 2px solid #f6d32d; }
+.label { color: #5e5c64; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>
+<label>OS index: <input id="search" type="number" min="0"></label>
+<button id="expand">Expand all</button>
+<button id="collapse">Collapse all</button>
+<span id="status"></span>
+</p>
+<div id="tree"></div>
+<script>
+"use strict";
+const topology = {{.Topology}};
+const overlay = {{.Overlay}};
+const nodes = topology.nodes || [];
+
+function label(data) {
+	if (typeof data === "string") {
+		return "Machine";
+	}
+	if (data.processing) {
+		return data.processing.kind + "(" + data.processing.id + ")";
+	}
+	const c = data.cache, a = c.attrs || {};
+	return c.lvl + " (L#" + c.li + ") " + a.size + "B/" + a.line + "B/" + a.ways + "-way";
+}
+
+const elements = [];
+function render(id) {
+	const node = nodes[id];
+	const text = document.createElement(node.desc && node.desc.length ? "summary" : "div");
+	text.textContent = "[" + id + "] " + label(node.data);
+	if (node.data.processing) {
+		text.className = "processing";
+	} else if (node.data.cache) {
+		text.className = "cache";
+	}
+	const value = (overlay.values || {})[id];
+	if (value !== undefined) {
+		const clamped = Math.max(0, Math.min(1, value));
+		text.textContent += " (" + clamped.toFixed(2) + ")";
+		text.style.background = "hsl(" + (1 - clamped) * 120 + ", 80%, 80%)";
+	}
+	const labelText = (overlay.labels || {})[id];
+	if (labelText !== undefined) {
+		const span = document.createElement("span");
+		span.className = "label";
+		span.textContent = " " + JSON.stringify(labelText);
+		text.appendChild(span);
+	}
+	elements[id] = text;
+	if (!node.desc || node.desc.length === 0) {
+		text.className += " leaf";
+		return text;
+	}
+	const details = document.createElement("details");
+	details.open = true;
+	details.appendChild(text);
+	for (const child of node.desc) {
+		details.appendChild(render(child));
+	}
+	return details;
+}
+if (nodes.length > 0) {
+	document.getElementById("tree").appendChild(render(0));
+}
+
+function setOpen(open) {
+	for (const details of document.querySelectorAll("details")) {
+		details.open = open;
+	}
+}
+document.getElementById("expand").onclick = () => setOpen(true);
+document.getElementById("collapse").onclick = () => setOpen(false);
+
+document.getElementById("search").oninput = (event) => {
+	const osIndex = event.target.value === "" ? NaN : Number(event.target.value);
+	let matches = 0;
+	nodes.forEach((node, id) => {
+		const match = node.data.processing !== undefined && node.data.processing.id === osIndex;
+		elements[id].classList.toggle("match", match);
+		if (match) {
+			matches++;
+			for (let e = elements[id].parentElement; e !== null; e = e.parentElement) {
+				if (e.tagName === "DETAILS") {
+					e.open = true;
+				}
+			}
+		}
+	});
+	document.getElementById("status").textContent = isNaN(osIndex) ? "" : matches + " match(es)";
+};
+</script>
+</body>
+</html>
+`))
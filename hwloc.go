@@ -0,0 +1,305 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// hwlocObject is an <object> element of an hwloc XML topology.
+type hwlocObject struct {
+	Type      string        `xml:"type,attr"`
+	OSIndex   string        `xml:"os_index,attr"`
+	CPUSet    string        `xml:"cpuset,attr"`
+	Depth     string        `xml:"depth,attr"`
+	CacheSize string        `xml:"cache_size,attr"`
+	LineSize  string        `xml:"cache_linesize,attr"`
+	Ways      string        `xml:"cache_associativity,attr"`
+	CacheType string        `xml:"cache_type,attr"`
+	Memory    string        `xml:"local_memory,attr"`
+	Subtype   string        `xml:"subtype,attr"`
+	Name      string        `xml:"name,attr"`
+	OSDevType string        `xml:"osdev_type,attr"`
+	PCIBusID  string        `xml:"pci_busid,attr"`
+	Infos     []hwlocInfo   `xml:"info"`
+	Children  []hwlocObject `xml:"object"`
+}
+
+// hwlocInfo is a name-value pair attached to an object of an hwloc XML
+// topology (e.g., "Inclusive", which the x86 backend reports for Caches).
+type hwlocInfo struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// ParseHwlocXML returns the Topology parsed from the provided hwloc XML
+// topology (as exported by `lstopo topo.xml`, in either the hwloc 1.x or the
+// 2.x format), or a non-nil error value in case of failure.
+//
+// Only the objects that have a counterpart in a Topology are kept: Packages
+// (or Sockets), NUMA nodes, data or unified Caches, Cores, PUs (as Threads)
+// and OS devices (as OSDevices, along with the bus ID of their PCI device).
+// Other CPU-side objects (e.g., Groups, Dies and instruction Caches), PCI
+// devices and bridges are removed, with their children attached to their
+// parent, while Misc objects are removed along with their subtrees. Since
+// hwloc 2.x attaches NUMA nodes to the side of the CPU-side hierarchy, each
+// NUMA node is placed between its parent and the children whose cpusets it
+// covers; I/O objects, which have no cpusets, are only placed under a NUMA
+// node if it is the only one of their parent. Caches are given logical indices
+// per level, in the order they appear.
+func ParseHwlocXML(r io.Reader) (*Topology, error) {
+	var doc struct {
+		XMLName xml.Name      `xml:"topology"`
+		Objects []hwlocObject `xml:"object"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid hwloc XML topology: %w", err)
+	}
+	if len(doc.Objects) != 1 || doc.Objects[0].Type != "Machine" && doc.Objects[0].Type != "System" {
+		return nil, fmt.Errorf("invalid hwloc XML topology: expected a single root Machine object")
+	}
+
+	p := &hwlocParser{tree: NewTree([]TreeNode{{Data: &Element{}}})}
+	for i := range doc.Objects[0].Children {
+		if err := p.add(0, &doc.Objects[0].Children[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.tree.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid hwloc XML topology: %w", err)
+	}
+	return &Topology{Tree: p.tree}, nil
+}
+
+// hwlocParser builds a Tree out of hwlocObjects, in pre-order.
+type hwlocParser struct {
+	tree *Tree
+	// cacheLI holds the next logical index of each CacheLevel.
+	cacheLI [L5 + 1]uint32
+	// busID is the PCI bus ID of the innermost PCI device that is being
+	// added, if any.
+	busID string
+}
+
+// add adds the provided hwlocObject, along with its subtree, under the parent
+// stored under the provided NodeID.
+func (p *hwlocParser) add(parent NodeID, obj *hwlocObject) error {
+	data, keep, err := p.element(obj)
+	if err != nil {
+		return err
+	}
+	if !keep {
+		if data == nil {
+			// Keep the children of any removed CPU-side object or
+			// PCI device.
+			if obj.Type == "PCIDev" {
+				busID := p.busID
+				p.busID = obj.PCIBusID
+				defer func() { p.busID = busID }()
+			}
+			return p.addChildren(parent, obj.Children)
+		}
+		return nil
+	}
+	id := NodeID(len(p.tree.Nodes))
+	p.tree.Nodes = append(p.tree.Nodes, TreeNode{Data: data})
+	p.tree.Nodes[parent].Children = append(p.tree.Nodes[parent].Children, id)
+	return p.addChildren(id, obj.Children)
+}
+
+// addChildren adds the provided hwlocObjects under the parent stored under
+// the provided NodeID, placing any NUMA nodes among them between the parent
+// and the objects whose cpusets they cover.
+func (p *hwlocParser) addChildren(parent NodeID, children []hwlocObject) error {
+	numaNodes := make([]*hwlocObject, 0)
+	others := make([]*hwlocObject, 0, len(children))
+	for i := range children {
+		if children[i].Type == "NUMANode" && len(children[i].Children) == 0 {
+			numaNodes = append(numaNodes, &children[i])
+		} else {
+			others = append(others, &children[i])
+		}
+	}
+
+	placed := make([]bool, len(others))
+	for _, numa := range numaNodes {
+		numaSet, err := parseHwlocCPUSet(numa.CPUSet)
+		if err != nil {
+			return err
+		}
+		numaID := NodeID(len(p.tree.Nodes))
+		if err = p.add(parent, numa); err != nil {
+			return err
+		}
+		for i, obj := range others {
+			if placed[i] {
+				continue
+			}
+			objSet, err := parseHwlocCPUSet(obj.CPUSet)
+			if err != nil {
+				return err
+			}
+			if len(numaNodes) == 1 || objSet.Sign() != 0 && new(big.Int).And(objSet, numaSet).Cmp(objSet) == 0 {
+				placed[i] = true
+				if err = p.add(numaID, obj); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for i, obj := range others {
+		if !placed[i] {
+			if err := p.add(parent, obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// element returns the Element that corresponds to the provided hwlocObject,
+// and whether it should be kept in the Tree. A nil Element that should not be
+// kept denotes a CPU-side object, a PCI device or a bridge, whose children
+// should be kept nonetheless.
+func (p *hwlocParser) element(obj *hwlocObject) (data *Element, keep bool, err error) {
+	processing := func(kind ProcessingKind) (*Element, bool, error) {
+		id, err := strconv.ParseUint(obj.OSIndex, 10, 32)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid os_index of %s object: %v", obj.Type, err)
+		}
+		return &Element{Processing: &Processing{Kind: kind, ID: uint32(id)}}, true, nil
+	}
+
+	switch obj.Type {
+	case "Package", "Socket":
+		return processing(Package)
+	case "NUMANode":
+		data, keep, err := processing(NUMANode)
+		if err == nil && obj.Memory != "" {
+			var bytes uint64
+			if bytes, err = parseHwlocUint(obj.Memory, 64); err != nil {
+				return nil, false, fmt.Errorf("invalid local_memory of %s object: %v", obj.Type, err)
+			}
+			data.Memory = &MemoryAttributes{Bytes: bytes}
+			// hwloc only sets the subtype of NUMA nodes that are
+			// not DRAM, and may use subtypes that are unknown to us.
+			if tier, tierErr := ParseMemoryTier(obj.Subtype); tierErr == nil {
+				data.Memory.Tier = tier
+			}
+		}
+		return data, keep, err
+	case "Core":
+		return processing(Core)
+	case "PU":
+		return processing(Thread)
+	case "Group", "Die", "L1iCache", "L2iCache", "L3iCache", "Bridge", "PCIDev":
+		return nil, false, nil
+	case "OSDev":
+		return &Element{Device: &Device{Type: OSDevice, Name: obj.Name, BusID: p.busID, Class: hwlocOSDevClass(obj)}}, true, nil
+	case "Cache", "L1Cache", "L2Cache", "L3Cache", "L4Cache", "L5Cache":
+		// hwloc 1.x uses a generic Cache type along with its depth.
+		levelStr := strings.TrimSuffix(obj.Type, "Cache")
+		if obj.Type == "Cache" {
+			levelStr = "L" + obj.Depth
+		}
+		level, err := ParseCacheLevel(levelStr)
+		if err != nil {
+			return nil, false, err
+		}
+		if obj.CacheType == "2" {
+			// Instruction cache
+			return nil, false, nil
+		}
+		attrs := &CacheAttributes{}
+		if attrs.Size, err = parseHwlocUint(obj.CacheSize, 64); err == nil {
+			var line, ways uint64
+			if line, err = parseHwlocUint(obj.LineSize, 32); err == nil {
+				attrs.Linesize = uint32(line)
+				ways, err = parseHwlocUint(strings.TrimPrefix(obj.Ways, "-"), 31)
+				attrs.Associativity = int32(ways)
+				if strings.HasPrefix(obj.Ways, "-") {
+					attrs.Associativity = -attrs.Associativity
+				}
+			}
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid attributes of %s object: %v", obj.Type, err)
+		}
+		// hwloc does not tell exclusive Caches apart from other
+		// non-inclusive ones.
+		for _, info := range obj.Infos {
+			if info.Name == "Inclusive" && info.Value == "0" {
+				attrs.Inclusion = NonInclusive
+			}
+		}
+		li := p.cacheLI[level]
+		p.cacheLI[level]++
+		return &Element{Cache: &Cache{Level: level, LogicalIndex: li, Attributes: attrs}}, true, nil
+	default:
+		// Misc and memory-side cache objects
+		return &Element{}, false, nil
+	}
+}
+
+// hwlocOSDevClass returns the class of the provided OSDev object, as named by
+// hwloc (e.g., "network"), or its subtype if its osdev_type is unknown.
+func hwlocOSDevClass(obj *hwlocObject) string {
+	switch obj.OSDevType {
+	case "0":
+		return "block"
+	case "1":
+		return "gpu"
+	case "2":
+		return "network"
+	case "3":
+		return "openfabrics"
+	case "4":
+		return "dma"
+	case "5":
+		return "coproc"
+	default:
+		return strings.ToLower(obj.Subtype)
+	}
+}
+
+// parseHwlocUint parses an optional unsigned integer attribute of an hwloc XML
+// object, defaulting to zero.
+func parseHwlocUint(str string, bitSize int) (uint64, error) {
+	if str == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(str, 10, bitSize)
+}
+
+// parseHwlocCPUSet parses an hwloc cpuset string (e.g., "0x000000ff,0xffffffff"),
+// which consists of comma-separated 32-bit hexadecimal words, most significant
+// first. A missing cpuset is parsed as an empty one.
+func parseHwlocCPUSet(str string) (*big.Int, error) {
+	if str == "" {
+		return new(big.Int), nil
+	}
+	set, ok := new(big.Int).SetString(strings.NewReplacer("0x", "", ",", "").Replace(str), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hwloc cpuset '%s'", str)
+	}
+	return set, nil
+}
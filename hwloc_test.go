@@ -0,0 +1,116 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseHwlocXML(t *testing.T) {
+	f, err := os.Open("test_artifacts/hwloc2_2pkg.xml")
+	if err != nil {
+		t.Fatalf("Error opening file: %v\n", err)
+	}
+	defer f.Close()
+	topo, err := ParseHwlocXML(f)
+	if err != nil {
+		t.Fatalf("Failed to parse hwloc XML: %v\n", err)
+	}
+
+	var sb strings.Builder
+	if err = topo.WriteLstopo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"Machine",
+		"  Package L#0 + NUMANode L#0 (P#0) + L3 L#0 (8192KB)",
+		"    L2 L#0 (256KB) + L1d L#0 (32KB) + Core L#0",
+		"      PU L#0 (P#0)",
+		"      PU L#1 (P#4)",
+		"    L2 L#1 (256KB) + L1d L#1 (32KB) + Core L#1",
+		"      PU L#2 (P#1)",
+		"      PU L#3 (P#5)",
+		"  Package L#1 + NUMANode L#1 (P#1) + L3 L#1 (8192KB)",
+		"    L2 L#2 (256KB) + L1d L#2 (32KB) + Core L#2",
+		"      PU L#4 (P#2)",
+		"      PU L#5 (P#6)",
+		"    L2 L#3 (256KB) + L1d L#3 (32KB) + Core L#3",
+		"      PU L#6 (P#3)",
+		"      PU L#7 (P#7)",
+		"",
+	}, "\n")
+	if got := sb.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if attrs := topo.Nodes[topo.L2Caches()[0]].Data.Attributes; attrs.Linesize != 64 || attrs.Associativity != 4 {
+		t.Errorf("got L2 attributes %s", attrs)
+	}
+	if attrs := topo.Nodes[topo.L3Caches()[0]].Data.Attributes; attrs.Inclusion != Inclusive {
+		t.Errorf("got L3 attributes %s", attrs)
+	}
+
+	payload := `<topology><object type="Machine" os_index="0">` +
+		`<object type="L3Cache" cache_size="8388608" cache_linesize="64" cache_associativity="16"><info name="Inclusive" value="0"/>` +
+		`<object type="Core" os_index="0"><object type="PU" os_index="0"/></object></object></object></topology>`
+	if topo, err = ParseHwlocXML(strings.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+	if attrs := topo.Nodes[topo.L3Caches()[0]].Data.Attributes; attrs.Inclusion != NonInclusive {
+		t.Errorf("got L3 attributes %s; expected a non-inclusive cache", attrs)
+	}
+
+	// OS devices are kept, along with the bus ID of their PCI device, under
+	// the NUMA node that their bridge is local to.
+	payload = `<topology><object type="Machine" os_index="0">` +
+		`<object type="Package" os_index="0" cpuset="0x00000001">` +
+		`<object type="NUMANode" os_index="0" cpuset="0x00000001"/>` +
+		`<object type="Core" os_index="0" cpuset="0x00000001"><object type="PU" os_index="0" cpuset="0x00000001"/></object>` +
+		`<object type="Bridge"><object type="PCIDev" pci_busid="0000:3b:00.0">` +
+		`<object type="OSDev" name="eth0" osdev_type="2"/><object type="OSDev" name="mlx5_0" osdev_type="3"/>` +
+		`</object></object><object type="OSDev" name="dma0chan0" osdev_type="4"/>` +
+		`<object type="Misc" name="misc"><object type="OSDev" name="hidden" osdev_type="4"/></object>` +
+		`</object></object></topology>`
+	if topo, err = ParseHwlocXML(strings.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+	osDevices := topo.Devices(OSDevice)
+	labels := make([]string, 0, len(osDevices))
+	for _, id := range osDevices {
+		labels = append(labels, topo.Nodes[id].Data.String())
+	}
+	want = "OSDevice(eth0, 0000:3b:00.0, class network); OSDevice(mlx5_0, 0000:3b:00.0, class openfabrics); OSDevice(dma0chan0, class dma)"
+	if got := strings.Join(labels, "; "); got != want {
+		t.Errorf("got OS devices %s; want %s", got, want)
+	}
+	for _, id := range osDevices {
+		if numaIDs, err := topo.LocalNUMANodes(id); err != nil || len(numaIDs) != 1 || numaIDs[0] != topo.NUMANodes()[0] {
+			t.Errorf("got local NUMA nodes %v (%v) of OS device %d; expected %v", numaIDs, err, id, topo.NUMANodes())
+		}
+	}
+
+	for name, payload := range map[string]string{
+		"not xml":   `{"nodes":[]}`,
+		"no root":   `<topology></topology>`,
+		"bad index": `<topology><object type="Machine"><object type="PU" os_index="x"/></object></topology>`,
+	} {
+		if _, err = ParseHwlocXML(strings.NewReader(payload)); err == nil {
+			t.Errorf("%s: parsing succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "sync"
+
+// treeIndexes contains secondary indexes over the Elements of a Tree, by their
+// kind, which are built lazily, the first time they are needed, and are then
+// used to serve all subsequent queries. The indexes over the structure of the
+// Tree (i.e., parents, depths and subtree sizes) are maintained by the
+// Hierarchy that it is built on.
+type treeIndexes struct {
+	once sync.Once
+	// processing contains the NodeIDs of all Processing Elements, indexed
+	// by their ProcessingKind.
+	processing [Thread + 1][]NodeID
+	// caches contains the NodeIDs of all Cache Elements, indexed by their
+	// CacheLevel.
+	caches [L5 + 1][]NodeID
+	// devices contains the NodeIDs of all Device Elements.
+	devices []NodeID
+}
+
+// noParent is stored in the parent index for Elements without a parent.
+const noParent = ^NodeID(0)
+
+// InvalidateIndexes discards the secondary indexes of the Tree, so that they
+// are rebuilt the next time they are needed.
+//
+// It must be called after any modification of the Tree's Nodes, and it is not
+// safe to call it concurrently with any query on the Tree.
+func (t *Tree) InvalidateIndexes() {
+	if nil == t {
+		return
+	}
+	t.Hierarchy.InvalidateIndexes()
+	t.elements = treeIndexes{}
+}
+
+// getIndexes returns the secondary indexes of the Tree, building them first if
+// needed, along with those of the Hierarchy that it is built on. It is safe to
+// call it concurrently.
+func (t *Tree) getIndexes() *treeIndexes {
+	t.elements.once.Do(func() {
+		t.Hierarchy.getIndexes()
+		for id := range t.Nodes {
+			switch data := t.Nodes[id].Data; {
+			case data.IsProcessing() && data.Kind <= Thread:
+				t.elements.processing[data.Kind] = append(t.elements.processing[data.Kind], NodeID(id))
+			case data.IsCache() && data.Level <= L5:
+				t.elements.caches[data.Level] = append(t.elements.caches[data.Level], NodeID(id))
+			case data.IsDevice():
+				t.elements.devices = append(t.elements.devices, NodeID(id))
+			}
+		}
+	})
+	return &t.elements
+}
+
+// Depth returns the depth of the element stored in the Tree under the provided
+// NodeID, where the root Element is at depth 0, or a non-nil error value in
+// case of failure.
+//
+// It is served from the Tree's secondary indexes (see InvalidateIndexes).
+func (t *Tree) Depth(id NodeID) (int, error) {
+	return t.hierarchy().Depth(id)
+}
+
+// SubtreeSize returns the number of Elements in the subtree of the element
+// stored in the Tree under the provided NodeID (including itself), or a non-nil
+// error value in case of failure.
+//
+// It is served from the Tree's secondary indexes (see InvalidateIndexes).
+func (t *Tree) SubtreeSize(id NodeID) (int, error) {
+	return t.hierarchy().SubtreeSize(id)
+}
+
+// Depths returns a table of the depths of all Elements of the Tree (see
+// Depth), indexed by their NodeIDs, with -1 for any Element that is not
+// reachable from the root. The table is owned by the caller.
+func (t *Tree) Depths() []int {
+	return t.hierarchy().Depths()
+}
+
+// SubtreeSizes returns a table of the subtree sizes of all Elements of the
+// Tree (see SubtreeSize), indexed by their NodeIDs. The table is owned by the
+// caller.
+func (t *Tree) SubtreeSizes() []int {
+	return t.hierarchy().SubtreeSizes()
+}
@@ -0,0 +1,144 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Interleave distributes the provided number of worker slots across the
+// provided hardware threads (or across all hardware threads of the Topology,
+// if threads is empty), and returns the list of threads assigned to each
+// worker, or a non-nil error value in case of failure.
+//
+// Threads are first grouped by the L3 cache they share (or by NUMA node, or by
+// Package, if the Topology has no such elements). Consecutive workers are then
+// assigned to groups in an interleaved fashion, alternating NUMA nodes (or
+// Packages) first and the groups within them second, so that e.g. worker 0
+// lands on NUMA node 0, worker 1 on NUMA node 1, worker 2 on another L3 of NUMA
+// node 0, and so on, skipping the groups that already have as many workers as
+// threads. The threads of each group are split into contiguous, evenly sized
+// chunks among the workers assigned to it. Threads are only shared among
+// workers if there are more workers than threads overall; then, the workers
+// beyond the number of threads are assigned to groups in the same interleaved
+// fashion, sharing their threads in a round-robin manner.
+func (t *Topology) Interleave(workers int, threads []NodeID) ([][]NodeID, error) {
+	if workers <= 0 {
+		return nil, fmt.Errorf("invalid number of workers: %d", workers)
+	}
+	if len(threads) == 0 {
+		threads = t.Threads()
+	}
+	if len(threads) == 0 {
+		return nil, fmt.Errorf("Topology contains no hardware threads")
+	}
+
+	// Group the threads by their innermost shared L3 cache, NUMA node or
+	// Package, and the groups by their NUMA node or Package.
+	groupOf := func(ancestorIDs []NodeID, match func(*Element) bool) (NodeID, bool) {
+		for _, id := range ancestorIDs {
+			if match(t.Nodes[id].Data) {
+				return id, true
+			}
+		}
+		return 0, false
+	}
+	isL3 := func(e *Element) bool { return e.IsCache() && e.Level == L3 }
+	isNUMA := func(e *Element) bool { return e.IsProcessing() && e.Kind == NUMANode }
+	isPackage := func(e *Element) bool { return e.IsProcessing() && e.Kind == Package }
+
+	members := make(map[NodeID][]NodeID)
+	domainOf := make(map[NodeID]NodeID)
+	for _, id := range threads {
+		data, err := t.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if !data.IsProcessing() || data.Kind != Thread {
+			return nil, fmt.Errorf("element %d is not a thread", id)
+		}
+		ancestorIDs, err := t.AncestorIDs(id)
+		if err != nil {
+			return nil, err
+		}
+		domain, ok := groupOf(ancestorIDs, isNUMA)
+		if !ok {
+			domain, _ = groupOf(ancestorIDs, isPackage)
+		}
+		group, ok := groupOf(ancestorIDs, isL3)
+		if !ok {
+			group = domain
+		}
+		members[group] = append(members[group], id)
+		domainOf[group] = domain
+	}
+
+	// Order the groups so that consecutive ones alternate domains.
+	perDomain := make(map[NodeID][]NodeID)
+	for group, domain := range domainOf {
+		perDomain[domain] = append(perDomain[domain], group)
+	}
+	domains := make([]NodeID, 0, len(perDomain))
+	for domain := range perDomain {
+		sort.Slice(perDomain[domain], func(i, j int) bool { return perDomain[domain][i] < perDomain[domain][j] })
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i] < domains[j] })
+	groups := make([]NodeID, 0, len(domainOf))
+	for round := 0; len(groups) < len(domainOf); round++ {
+		for _, domain := range domains {
+			if round < len(perDomain[domain]) {
+				groups = append(groups, perDomain[domain][round])
+			}
+		}
+	}
+
+	// Assign the workers to the groups, skipping full ones until every
+	// thread has a worker, and split each group's threads among its
+	// workers.
+	workersOf := make([][]int, len(groups))
+	for w, g := 0, 0; w < workers; w, g = w+1, (g+1)%len(groups) {
+		if w < len(threads) {
+			for len(workersOf[g]) >= len(members[groups[g]]) {
+				g = (g + 1) % len(groups)
+			}
+		}
+		workersOf[g] = append(workersOf[g], w)
+	}
+	ret := make([][]NodeID, workers)
+	for g, group := range groups {
+		ws, ts := workersOf[g], members[group]
+		sort.Slice(ts, func(i, j int) bool { return ts[i] < ts[j] })
+		if len(ws) > len(ts) {
+			for j, w := range ws {
+				ret[w] = []NodeID{ts[j%len(ts)]}
+			}
+			continue
+		}
+		start := 0
+		for j, w := range ws {
+			size := len(ts) / len(ws)
+			if j < len(ts)%len(ws) {
+				size++
+			}
+			ret[w] = ts[start : start+size : start+size]
+			start += size
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,80 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInterleave(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+
+	assignments, err := topo.Interleave(4, nil)
+	if err != nil {
+		t.Fatalf("Failed to interleave 4 workers: %v\n", err)
+	}
+	t.Logf("Interleave(4) = %v", assignments)
+	for w, threads := range assignments {
+		if len(threads) != 6 {
+			t.Errorf("worker %d was assigned %d threads; want 6", w, len(threads))
+		}
+		// Even workers on NUMA node 2, odd ones on NUMA node 22.
+		for _, id := range threads {
+			if (w%2 == 0) != (id < 22) {
+				t.Errorf("worker %d was assigned thread %d of the wrong NUMA node", w, id)
+			}
+		}
+	}
+
+	// More workers than threads: threads are shared.
+	assignments, err = topo.Interleave(3, []NodeID{4, 24})
+	if err != nil {
+		t.Fatalf("Failed to interleave 3 workers: %v\n", err)
+	}
+	if want := [][]NodeID{{4}, {24}, {4}}; !reflect.DeepEqual(assignments, want) {
+		t.Errorf("Interleave(3, [4 24]) = %v; want %v", assignments, want)
+	}
+
+	// Uneven groups: the single thread of NUMA node 2 is not shared while
+	// those of NUMA node 22 are left idle.
+	assignments, err = topo.Interleave(5, []NodeID{4, 24, 25, 27, 28})
+	if err != nil {
+		t.Fatalf("Failed to interleave 5 workers: %v\n", err)
+	}
+	if want := [][]NodeID{{4}, {24}, {25}, {27}, {28}}; !reflect.DeepEqual(assignments, want) {
+		t.Errorf("Interleave(5, [4 24 25 27 28]) = %v; want %v", assignments, want)
+	}
+	assignments, err = topo.Interleave(3, []NodeID{4, 24, 25, 27, 28})
+	if err != nil {
+		t.Fatalf("Failed to interleave 3 workers: %v\n", err)
+	}
+	if want := [][]NodeID{{4}, {24, 25}, {27, 28}}; !reflect.DeepEqual(assignments, want) {
+		t.Errorf("Interleave(3, [4 24 25 27 28]) = %v; want %v", assignments, want)
+	}
+	assignments, err = topo.Interleave(7, []NodeID{4, 24, 25})
+	if err != nil {
+		t.Fatalf("Failed to interleave 7 workers: %v\n", err)
+	}
+	if want := [][]NodeID{{4}, {24}, {25}, {4}, {24}, {4}, {25}}; !reflect.DeepEqual(assignments, want) {
+		t.Errorf("Interleave(7, [4 24 25]) = %v; want %v", assignments, want)
+	}
+
+	if _, err = topo.Interleave(1, []NodeID{3}); err == nil {
+		t.Errorf("Interleave over a non-thread element succeeded")
+	}
+}
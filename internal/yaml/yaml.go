@@ -0,0 +1,402 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Package yaml reads the subset of YAML that actitopo supports (see Subset),
+// which covers the YAML that it writes, hand-written documents in block style,
+// and typical kubeconfig files, without external dependencies.
+package yaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Subset describes the subset of YAML that Read supports.
+const Subset = "block mappings and sequences, flow collections within a single line, " +
+	"plain and quoted scalars, and comments (but no anchors, aliases, tags or multi-line scalars)"
+
+// maxDepth bounds the nesting of the documents that Read accepts.
+const maxDepth = 1024
+
+// srcLine is a non-empty line of a YAML document, along with its indentation
+// (in spaces) and its number.
+type srcLine struct {
+	indent int
+	text   string
+	num    int
+}
+
+// Read returns the document in the provided YAML data, as if produced by
+// unmarshalling JSON (with numbers as json.Number) into an interface{}, or a
+// non-nil error value in case of failure.
+//
+// Only the subset of YAML described by Subset is supported.
+func Read(data []byte) (interface{}, error) {
+	var lines []srcLine
+	for i, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimRight(stripComment(strings.TrimRight(text, "\r")), " ")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed in indentation", i+1)
+		}
+		lines = append(lines, srcLine{indent: len(text) - len(trimmed), text: trimmed, num: i + 1})
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("empty YAML document")
+	}
+	p := &parser{lines: lines}
+	doc, err := p.parseValue(-1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return doc, nil
+}
+
+// parser parses the lines of a YAML document.
+type parser struct {
+	lines []srcLine
+	pos   int
+}
+
+// parseValue parses the value that starts at the current line, which must be
+// indented deeper than the provided parent indentation.
+func (p *parser) parseValue(parent, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("line %d: YAML document nested too deeply", p.lines[p.pos].num)
+	}
+	line := p.lines[p.pos]
+	if line.indent <= parent {
+		return nil, fmt.Errorf("line %d: missing value", line.num)
+	}
+	if isItem(line.text) {
+		return p.parseSequence(line.indent, depth)
+	}
+	if _, _, ok := splitKey(line.text); ok {
+		return p.parseMapping(line.indent, depth)
+	}
+	p.pos++
+	v, err := parseScalar(line.text)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", line.num, err)
+	}
+	return v, nil
+}
+
+// parseSequence parses the block sequence whose items start at the provided
+// indentation.
+func (p *parser) parseSequence(indent, depth int) (interface{}, error) {
+	seq := []interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			if p.pos == len(p.lines) || p.lines[p.pos].indent <= indent {
+				seq = append(seq, nil)
+				continue
+			}
+		} else {
+			// The item starts on the same line as the dash, so treat its
+			// remainder as a line of its own, at the column it starts.
+			p.lines[p.pos] = srcLine{indent: indent + len(line.text) - len(rest), text: rest, num: line.num}
+		}
+		item, err := p.parseValue(indent, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, item)
+	}
+	return seq, nil
+}
+
+// parseMapping parses the block mapping whose keys start at the provided
+// indentation.
+func (p *parser) parseMapping(indent, depth int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		key, rest, ok := splitKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a mapping key", line.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+		var (
+			value interface{}
+			err   error
+		)
+		switch {
+		case rest != "":
+			if value, err = parseScalar(rest); err != nil {
+				err = fmt.Errorf("line %d: %w", line.num, err)
+			}
+		case p.pos == len(p.lines):
+		case p.lines[p.pos].indent > indent:
+			value, err = p.parseValue(indent, depth+1)
+		case p.lines[p.pos].indent == indent && isItem(p.lines[p.pos].text):
+			// Sequences may be indented as deep as their key.
+			value, err = p.parseSequence(indent, depth+1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// isItem returns true if the provided line starts a sequence item.
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitKey splits the provided line into a mapping key and the remainder
+// that follows it, or returns false if it does not start with a key.
+func splitKey(text string) (key, rest string, ok bool) {
+	if strings.HasPrefix(text, `"`) {
+		quoted, err := strconv.QuotedPrefix(text)
+		if err != nil {
+			return "", "", false
+		}
+		if key, err = strconv.Unquote(quoted); err != nil {
+			return "", "", false
+		}
+		text = text[len(quoted):]
+		if text != ":" && !strings.HasPrefix(text, ": ") {
+			return "", "", false
+		}
+		return key, strings.TrimLeft(text[1:], " "), true
+	}
+	if strings.HasPrefix(text, "'") || strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		return "", "", false
+	}
+	if strings.HasSuffix(text, ":") {
+		return text[:len(text)-1], "", true
+	}
+	if i := strings.Index(text, ": "); i > 0 {
+		return text[:i], strings.TrimLeft(text[i+1:], " "), true
+	}
+	return "", "", false
+}
+
+// parseScalar returns the value of the provided scalar (or empty flow
+// collection).
+func parseScalar(text string) (interface{}, error) {
+	switch text {
+	case "{}":
+		return map[string]interface{}{}, nil
+	case "[]":
+		return []interface{}{}, nil
+	case "~":
+		return nil, nil
+	}
+	if strings.HasPrefix(text, "'") {
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("invalid single-quoted scalar %s", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+	if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		return parseFlow(text)
+	}
+	if strings.ContainsAny(text[:1], "&*!|>") {
+		return nil, fmt.Errorf("unsupported YAML %s; only %s are supported", text, Subset)
+	}
+	if json.Valid([]byte(text)) {
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		return nil, fmt.Errorf("invalid double-quoted scalar %s", text)
+	}
+	return text, nil
+}
+
+// stripComment returns the provided line without its trailing comment, if
+// any, i.e., from the first '#' that is outside quoted scalars and either
+// starts the line or follows a space.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[{,:-", line[i-1]) >= 0):
+			// Quotes only start quoted scalars at the start of a
+			// token, unlike those within plain ones (e.g., "it's").
+			quote = c
+		}
+	}
+	return line
+}
+
+// flow parses a flow collection (e.g., "[1, 2]" or "{a: 1}"), which must
+// fit in a single line.
+type flow struct {
+	text string
+	pos  int
+}
+
+// parseFlow returns the value of the provided flow collection.
+func parseFlow(text string) (interface{}, error) {
+	p := &flow{text: text}
+	v, err := p.parseValue(0, false)
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpaces(); p.pos < len(p.text) {
+		return nil, fmt.Errorf("unexpected %q after flow collection", p.text[p.pos:])
+	}
+	return v, nil
+}
+
+// skipSpaces advances past any spaces.
+func (p *flow) skipSpaces() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// parseValue parses the value at the current position; isKey is true if it
+// is the key of a mapping, which ends at a ':'.
+func (p *flow) parseValue(depth int, isKey bool) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("flow collection nested too deeply")
+	}
+	p.skipSpaces()
+	if p.pos == len(p.text) {
+		return nil, fmt.Errorf("unterminated flow collection %s (flow collections may not span multiple lines)", p.text)
+	}
+	switch p.text[p.pos] {
+	case '[':
+		p.pos++
+		seq := []interface{}{}
+		for !p.closes(']') {
+			item, err := p.parseValue(depth+1, false)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, item)
+			if err = p.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+		return seq, nil
+	case '{':
+		p.pos++
+		m := make(map[string]interface{})
+		for !p.closes('}') {
+			k, err := p.parseValue(depth+1, true)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+			}
+			if _, dup := m[key]; dup {
+				return nil, fmt.Errorf("duplicate key %q", key)
+			}
+			if p.skipSpaces(); p.pos == len(p.text) || p.text[p.pos] != ':' {
+				return nil, fmt.Errorf("expected ':' after key %q in flow mapping %s", key, p.text)
+			}
+			p.pos++
+			if m[key], err = p.parseValue(depth+1, false); err != nil {
+				return nil, err
+			}
+			if err = p.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case '"':
+		quoted, err := strconv.QuotedPrefix(p.text[p.pos:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid double-quoted scalar in %s", p.text)
+		}
+		p.pos += len(quoted)
+		return strconv.Unquote(quoted)
+	case '\'':
+		for end := p.pos + 1; end < len(p.text); end++ {
+			if p.text[end] != '\'' {
+				continue
+			}
+			if end+1 < len(p.text) && p.text[end+1] == '\'' {
+				end++
+				continue
+			}
+			v := strings.ReplaceAll(p.text[p.pos+1:end], "''", "'")
+			p.pos = end + 1
+			return v, nil
+		}
+		return nil, fmt.Errorf("invalid single-quoted scalar in %s", p.text)
+	}
+	start := p.pos
+	for p.pos < len(p.text) && strings.IndexByte(",]}", p.text[p.pos]) < 0 && !(isKey && p.text[p.pos] == ':') {
+		p.pos++
+	}
+	token := strings.TrimRight(p.text[start:p.pos], " \t")
+	if token == "" {
+		return nil, fmt.Errorf("missing value in flow collection %s", p.text)
+	}
+	return parseScalar(token)
+}
+
+// closes consumes the provided closing bracket, if it is next.
+func (p *flow) closes(bracket byte) bool {
+	if p.skipSpaces(); p.pos < len(p.text) && p.text[p.pos] == bracket {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// separator consumes the ',' after an entry of a flow collection, unless the
+// provided closing bracket follows instead.
+func (p *flow) separator(bracket byte) error {
+	p.skipSpaces()
+	switch {
+	case p.pos < len(p.text) && p.text[p.pos] == ',':
+		p.pos++
+		return nil
+	case p.pos < len(p.text) && p.text[p.pos] == bracket:
+		return nil
+	default:
+		return fmt.Errorf("expected ',' or '%c' in flow collection %s (flow collections may not span multiple lines)", bracket, p.text)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package yaml
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestReadYAML(t *testing.T) {
+	for _, tc := range []struct{ input, want string }{
+		{"nodes:\n- data: machine\n  desc:\n    - 1\n  name: 'it''s'\n  empty: {}\n  none:\n",
+			`{"nodes":[{"data":"machine","desc":[1],"empty":{},"name":"it's","none":null}]}`},
+		{"# comment\n---\nnodes:  # trailing comment\n- data: machine # machine\n  desc: [1, 2]  # children\n",
+			`{"nodes":[{"data":"machine","desc":[1,2]}]}`},
+		{"a: \"# not a comment\"  # comment\nb: 'x # y'\nc: x#y\n",
+			`{"a":"# not a comment","b":"x # y","c":"x#y"}`},
+		{"- {processing: {kind: thread, id: 3}}\n- [\"a, b\", 'c', [], {}, null, 0000:3b:00.0]\n",
+			`[{"processing":{"id":3,"kind":"thread"}},["a, b","c",[],{},null,"0000:3b:00.0"]]`},
+	} {
+		doc, err := Read([]byte(tc.input))
+		if err != nil {
+			t.Errorf("%q: %v", tc.input, err)
+			continue
+		}
+		if data := string(mustMarshal(t, doc)); data != tc.want {
+			t.Errorf("%q: got %s; want %s", tc.input, data, tc.want)
+		}
+	}
+	for _, input := range []string{
+		"",
+		"a: 1\na: 2\n",
+		"a: 1\n   b: 2\n",
+		"- 1\nb: 2\n",
+		"\"a: 1\n",
+		"a: [1,\n  2]\n",
+		"a: {b 1}\n",
+		"a: [1] 2\n",
+		"a: &anchor 1\n",
+		"a: |\n  text\n",
+	} {
+		if doc, err := Read([]byte(input)); err == nil {
+			t.Errorf("%q: got %v; want an error", input, doc)
+		}
+	}
+}
+
+// mustMarshal returns the provided value in JSON.
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
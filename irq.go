@@ -0,0 +1,193 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// IRQAffinity is the set of hardware threads proposed to serve an IRQ (see
+// Topology.SpreadIRQs).
+type IRQAffinity struct {
+	// IRQ is the number of the IRQ.
+	IRQ uint32 `json:"irq"`
+	// Threads lists the NodeIDs of the hardware threads, in ascending
+	// order.
+	Threads []NodeID `json:"threads"`
+}
+
+// SpreadIRQs proposes a spread of the provided IRQs (e.g., those of the queues
+// of a NIC; see ReadDeviceIRQs) across the physical cores that are local to
+// the Device element stored under the provided NodeID (see LocalThreads), and
+// returns the hardware threads of the core assigned to each IRQ, in the order
+// of the IRQs, or a non-nil error value in case of failure.
+//
+// A thread's core is its nearest Core ancestor (a thread without one counts as
+// a core of its own). Consecutive IRQs are assigned to distinct cores in an
+// interleaved fashion, alternating L3 caches and NUMA nodes (see Interleave),
+// so that the load of the queues is spread across as many caches as possible;
+// only if there are more IRQs than cores do they wrap around.
+func (t *Topology) SpreadIRQs(deviceID NodeID, irqs []uint32) ([]IRQAffinity, error) {
+	if len(irqs) == 0 {
+		return nil, fmt.Errorf("no IRQs to spread")
+	}
+	threadIDs, err := t.LocalThreads(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group the local threads by their core, and represent each core by
+	// its first thread.
+	siblings := make(map[NodeID][]NodeID)
+	coreOf := make(map[NodeID]NodeID)
+	firsts := make([]NodeID, 0)
+	for _, id := range threadIDs {
+		coreID, ok, err := t.coreOf(id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			coreID = id
+		}
+		if len(siblings[coreID]) == 0 {
+			firsts = append(firsts, id)
+			coreOf[id] = coreID
+		}
+		siblings[coreID] = append(siblings[coreID], id)
+	}
+
+	// Order the cores as interleaved, taking each of them once; any cores
+	// that Interleave leaves out (e.g., by sharing threads among workers)
+	// follow in their original order.
+	order, err := t.Interleave(len(firsts), firsts)
+	if err != nil {
+		return nil, err
+	}
+	cores := make([]NodeID, 0, len(firsts))
+	seen := make(map[NodeID]bool, len(firsts))
+	for _, assigned := range order {
+		for _, id := range assigned {
+			if core := coreOf[id]; !seen[core] {
+				seen[core] = true
+				cores = append(cores, core)
+				break
+			}
+		}
+	}
+	for _, id := range firsts {
+		if core := coreOf[id]; !seen[core] {
+			seen[core] = true
+			cores = append(cores, core)
+		}
+	}
+
+	ret := make([]IRQAffinity, len(irqs))
+	for i, irq := range irqs {
+		core := cores[i%len(cores)]
+		ret[i] = IRQAffinity{IRQ: irq, Threads: append([]NodeID(nil), siblings[core]...)}
+	}
+	return ret, nil
+}
+
+// IRQWriteOptions configures Topology.WriteIRQAffinities.
+type IRQWriteOptions struct {
+	// Apply, if true, also writes the affinities to the smp_affinity_list
+	// files of the IRQs, which requires root privileges.
+	Apply bool
+	// ProcDir is the mount point of procfs; it defaults to /proc if empty.
+	ProcDir string
+}
+
+// WriteIRQAffinities writes the contents of the /proc/irq/<n>/smp_affinity_list
+// file of each of the provided IRQAffinities (i.e., the OS indices of their
+// hardware threads, in the Linux kernel's "cpulist" format) to the provided
+// io.Writer, as shell commands that apply them (e.g.,
+// "echo 0,2 > /proc/irq/34/smp_affinity_list"), or a non-nil error value in
+// case of failure.
+//
+// If opts.Apply is true, the affinities are also applied, in order, stopping
+// at the first failure; the commands written up to that point reflect the
+// affinities that have been applied.
+func (t *Topology) WriteIRQAffinities(w io.Writer, affinities []IRQAffinity, opts IRQWriteOptions) error {
+	procDir := opts.ProcDir
+	if procDir == "" {
+		procDir = "/proc"
+	}
+	for _, a := range affinities {
+		if len(a.Threads) == 0 {
+			return fmt.Errorf("no hardware threads for IRQ %d", a.IRQ)
+		}
+		osIDs := make([]uint32, 0, len(a.Threads))
+		for _, id := range a.Threads {
+			data, err := t.Get(id)
+			if err != nil {
+				return err
+			}
+			if !data.IsProcessing() || data.Kind != Thread {
+				return fmt.Errorf("element %d is not a thread", id)
+			}
+			osIDs = append(osIDs, data.ID)
+		}
+		cpuList := FormatCPUList(osIDs)
+		file := filepath.Join(procDir, "irq", strconv.FormatUint(uint64(a.IRQ), 10), "smp_affinity_list")
+		if opts.Apply {
+			if err := os.WriteFile(file, []byte(cpuList+"\n"), 0o644); err != nil {
+				return fmt.Errorf("cannot apply the affinity of IRQ %d: %w", a.IRQ, err)
+			}
+		}
+		if _, err := fmt.Fprintf(w, "echo %s > %s\n", cpuList, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDeviceIRQs returns the numbers of the MSI or MSI-X IRQs of the network
+// interface with the provided name (e.g., "eth0"), in ascending order, as
+// exposed by the Linux kernel through the provided file system, which is
+// expected to be rooted at the mount point of sysfs (i.e., /sys), or a non-nil
+// error value in case of failure. If fsys is nil, /sys is used.
+//
+// Most drivers allocate one IRQ per queue, along with a few others (e.g., for
+// link events), which are included as well.
+func ReadDeviceIRQs(fsys fs.FS, iface string) ([]uint32, error) {
+	if nil == fsys {
+		fsys = os.DirFS("/sys")
+	}
+	dir := path.Join("class/net", iface, "device/msi_irqs")
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	irqs := make([]uint32, 0, len(entries))
+	for _, entry := range entries {
+		irq, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IRQ '%s' in %s: %v", entry.Name(), dir, err)
+		}
+		irqs = append(irqs, uint32(irq))
+	}
+	sort.Slice(irqs, func(i, j int) bool { return irqs[i] < irqs[j] })
+	return irqs, nil
+}
@@ -0,0 +1,193 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSpreadIRQs(t *testing.T) {
+	// A NIC of unknown locality is local to all four cores, two on each
+	// NUMA node.
+	topo := &Topology{syntheticTree(2, 1, 2, 2)}
+	nic := NodeID(topo.Size())
+	topo.Nodes = append(topo.Nodes, TreeNode{Data: &Element{Device: &Device{Type: NIC, Name: "eth0"}}})
+	topo.Nodes[0].Children = append(topo.Nodes[0].Children, nic)
+	topo.InvalidateIndexes()
+	if err := topo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	sysfs := fstest.MapFS{
+		"class/net/eth0/device/msi_irqs/130": {},
+		"class/net/eth0/device/msi_irqs/34":  {},
+		"class/net/eth0/device/msi_irqs/35":  {},
+		"class/net/eth0/device/msi_irqs/36":  {},
+		"class/net/eth0/device/msi_irqs/37":  {},
+	}
+	irqs, err := ReadDeviceIRQs(sysfs, "eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint32{34, 35, 36, 37, 130}; !reflect.DeepEqual(irqs, want) {
+		t.Errorf("ReadDeviceIRQs() = %v; expected %v", irqs, want)
+	}
+
+	affinities, err := topo.SpreadIRQs(nic, irqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Consecutive IRQs alternate NUMA nodes, and wrap around.
+	threads := topo.Threads()
+	core := func(c int) []NodeID { return threads[2*c : 2*c+2] }
+	want := []IRQAffinity{
+		{IRQ: 34, Threads: core(0)},
+		{IRQ: 35, Threads: core(2)},
+		{IRQ: 36, Threads: core(1)},
+		{IRQ: 37, Threads: core(3)},
+		{IRQ: 130, Threads: core(0)},
+	}
+	if !reflect.DeepEqual(affinities, want) {
+		t.Errorf("SpreadIRQs() = %v; expected %v", affinities, want)
+	}
+
+	if _, err = topo.SpreadIRQs(nic, nil); err == nil {
+		t.Errorf("expected an error for no IRQs")
+	}
+	if _, err = topo.SpreadIRQs(threads[0], irqs); err == nil {
+		t.Errorf("expected an error for a thread instead of a device")
+	}
+	if _, err = ReadDeviceIRQs(sysfs, "eth1"); err == nil {
+		t.Errorf("expected an error for a missing network interface")
+	}
+}
+
+func TestSpreadIRQsDistinctCores(t *testing.T) {
+	// Six cores in uneven L3 caches (1 and 3 cores in NUMA node 0, 2 in
+	// NUMA node 1), whose threads lie under an L1 cache rather than
+	// directly under the Core; the NIC is local to all of them.
+	tree := NewTree([]TreeNode{{Data: &Element{}}})
+	add := func(parent NodeID, data *Element) NodeID {
+		id := NodeID(len(tree.Nodes))
+		tree.Nodes = append(tree.Nodes, TreeNode{Data: data})
+		tree.Nodes[parent].Children = append(tree.Nodes[parent].Children, id)
+		return id
+	}
+	var coreID, threadID uint32
+	pkg := add(0, &Element{Processing: &Processing{Kind: Package}})
+	for numaID, l3s := range [][]int{{1, 3}, {2}} {
+		numa := add(pkg, &Element{Processing: &Processing{Kind: NUMANode, ID: uint32(numaID)}})
+		for _, cores := range l3s {
+			l3 := add(numa, &Element{Cache: &Cache{Level: L3}})
+			for c := 0; c < cores; c++ {
+				core := add(l3, &Element{Processing: &Processing{Kind: Core, ID: coreID}})
+				l1 := add(core, &Element{Cache: &Cache{Level: L1, LogicalIndex: coreID}})
+				coreID++
+				for i := 0; i < 2; i++ {
+					add(l1, &Element{Processing: &Processing{Kind: Thread, ID: threadID}})
+					threadID++
+				}
+			}
+		}
+	}
+	nic := add(0, &Element{Device: &Device{Type: NIC, Name: "eth0"}})
+	topo := &Topology{tree}
+
+	irqs := make([]uint32, coreID+1)
+	for i := range irqs {
+		irqs[i] = uint32(32 + i)
+	}
+	for n := 1; n <= len(irqs); n++ {
+		affinities, err := topo.SpreadIRQs(nic, irqs[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		used := make(map[NodeID]int)
+		for i, a := range affinities {
+			if len(a.Threads) != 2 {
+				t.Fatalf("%d IRQs: IRQ %d got threads %v; want the 2 threads of a core", n, a.IRQ, a.Threads)
+			}
+			core, ok, err := topo.coreOf(a.Threads[0])
+			if err != nil || !ok {
+				t.Fatalf("%d IRQs: thread %d has no core (%v)", n, a.Threads[0], err)
+			}
+			if other, _, _ := topo.coreOf(a.Threads[1]); other != core {
+				t.Errorf("%d IRQs: IRQ %d got threads %v of different cores", n, a.IRQ, a.Threads)
+			}
+			if prev, dup := used[core]; dup && n <= int(coreID) {
+				t.Errorf("%d IRQs: IRQs %d and %d share core %d", n, affinities[prev].IRQ, a.IRQ, core)
+			}
+			used[core] = i
+		}
+		if n > int(coreID) && !reflect.DeepEqual(affinities[n-1].Threads, affinities[0].Threads) {
+			t.Errorf("%d IRQs: IRQ %d got %v; want the first core again", n, affinities[n-1].IRQ, affinities[n-1].Threads)
+		}
+	}
+}
+
+func TestWriteIRQAffinities(t *testing.T) {
+	topo := &Topology{syntheticTree(1, 1, 2, 2)}
+	threads := topo.Threads()
+	affinities := []IRQAffinity{
+		{IRQ: 34, Threads: threads[:2]},
+		{IRQ: 35, Threads: threads[2:]},
+	}
+	procDir := t.TempDir()
+	for _, irq := range []string{"34", "35"} {
+		if err := os.MkdirAll(filepath.Join(procDir, "irq", irq), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := topo.WriteIRQAffinities(&buf, affinities, IRQWriteOptions{ProcDir: procDir}); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("echo 0-1 > %[1]s/irq/34/smp_affinity_list\necho 2-3 > %[1]s/irq/35/smp_affinity_list\n", procDir)
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	if _, err := os.Stat(filepath.Join(procDir, "irq/34/smp_affinity_list")); err == nil {
+		t.Errorf("affinities were applied without opts.Apply")
+	}
+
+	buf.Reset()
+	if err := topo.WriteIRQAffinities(&buf, affinities, IRQWriteOptions{Apply: true, ProcDir: procDir}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	if data, err := os.ReadFile(filepath.Join(procDir, "irq/35/smp_affinity_list")); err != nil || string(data) != "2-3\n" {
+		t.Errorf("got smp_affinity_list %q, %v; expected \"2-3\\n\"", data, err)
+	}
+
+	affinities = append(affinities, IRQAffinity{IRQ: 36, Threads: threads[:1]})
+	if err := topo.WriteIRQAffinities(io.Discard, affinities, IRQWriteOptions{Apply: true, ProcDir: procDir}); err == nil {
+		t.Errorf("expected an error for a missing IRQ")
+	}
+	if err := topo.WriteIRQAffinities(io.Discard, []IRQAffinity{{IRQ: 34, Threads: topo.Cores()}}, IRQWriteOptions{}); err == nil {
+		t.Errorf("expected an error for cores instead of threads")
+	}
+}
@@ -0,0 +1,69 @@
+//go:build go1.23
+
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "iter"
+
+// EachThread returns an iterator over all hardware thread processing elements
+// of the Topology and their NodeIDs, in ascending NodeID order (see Threads).
+//
+// Unlike Threads, it does not allocate a list, and it stops as soon as the
+// caller breaks out of the loop. It is only available with Go 1.23 or later.
+func (t *Topology) EachThread() iter.Seq2[NodeID, *Element] {
+	return t.eachProcessingKind(Thread)
+}
+
+// EachCore returns an iterator over all physical core processing elements of
+// the Topology and their NodeIDs, in ascending NodeID order (see EachThread).
+func (t *Topology) EachCore() iter.Seq2[NodeID, *Element] {
+	return t.eachProcessingKind(Core)
+}
+
+// EachCache returns an iterator over all cache elements of the provided level
+// in the Topology and their NodeIDs, in ascending NodeID order (see
+// EachThread).
+func (t *Topology) EachCache(level CacheLevel) iter.Seq2[NodeID, *Element] {
+	return func(yield func(NodeID, *Element) bool) {
+		if level > L5 {
+			return
+		}
+		t.yieldAll(t.getIndexes().caches[level], yield)
+	}
+}
+
+// eachProcessingKind returns an iterator over all processing elements of the
+// provided kind in the Topology and their NodeIDs.
+func (t *Topology) eachProcessingKind(kind ProcessingKind) iter.Seq2[NodeID, *Element] {
+	return func(yield func(NodeID, *Element) bool) {
+		if kind > Thread {
+			return
+		}
+		t.yieldAll(t.getIndexes().processing[kind], yield)
+	}
+}
+
+// yieldAll yields the provided NodeIDs of the Topology and their Elements, in
+// order, until yield returns false.
+func (t *Topology) yieldAll(ids []NodeID, yield func(NodeID, *Element) bool) {
+	for _, id := range ids {
+		if !yield(id, t.Nodes[id].Data) {
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+//go:build go1.23
+
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "testing"
+
+func TestIterators(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 2, 4, 2)}
+
+	threads := topo.Threads()
+	i := 0
+	for id, e := range topo.EachThread() {
+		if id != threads[i] || e != topo.Nodes[id].Data {
+			t.Errorf("got thread %d (%v) at position %d; want %d", id, e, i, threads[i])
+		}
+		i++
+	}
+	if i != len(threads) {
+		t.Errorf("got %d threads; want %d", i, len(threads))
+	}
+
+	n := 0
+	for _, e := range topo.EachCore() {
+		if e.Kind != Core {
+			t.Errorf("got %v while iterating over cores", e)
+		}
+		if n++; n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Errorf("breaking out of the loop did not stop the iteration")
+	}
+
+	n = 0
+	for id, e := range topo.EachCache(L3) {
+		if !e.IsCache() || e.Level != L3 || topo.L3Caches()[n] != id {
+			t.Errorf("got %v while iterating over L3 caches", e)
+		}
+		n++
+	}
+	if n != 4 {
+		t.Errorf("got %d L3 caches; want 4", n)
+	}
+	for range topo.EachCache(CacheLevel(42)) {
+		t.Errorf("got caches of an invalid level")
+	}
+	if allocs := testing.AllocsPerRun(10, func() {
+		for range topo.EachThread() {
+		}
+	}); allocs > 1 {
+		t.Errorf("iterating over threads allocated %v times", allocs)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/ckatsak/actitopo-go/internal/yaml"
+)
+
+// kubeconfig is the subset of a kubeconfig file that NewKubeconfigAggregator
+// supports.
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Clusters       []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData string `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+			TLSServerName            string `json:"tls-server-name"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster string `json:"cluster"`
+			User    string `json:"user"`
+		} `json:"context"`
+	} `json:"contexts"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Token                 string          `json:"token"`
+			TokenFile             string          `json:"tokenFile"`
+			ClientCertificate     string          `json:"client-certificate"`
+			ClientCertificateData string          `json:"client-certificate-data"`
+			ClientKey             string          `json:"client-key"`
+			ClientKeyData         string          `json:"client-key-data"`
+			Username              string          `json:"username"`
+			Exec                  json.RawMessage `json:"exec"`
+			AuthProvider          json.RawMessage `json:"auth-provider"`
+		} `json:"user"`
+	} `json:"users"`
+}
+
+// NewKubeconfigAggregator returns a new Aggregator that accesses the
+// Kubernetes API server with the cluster and the credentials of the provided
+// context (or of the current one, if empty) of the kubeconfig file at the
+// provided path (or, if empty, at the first path listed in $KUBECONFIG, or at
+// ~/.kube/config), or a non-nil error value in case of failure.
+//
+// Unlike client-go, it neither merges multiple kubeconfig files nor runs
+// credential plugins: users that authenticate through "exec" or
+// "auth-provider" (or through a username and password) are rejected. Bearer
+// tokens (or token files) and client certificates are supported, as are
+// custom certificate authorities and TLS server names.
+func NewKubeconfigAggregator(path, contextName string) (*Aggregator, error) {
+	if path == "" {
+		if list := filepath.SplitList(os.Getenv("KUBECONFIG")); len(list) > 0 && list[0] != "" {
+			path = list[0]
+		} else {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.Join(home, ".kube", "config")
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := yaml.Read(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig %s: %w", path, err)
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig %s: %w", path, err)
+	}
+	var config kubeconfig
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig %s: %w", path, err)
+	}
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+	if contextName == "" {
+		return nil, fmt.Errorf("kubeconfig %s has no current context", path)
+	}
+	ctxIdx := -1
+	for i := range config.Contexts {
+		if config.Contexts[i].Name == contextName {
+			ctxIdx = i
+		}
+	}
+	if ctxIdx < 0 {
+		return nil, fmt.Errorf("context '%s' not found in kubeconfig %s", contextName, path)
+	}
+	clusterName, userName := config.Contexts[ctxIdx].Context.Cluster, config.Contexts[ctxIdx].Context.User
+	clusterIdx, userIdx := -1, -1
+	for i := range config.Clusters {
+		if config.Clusters[i].Name == clusterName {
+			clusterIdx = i
+		}
+	}
+	for i := range config.Users {
+		if config.Users[i].Name == userName {
+			userIdx = i
+		}
+	}
+	if clusterIdx < 0 {
+		return nil, fmt.Errorf("cluster '%s' not found in kubeconfig %s", clusterName, path)
+	}
+	cluster := &config.Clusters[clusterIdx].Cluster
+	if cluster.Server == "" {
+		return nil, fmt.Errorf("cluster '%s' has no server in kubeconfig %s", clusterName, path)
+	}
+
+	// Relative paths are relative to the kubeconfig file.
+	dir := filepath.Dir(path)
+	readData := func(file, encoded string) ([]byte, error) {
+		if encoded != "" {
+			return base64.StdEncoding.DecodeString(encoded)
+		}
+		if file == "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		return os.ReadFile(file)
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: cluster.InsecureSkipTLSVerify, ServerName: cluster.TLSServerName}
+	ca, err := readData(cluster.CertificateAuthority, cluster.CertificateAuthorityData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate authority of cluster '%s': %w", clusterName, err)
+	}
+	if len(ca) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid certificate authority of cluster '%s'", clusterName)
+		}
+	}
+
+	aggregator := &Aggregator{APIServer: cluster.Server}
+	if userIdx >= 0 {
+		user := &config.Users[userIdx].User
+		switch {
+		case len(user.Exec) > 0 && string(user.Exec) != "null":
+			return nil, fmt.Errorf("user '%s' authenticates through an exec plugin, which is not supported", userName)
+		case len(user.AuthProvider) > 0 && string(user.AuthProvider) != "null":
+			return nil, fmt.Errorf("user '%s' authenticates through an auth provider, which is not supported", userName)
+		case user.Username != "":
+			return nil, fmt.Errorf("user '%s' authenticates through basic authentication, which is not supported", userName)
+		}
+		aggregator.BearerToken = user.Token
+		if user.TokenFile != "" {
+			aggregator.TokenFile = user.TokenFile
+			if !filepath.IsAbs(user.TokenFile) {
+				aggregator.TokenFile = filepath.Join(dir, user.TokenFile)
+			}
+		}
+		cert, err := readData(user.ClientCertificate, user.ClientCertificateData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate of user '%s': %w", userName, err)
+		}
+		key, err := readData(user.ClientKey, user.ClientKeyData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client key of user '%s': %w", userName, err)
+		}
+		if len(cert) > 0 || len(key) > 0 {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client certificate of user '%s': %w", userName, err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	} else if userName != "" {
+		return nil, fmt.Errorf("user '%s' not found in kubeconfig %s", userName, path)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	aggregator.Client = &http.Client{Transport: transport}
+	return aggregator, nil
+}
@@ -0,0 +1,132 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKubeconfigAggregator(t *testing.T) {
+	small, _ := json.Marshal(&Topology{syntheticTree(1, 1, 2, 2)})
+	apiServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, `{"kind":"Status","message":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"metadata":{},"items":[{"metadata":{"name":"node-a","annotations":{"actik8s.io/topology":` +
+			jsonString(small) + `}}}]}`))
+	}))
+	defer apiServer.Close()
+	agent := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("the bearer token was sent to an agent")
+		}
+		w.Write(small)
+	}))
+	defer agent.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ca := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: apiServer.Certificate().Raw}))
+	config := `apiVersion: v1
+kind: Config
+current-context: test  # the default
+preferences: {}
+clusters:
+- cluster:
+    certificate-authority-data: ` + ca + `
+    server: ` + apiServer.URL + `
+  name: test
+- cluster:
+    server: https://127.0.0.1:1
+  name: other
+contexts:
+- context:
+    cluster: test
+    user: admin
+  name: test
+- context: {cluster: test, user: plugin}
+  name: plugin
+- context: {cluster: missing, user: admin}
+  name: broken
+users:
+- name: admin
+  user:
+    tokenFile: token
+- name: plugin
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: get-token
+`
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	aggregator, err := NewKubeconfigAggregator(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aggregator.APIServer != apiServer.URL || aggregator.TokenFile != filepath.Join(dir, "token") {
+		t.Errorf("got API server %s and token file %s", aggregator.APIServer, aggregator.TokenFile)
+	}
+	cluster, err := aggregator.Collect(context.Background())
+	if err != nil || cluster.Size() != 1 {
+		t.Errorf("got %d nodes (%v); expected 1", cluster.Size(), err)
+	}
+
+	// The agents are not trusted through the API server's client.
+	aggregator.AgentURL = agent.URL + "/topology/{name}"
+	var failures NodeErrors
+	if _, err = aggregator.Collect(context.Background()); !errors.As(err, &failures) || nil == failures["node-a"] {
+		t.Errorf("got %v; expected a failure for node-a", err)
+	}
+	aggregator.AgentClient = agent.Client()
+	if cluster, err = aggregator.Collect(context.Background()); err != nil || cluster.Size() != 1 {
+		t.Errorf("got %d nodes (%v) from the agents; expected 1", cluster.Size(), err)
+	}
+
+	// The path defaults to the first one in $KUBECONFIG.
+	t.Setenv("KUBECONFIG", path+string(filepath.ListSeparator)+filepath.Join(dir, "missing"))
+	if aggregator, err = NewKubeconfigAggregator("", "test"); err != nil || aggregator.APIServer != apiServer.URL {
+		t.Errorf("got %v from $KUBECONFIG", err)
+	}
+
+	for _, tc := range []struct{ path, context, want string }{
+		{path, "plugin", "exec plugin"},
+		{path, "broken", "cluster 'missing' not found"},
+		{path, "nonexistent", "context 'nonexistent' not found"},
+		{filepath.Join(dir, "missing"), "", "missing"},
+	} {
+		if _, err = NewKubeconfigAggregator(tc.path, tc.context); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("context %q: got %v; expected an error about %s", tc.context, err, tc.want)
+		}
+	}
+}
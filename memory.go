@@ -0,0 +1,315 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NUMAMemoryBytes returns the size (in bytes) of the memory of the NUMA node
+// element stored under the provided NodeID, or a non-nil error value in case
+// of failure (e.g., if its memory attributes are unknown).
+func (t *Topology) NUMAMemoryBytes(numaID NodeID) (uint64, error) {
+	data, err := t.Get(numaID)
+	if err != nil {
+		return 0, err
+	}
+	if !data.IsProcessing() || data.Kind != NUMANode {
+		return 0, fmt.Errorf("element %d is not a NUMA node", numaID)
+	}
+	if nil == data.Memory || data.Memory.Bytes == 0 {
+		return 0, fmt.Errorf("memory of NUMA node %d is unknown", data.ID)
+	}
+	return data.Memory.Bytes, nil
+}
+
+// TotalMemoryBytes returns the total size (in bytes) of the memory of all NUMA
+// nodes of the Topology, or a non-nil error value in case of failure (e.g., if
+// the memory attributes of any NUMA node are unknown).
+func (t *Topology) TotalMemoryBytes() (uint64, error) {
+	if nil == t || nil == t.Tree {
+		return 0, ErrNilTree
+	}
+	numaIDs := t.getIndexes().processing[NUMANode]
+	if len(numaIDs) == 0 {
+		return 0, fmt.Errorf("Topology contains no NUMA nodes")
+	}
+	var total uint64
+	for _, numaID := range numaIDs {
+		bytes, err := t.NUMAMemoryBytes(numaID)
+		if err != nil {
+			return 0, err
+		}
+		total += bytes
+	}
+	return total, nil
+}
+
+// ValidateMemory makes sure that the memory of all NUMA nodes of the Topology
+// adds up to the provided total memory of the machine (e.g., as returned by
+// ReadMemTotal), and returns a non-nil error value if it does not, or if the
+// memory of any NUMA node is unknown.
+//
+// A mismatch indicates that the Topology is missing NUMA nodes (e.g., the
+// memory-only ones, which DiscoverSysfs omits) or that it was collected from
+// another machine.
+func (t *Topology) ValidateMemory(total uint64) error {
+	sum, err := t.TotalMemoryBytes()
+	if err != nil {
+		return err
+	}
+	if sum != total {
+		return fmt.Errorf("memory of NUMA nodes adds up to %dB instead of %dB", sum, total)
+	}
+	return nil
+}
+
+// MemoryTierGroup describes the NUMA nodes of a Topology whose memory belongs
+// to the same MemoryTier (see Topology.MemoryTiers).
+type MemoryTierGroup struct {
+	// Tier is the MemoryTier of the NUMA nodes.
+	Tier MemoryTier `json:"tier"`
+	// NUMANodes lists the NodeIDs of the NUMA nodes, in ascending order.
+	NUMANodes []NodeID `json:"numa_nodes"`
+	// Bytes is the total size of the memory of the NUMA nodes, in bytes.
+	Bytes uint64 `json:"bytes"`
+}
+
+// MemoryTiers returns the NUMA nodes of the Topology grouped by the MemoryTier
+// of their memory, in the order of the MemoryTier constants, omitting those
+// tiers that no NUMA node belongs to.
+//
+// NUMA nodes whose memory attributes are unknown are assumed to be DRAM, and
+// do not contribute to its size.
+func (t *Topology) MemoryTiers() []MemoryTierGroup {
+	ret := make([]MemoryTierGroup, 0)
+	if nil == t || nil == t.Tree {
+		return ret
+	}
+	for tier := DRAM; tier <= PMEM; tier++ {
+		group := MemoryTierGroup{Tier: tier, NUMANodes: make([]NodeID, 0)}
+		for _, numaID := range t.getIndexes().processing[NUMANode] {
+			if data := t.Nodes[numaID].Data; memoryTier(data) == tier {
+				group.NUMANodes = append(group.NUMANodes, numaID)
+				if nil != data.Memory {
+					group.Bytes += data.Memory.Bytes
+				}
+			}
+		}
+		if len(group.NUMANodes) > 0 {
+			ret = append(ret, group)
+		}
+	}
+	return ret
+}
+
+// NearestTier returns the NodeID of the NUMA node whose memory belongs to the
+// provided MemoryTier and is nearest to the hardware thread stored under the
+// provided NodeID, or a non-nil error value in case of failure (e.g., if there
+// is no memory of the tier).
+//
+// The nearest NUMA node is the one that shares the deepest common ancestor
+// with the thread (e.g., a memory-only NUMA node of its own Package rather
+// than of another one); ties are broken in favor of the lowest NodeID.
+func (t *Topology) NearestTier(threadID NodeID, tier MemoryTier) (NodeID, error) {
+	data, err := t.Get(threadID)
+	if err != nil {
+		return 0, err
+	}
+	if !data.IsProcessing() || data.Kind != Thread {
+		return 0, fmt.Errorf("element %d is not a thread", threadID)
+	}
+	numaID, found, err := t.nearestNUMANode(threadID, func(data *Element) bool { return memoryTier(data) == tier })
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("no %s memory found for thread %d", tier, threadID)
+	}
+	return numaID, nil
+}
+
+// NearestNUMANode returns the NodeID of the NUMA node that is nearest to the
+// element stored under the provided NodeID, which may be of any kind, or a
+// non-nil error value in case of failure (e.g., if the Topology contains no
+// NUMA nodes).
+//
+// The nearest NUMA node of a NUMA node is itself, while that of any element in
+// the subtree of a NUMA node (e.g., a thread or a private cache) is the
+// closest such ancestor. Otherwise (e.g., for a Package or a cache that spans
+// several NUMA nodes), it is the NUMA node that shares the deepest common
+// ancestor with the element, including the element itself; ties are broken in
+// favor of the lowest NodeID.
+func (t *Topology) NearestNUMANode(id NodeID) (NodeID, error) {
+	numaID, found, err := t.nearestNUMANode(id, func(*Element) bool { return true })
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("Topology contains no NUMA nodes")
+	}
+	return numaID, nil
+}
+
+// nearestNUMANode returns the NodeID of the NUMA node that matches the provided
+// function and shares the deepest common ancestor with the element stored
+// under the provided NodeID (see NearestNUMANode), and whether one was found.
+func (t *Topology) nearestNUMANode(id NodeID, match func(*Element) bool) (NodeID, bool, error) {
+	if _, err := t.Get(id); err != nil {
+		return 0, false, err
+	}
+	ancestorIDs, err := t.AncestorIDs(id)
+	if err != nil {
+		return 0, false, err
+	}
+	numaIDs := t.getIndexes().processing[NUMANode]
+	for _, ancestorID := range append([]NodeID{id}, ancestorIDs...) {
+		start, end, err := t.SubtreeRange(ancestorID)
+		if err != nil {
+			return 0, false, err
+		}
+		// The NUMA nodes are sorted by their NodeIDs, as the indexes
+		// are built in pre-order; the closest NUMA node ancestor, if
+		// any, is the first one in the subtree of the ancestor.
+		first := sort.Search(len(numaIDs), func(i int) bool { return numaIDs[i] >= start })
+		for _, numaID := range numaIDs[first:] {
+			if numaID >= end {
+				break
+			}
+			if match(t.Nodes[numaID].Data) {
+				return numaID, true, nil
+			}
+		}
+	}
+	return 0, false, nil
+}
+
+// Default NUMA distances, which Linux also assumes in the absence of an ACPI
+// SLIT (see NUMADistance).
+const (
+	// LocalDistance is the distance of every NUMA node to itself.
+	LocalDistance = 10
+	// RemoteDistance is the default distance between two NUMA nodes.
+	RemoteDistance = 20
+)
+
+// NUMADistance returns the relative distance between the NUMA node elements
+// stored under the provided NodeIDs, as reported by the firmware (see
+// MemoryAttributes), or a non-nil error value in case of failure.
+//
+// If the distance is unknown (e.g., if the Topology was not discovered through
+// sysfs), it defaults to LocalDistance for a NUMA node and itself, and to
+// RemoteDistance for any other pair of NUMA nodes. See Distance for the
+// distance between elements of any kind.
+func (t *Topology) NUMADistance(a, b NodeID) (uint32, error) {
+	osIDs := [2]uint32{}
+	mems := [2]*MemoryAttributes{}
+	for i, numaID := range []NodeID{a, b} {
+		data, err := t.Get(numaID)
+		if err != nil {
+			return 0, err
+		}
+		if !data.IsProcessing() || data.Kind != NUMANode {
+			return 0, fmt.Errorf("element %d is not a NUMA node", numaID)
+		}
+		osIDs[i], mems[i] = data.ID, data.Memory
+	}
+	if nil != mems[0] {
+		if dist, ok := mems[0].Distances[osIDs[1]]; ok {
+			return dist, nil
+		}
+	}
+	if nil != mems[1] {
+		if dist, ok := mems[1].Distances[osIDs[0]]; ok {
+			return dist, nil
+		}
+	}
+	if a == b {
+		return LocalDistance, nil
+	}
+	return RemoteDistance, nil
+}
+
+// distanceCost returns the sum of the distances among all pairs of the NUMA
+// node elements stored under the provided NodeIDs (see NUMADistance).
+func (t *Topology) distanceCost(numaIDs []NodeID) (uint32, error) {
+	var cost uint32
+	for i := range numaIDs {
+		for j := i + 1; j < len(numaIDs); j++ {
+			dist, err := t.NUMADistance(numaIDs[i], numaIDs[j])
+			if err != nil {
+				return 0, err
+			}
+			cost += dist
+		}
+	}
+	return cost, nil
+}
+
+// memoryTier returns the MemoryTier of the provided NUMA node element, which is
+// assumed to be DRAM if its memory attributes are unknown.
+func memoryTier(data *Element) MemoryTier {
+	if nil == data.Memory {
+		return DRAM
+	}
+	return data.Memory.Tier
+}
+
+// ReadMemTotal returns the total memory of the machine (in bytes), as exposed
+// by the Linux kernel through the provided file system, which is expected to
+// be rooted at the mount point of procfs (i.e., /proc), or a non-nil error
+// value in case of failure. If fsys is nil, /proc is used.
+func ReadMemTotal(fsys fs.FS) (uint64, error) {
+	if nil == fsys {
+		fsys = os.DirFS("/proc")
+	}
+	data, err := fs.ReadFile(fsys, "meminfo")
+	if err != nil {
+		return 0, err
+	}
+	return parseMemTotal(string(data))
+}
+
+// parseMemTotal returns the MemTotal (in bytes) found in the provided contents
+// of /proc/meminfo, or of a NUMA node's meminfo in sysfs, whose lines are
+// prefixed by "Node N".
+func parseMemTotal(meminfo string) (uint64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(meminfo))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for i, field := range fields {
+			if field != "MemTotal:" || i+1 >= len(fields) {
+				continue
+			}
+			kb, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid MemTotal: %w", err)
+			}
+			return kb << 10, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("MemTotal not found")
+}
@@ -0,0 +1,265 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMemoryBytes(t *testing.T) {
+	fsys := sysfsFixture()
+	fsys["devices/system/node/node0/meminfo"] = &fstest.MapFile{Data: []byte(
+		"Node 0 MemTotal:       16310012 kB\nNode 0 MemFree:         1234567 kB\n")}
+	topo, err := DiscoverSysfs(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = 16310012 << 10
+	numaID := topo.NUMANodes()[0]
+	if got, err := topo.NUMAMemoryBytes(numaID); err != nil || got != want {
+		t.Errorf("NUMAMemoryBytes(%d) = %d, %v; expected %d", numaID, got, err, want)
+	}
+	if got, err := topo.TotalMemoryBytes(); err != nil || got != want {
+		t.Errorf("TotalMemoryBytes() = %d, %v; expected %d", got, err, want)
+	}
+	if _, err = topo.NUMAMemoryBytes(topo.Threads()[0]); err == nil {
+		t.Errorf("expected an error for a thread")
+	}
+
+	procfs := fstest.MapFS{"meminfo": {Data: []byte("MemTotal:       16310012 kB\nMemFree:         1234567 kB\n")}}
+	total, err := ReadMemTotal(procfs)
+	if err != nil || total != want {
+		t.Fatalf("ReadMemTotal() = %d, %v; expected %d", total, err, want)
+	}
+	if err = topo.ValidateMemory(total); err != nil {
+		t.Error(err)
+	}
+	if err = topo.ValidateMemory(2 * total); err == nil {
+		t.Errorf("expected an error for a memory-only NUMA node missing from the Topology")
+	}
+	if _, err = ReadMemTotal(fstest.MapFS{"meminfo": {Data: []byte("MemFree: 1 kB\n")}}); err == nil {
+		t.Errorf("expected an error for a missing MemTotal")
+	}
+
+	// The memory attributes survive a round trip through JSON and a clone.
+	data, err := json.Marshal(topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Topology
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decoded.TotalMemoryBytes(); err != nil || got != want {
+		t.Errorf("TotalMemoryBytes() = %d, %v after a round trip; expected %d", got, err, want)
+	}
+	clone := topo.clone()
+	clone.Nodes[numaID].Data.Memory.Bytes = 0
+	if got, _ := topo.NUMAMemoryBytes(numaID); got != want {
+		t.Errorf("modifying a clone modified the original")
+	}
+
+	// Memory is unknown without meminfo, and only NUMA nodes may have it.
+	if topo, err = DiscoverSysfs(sysfsFixture()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = topo.TotalMemoryBytes(); err == nil {
+		t.Errorf("expected an error for unknown memory")
+	}
+	topo.Nodes[topo.Threads()[0]].Data.Memory = &MemoryAttributes{Bytes: 1}
+	if err = topo.Validate(); err == nil {
+		t.Errorf("expected an error for memory attributes on a thread")
+	}
+}
+
+func TestMemoryTiers(t *testing.T) {
+	topo, err := FromSpec(TopologySpec{Packages: 2, NUMANodesPerPackage: 2, Cores: 2, ThreadsPerCore: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	numaIDs := topo.NUMANodes()
+	for i, numaID := range numaIDs {
+		topo.Nodes[numaID].Data.Memory = &MemoryAttributes{Bytes: 64 << 30}
+		if i%2 == 1 {
+			topo.Nodes[numaID].Data.Memory = &MemoryAttributes{Bytes: 16 << 30, Tier: HBM}
+		}
+	}
+
+	want := []MemoryTierGroup{
+		{Tier: DRAM, NUMANodes: []NodeID{numaIDs[0], numaIDs[2]}, Bytes: 128 << 30},
+		{Tier: HBM, NUMANodes: []NodeID{numaIDs[1], numaIDs[3]}, Bytes: 32 << 30},
+	}
+	if got := topo.MemoryTiers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; expected %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		numa int
+		tier MemoryTier
+		want int
+	}{
+		{0, DRAM, 0},
+		{0, HBM, 1},
+		{1, DRAM, 0},
+		{2, HBM, 3},
+		{3, DRAM, 2},
+	} {
+		leafIDs, err := topo.LeafDescendantIDs(numaIDs[tc.numa])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := topo.NearestTier(leafIDs[0], tc.tier); err != nil || got != numaIDs[tc.want] {
+			t.Errorf("NearestTier(%d, %s) = %d, %v; expected %d", leafIDs[0], tc.tier, got, err, numaIDs[tc.want])
+		}
+	}
+	if _, err = topo.NearestTier(topo.Threads()[0], CXL); err == nil {
+		t.Errorf("expected an error for a missing memory tier")
+	}
+
+	// hwloc reports the tier as the subtype of NUMA nodes.
+	payload := `<topology><object type="Machine" os_index="0">` +
+		`<object type="Package" os_index="0" cpuset="0x1">` +
+		`<object type="NUMANode" os_index="0" cpuset="0x1" local_memory="1073741824"/>` +
+		`<object type="NUMANode" os_index="1" subtype="MCDRAM" local_memory="536870912"/>` +
+		`<object type="Core" os_index="0" cpuset="0x1"><object type="PU" os_index="0" cpuset="0x1"/></object>` +
+		`</object></object></topology>`
+	if topo, err = ParseHwlocXML(strings.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+	tiers := topo.MemoryTiers()
+	if len(tiers) != 2 || tiers[1].Tier != HBM || tiers[1].Bytes != 512<<20 {
+		t.Errorf("got %v", tiers)
+	}
+	if got, err := topo.NearestTier(topo.Threads()[0], HBM); err != nil || got != tiers[1].NUMANodes[0] {
+		t.Errorf("NearestTier(HBM) = %d, %v; expected %v", got, err, tiers[1].NUMANodes)
+	}
+}
+
+func TestNearestNUMANode(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 2, 2, 2)}
+	numaIDs := topo.NUMANodes()
+	for _, tc := range []struct {
+		id   NodeID
+		want NodeID
+	}{
+		{0, numaIDs[0]},
+		{topo.Packages()[1], numaIDs[2]},
+		{numaIDs[1], numaIDs[1]},
+		{topo.L3Caches()[3], numaIDs[3]},
+		{topo.L1Caches()[5], numaIDs[2]},
+		{topo.Threads()[len(topo.Threads())-1], numaIDs[3]},
+	} {
+		if got, err := topo.NearestNUMANode(tc.id); err != nil || got != tc.want {
+			t.Errorf("NearestNUMANode(%d) = %d, %v; expected %d", tc.id, got, err, tc.want)
+		}
+	}
+	if _, err := topo.NearestNUMANode(NodeID(topo.Size())); err == nil {
+		t.Errorf("expected an error for an invalid NodeID")
+	}
+
+	// The NUMA node of a thread is the nearest one of its tier, too.
+	for _, numaID := range numaIDs {
+		topo.Nodes[numaID].Data.Memory = &MemoryAttributes{Bytes: 1 << 30, Tier: HBM}
+	}
+	if got, err := topo.NearestTier(topo.Threads()[len(topo.Threads())-1], HBM); err != nil || got != numaIDs[3] {
+		t.Errorf("NearestTier(HBM) = %d, %v; expected %d", got, err, numaIDs[3])
+	}
+
+	topo = &Topology{NewTree([]TreeNode{
+		{Data: &Element{}, Children: []NodeID{1}},
+		{Data: &Element{Processing: &Processing{Kind: Package}}, Children: []NodeID{2}},
+		{Data: &Element{Processing: &Processing{Kind: Thread}}},
+	})}
+	if _, err := topo.NearestNUMANode(2); err == nil {
+		t.Errorf("expected an error for a Topology without NUMA nodes")
+	}
+}
+
+func TestNUMADistance(t *testing.T) {
+	fsys := sysfsFixture()
+	fsys["devices/system/node/online"] = &fstest.MapFile{Data: []byte("0-1\n")}
+	fsys["devices/system/node/node0/distance"] = &fstest.MapFile{Data: []byte("10 21\n")}
+	topo, err := DiscoverSysfs(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numaID := topo.NUMANodes()[0]
+	want := map[uint32]uint32{0: 10, 1: 21}
+	if got := topo.Nodes[numaID].Data.Memory.Distances; !reflect.DeepEqual(got, want) {
+		t.Errorf("got distances %v; expected %v", got, want)
+	}
+	if got, err := topo.NUMADistance(numaID, numaID); err != nil || got != LocalDistance {
+		t.Errorf("NUMADistance(%d, %d) = %d, %v; expected %d", numaID, numaID, got, err, LocalDistance)
+	}
+	if _, err = topo.NUMADistance(numaID, topo.Threads()[0]); err == nil {
+		t.Errorf("expected an error for a thread")
+	}
+
+	// The distances survive a round trip through JSON and a clone.
+	data, err := json.Marshal(topo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"dist":{"0":10,"1":21}`) {
+		t.Errorf("got %s; expected the distances of NUMA node 0", data)
+	}
+	var decoded Topology
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Nodes[numaID].Data.Memory.Distances; !reflect.DeepEqual(got, want) {
+		t.Errorf("got distances %v after a round trip; expected %v", got, want)
+	}
+	clone := topo.clone()
+	clone.Nodes[numaID].Data.Memory.Distances[1] = 11
+	if got := topo.Nodes[numaID].Data.Memory.Distances[1]; got != 21 {
+		t.Errorf("modifying a clone modified the original")
+	}
+	if err = decoded.UnmarshalJSON([]byte(`{"nodes":[{"data":{"processing":{"kind":"numanode","id":0,"mem":{"bytes":0,"dist":{"x":10}}}}}]}`)); err == nil {
+		t.Errorf("expected an error for a malformed distance")
+	}
+
+	// Distances are looked up in either row, and default to the ones Linux
+	// assumes in the absence of a SLIT.
+	topo = &Topology{syntheticTree(1, 3, 1, 1)}
+	numaIDs := topo.NUMANodes()
+	topo.Nodes[numaIDs[0]].Data.Memory = &MemoryAttributes{Distances: map[uint32]uint32{0: 10, 1: 16}}
+	for _, tc := range []struct {
+		a, b NodeID
+		want uint32
+	}{
+		{numaIDs[0], numaIDs[1], 16},
+		{numaIDs[1], numaIDs[0], 16},
+		{numaIDs[1], numaIDs[2], RemoteDistance},
+		{numaIDs[2], numaIDs[2], LocalDistance},
+	} {
+		if got, err := topo.NUMADistance(tc.a, tc.b); err != nil || got != tc.want {
+			t.Errorf("NUMADistance(%d, %d) = %d, %v; expected %d", tc.a, tc.b, got, err, tc.want)
+		}
+	}
+
+	broken := sysfsFixture()
+	broken["devices/system/node/online"] = &fstest.MapFile{Data: []byte("0-1\n")}
+	broken["devices/system/node/node0/distance"] = &fstest.MapFile{Data: []byte("10\n")}
+	if _, err = DiscoverSysfs(broken); err == nil {
+		t.Errorf("expected an error for a distance row that does not match the online NUMA nodes")
+	}
+}
@@ -0,0 +1,249 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MemPolicy enumerates the NUMA memory policies of Linux (see
+// set_mempolicy(2)); their values match the kernel's MPOL_* constants, so that
+// they can be passed to set_mempolicy and mbind as they are.
+type MemPolicy int
+
+const (
+	// MemPolicyDefault allocates memory on the NUMA node of the CPU that
+	// triggers the allocation (i.e., MPOL_DEFAULT).
+	MemPolicyDefault MemPolicy = iota
+	// MemPolicyPreferred allocates memory on a single NUMA node, falling
+	// back to others when it runs out of memory (i.e., MPOL_PREFERRED).
+	MemPolicyPreferred
+	// MemPolicyBind allocates memory strictly on a set of NUMA nodes
+	// (i.e., MPOL_BIND).
+	MemPolicyBind
+	// MemPolicyInterleave interleaves allocations across a set of NUMA
+	// nodes, page by page (i.e., MPOL_INTERLEAVE).
+	MemPolicyInterleave
+)
+
+// String returns the string representation of the MemPolicy.
+func (mp MemPolicy) String() string {
+	switch mp {
+	case MemPolicyDefault:
+		return "default"
+	case MemPolicyPreferred:
+		return "preferred"
+	case MemPolicyBind:
+		return "bind"
+	case MemPolicyInterleave:
+		return "interleave"
+	default:
+		return fmt.Sprintf("Unknown memory policy %d", int(mp))
+	}
+}
+
+// NodeMask is a set of NUMA nodes in the layout of the kernel's nodemask_t,
+// as expected by set_mempolicy(2) and mbind(2): bit i of word i/64 is set if
+// the NUMA node with OS index i is included.
+type NodeMask []uint64
+
+// MaxNode returns the value of the maxnode argument of set_mempolicy(2) and
+// mbind(2) for the NodeMask, accounting for the kernel ignoring the last bit
+// (as libnuma does).
+func (m NodeMask) MaxNode() uint64 {
+	return uint64(len(m))*64 + 1
+}
+
+// NUMAOSIndices returns the OS indices of the NUMA node elements stored under
+// the provided NodeIDs, sorted and without duplicates, or a non-nil error
+// value if any of them is not a NUMA node.
+func (t *Topology) NUMAOSIndices(numaIDs []NodeID) ([]uint32, error) {
+	set := make(map[uint32]struct{}, len(numaIDs))
+	for _, numaID := range numaIDs {
+		data, err := t.Get(numaID)
+		if err != nil {
+			return nil, err
+		}
+		if !data.IsProcessing() || data.Kind != NUMANode {
+			return nil, fmt.Errorf("element %d is not a NUMA node", numaID)
+		}
+		set[data.ID] = struct{}{}
+	}
+	ret := make([]uint32, 0, len(set))
+	for osID := range set {
+		ret = append(ret, osID)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret, nil
+}
+
+// NUMANodeList returns the OS indices of the NUMA node elements stored under
+// the provided NodeIDs in the Linux kernel's "nodelist" format (e.g., "0-1,3"),
+// as expected by numactl, cpuset.mems or the mpol mount option of tmpfs, or a
+// non-nil error value if any of them is not a NUMA node.
+func (t *Topology) NUMANodeList(numaIDs []NodeID) (string, error) {
+	osIDs, err := t.NUMAOSIndices(numaIDs)
+	if err != nil {
+		return "", err
+	}
+	// The nodelist format is the same as the cpulist one.
+	return FormatCPUList(osIDs), nil
+}
+
+// NUMANodeMask returns the NodeMask of the NUMA node elements stored under the
+// provided NodeIDs, to be passed to set_mempolicy(2) or mbind(2), or a non-nil
+// error value if any of them is not a NUMA node.
+func (t *Topology) NUMANodeMask(numaIDs []NodeID) (NodeMask, error) {
+	osIDs, err := t.NUMAOSIndices(numaIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(osIDs) == 0 {
+		return NodeMask{}, nil
+	}
+	mask := make(NodeMask, osIDs[len(osIDs)-1]/64+1)
+	for _, osID := range osIDs {
+		mask[osID/64] |= 1 << (osID % 64)
+	}
+	return mask, nil
+}
+
+// NumactlArgs returns the arguments of numactl(8) that apply the provided
+// MemPolicy to the NUMA node elements stored under the provided NodeIDs (e.g.,
+// "--membind=0-1"), or a non-nil error value in case of failure.
+//
+// MemPolicyDefault requires no NUMA nodes and results in no arguments, while
+// MemPolicyPreferred requires exactly one NUMA node; the others require at
+// least one.
+func (t *Topology) NumactlArgs(policy MemPolicy, numaIDs []NodeID) ([]string, error) {
+	osIDs, err := t.NUMAOSIndices(numaIDs)
+	if err != nil {
+		return nil, err
+	}
+	nodeList := FormatCPUList(osIDs)
+	switch {
+	case policy == MemPolicyDefault && len(osIDs) == 0:
+		return []string{}, nil
+	case policy == MemPolicyPreferred && len(osIDs) == 1:
+		return []string{"--preferred=" + nodeList}, nil
+	case policy == MemPolicyBind && len(osIDs) > 0:
+		return []string{"--membind=" + nodeList}, nil
+	case policy == MemPolicyInterleave && len(osIDs) > 0:
+		return []string{"--interleave=" + nodeList}, nil
+	case policy < MemPolicyDefault || policy > MemPolicyInterleave:
+		return nil, fmt.Errorf("invalid memory policy %d", int(policy))
+	default:
+		return nil, fmt.Errorf("invalid number of NUMA nodes for the %s memory policy: %d", policy, len(osIDs))
+	}
+}
+
+// CpusetMems returns the NodeIDs of the NUMA node elements that the hardware
+// threads stored under the provided NodeIDs belong to, in ascending order,
+// along with their OS indices in the format of cpuset.mems (e.g., "0-1"), or a
+// non-nil error value in case of failure.
+//
+// The NUMA nodes stored under the excluded NodeIDs are left out of the result,
+// so that the memory of threads that belong to them is allocated on the rest.
+// In strict mode, threads that belong to excluded NUMA nodes are an error
+// instead. Either way, it is an error if no NUMA nodes are left, since the
+// kernel rejects an empty cpuset.mems for a cgroup with tasks.
+func (t *Topology) CpusetMems(threadIDs, excluded []NodeID, strict bool) ([]NodeID, string, error) {
+	if _, err := t.NUMAOSIndices(excluded); err != nil {
+		return nil, "", err
+	}
+	isExcluded := make(map[NodeID]bool, len(excluded))
+	for _, numaID := range excluded {
+		isExcluded[numaID] = true
+	}
+
+	numaIDs := make([]NodeID, 0)
+	seen := make(map[NodeID]bool)
+	for _, id := range threadIDs {
+		numaID, err := t.numaNodeOfThread(id)
+		if err != nil {
+			return nil, "", err
+		}
+		switch {
+		case isExcluded[numaID] && strict:
+			return nil, "", fmt.Errorf("thread %d belongs to excluded NUMA node %d", id, numaID)
+		case isExcluded[numaID] || seen[numaID]:
+			continue
+		}
+		seen[numaID] = true
+		numaIDs = append(numaIDs, numaID)
+	}
+	if len(numaIDs) == 0 {
+		return nil, "", fmt.Errorf("no NUMA nodes left for threads %v", threadIDs)
+	}
+	sort.Slice(numaIDs, func(i, j int) bool { return numaIDs[i] < numaIDs[j] })
+
+	mems, err := t.NUMANodeList(numaIDs)
+	if err != nil {
+		return nil, "", err
+	}
+	return numaIDs, mems, nil
+}
+
+// NUMASpan returns the NodeIDs of the NUMA node elements that the hardware
+// threads stored under the provided NodeIDs belong to, in ascending order,
+// along with the number of the threads that belong to each of them (e.g., to
+// interleave memory across them in proportion to their threads), or a non-nil
+// error value in case of failure. Duplicate threads are counted once.
+func (t *Topology) NUMASpan(threadIDs []NodeID) ([]NodeID, map[NodeID]int, error) {
+	counts := make(map[NodeID]int)
+	seen := make(map[NodeID]bool, len(threadIDs))
+	for _, id := range threadIDs {
+		numaID, err := t.numaNodeOfThread(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			counts[numaID]++
+		}
+	}
+	numaIDs := make([]NodeID, 0, len(counts))
+	for numaID := range counts {
+		numaIDs = append(numaIDs, numaID)
+	}
+	sort.Slice(numaIDs, func(i, j int) bool { return numaIDs[i] < numaIDs[j] })
+	return numaIDs, counts, nil
+}
+
+// numaNodeOfThread returns the NodeID of the NUMA node element that the
+// hardware thread stored under the provided NodeID belongs to, or a non-nil
+// error value if it is not a thread or it belongs to no NUMA node.
+func (t *Topology) numaNodeOfThread(id NodeID) (NodeID, error) {
+	data, err := t.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	if !data.IsProcessing() || data.Kind != Thread {
+		return 0, fmt.Errorf("element %d is not a thread", id)
+	}
+	ancestorIDs, err := t.AncestorIDs(id)
+	if err != nil {
+		return 0, err
+	}
+	for _, ancestorID := range ancestorIDs {
+		if ancestor := t.Nodes[ancestorID].Data; ancestor.IsProcessing() && ancestor.Kind == NUMANode {
+			return ancestorID, nil
+		}
+	}
+	return 0, fmt.Errorf("thread %d does not belong to any NUMA node", id)
+}
@@ -0,0 +1,120 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMemPolicyHelpers(t *testing.T) {
+	topo, err := FromSpec(TopologySpec{Packages: 2, NUMANodesPerPackage: 2, Cores: 1, ThreadsPerCore: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	numaIDs := topo.NUMANodes()
+	// Make one of the OS indices span a second word of the NodeMask.
+	topo.Nodes[numaIDs[3]].Data.ID = 65
+
+	selected := []NodeID{numaIDs[3], numaIDs[0], numaIDs[1], numaIDs[0]}
+	if got, err := topo.NUMANodeList(selected); err != nil || got != "0-1,65" {
+		t.Errorf("NUMANodeList() = %q, %v; expected \"0-1,65\"", got, err)
+	}
+	mask, err := topo.NUMANodeMask(selected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (NodeMask{0b11, 0b10}); !reflect.DeepEqual(mask, want) || mask.MaxNode() != 129 {
+		t.Errorf("NUMANodeMask() = %b (maxnode %d); expected %b (maxnode 129)", mask, mask.MaxNode(), want)
+	}
+
+	for _, tc := range []struct {
+		policy MemPolicy
+		ids    []NodeID
+		want   []string
+	}{
+		{MemPolicyDefault, nil, []string{}},
+		{MemPolicyPreferred, numaIDs[2:3], []string{"--preferred=2"}},
+		{MemPolicyBind, selected, []string{"--membind=0-1,65"}},
+		{MemPolicyInterleave, numaIDs[:3], []string{"--interleave=0-2"}},
+		{MemPolicyDefault, numaIDs[:1], nil},
+		{MemPolicyPreferred, numaIDs[:2], nil},
+		{MemPolicyBind, nil, nil},
+		{MemPolicy(42), numaIDs, nil},
+	} {
+		got, err := topo.NumactlArgs(tc.policy, tc.ids)
+		if (err != nil) != (nil == tc.want) || (nil != tc.want && !reflect.DeepEqual(got, tc.want)) {
+			t.Errorf("NumactlArgs(%s, %v) = %v, %v; expected %v", tc.policy, tc.ids, got, err, tc.want)
+		}
+	}
+
+	if _, err = topo.NUMANodeList([]NodeID{topo.Threads()[0]}); err == nil {
+		t.Errorf("expected an error for a thread")
+	}
+}
+
+func TestCpusetMems(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 2, 1, 2)}
+	numaIDs, threads := topo.NUMANodes(), topo.Threads()
+	// Threads 0-1 belong to NUMA node 0, 2-3 to NUMA node 1, and so on.
+	placement := []NodeID{threads[7], threads[0], threads[1], threads[5]}
+
+	got, mems, err := topo.CpusetMems(placement, nil, true)
+	if want := []NodeID{numaIDs[0], numaIDs[2], numaIDs[3]}; err != nil || !reflect.DeepEqual(got, want) || mems != "0,2-3" {
+		t.Errorf("CpusetMems() = %v, %q, %v; expected %v, \"0,2-3\"", got, mems, err, want)
+	}
+	excluded := []NodeID{numaIDs[2]}
+	got, mems, err = topo.CpusetMems(placement, excluded, false)
+	if want := []NodeID{numaIDs[0], numaIDs[3]}; err != nil || !reflect.DeepEqual(got, want) || mems != "0,3" {
+		t.Errorf("CpusetMems(excluded) = %v, %q, %v; expected %v, \"0,3\"", got, mems, err, want)
+	}
+	if _, _, err = topo.CpusetMems(placement, excluded, true); err == nil {
+		t.Errorf("expected an error for a thread of an excluded NUMA node in strict mode")
+	}
+	if _, _, err = topo.CpusetMems(placement[2:3], numaIDs[:1], false); err == nil {
+		t.Errorf("expected an error for no NUMA nodes left")
+	}
+	if _, _, err = topo.CpusetMems(numaIDs[:1], nil, false); err == nil {
+		t.Errorf("expected an error for a NUMA node instead of a thread")
+	}
+	if _, _, err = topo.CpusetMems(placement, threads[:1], false); err == nil {
+		t.Errorf("expected an error for a thread instead of an excluded NUMA node")
+	}
+}
+
+func TestNUMASpan(t *testing.T) {
+	topo := &Topology{syntheticTree(1, 3, 2, 1)}
+	numaIDs, threads := topo.NUMANodes(), topo.Threads()
+	// Threads 0-1 belong to NUMA node 0, 2-3 to NUMA node 1, and 4-5 to
+	// NUMA node 2.
+	got, counts, err := topo.NUMASpan([]NodeID{threads[5], threads[0], threads[1], threads[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []NodeID{numaIDs[0], numaIDs[2]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NUMASpan() = %v; expected %v", got, want)
+	}
+	if want := map[NodeID]int{numaIDs[0]: 2, numaIDs[2]: 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("got counts %v; expected %v", counts, want)
+	}
+	if got, counts, err = topo.NUMASpan(nil); err != nil || len(got) != 0 || len(counts) != 0 {
+		t.Errorf("NUMASpan(nil) = %v, %v, %v; expected nothing", got, counts, err)
+	}
+	if _, _, err = topo.NUMASpan(numaIDs); err == nil {
+		t.Errorf("expected an error for NUMA nodes instead of threads")
+	}
+}
@@ -0,0 +1,235 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// PerturbationKind enumerates the kinds of Perturbations that a Mutator can
+// apply to a Topology.
+type PerturbationKind byte
+
+const (
+	// OfflineCore removes a Core along with its hardware threads, and any
+	// of its caches that are left without any Core, as if it had been
+	// taken offline.
+	OfflineCore PerturbationKind = iota + 1
+	// DropL3 removes an L3 cache, attaching its children to its parent, as
+	// if the cache had not been reported.
+	DropL3
+	// SwapOSIDs swaps the OS indices of two hardware threads, as if they
+	// had been enumerated in a different order.
+	SwapOSIDs
+)
+
+// String returns the string representation of the PerturbationKind.
+func (pk PerturbationKind) String() string {
+	switch pk {
+	case OfflineCore:
+		return "offline-core"
+	case DropL3:
+		return "drop-l3"
+	case SwapOSIDs:
+		return "swap-os-ids"
+	default:
+		return fmt.Sprintf("PerturbationKind(%d)", byte(pk))
+	}
+}
+
+// MarshalText returns the PerturbationKind marshalled as text (e.g., in JSON).
+func (pk PerturbationKind) MarshalText() ([]byte, error) {
+	return []byte(pk.String()), nil
+}
+
+// UnmarshalText unmarshals the PerturbationKind from text (e.g., in JSON).
+func (pk *PerturbationKind) UnmarshalText(text []byte) error {
+	for kind := OfflineCore; kind <= SwapOSIDs; kind++ {
+		if kind.String() == string(text) {
+			*pk = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown perturbation kind '%s'", text)
+}
+
+// Perturbation describes a change that a Mutator applied to a Topology.
+type Perturbation struct {
+	// Kind is the kind of the Perturbation.
+	Kind PerturbationKind `json:"kind"`
+	// Elements are the NodeIDs of the perturbed Elements (e.g., the Core
+	// that was taken offline), in the original Topology.
+	Elements []NodeID `json:"elements"`
+	// Description is a human-readable description of the Perturbation.
+	Description string `json:"desc"`
+}
+
+// String returns the string representation of the Perturbation.
+func (p Perturbation) String() string {
+	return p.Description
+}
+
+// Mutator applies controlled, randomized Perturbations to Topologies, to test
+// how their consumers handle hardware surprises.
+//
+// A Mutator is safe for concurrent use, and the Perturbations that it applies
+// are reproducible for a given seed (see NewMutator), as long as it is used by
+// a single goroutine.
+type Mutator struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewMutator returns a new Mutator, which draws its random choices from a
+// source seeded with the provided seed.
+func NewMutator(seed int64) *Mutator {
+	return &Mutator{rand: rand.New(rand.NewSource(seed))}
+}
+
+// Perturb returns a perturbed copy of the provided Topology, which is left
+// intact, along with a description of the Perturbation of the provided kind
+// that was applied to it, or a non-nil error value in case of failure. The
+// perturbed Elements are chosen at random.
+//
+// ErrNotApplicable is returned if the Topology contains no Elements to apply
+// the Perturbation to (e.g., fewer than two Cores or hardware threads).
+func (m *Mutator) Perturb(t *Topology, kind PerturbationKind) (*Topology, Perturbation, error) {
+	if nil == t || nil == t.Tree {
+		return nil, Perturbation{}, ErrNilTree
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var (
+		ret *Topology
+		p   = Perturbation{Kind: kind}
+	)
+	switch kind {
+	case OfflineCore:
+		cores := t.Cores()
+		if len(cores) < 2 {
+			return nil, p, fmt.Errorf("%w: %s requires at least 2 Cores", ErrNotApplicable, kind)
+		}
+		core := cores[m.rand.Intn(len(cores))]
+		ret = t.offlineCore(core)
+		p.Elements = []NodeID{core}
+		p.Description = fmt.Sprintf("%s: took %s (node %d) offline", kind, t.Nodes[core].Data, core)
+	case DropL3:
+		l3s := t.L3Caches()
+		if len(l3s) == 0 {
+			return nil, p, fmt.Errorf("%w: %s requires an L3 cache", ErrNotApplicable, kind)
+		}
+		l3 := l3s[m.rand.Intn(len(l3s))]
+		ret = t.rebuild(nil, map[NodeID]bool{l3: true})
+		p.Elements = []NodeID{l3}
+		p.Description = fmt.Sprintf("%s: dropped L3 cache L#%d (node %d)", kind, t.Nodes[l3].Data.LogicalIndex, l3)
+	case SwapOSIDs:
+		threads := t.Threads()
+		if len(threads) < 2 {
+			return nil, p, fmt.Errorf("%w: %s requires at least 2 hardware threads", ErrNotApplicable, kind)
+		}
+		i := m.rand.Intn(len(threads))
+		j := (i + 1 + m.rand.Intn(len(threads)-1)) % len(threads)
+		a, b := threads[i], threads[j]
+		ret = t.clone()
+		ret.Nodes[a].Data.ID, ret.Nodes[b].Data.ID = ret.Nodes[b].Data.ID, ret.Nodes[a].Data.ID
+		p.Elements = []NodeID{a, b}
+		p.Description = fmt.Sprintf("%s: swapped the OS indices of hardware threads %d (node %d) and %d (node %d)",
+			kind, t.Nodes[a].Data.ID, a, t.Nodes[b].Data.ID, b)
+	default:
+		return nil, p, fmt.Errorf("unknown perturbation kind %d", kind)
+	}
+	if err := ret.Validate(); err != nil {
+		return nil, p, err
+	}
+	return ret, p, nil
+}
+
+// PerturbRandom applies a Perturbation of a random kind, among those that are
+// applicable to the provided Topology (see Perturb).
+func (m *Mutator) PerturbRandom(t *Topology) (*Topology, Perturbation, error) {
+	if nil == t || nil == t.Tree {
+		return nil, Perturbation{}, ErrNilTree
+	}
+	kinds := make([]PerturbationKind, 0, SwapOSIDs)
+	if len(t.Cores()) >= 2 {
+		kinds = append(kinds, OfflineCore)
+	}
+	if len(t.L3Caches()) > 0 {
+		kinds = append(kinds, DropL3)
+	}
+	if len(t.Threads()) >= 2 {
+		kinds = append(kinds, SwapOSIDs)
+	}
+	if len(kinds) == 0 {
+		return nil, Perturbation{}, fmt.Errorf("%w: Topology is too small", ErrNotApplicable)
+	}
+	m.mu.Lock()
+	kind := kinds[m.rand.Intn(len(kinds))]
+	m.mu.Unlock()
+	return m.Perturb(t, kind)
+}
+
+// offlineCore returns a copy of the Topology without the Core stored under the
+// provided NodeID, its subtree, and any of its ancestor caches that are left
+// without children.
+func (t *Topology) offlineCore(core NodeID) *Topology {
+	drop := map[NodeID]bool{core: true}
+	for id := core; ; {
+		parent, err := t.ParentID(id)
+		if err != nil || !t.Nodes[parent].Data.IsCache() || len(t.Nodes[parent].Children) > 1 {
+			break
+		}
+		drop[parent] = true
+		id = parent
+	}
+	return t.rebuild(drop, nil)
+}
+
+// rebuild returns a deep copy of the Topology, without the subtrees of the
+// Elements stored under the NodeIDs in drop, and without the Elements stored
+// under the NodeIDs in splice, whose children are attached to their parents
+// instead. The Elements of the copy are renumbered, to remain in pre-order.
+func (t *Topology) rebuild(drop, splice map[NodeID]bool) *Topology {
+	nodes := make([]TreeNode, 0, len(t.Nodes))
+	var visit func(id, parent NodeID)
+	visit = func(id, parent NodeID) {
+		if drop[id] {
+			return
+		}
+		if splice[id] {
+			for _, child := range t.Nodes[id].Children {
+				visit(child, parent)
+			}
+			return
+		}
+		newID := NodeID(len(nodes))
+		node := cloneTreeNode(&t.Nodes[id])
+		node.Children = nil
+		nodes = append(nodes, node)
+		if newID != 0 {
+			nodes[parent].Children = append(nodes[parent].Children, newID)
+		}
+		for _, child := range t.Nodes[id].Children {
+			visit(child, newID)
+		}
+	}
+	visit(0, 0)
+	return &Topology{NewTree(nodes)}
+}
@@ -0,0 +1,84 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMutator(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 1, 4, 2)}
+	size := topo.Size()
+	m := NewMutator(42)
+
+	offline, p, err := m.Perturb(topo, OfflineCore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The Core, its 2 threads and its private L2 and L1 are gone.
+	if offline.Size() != size-5 || len(offline.Cores()) != 7 || len(p.Elements) != 1 {
+		t.Errorf("got %d elements after %v; expected %d", offline.Size(), p, size-5)
+	}
+
+	dropped, p, err := m.Perturb(topo, DropL3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dropped.L3Caches()) != 1 || len(dropped.Cores()) != 8 {
+		t.Errorf("got %d L3 caches and %d Cores after %v", len(dropped.L3Caches()), len(dropped.Cores()), p)
+	}
+
+	swapped, p, err := m.Perturb(topo, SwapOSIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := p.Elements[0], p.Elements[1]
+	if swapped.Nodes[a].Data.ID != topo.Nodes[b].Data.ID || swapped.Nodes[b].Data.ID != topo.Nodes[a].Data.ID {
+		t.Errorf("OS indices were not swapped by %v", p)
+	}
+
+	// The original Topology is left intact.
+	if topo.Size() != size || topo.Nodes[a].Data.ID == swapped.Nodes[a].Data.ID {
+		t.Errorf("the original Topology was modified")
+	}
+
+	// Perturbations are reproducible for a given seed.
+	for i := 0; i < 20; i++ {
+		_, p1, err1 := NewMutator(int64(i)).PerturbRandom(topo)
+		_, p2, err2 := NewMutator(int64(i)).PerturbRandom(topo)
+		if err1 != nil || err2 != nil || p1.Description != p2.Description {
+			t.Errorf("got %v (%v) and %v (%v) from the same seed", p1, err1, p2, err2)
+		}
+	}
+}
+
+func TestMutatorNotApplicable(t *testing.T) {
+	topo, err := FromSpec(TopologySpec{Packages: 1, NUMANodesPerPackage: 1, Cores: 1, ThreadsPerCore: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMutator(0)
+	for _, kind := range []PerturbationKind{OfflineCore, DropL3, SwapOSIDs} {
+		if _, _, err = m.Perturb(topo, kind); !errors.Is(err, ErrNotApplicable) {
+			t.Errorf("got %v for %s; expected ErrNotApplicable", err, kind)
+		}
+	}
+	if _, _, err = m.PerturbRandom(topo); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("got %v; expected ErrNotApplicable", err)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Remapping records an index that Normalize changed.
+type Remapping struct {
+	// ID is the NodeID of the Element whose index changed.
+	ID NodeID `json:"id"`
+	// Kind is the kind of the Element (e.g., "L3" or "Core").
+	Kind string `json:"kind"`
+	// Old and New are the index of the Element before and after Normalize:
+	// the logical index for Caches, or the OS index for Processing nodes.
+	Old uint32 `json:"old"`
+	New uint32 `json:"new"`
+}
+
+// String returns the string representation of the Remapping.
+func (r Remapping) String() string {
+	return fmt.Sprintf("node %d (%s): %d -> %d", r.ID, r.Kind, r.Old, r.New)
+}
+
+// Normalize fixes the indices of the Elements of the Topology in place, as
+// they may be left inconsistent by partial collections or by mixing the
+// output of different versions of collectors, and returns the list of the
+// indices that it changed, in pre-order, or a non-nil error value if the Tree
+// is structurally invalid (see Tree.Validate).
+//
+// The logical indices of the Caches of each level are renumbered densely, from
+// 0, in pre-order, which removes both gaps and duplicates. The OS indices of
+// Processing nodes, on the other hand, identify the hardware (e.g., in
+// cpusets), so gaps in them are legitimate (e.g., offline CPUs) and are kept;
+// only duplicates are fixed, by assigning the next unused OS indices to all
+// but the first Element that uses each of them, where the OS indices of Cores
+// are only required to be unique within their Package.
+//
+// Normalize invalidates the indexes of the Tree (see InvalidateIndexes).
+func (t *Topology) Normalize() ([]Remapping, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	if err := validateStructure(t.Nodes, func(id NodeID, err error) error {
+		return t.nodeError(id, nil, err)
+	}); err != nil {
+		return nil, err
+	}
+	for id := range t.Nodes {
+		if data := t.Nodes[id].Data; nil == data || (!data.IsRoot() && !data.IsProcessing() && !data.IsCache() && !data.IsDevice()) {
+			return nil, t.nodeError(NodeID(id), nil, fmt.Errorf("%w: malformed element", ErrInvalidElement))
+		}
+	}
+	defer t.InvalidateIndexes()
+
+	type processingKey struct {
+		kind ProcessingKind
+		// pkg is the NodeID of the enclosing Package, for Cores only.
+		pkg NodeID
+	}
+	// used holds the OS indices in use per kind (and Package, for Cores),
+	// and next the smallest candidate for the next unused one.
+	used := make(map[processingKey]map[uint32]bool)
+	next := make(map[processingKey]uint32)
+	keyOf := make([]processingKey, len(t.Nodes))
+	pkg := make([]NodeID, len(t.Nodes))
+	for id := range t.Nodes {
+		data := t.Nodes[id].Data
+		if data.IsProcessing() && data.Kind == Package {
+			pkg[id] = NodeID(id)
+		}
+		for _, child := range t.Nodes[id].Children {
+			pkg[child] = pkg[id]
+		}
+		if !data.IsProcessing() {
+			continue
+		}
+		key := processingKey{kind: data.Kind}
+		if data.Kind == Core {
+			key.pkg = pkg[id]
+		}
+		keyOf[id] = key
+		if nil == used[key] {
+			used[key] = make(map[uint32]bool)
+		}
+	}
+
+	var (
+		remappings []Remapping
+		cacheLI    [L5 + 1]uint32
+		duplicates []NodeID
+	)
+	for id := range t.Nodes {
+		data := t.Nodes[id].Data
+		switch {
+		case data.IsCache():
+			if data.Level > L5 {
+				continue
+			}
+			if li := cacheLI[data.Level]; data.LogicalIndex != li {
+				remappings = append(remappings, Remapping{
+					ID: NodeID(id), Kind: elementKind(data), Old: data.LogicalIndex, New: li,
+				})
+				data.LogicalIndex = li
+			}
+			cacheLI[data.Level]++
+		case data.IsProcessing():
+			if used[keyOf[id]][data.ID] {
+				duplicates = append(duplicates, NodeID(id))
+				continue
+			}
+			used[keyOf[id]][data.ID] = true
+		}
+	}
+	// Duplicates are only fixed after all OS indices in use are known, so
+	// that the new ones do not collide with any Element that comes later.
+	for _, id := range duplicates {
+		data, key := t.Nodes[id].Data, keyOf[id]
+		newID := next[key]
+		for used[key][newID] {
+			newID++
+		}
+		used[key][newID], next[key] = true, newID+1
+		remappings = append(remappings, Remapping{ID: id, Kind: elementKind(data), Old: data.ID, New: newID})
+		data.ID = newID
+	}
+	sort.Slice(remappings, func(i, j int) bool { return remappings[i].ID < remappings[j].ID })
+	return remappings, nil
+}
@@ -0,0 +1,70 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 1, 2, 2)}
+	l3s, l2s, threads := topo.L3Caches(), topo.L2Caches(), topo.Threads()
+	// A gap among the L3s, a duplicate L2 and a duplicate thread.
+	topo.Nodes[l3s[1]].Data.LogicalIndex = 5
+	topo.Nodes[l2s[3]].Data.LogicalIndex = 0
+	topo.Nodes[threads[6]].Data.ID = topo.Nodes[threads[1]].Data.ID
+	// A legitimate gap among the threads (e.g., an offline CPU).
+	topo.Nodes[threads[7]].Data.ID = 42
+	if err := topo.Validate(); !errors.Is(err, ErrDuplicateID) {
+		t.Fatalf("got %v; expected ErrDuplicateID", err)
+	}
+
+	remappings, err := topo.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = topo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := []Remapping{
+		{ID: l3s[1], Kind: "L3", Old: 5, New: 1},
+		{ID: l2s[3], Kind: "L2", Old: 0, New: 3},
+		{ID: threads[6], Kind: "Thread", Old: 1, New: 6},
+	}
+	if len(remappings) != len(want) {
+		t.Fatalf("got remappings %v; expected %v", remappings, want)
+	}
+	for i := range want {
+		if remappings[i] != want[i] {
+			t.Errorf("got %v; expected %v", remappings[i], want[i])
+		}
+	}
+	if id := topo.Nodes[threads[7]].Data.ID; id != 42 {
+		t.Errorf("got OS index %d for the last thread; expected 42 to be kept", id)
+	}
+
+	// Normalizing again changes nothing.
+	if remappings, err = topo.Normalize(); err != nil || len(remappings) != 0 {
+		t.Errorf("got %v, %v; expected no remappings", remappings, err)
+	}
+
+	topo.Nodes[1].Children = append(topo.Nodes[1].Children, 1)
+	if _, err = topo.Normalize(); !errors.Is(err, ErrCycle) {
+		t.Errorf("got %v; expected ErrCycle", err)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "fmt"
+
+// DefaultImbalanceThreshold is the default difference between the highest and
+// the lowest utilization of the NUMA nodes of a Topology above which they are
+// considered imbalanced (see Topology.RecommendNUMANode).
+const DefaultImbalanceThreshold = 0.25
+
+// NUMAUsage tallies the resources of a NUMA node that are already allocated,
+// as tracked by the caller (e.g., a kubelet hook or a node agent).
+type NUMAUsage struct {
+	// Threads is the number of allocated hardware threads.
+	Threads int `json:"threads"`
+	// Memory is the allocated memory, in bytes.
+	Memory uint64 `json:"memory"`
+}
+
+// NUMARequest describes the resources that a new workload requires from a
+// single NUMA node (see Topology.RecommendNUMANode).
+type NUMARequest struct {
+	// Threads is the number of hardware threads.
+	Threads int `json:"threads"`
+	// Memory is the memory, in bytes; it is not taken into account for
+	// NUMA nodes whose memory attributes are unknown.
+	Memory uint64 `json:"memory"`
+	// ImbalanceThreshold overrides DefaultImbalanceThreshold, if positive.
+	ImbalanceThreshold float64 `json:"imbalance_threshold,omitempty"`
+}
+
+// NUMARecommendation is the outcome of Topology.RecommendNUMANode.
+type NUMARecommendation struct {
+	// NUMANode is the NodeID of the recommended NUMA node.
+	NUMANode NodeID `json:"numa_node"`
+	// Utilization maps the NodeIDs of all NUMA nodes to their current
+	// utilization, from 0 to 1 (i.e., the highest of the fractions of
+	// their hardware threads and memory that are allocated).
+	Utilization map[NodeID]float64 `json:"utilization"`
+	// Imbalanced is true if the highest and the lowest Utilization differ
+	// by more than the imbalance threshold.
+	Imbalanced bool `json:"imbalanced"`
+}
+
+// RecommendNUMANode recommends the NUMA node of the Topology that a new
+// workload with the provided requirements should be placed on, given the
+// resources of each NUMA node that are already allocated (indexed by the
+// NodeIDs of the NUMA nodes; missing ones are considered idle), or returns a
+// non-nil error value if no NUMA node can fit the workload.
+//
+// The recommended NUMA node is the one whose utilization would be the lowest
+// after placing the workload on it, among those with enough free hardware
+// threads and memory; ties are broken in favor of the lowest NodeID. The
+// capacity of each NUMA node consists of the hardware threads in its subtree
+// and the memory in its attributes, if known.
+func (t *Topology) RecommendNUMANode(usage map[NodeID]NUMAUsage, req NUMARequest) (*NUMARecommendation, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	if req.Threads < 0 {
+		return nil, fmt.Errorf("invalid number of threads: %d", req.Threads)
+	}
+	threshold := req.ImbalanceThreshold
+	if threshold <= 0 {
+		threshold = DefaultImbalanceThreshold
+	}
+	numaIDs := t.NUMANodes()
+	if len(numaIDs) == 0 {
+		return nil, fmt.Errorf("Topology contains no NUMA nodes")
+	}
+	for numaID := range usage {
+		if data, err := t.Get(numaID); err != nil {
+			return nil, err
+		} else if !data.IsProcessing() || data.Kind != NUMANode {
+			return nil, fmt.Errorf("element %d is not a NUMA node", numaID)
+		}
+	}
+
+	// utilization returns the utilization of a NUMA node with the provided
+	// capacity and allocated resources.
+	utilization := func(threads int, memory *MemoryAttributes, used NUMAUsage) float64 {
+		var ret float64
+		if threads > 0 {
+			ret = float64(used.Threads) / float64(threads)
+		}
+		if nil != memory && memory.Bytes > 0 {
+			if mem := float64(used.Memory) / float64(memory.Bytes); mem > ret {
+				ret = mem
+			}
+		}
+		return ret
+	}
+
+	rec := &NUMARecommendation{Utilization: make(map[NodeID]float64, len(numaIDs))}
+	lowest, highest, best := 0.0, 0.0, -1.0
+	for i, numaID := range numaIDs {
+		threads := 0
+		start, end, err := t.SubtreeRange(numaID)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range t.Nodes[start:end] {
+			if node.Data.IsProcessing() && node.Data.Kind == Thread {
+				threads++
+			}
+		}
+		memory, used := t.Nodes[numaID].Data.Memory, usage[numaID]
+		current := utilization(threads, memory, used)
+		rec.Utilization[numaID] = current
+		if i == 0 || current < lowest {
+			lowest = current
+		}
+		if i == 0 || current > highest {
+			highest = current
+		}
+
+		if used.Threads+req.Threads > threads ||
+			(nil != memory && used.Memory+req.Memory > memory.Bytes) {
+			continue
+		}
+		used.Threads += req.Threads
+		used.Memory += req.Memory
+		if after := utilization(threads, memory, used); best < 0 || after < best {
+			best = after
+			rec.NUMANode = numaID
+		}
+	}
+	rec.Imbalanced = highest-lowest > threshold
+	if best < 0 {
+		return nil, fmt.Errorf("no NUMA node can fit %d threads and %dB of memory", req.Threads, req.Memory)
+	}
+	return rec, nil
+}
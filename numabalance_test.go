@@ -0,0 +1,83 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "testing"
+
+func TestRecommendNUMANode(t *testing.T) {
+	// 4 NUMA nodes with 4 hardware threads and 16GiB of memory each.
+	topo, err := FromSpec(TopologySpec{Packages: 2, NUMANodesPerPackage: 2, Cores: 2, ThreadsPerCore: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := topo.NUMANodes()
+	for _, numaID := range n {
+		topo.Nodes[numaID].Data.Memory = &MemoryAttributes{Bytes: 16 << 30}
+	}
+
+	for _, tc := range []struct {
+		name       string
+		usage      map[NodeID]NUMAUsage
+		req        NUMARequest
+		want       NodeID
+		imbalanced bool
+	}{
+		{"idle", nil, NUMARequest{Threads: 2, Memory: 4 << 30}, n[0], false},
+		{
+			"least utilized",
+			map[NodeID]NUMAUsage{n[0]: {Threads: 3}, n[1]: {Threads: 1}, n[2]: {Threads: 1, Memory: 8 << 30}, n[3]: {Threads: 2}},
+			NUMARequest{Threads: 1},
+			n[1],
+			true,
+		},
+		{
+			"memory-bound",
+			map[NodeID]NUMAUsage{n[0]: {Memory: 14 << 30}, n[1]: {Threads: 2, Memory: 2 << 30}, n[2]: {Threads: 1, Memory: 12 << 30}, n[3]: {Threads: 3}},
+			NUMARequest{Threads: 1, Memory: 3 << 30},
+			n[1],
+			true,
+		},
+		{
+			"custom threshold",
+			map[NodeID]NUMAUsage{n[0]: {Threads: 2}, n[1]: {Threads: 1}, n[2]: {Threads: 1}, n[3]: {Threads: 1}},
+			NUMARequest{Threads: 1, ImbalanceThreshold: 0.5},
+			n[1],
+			false,
+		},
+	} {
+		rec, err := topo.RecommendNUMANode(tc.usage, tc.req)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if rec.NUMANode != tc.want || rec.Imbalanced != tc.imbalanced {
+			t.Errorf("%s: got NUMA node %d (imbalanced: %t); expected %d (imbalanced: %t)",
+				tc.name, rec.NUMANode, rec.Imbalanced, tc.want, tc.imbalanced)
+		}
+		if len(rec.Utilization) != len(n) {
+			t.Errorf("%s: got utilization %v", tc.name, rec.Utilization)
+		}
+	}
+
+	full := map[NodeID]NUMAUsage{n[0]: {Threads: 4}, n[1]: {Threads: 4}, n[2]: {Threads: 1, Memory: 16 << 30}, n[3]: {Threads: 3}}
+	if _, err = topo.RecommendNUMANode(full, NUMARequest{Threads: 2, Memory: 1 << 20}); err == nil {
+		t.Errorf("expected an error when no NUMA node fits")
+	}
+	if _, err = topo.RecommendNUMANode(map[NodeID]NUMAUsage{topo.Threads()[0]: {}}, NUMARequest{}); err == nil {
+		t.Errorf("expected an error for usage of a thread")
+	}
+}
@@ -0,0 +1,161 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ResidentMemory reports the resident memory of a process per NUMA node of a
+// Topology (see Topology.ReadNUMAMaps).
+type ResidentMemory struct {
+	// NUMANodes maps the NodeIDs of NUMA node elements to the resident
+	// memory on them, in bytes.
+	NUMANodes map[NodeID]uint64 `json:"numa_nodes"`
+	// Unknown maps the OS indices of NUMA nodes that are missing from the
+	// Topology (e.g., memory-only ones) to the resident memory on them, in
+	// bytes.
+	Unknown map[uint32]uint64 `json:"unknown,omitempty"`
+}
+
+// Total returns the resident memory on all NUMA nodes, in bytes.
+func (rm *ResidentMemory) Total() uint64 {
+	var total uint64
+	for _, bytes := range rm.NUMANodes {
+		total += bytes
+	}
+	for _, bytes := range rm.Unknown {
+		total += bytes
+	}
+	return total
+}
+
+// Fraction returns the fraction of the resident memory that is on the NUMA
+// node elements stored under the provided NodeIDs (e.g., those that the
+// threads of the process are placed on), from 0 to 1; it returns 0 if there
+// is no resident memory at all.
+func (rm *ResidentMemory) Fraction(numaIDs []NodeID) float64 {
+	total := rm.Total()
+	if total == 0 {
+		return 0
+	}
+	var local uint64
+	seen := make(map[NodeID]bool, len(numaIDs))
+	for _, numaID := range numaIDs {
+		if !seen[numaID] {
+			seen[numaID] = true
+			local += rm.NUMANodes[numaID]
+		}
+	}
+	return float64(local) / float64(total)
+}
+
+// ReadNUMAMaps returns the resident memory of the process with the provided
+// PID per NUMA node of the Topology, as exposed by the Linux kernel through
+// /proc/<pid>/numa_maps in the provided file system, which is expected to be
+// rooted at the mount point of procfs (i.e., /proc), or a non-nil error value
+// in case of failure. If fsys is nil, /proc is used; if pid is not positive,
+// the calling process is inspected.
+func (t *Topology) ReadNUMAMaps(fsys fs.FS, pid int) (*ResidentMemory, error) {
+	if nil == fsys {
+		fsys = os.DirFS("/proc")
+	}
+	name := "self/numa_maps"
+	if pid > 0 {
+		name = fmt.Sprintf("%d/numa_maps", pid)
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	perOSIndex, err := ParseNUMAMaps(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", name, err)
+	}
+
+	numaOf := make(map[uint32]NodeID)
+	for _, numaID := range t.NUMANodes() {
+		numaOf[t.Nodes[numaID].Data.ID] = numaID
+	}
+	rm := &ResidentMemory{NUMANodes: make(map[NodeID]uint64, len(perOSIndex))}
+	for osID, bytes := range perOSIndex {
+		if numaID, ok := numaOf[osID]; ok {
+			rm.NUMANodes[numaID] = bytes
+			continue
+		}
+		if nil == rm.Unknown {
+			rm.Unknown = make(map[uint32]uint64)
+		}
+		rm.Unknown[osID] = bytes
+	}
+	return rm, nil
+}
+
+// ParseNUMAMaps returns the resident memory (in bytes) per OS index of NUMA
+// node, as found in the provided contents of a /proc/<pid>/numa_maps file, or
+// a non-nil error value in case of failure.
+//
+// The "N<node>=<pages>" fields of each mapping are weighed by the size of its
+// pages (i.e., its "kernelpagesize_kB" field, or 4KiB in its absence), so that
+// huge pages are accounted for correctly.
+func ParseNUMAMaps(r io.Reader) (map[uint32]uint64, error) {
+	ret := make(map[uint32]uint64)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		pageSize := uint64(4 << 10)
+		pages := make(map[uint32]uint64)
+		for _, field := range strings.Fields(scanner.Text()) {
+			key, val, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch {
+			case key == "kernelpagesize_kB":
+				kb, err := strconv.ParseUint(val, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid page size '%s': %v", line, val, err)
+				}
+				pageSize = kb << 10
+			case len(key) > 1 && key[0] == 'N':
+				osID, err := strconv.ParseUint(key[1:], 10, 32)
+				if err != nil {
+					// Not a NUMA node field after all.
+					continue
+				}
+				n, err := strconv.ParseUint(val, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid number of pages '%s': %v", line, val, err)
+				}
+				pages[uint32(osID)] += n
+			}
+		}
+		for osID, n := range pages {
+			ret[osID] += n * pageSize
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
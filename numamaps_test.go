@@ -0,0 +1,68 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadNUMAMaps(t *testing.T) {
+	const numaMaps = `55d1c5c4a000 default file=/usr/bin/cat mapped=4 mapmax=2 N0=4 kernelpagesize_kB=4
+55d1c6a5d000 default heap anon=33 dirty=33 active=0 N0=30 N1=3 kernelpagesize_kB=4
+7f0e4c000000 bind:1 anon=1 dirty=1 N1=1 kernelpagesize_kB=2048
+7f0e4e200000 default
+7ffc0e1f2000 default stack anon=3 dirty=3 N2=3 kernelpagesize_kB=4
+`
+	perOSIndex, err := ParseNUMAMaps(strings.NewReader(numaMaps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint32]uint64{0: 34 << 12, 1: 3<<12 + 2<<20, 2: 3 << 12}
+	if !reflect.DeepEqual(perOSIndex, want) {
+		t.Errorf("ParseNUMAMaps() = %v; expected %v", perOSIndex, want)
+	}
+	if _, err = ParseNUMAMaps(strings.NewReader("7f0e4c000000 default N0=x\n")); err == nil {
+		t.Errorf("expected an error for a malformed number of pages")
+	}
+
+	topo := &Topology{syntheticTree(1, 2, 1, 1)}
+	numaIDs := topo.NUMANodes()
+	procfs := fstest.MapFS{"42/numa_maps": {Data: []byte(numaMaps)}}
+	rm, err := topo.ReadNUMAMaps(procfs, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[NodeID]uint64{numaIDs[0]: 34 << 12, numaIDs[1]: 3<<12 + 2<<20}; !reflect.DeepEqual(rm.NUMANodes, want) {
+		t.Errorf("got %v per NUMA node; expected %v", rm.NUMANodes, want)
+	}
+	if want := map[uint32]uint64{2: 3 << 12}; !reflect.DeepEqual(rm.Unknown, want) {
+		t.Errorf("got %v for unknown NUMA nodes; expected %v", rm.Unknown, want)
+	}
+	if got, want := rm.Fraction(numaIDs[:1]), float64(34<<12)/float64(rm.Total()); math.Abs(got-want) > 1e-9 {
+		t.Errorf("Fraction() = %f; expected %f", got, want)
+	}
+	if got := (&ResidentMemory{}).Fraction(numaIDs); got != 0 {
+		t.Errorf("Fraction() = %f without resident memory; expected 0", got)
+	}
+	if _, err = topo.ReadNUMAMaps(procfs, 43); err == nil {
+		t.Errorf("expected an error for a missing process")
+	}
+}
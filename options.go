@@ -0,0 +1,218 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// Option configures the behavior of the query, render and marshal entry points
+// of a Topology that accept Options (i.e., Descendants, Render and Marshal),
+// so that their behavior may evolve without breaking their signatures. Each
+// entry point documents the Options that it honors, and ignores the rest.
+type Option func(*options)
+
+// options holds the configuration that a list of Options results in.
+type options struct {
+	sorted        bool
+	includeCaches bool
+	maxDepth      int
+	indent        bool
+}
+
+// resolveOptions returns the configuration that the provided Options result
+// in, starting from the defaults.
+func resolveOptions(opts []Option) options {
+	o := options{includeCaches: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithSorted orders Elements by their kind (from the outermost to the
+// innermost; i.e., Packages, NUMA nodes, caches from L5 to L1, Cores and
+// Threads), and then by their OS or logical index, rather than by NodeID.
+func WithSorted() Option {
+	return func(o *options) { o.sorted = true }
+}
+
+// WithIncludeCaches sets whether cache elements are included (the default) or
+// omitted; omitted caches are collapsed, i.e., their descendants take their
+// place, one level up.
+func WithIncludeCaches(include bool) Option {
+	return func(o *options) { o.includeCaches = include }
+}
+
+// WithMaxDepth limits the Elements to those at most n levels below the
+// starting one (i.e., the root Element, unless stated otherwise), after any
+// caches have been omitted; it has no effect if n is not positive.
+func WithMaxDepth(n int) Option {
+	return func(o *options) { o.maxDepth = n }
+}
+
+// WithIndent indents marshalled output, for readability.
+func WithIndent() Option {
+	return func(o *options) { o.indent = true }
+}
+
+// Descendants returns the NodeIDs of the descendants of the element stored in
+// the Topology under the provided NodeID, in pre-order, or a non-nil error
+// value in case of failure.
+//
+// It honors WithSorted, WithIncludeCaches and WithMaxDepth (relative to the
+// provided element).
+func (t *Topology) Descendants(id NodeID, opts ...Option) ([]NodeID, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	if int(id) >= len(t.Nodes) {
+		return nil, ErrInvalidNodeID{ID: id}
+	}
+	o := resolveOptions(opts)
+
+	ret := make([]NodeID, 0)
+	t.walk(id, o, func(id NodeID, depth int) { ret = append(ret, id) })
+	if o.sorted {
+		sort.SliceStable(ret, func(i, j int) bool { return lessElements(t.Nodes[ret[i]].Data, t.Nodes[ret[j]].Data) })
+	}
+	return ret, nil
+}
+
+// Render writes a textual representation of the Topology to the provided
+// io.Writer, like WriteTree does, or returns a non-nil error value in case of
+// failure.
+//
+// It honors WithSorted (which orders the children of each Element),
+// WithIncludeCaches and WithMaxDepth.
+func (t *Topology) Render(w io.Writer, opts ...Option) error {
+	if nil == t || nil == t.Tree {
+		return ErrNilTree
+	}
+	if t.IsEmpty() {
+		return ErrEmptyTree
+	}
+	return t.reshape(resolveOptions(opts)).WriteTree(w, RenderOptions{})
+}
+
+// Marshal returns the Topology marshalled in JSON, or a non-nil error value in
+// case of failure.
+//
+// It honors WithSorted (which orders the children of each Element, and hence
+// the NodeIDs), WithIncludeCaches, WithMaxDepth and WithIndent. Without any
+// Options, it is equivalent to json.Marshal.
+func (t *Topology) Marshal(opts ...Option) ([]byte, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	if t.IsEmpty() {
+		return nil, ErrEmptyTree
+	}
+	o := resolveOptions(opts)
+	reshaped := t.reshape(o)
+	if o.indent {
+		return json.MarshalIndent(reshaped, "", "  ")
+	}
+	return json.Marshal(reshaped)
+}
+
+// walk calls visit for each descendant of the element stored under the
+// provided NodeID that the provided options select, in pre-order (or in the
+// order of lessElements among siblings, if sorted), along with its depth
+// relative to the element.
+func (t *Topology) walk(id NodeID, o options, visit func(id NodeID, depth int)) {
+	var rec func(id NodeID, depth int)
+	rec = func(id NodeID, depth int) {
+		children := t.Nodes[id].Children
+		if o.sorted {
+			children = append([]NodeID(nil), children...)
+			sort.SliceStable(children, func(i, j int) bool {
+				return lessElements(t.Nodes[children[i]].Data, t.Nodes[children[j]].Data)
+			})
+		}
+		for _, child := range children {
+			if !o.includeCaches && t.Nodes[child].Data.IsCache() {
+				rec(child, depth)
+				continue
+			}
+			if o.maxDepth > 0 && depth+1 > o.maxDepth {
+				continue
+			}
+			visit(child, depth+1)
+			rec(child, depth+1)
+		}
+	}
+	rec(id, 0)
+}
+
+// reshape returns a new Topology that only contains the Elements of this one
+// that the provided options select, shared with it, in pre-order.
+func (t *Topology) reshape(o options) *Topology {
+	if !o.sorted && o.includeCaches && o.maxDepth <= 0 {
+		return t
+	}
+	nodes := []TreeNode{{Data: t.Nodes[0].Data}}
+	// path holds the new NodeIDs of the latest Element at each depth.
+	path := []NodeID{0}
+	t.walk(0, o, func(id NodeID, depth int) {
+		newID := NodeID(len(nodes))
+		nodes = append(nodes, TreeNode{Data: t.Nodes[id].Data})
+		path = append(path[:depth], newID)
+		parent := path[depth-1]
+		nodes[parent].Children = append(nodes[parent].Children, newID)
+	})
+	return &Topology{NewTree(nodes)}
+}
+
+// elementRank returns the rank of the provided Element in the order of
+// WithSorted.
+func elementRank(e *Element) (rank int, index uint32) {
+	switch {
+	case e.IsRoot():
+		return 0, 0
+	case e.IsCache():
+		return 3 + int(L5-e.Level), e.LogicalIndex
+	case e.IsProcessing():
+		switch e.Kind {
+		case Package:
+			return 1, e.ID
+		case NUMANode:
+			return 2, e.ID
+		case Core:
+			return 4 + int(L5-L1), e.ID
+		case Thread:
+			return 5 + int(L5-L1), e.ID
+		}
+	case e.IsDevice():
+		return 6 + int(L5-L1), 0
+	}
+	// Invalid Elements come last.
+	return 7 + int(L5-L1), 0
+}
+
+// lessElements reports whether Element a precedes Element b in the order of
+// WithSorted.
+func lessElements(a, b *Element) bool {
+	rankA, indexA := elementRank(a)
+	rankB, indexB := elementRank(b)
+	if rankA != rankB {
+		return rankA < rankB
+	}
+	return indexA < indexB
+}
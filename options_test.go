@@ -0,0 +1,90 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 1, 2, 2)}
+
+	all, err := topo.Descendants(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != topo.Size()-1 || all[0] != 1 || all[len(all)-1] != NodeID(topo.Size()-1) {
+		t.Errorf("got descendants %v; expected all elements but the root, in pre-order", all)
+	}
+	packages := topo.Packages()
+	shallow, err := topo.Descendants(packages[1], WithIncludeCaches(false), WithMaxDepth(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NUMA node, and then its two cores (with their caches collapsed).
+	if len(shallow) != 3 || topo.Nodes[shallow[0]].Data.Kind != NUMANode || topo.Nodes[shallow[2]].Data.Kind != Core {
+		t.Errorf("got descendants %v of the second Package", shallow)
+	}
+	sorted, err := topo.Descendants(0, WithSorted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(sorted); i++ {
+		if lessElements(topo.Nodes[sorted[i]].Data, topo.Nodes[sorted[i-1]].Data) {
+			t.Errorf("got %v before %v", topo.Nodes[sorted[i-1]].Data, topo.Nodes[sorted[i]].Data)
+		}
+	}
+	if first, last := topo.Nodes[sorted[0]].Data, topo.Nodes[sorted[len(sorted)-1]].Data; first.Kind != Package || first.ID != 0 || last.Kind != Thread {
+		t.Errorf("got %v first and %v last", first, last)
+	}
+	if _, err = topo.Descendants(NodeID(topo.Size())); err == nil {
+		t.Errorf("expected an error for an invalid NodeID")
+	}
+
+	// Without Options, Marshal is equivalent to json.Marshal.
+	data, err := topo.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, _ := json.Marshal(topo); !bytes.Equal(data, plain) {
+		t.Errorf("Marshal without Options differs from json.Marshal")
+	}
+	if data, err = topo.Marshal(WithIncludeCaches(false), WithIndent()); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Topology
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal: %v\n%s", err, data)
+	}
+	if err = decoded.Validate(); err != nil || len(decoded.L3Caches()) != 0 || len(decoded.Threads()) != 8 {
+		t.Errorf("got an invalid Topology (%v) or one with caches", err)
+	}
+	if !bytes.Contains(data, []byte("\n  ")) {
+		t.Errorf("WithIndent did not indent the output")
+	}
+
+	var sb strings.Builder
+	if err = topo.Render(&sb, WithIncludeCaches(false), WithMaxDepth(1)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sb.String(), "Machine\n  Package(0)\n  Package(1)\n"; got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
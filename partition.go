@@ -0,0 +1,73 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "fmt"
+
+// PartitionByCache partitions the Elements stored under the provided NodeIDs
+// (e.g., hardware threads or Cores), or all hardware threads of the Topology
+// if ids is empty, by the Cache of the provided level that each of them maps
+// to (i.e., its closest ancestor of that level, or itself), and returns the
+// NodeIDs of each partition, in the order they were provided, indexed by the
+// NodeID of the Cache, or a non-nil error value in case of failure.
+//
+// This allows grouping by the level that is actually shared on each machine
+// (e.g., L2 on many ARM machines, rather than L3). It fails for any Element
+// that does not map to a Cache of the level (e.g., a Core on a machine without
+// such Caches, or a NUMA node that contains several of them).
+func (t *Topology) PartitionByCache(level CacheLevel, ids []NodeID) (map[NodeID][]NodeID, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	if level > L5 {
+		return nil, fmt.Errorf("invalid cache level %d", level)
+	}
+	if len(ids) == 0 {
+		ids = t.Threads()
+	}
+	ret := make(map[NodeID][]NodeID)
+	for _, id := range ids {
+		cacheID, err := t.cacheOf(id, level)
+		if err != nil {
+			return nil, err
+		}
+		ret[cacheID] = append(ret[cacheID], id)
+	}
+	return ret, nil
+}
+
+// cacheOf returns the NodeID of the Cache of the provided level that the
+// element stored under the provided NodeID maps to (see PartitionByCache).
+func (t *Topology) cacheOf(id NodeID, level CacheLevel) (NodeID, error) {
+	data, err := t.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	if data.IsCache() && data.Level == level {
+		return id, nil
+	}
+	ancestorIDs, err := t.AncestorIDs(id)
+	if err != nil {
+		return 0, err
+	}
+	for _, ancestorID := range ancestorIDs {
+		if ancestor := t.Nodes[ancestorID].Data; ancestor.IsCache() && ancestor.Level == level {
+			return ancestorID, nil
+		}
+	}
+	return 0, fmt.Errorf("element %d (%s) is not in the subtree of any %s cache", id, elementKind(data), level)
+}
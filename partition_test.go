@@ -0,0 +1,70 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPartitionByCache(t *testing.T) {
+	// 2 clusters of 4 Cores, each sharing an L2, as on many ARM machines.
+	topo, err := FromSpec(TopologySpec{
+		Packages: 1, NUMANodesPerPackage: 1, L3PerNUMANode: 2, Cores: 4, ThreadsPerCore: 1,
+		L3Size: 2 << 20, L1Size: 64 << 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pretend that the shared level is L2, by relabelling the L3s.
+	for _, id := range topo.L3Caches() {
+		topo.Nodes[id].Data.Level = L2
+	}
+	topo.InvalidateIndexes()
+
+	partitions, err := topo.PartitionByCache(L2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partitions) != 2 {
+		t.Fatalf("got %d partitions; expected 2", len(partitions))
+	}
+	threads := topo.Threads()
+	for i, l2 := range topo.L2Caches() {
+		if want := threads[4*i : 4*i+4]; !reflect.DeepEqual(partitions[l2], want) {
+			t.Errorf("got %v under L2 %d; expected %v", partitions[l2], l2, want)
+		}
+	}
+
+	// Cores work too, and each L1 maps to itself.
+	cores := topo.Cores()
+	if partitions, err = topo.PartitionByCache(L1, cores); err != nil || len(partitions) != len(cores) {
+		t.Errorf("got %v, %v; expected a partition per Core", partitions, err)
+	}
+	l1s := topo.L1Caches()
+	if partitions, err = topo.PartitionByCache(L1, l1s[:1]); err != nil || !reflect.DeepEqual(partitions[l1s[0]], l1s[:1]) {
+		t.Errorf("got %v, %v; expected the L1 to map to itself", partitions, err)
+	}
+
+	// The NUMA node is above the L2s, and there are no L3s anymore.
+	if _, err = topo.PartitionByCache(L2, topo.NUMANodes()); err == nil {
+		t.Errorf("expected an error for a NUMA node")
+	}
+	if _, err = topo.PartitionByCache(L3, nil); err == nil {
+		t.Errorf("expected an error for a missing cache level")
+	}
+}
@@ -0,0 +1,191 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+// Predicate reports whether the Element stored under the provided NodeID of a
+// Topology matches a condition (see Topology.Find).
+type Predicate func(id NodeID, e *Element) bool
+
+// Find returns the NodeIDs of all Elements of the Topology that match the
+// provided Predicate, in ascending order.
+//
+// Predicates compose through And, Or and Not, so that complex queries become a
+// single expression; e.g., for the L2 caches larger than 1MiB under the
+// Package stored under pkgID:
+//
+//	topo.Find(And(IsCacheLevel(L2), CacheLargerThan(1<<20), topo.UnderNode(pkgID)))
+func (t *Topology) Find(pred Predicate) []NodeID {
+	ret := make([]NodeID, 0)
+	if nil == t || nil == t.Tree {
+		return ret
+	}
+	for id, node := range t.Nodes {
+		if pred(NodeID(id), node.Data) {
+			ret = append(ret, NodeID(id))
+		}
+	}
+	return ret
+}
+
+// And returns a Predicate that matches the Elements that all of the provided
+// Predicates match, evaluating them in order until one of them does not.
+func And(preds ...Predicate) Predicate {
+	return func(id NodeID, e *Element) bool {
+		for _, pred := range preds {
+			if !pred(id, e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a Predicate that matches the Elements that any of the provided
+// Predicates matches, evaluating them in order until one of them does.
+func Or(preds ...Predicate) Predicate {
+	return func(id NodeID, e *Element) bool {
+		for _, pred := range preds {
+			if pred(id, e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a Predicate that matches the Elements that the provided one does
+// not.
+func Not(pred Predicate) Predicate {
+	return func(id NodeID, e *Element) bool {
+		return !pred(id, e)
+	}
+}
+
+// IsKind returns a Predicate that matches the processing elements of any of
+// the provided kinds.
+func IsKind(kinds ...ProcessingKind) Predicate {
+	return func(_ NodeID, e *Element) bool {
+		if !e.IsProcessing() {
+			return false
+		}
+		for _, kind := range kinds {
+			if e.Kind == kind {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IsCacheLevel returns a Predicate that matches the cache elements of any of
+// the provided levels.
+func IsCacheLevel(levels ...CacheLevel) Predicate {
+	return func(_ NodeID, e *Element) bool {
+		if !e.IsCache() {
+			return false
+		}
+		for _, level := range levels {
+			if e.Level == level {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// CacheLargerThan returns a Predicate that matches the cache elements whose
+// size is larger than the provided number of bytes.
+func CacheLargerThan(size uint64) Predicate {
+	return func(_ NodeID, e *Element) bool {
+		return e.IsCache() && nil != e.Attributes && e.Attributes.Size > size
+	}
+}
+
+// HasOSIndex returns a Predicate that matches the processing elements with the
+// provided OS index.
+func HasOSIndex(osID uint32) Predicate {
+	return func(_ NodeID, e *Element) bool {
+		return e.IsProcessing() && e.ID == osID
+	}
+}
+
+// UnderNode returns a Predicate that matches the descendants of the element
+// stored in the Topology under the provided NodeID (but not the element
+// itself). It matches nothing if the NodeID is invalid.
+//
+// It relies on the pre-order layout of the Tree (see SubtreeRange), so that
+// each evaluation is O(1).
+func (t *Topology) UnderNode(id NodeID) Predicate {
+	start, end, err := t.SubtreeRange(id)
+	if err != nil {
+		return func(NodeID, *Element) bool { return false }
+	}
+	return func(desc NodeID, _ *Element) bool {
+		return desc > start && desc < end
+	}
+}
+
+// CachePredicate reports whether a Cache matches a condition (see
+// Topology.CachesWhere).
+type CachePredicate func(c *Cache) bool
+
+// CachesWhere returns the NodeIDs of all Caches of the Topology that match the
+// provided CachePredicate, in ascending order; e.g., for all caches of at least
+// 16MiB:
+//
+//	topo.CachesWhere(MinCacheSize(16 << 20))
+func (t *Topology) CachesWhere(pred CachePredicate) []NodeID {
+	return t.Find(pred.Predicate())
+}
+
+// Predicate returns a Predicate that matches the cache elements that the
+// CachePredicate matches, so that it can be composed with others (e.g., via
+// And) and passed to Topology.Find.
+func (pred CachePredicate) Predicate() Predicate {
+	return func(_ NodeID, e *Element) bool {
+		return e.IsCache() && pred(e.Cache)
+	}
+}
+
+// MinCacheSize returns a CachePredicate that matches the Caches whose size is
+// at least the provided number of bytes.
+func MinCacheSize(size uint64) CachePredicate {
+	return func(c *Cache) bool {
+		return nil != c.Attributes && c.Attributes.Size >= size
+	}
+}
+
+// HasAssociativity returns a CachePredicate that matches the Caches of the
+// provided associativity, in # ways (or FullyAssociative).
+func HasAssociativity(ways int32) CachePredicate {
+	return func(c *Cache) bool {
+		return nil != c.Attributes && c.Attributes.Associativity == ways
+	}
+}
+
+// AtCacheLevel returns a CachePredicate that matches the Caches of any of the
+// provided levels.
+func AtCacheLevel(levels ...CacheLevel) CachePredicate {
+	return func(c *Cache) bool {
+		for _, level := range levels {
+			if c.Level == level {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,92 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "testing"
+
+func TestFind(t *testing.T) {
+	topo, err := FromSpec(TopologySpec{
+		Packages: 2, NUMANodesPerPackage: 2, L3PerNUMANode: 1, Cores: 4, ThreadsPerCore: 2,
+		L3Size: 32 << 20, L2Size: 2 << 20, L1Size: 48 << 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg1 := topo.Find(And(IsKind(Package), HasOSIndex(1)))
+	if len(pkg1) != 1 {
+		t.Fatalf("got Packages %v; expected one", pkg1)
+	}
+
+	l2 := topo.Find(And(IsCacheLevel(L2), CacheLargerThan(1<<20), topo.UnderNode(pkg1[0])))
+	if len(l2) != 8 {
+		t.Errorf("got %d L2 caches under Package 1; expected 8", len(l2))
+	}
+	for i, id := range l2 {
+		if e := topo.Nodes[id].Data; !e.IsCache() || e.Level != L2 || e.LogicalIndex != uint32(8+i) {
+			t.Errorf("got %v", e)
+		}
+	}
+	if got := topo.Find(And(IsCacheLevel(L2), CacheLargerThan(2<<20))); len(got) != 0 {
+		t.Errorf("got %d L2 caches larger than their size", len(got))
+	}
+	if got := topo.Find(Or(IsKind(Package, NUMANode), IsCacheLevel(L3))); len(got) != 2+4+4 {
+		t.Errorf("got %d Packages, NUMA nodes and L3 caches; expected 10", len(got))
+	}
+	if got := topo.Find(And(IsKind(Thread), Not(topo.UnderNode(pkg1[0])))); len(got) != 16 {
+		t.Errorf("got %d threads outside of Package 1; expected 16", len(got))
+	}
+	if got := topo.Find(topo.UnderNode(NodeID(topo.Size()))); len(got) != 0 {
+		t.Errorf("got %d elements under an invalid NodeID", len(got))
+	}
+}
+
+func TestCachesWhere(t *testing.T) {
+	topo, err := FromSpec(TopologySpec{
+		Packages: 2, NUMANodesPerPackage: 2, L3PerNUMANode: 1, Cores: 4, ThreadsPerCore: 2,
+		L3Size: 32 << 20, L2Size: 2 << 20, L1Size: 48 << 10, Associativity: 8,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := topo.CachesWhere(MinCacheSize(16 << 20)); len(got) != 4 {
+		t.Errorf("got %d caches of at least 16MiB; expected 4", len(got))
+	}
+	if got := topo.CachesWhere(MinCacheSize(2 << 20)); len(got) != 4+16 {
+		t.Errorf("got %d caches of at least 2MiB; expected 20", len(got))
+	}
+	if got := topo.CachesWhere(AtCacheLevel(L1, L2)); len(got) != 32 {
+		t.Errorf("got %d L1 and L2 caches; expected 32", len(got))
+	}
+	if got := topo.CachesWhere(HasAssociativity(8)); len(got) != 4+16+16 {
+		t.Errorf("got %d 8-way caches; expected 36", len(got))
+	}
+	if got := topo.CachesWhere(HasAssociativity(FullyAssociative)); len(got) != 0 {
+		t.Errorf("got %d fully associative caches; expected none", len(got))
+	}
+
+	// CachePredicates compose with any other Predicates.
+	pkgs := topo.Packages()
+	got := topo.Find(And(MinCacheSize(1<<20).Predicate(), topo.UnderNode(pkgs[1])))
+	if len(got) != 2+8 {
+		t.Errorf("got %d caches of at least 1MiB under Package 1; expected 10", len(got))
+	}
+	for _, id := range got {
+		if id < pkgs[1] {
+			t.Errorf("got cache %d outside of Package 1", id)
+		}
+	}
+}
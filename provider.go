@@ -0,0 +1,301 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Provider is a backend that discovers the Topology of the local machine
+// (e.g., through sysfs or hwloc), so that backends are interchangeable and
+// fakes can be injected in tests.
+type Provider interface {
+	// Discover returns the Topology of the local machine, or a non-nil
+	// error value in case of failure.
+	Discover(ctx context.Context) (*Topology, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ctx context.Context) (*Topology, error)
+
+// Discover calls the ProviderFunc.
+func (f ProviderFunc) Discover(ctx context.Context) (*Topology, error) {
+	return f(ctx)
+}
+
+// StaticProvider returns a Provider that always discovers a deep copy of the
+// provided Topology, for tests.
+func StaticProvider(topo *Topology) Provider {
+	return ProviderFunc(func(ctx context.Context) (*Topology, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if nil == topo {
+			return nil, ErrNilTree
+		}
+		return topo.clone(), nil
+	})
+}
+
+// SysfsProvider discovers the Topology through sysfs (see DiscoverSysfs).
+type SysfsProvider struct {
+	// FS is rooted at the mount point of sysfs; if nil, /sys is used.
+	FS fs.FS
+}
+
+// Discover implements the Provider interface.
+func (p SysfsProvider) Discover(ctx context.Context) (*Topology, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fsys := p.FS
+	if nil == fsys {
+		fsys = os.DirFS("/sys")
+	}
+	return DiscoverSysfs(fsys)
+}
+
+// HwlocProvider discovers the Topology through hwloc's lstopo command, which
+// is expected to be installed (see ParseHwlocXML).
+type HwlocProvider struct {
+	// Command is the path of the lstopo command; if empty, "lstopo" is
+	// looked up in the PATH.
+	Command string
+}
+
+// Discover implements the Provider interface.
+func (p HwlocProvider) Discover(ctx context.Context) (*Topology, error) {
+	out, err := runProviderCommand(ctx, p.Command, "lstopo", "--of", "xml", "-")
+	if err != nil {
+		return nil, err
+	}
+	return ParseHwlocXML(bytes.NewReader(out))
+}
+
+// LscpuProvider discovers the Topology through util-linux's lscpu command,
+// which is expected to be installed (see ParseLscpu).
+type LscpuProvider struct {
+	// Command is the path of the lscpu command; if empty, "lscpu" is
+	// looked up in the PATH.
+	Command string
+}
+
+// Discover implements the Provider interface.
+func (p LscpuProvider) Discover(ctx context.Context) (*Topology, error) {
+	out, err := runProviderCommand(ctx, p.Command, "lscpu", "-p=CPU,CORE,SOCKET,NODE,CACHE")
+	if err != nil {
+		return nil, err
+	}
+	return ParseLscpu(bytes.NewReader(out))
+}
+
+// runProviderCommand runs the provided command (or the default one, if empty)
+// with the provided arguments, and returns its standard output.
+func runProviderCommand(ctx context.Context, command, defaultCommand string, args ...string) ([]byte, error) {
+	if command == "" {
+		command = defaultCommand
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", command, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", command, err)
+	}
+	return out, nil
+}
+
+// ParseLscpu returns the Topology parsed from the provided output of
+// `lscpu -p=CPU,CORE,SOCKET,NODE,CACHE`, or a non-nil error value if parsing
+// fails.
+//
+// Elements are nested as in DiscoverSysfs. Since lscpu does not report the
+// attributes of the caches in this format, they are all zero.
+func ParseLscpu(r io.Reader) (*Topology, error) {
+	var columns []string
+	objects := make(map[string]*sysfsObject)
+	add := func(key string, rank int, data *Element, cpu uint32) {
+		obj, ok := objects[key]
+		if !ok {
+			obj = &sysfsObject{rank: rank, data: data, cpus: new(big.Int)}
+			objects[key] = obj
+		}
+		obj.cpus.SetBit(obj.cpus, int(cpu), 1)
+	}
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		// The last comment line holds the names of the columns.
+		if strings.HasPrefix(line, "#") {
+			columns = strings.Split(strings.TrimSpace(strings.TrimPrefix(line, "#")), ",")
+			continue
+		}
+		if columns == nil {
+			return nil, fmt.Errorf("lscpu line %d: missing header", lineNum)
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != len(columns) {
+			return nil, fmt.Errorf("lscpu line %d: expected %d fields, found %d", lineNum, len(columns), len(fields))
+		}
+
+		values := make(map[string]uint32, len(fields))
+		for i, field := range fields {
+			if columns[i] == "" || field == "" {
+				continue
+			}
+			val, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("lscpu line %d: invalid %s '%s'", lineNum, columns[i], field)
+			}
+			values[columns[i]] = uint32(val)
+		}
+		cpu, ok := values["CPU"]
+		if !ok {
+			return nil, fmt.Errorf("lscpu line %d: missing CPU", lineNum)
+		}
+		pkgID := values["Socket"]
+		coreID, ok := values["Core"]
+		if !ok {
+			coreID = cpu
+		}
+		add(fmt.Sprintf("package:%d", pkgID), sysfsRankPackage,
+			&Element{Processing: &Processing{Kind: Package, ID: pkgID}}, cpu)
+		add(fmt.Sprintf("package:%d/core:%d", pkgID, coreID), sysfsRankCore,
+			&Element{Processing: &Processing{Kind: Core, ID: coreID}}, cpu)
+		add(fmt.Sprintf("thread:%d", cpu), sysfsRankThread,
+			&Element{Processing: &Processing{Kind: Thread, ID: cpu}}, cpu)
+		if nodeID, ok := values["Node"]; ok {
+			add(fmt.Sprintf("node%d", nodeID), sysfsRankNUMANode,
+				&Element{Processing: &Processing{Kind: NUMANode, ID: nodeID}}, cpu)
+		}
+		for _, column := range columns {
+			// Instruction caches are omitted, as in DiscoverSysfs.
+			id, ok := values[column]
+			if !ok || !strings.HasPrefix(column, "L") || strings.HasSuffix(column, "i") {
+				continue
+			}
+			level, err := ParseCacheLevel(strings.TrimSuffix(column, "d"))
+			if err != nil {
+				return nil, fmt.Errorf("lscpu line %d: %w", lineNum, err)
+			}
+			add(fmt.Sprintf("%s:%d", level, id), sysfsRankCache+int(L5-level),
+				&Element{Cache: &Cache{Level: level, Attributes: &CacheAttributes{}}}, cpu)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no CPUs found in lscpu output")
+	}
+	return buildSysfsTopology(objects)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{
+		"sysfs": SysfsProvider{},
+		"hwloc": HwlocProvider{},
+		"lscpu": LscpuProvider{},
+	}
+	// defaultProviders is the order in which DiscoverWith tries the
+	// built-in Providers, if no names are provided.
+	defaultProviders = []string{"sysfs", "hwloc", "lscpu"}
+)
+
+// RegisterProvider makes the provided Provider available under the provided
+// name (see LookupProvider and DiscoverWith), replacing any Provider that was
+// registered under the same name before (including the built-in ones: "sysfs",
+// "hwloc" and "lscpu").
+func RegisterProvider(name string, p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if nil == p {
+		delete(providers, name)
+		return
+	}
+	providers[name] = p
+}
+
+// LookupProvider returns the Provider registered under the provided name, if
+// any.
+func LookupProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// ProviderNames returns the sorted names of all registered Providers.
+func ProviderNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DiscoverWith tries the Providers registered under the provided names in
+// order (or the built-in ones, i.e. "sysfs", "hwloc" and "lscpu", if none are
+// provided), and returns the Topology discovered by the first one that
+// succeeds, along with its name, or a non-nil error value that describes the
+// failures of all of them.
+func DiscoverWith(ctx context.Context, names ...string) (*Topology, string, error) {
+	if len(names) == 0 {
+		names = defaultProviders
+	}
+	failures := make([]string, 0, len(names))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		p, ok := LookupProvider(name)
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s: unknown provider", name))
+			continue
+		}
+		topo, err := p.Discover(ctx)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		return topo, name, nil
+	}
+	return nil, "", fmt.Errorf("discovery failed: %s", strings.Join(failures, "; "))
+}
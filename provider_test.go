@@ -0,0 +1,117 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const lscpuFixture = `# The following is the parsable format, which can be fed to other
+# programs. Each different item in every column has an unique ID
+# starting usually from zero.
+# CPU,Core,Socket,Node,,L1d,L1i,L2,L3
+0,0,0,0,,0,0,0,0
+1,1,0,0,,1,1,1,0
+2,2,1,1,,2,2,2,1
+3,3,1,1,,3,3,3,1
+4,0,0,0,,0,0,0,0
+5,1,0,0,,1,1,1,0
+6,2,1,1,,2,2,2,1
+7,3,1,1,,3,3,3,1
+`
+
+func TestParseLscpu(t *testing.T) {
+	topo, err := ParseLscpu(strings.NewReader(lscpuFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := topo.Profile()
+	if profile.Packages != 2 || profile.NUMANodes != 2 || profile.Cores != 4 || profile.Threads != 8 ||
+		len(profile.Caches) != 3 {
+		t.Errorf("got profile %+v", profile)
+	}
+	// SMT siblings share their Core.
+	threads := topo.ThreadsDetailed()
+	if id, err := topo.CommonAncestorID(threads[0].ID, threads[1].ID); err != nil || topo.Nodes[id].Data.Kind != Core {
+		t.Errorf("got %v as the common ancestor of threads %d and %d", topo.Nodes[id].Data,
+			threads[0].Processing.ID, threads[1].Processing.ID)
+	}
+
+	for _, input := range []string{"", "0,0,0,0\n", "# CPU,Core\n0,x\n", "# CPU,Core\n0,0,0\n"} {
+		if _, err = ParseLscpu(strings.NewReader(input)); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestDiscoverWith(t *testing.T) {
+	fake := &Topology{syntheticTree(1, 1, 2, 2)}
+	failing := ProviderFunc(func(ctx context.Context) (*Topology, error) {
+		return nil, errors.New("no hardware")
+	})
+	RegisterProvider("test-fake", StaticProvider(fake))
+	RegisterProvider("test-failing", failing)
+	defer RegisterProvider("test-fake", nil)
+	defer RegisterProvider("test-failing", nil)
+
+	ctx := context.Background()
+	topo, name, err := DiscoverWith(ctx, "test-missing", "test-failing", "test-fake", "sysfs")
+	if err != nil || name != "test-fake" || topo.Size() != fake.Size() {
+		t.Fatalf("got %v from %q; expected the fake Topology", err, name)
+	}
+	// Providers hand out their own copies.
+	topo.Nodes[0].Children = nil
+	if again, _, _ := DiscoverWith(ctx, "test-fake"); len(again.Nodes[0].Children) == 0 {
+		t.Errorf("StaticProvider returned a shared Topology")
+	}
+
+	_, _, err = DiscoverWith(ctx, "test-missing", "test-failing")
+	if err == nil || !strings.Contains(err.Error(), "test-missing: unknown provider") ||
+		!strings.Contains(err.Error(), "test-failing: no hardware") {
+		t.Errorf("got %v; expected the failures of both Providers", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err = DiscoverWith(canceled, "test-fake"); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v; expected context.Canceled", err)
+	}
+
+	if _, ok := LookupProvider("sysfs"); !ok {
+		t.Errorf("the sysfs Provider is not registered")
+	}
+	if names := ProviderNames(); len(names) != 5 {
+		t.Errorf("got Providers %v", names)
+	}
+}
+
+func TestProviders(t *testing.T) {
+	ctx := context.Background()
+	topo, err := SysfsProvider{FS: sysfsFixture()}.Discover(ctx)
+	if err != nil || topo.Size() == 0 {
+		t.Errorf("got %v from the sysfs Provider", err)
+	}
+	if _, err = (HwlocProvider{Command: "/nonexistent/lstopo"}).Discover(ctx); err == nil {
+		t.Errorf("expected an error for a missing lstopo")
+	}
+	if _, err = (LscpuProvider{Command: "/nonexistent/lscpu"}).Discover(ctx); err == nil {
+		t.Errorf("expected an error for a missing lscpu")
+	}
+}
@@ -0,0 +1,140 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"math/rand"
+	"reflect"
+)
+
+// RandomTopology returns a new, randomly shaped but valid Topology of at most
+// maxSize Elements (and at least 1, i.e. the Machine), drawing all random
+// choices from the provided source, so that the same seed always yields the
+// same Topology.
+//
+// The Topologies it returns vary in the numbers of Packages, NUMA nodes, L3
+// caches, Cores and hardware threads at each level, in the presence of L3, L2
+// and L1 caches and in their sizes, and may mix Cores with and without SMT.
+// NUMA nodes are numbered across the machine, Cores within their Package and
+// hardware threads consecutively, in pre-order. If maxSize is too small for the shape
+// that was drawn, the Topology is truncated in pre-order, so that its last
+// Elements may lack some of their descendants.
+func RandomTopology(r *rand.Rand, maxSize int) *Topology {
+	tree := NewTree([]TreeNode{{Data: &Element{}}})
+	add := func(parent NodeID, data *Element) (NodeID, bool) {
+		if len(tree.Nodes) >= maxSize {
+			return 0, false
+		}
+		id := NodeID(len(tree.Nodes))
+		tree.Nodes = append(tree.Nodes, TreeNode{Data: data})
+		tree.Nodes[parent].Children = append(tree.Nodes[parent].Children, id)
+		return id, true
+	}
+	processing := func(parent NodeID, kind ProcessingKind, id int) (NodeID, bool) {
+		return add(parent, &Element{Processing: &Processing{Kind: kind, ID: uint32(id)}})
+	}
+	pick := func(choices ...uint64) uint64 {
+		return choices[r.Intn(len(choices))]
+	}
+	var logicalIndex [L5 + 1]uint32
+	cache := func(parent NodeID, level CacheLevel, size uint64) (NodeID, bool) {
+		id, ok := add(parent, &Element{Cache: &Cache{
+			Level:        level,
+			LogicalIndex: logicalIndex[level],
+			Attributes:   &CacheAttributes{Size: size, Linesize: 64, Associativity: int32(pick(4, 8, 16))},
+		}})
+		if ok {
+			logicalIndex[level]++
+		}
+		return id, ok
+	}
+
+	// Draw the shape of the Topology, which is shared by all its Packages
+	// (except for the number of hardware threads per Core).
+	var (
+		packages     = 1 + r.Intn(4)
+		numaNodes    = 1 + r.Intn(2)
+		l3s          = r.Intn(3)
+		cores        = 1 + r.Intn(8)
+		hasL2, hasL1 = r.Intn(4) > 0, r.Intn(4) > 0
+		smt          = 1 + r.Intn(2)
+		l3Size       = pick(8<<20, 16<<20, 32<<20, 64<<20)
+		l2Size       = pick(256<<10, 512<<10, 1<<20, 2<<20)
+		l1Size       = pick(32<<10, 48<<10, 64<<10)
+	)
+	var numaID, threadID int
+	for p := 0; p < packages; p++ {
+		pkg, ok := processing(0, Package, p)
+		if !ok {
+			break
+		}
+		coreID := 0
+		for n := 0; n < numaNodes; n++ {
+			numa, ok := processing(pkg, NUMANode, numaID)
+			if !ok {
+				break
+			}
+			numaID++
+			for l := 0; l < l3s || (l3s == 0 && l == 0); l++ {
+				parent := numa
+				if l3s > 0 {
+					if parent, ok = cache(numa, L3, l3Size); !ok {
+						break
+					}
+				}
+				for c := 0; c < cores; c++ {
+					coreParent := parent
+					if hasL2 {
+						if coreParent, ok = cache(coreParent, L2, l2Size); !ok {
+							break
+						}
+					}
+					if hasL1 {
+						if coreParent, ok = cache(coreParent, L1, l1Size); !ok {
+							break
+						}
+					}
+					core, ok := processing(coreParent, Core, coreID)
+					if !ok {
+						break
+					}
+					coreID++
+					// Mix in some Cores without SMT (e.g., hybrid CPUs).
+					threads := smt
+					if smt > 1 && r.Intn(8) == 0 {
+						threads = 1
+					}
+					for th := 0; th < threads; th++ {
+						if _, ok = processing(core, Thread, threadID); !ok {
+							break
+						}
+						threadID++
+					}
+				}
+			}
+		}
+	}
+	return &Topology{tree}
+}
+
+// Generate implements the testing/quick.Generator interface, so that random
+// Topologies (see RandomTopology) can be used in property-based tests through
+// quick.Check; the size hint bounds the size of the Topology to 16*(size+1)
+// Elements.
+func (*Topology) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(RandomTopology(r, 16*(size+1)))
+}
@@ -0,0 +1,60 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+func TestRandomTopology(t *testing.T) {
+	for _, maxSize := range []int{0, 1, 5, 20, 1000} {
+		for seed := int64(0); seed < 50; seed++ {
+			topo := RandomTopology(rand.New(rand.NewSource(seed)), maxSize)
+			if err := topo.Validate(); err != nil {
+				t.Fatalf("seed %d, maxSize %d: %v", seed, maxSize, err)
+			}
+			if topo.Size() > maxSize && topo.Size() > 1 {
+				t.Fatalf("seed %d: got %d elements; expected at most %d", seed, topo.Size(), maxSize)
+			}
+			again := RandomTopology(rand.New(rand.NewSource(seed)), maxSize)
+			if !reflect.DeepEqual(topo.Nodes, again.Nodes) {
+				t.Fatalf("seed %d: got different Topologies from the same seed", seed)
+			}
+		}
+	}
+}
+
+func TestQuickRoundTrip(t *testing.T) {
+	roundTrip := func(topo *Topology) bool {
+		data, err := json.Marshal(topo)
+		if err != nil {
+			return false
+		}
+		var decoded Topology
+		if err = json.Unmarshal(data, &decoded); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(topo.Nodes, decoded.Nodes)
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ResctrlCacheIDs returns the IDs of the L3 Caches of the Topology in the
+// Linux resctrl file system (i.e., the domain IDs in its "schemata" files),
+// indexed by their NodeIDs, as exposed by the provided file system, which is
+// expected to be rooted at the mount point of a Linux sysfs (or /sys, if nil),
+// or a non-nil error value in case of failure.
+//
+// The resctrl ID of each L3 is the "id" that sysfs reports for it, which is
+// looked up through the first hardware thread in its subtree; it does not
+// necessarily match its logical index.
+func (t *Topology) ResctrlCacheIDs(fsys fs.FS) (map[NodeID]uint32, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	if nil == fsys {
+		fsys = os.DirFS("/sys")
+	}
+	ret := make(map[NodeID]uint32)
+	for _, id := range t.L3Caches() {
+		start, end, err := t.SubtreeRange(id)
+		if err != nil {
+			return nil, err
+		}
+		cpu, found := uint32(0), false
+		for _, node := range t.Nodes[start:end] {
+			if node.Data.IsProcessing() && node.Data.Kind == Thread {
+				cpu, found = node.Data.ID, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("L3 cache %d contains no hardware threads", id)
+		}
+		cacheID, err := resctrlCacheID(fsys, cpu)
+		if err != nil {
+			return nil, err
+		}
+		ret[id] = cacheID
+	}
+	return ret, nil
+}
+
+// resctrlCacheID returns the sysfs ID of the L3 cache of the provided CPU.
+func resctrlCacheID(fsys fs.FS, cpu uint32) (uint32, error) {
+	dir := fmt.Sprintf("devices/system/cpu/cpu%d/cache", cpu)
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "index") {
+			continue
+		}
+		index := path.Join(dir, entry.Name())
+		if level, err := readSysfsInt(fsys, path.Join(index, "level")); err != nil || level != 3 {
+			continue
+		}
+		if cacheType, err := readSysfs(fsys, path.Join(index, "type")); err != nil || cacheType == "Instruction" {
+			continue
+		}
+		id, err := readSysfsInt(fsys, path.Join(index, "id"))
+		if err != nil {
+			return 0, err
+		}
+		return uint32(id), nil
+	}
+	return 0, fmt.Errorf("%s: no L3 cache found", dir)
+}
+
+// CATClass requests a partition of the L3 Caches for a resctrl group (i.e., a
+// class of service of Intel's Cache Allocation Technology).
+type CATClass struct {
+	// Name is the name of the resctrl group.
+	Name string `json:"name"`
+	// Ways is the number of ways of each L3 cache that are dedicated to
+	// the class.
+	Ways int `json:"ways"`
+}
+
+// ResctrlGroup is a resctrl group, along with its "schemata" line.
+type ResctrlGroup struct {
+	// Name is the name of the resctrl group.
+	Name string `json:"name"`
+	// Schemata is the line to be written to the "schemata" file of the
+	// group (e.g., "L3:0=ff0;1=ff0").
+	Schemata string `json:"schemata"`
+}
+
+// CATSchemata partitions the ways of every L3 Cache of the Topology among the
+// provided CATClasses, and returns a ResctrlGroup for each of them, in the same
+// order, or a non-nil error value if the classes do not fit.
+//
+// The provided cacheIDs map the NodeIDs of the L3 Caches to their resctrl IDs
+// (see ResctrlCacheIDs). Each class is given a contiguous, non-overlapping
+// range of ways (as CAT requires contiguous capacity bitmasks), starting from
+// the most significant one, in the provided order. The length of the bitmask of
+// each L3 is its associativity, unless cbmBits is positive, in which case it
+// is used instead (e.g., as read from /sys/fs/resctrl/info/L3/cbm_mask, which
+// may differ from the associativity).
+func (t *Topology) CATSchemata(cacheIDs map[NodeID]uint32, cbmBits int, classes []CATClass) ([]ResctrlGroup, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+	l3s := t.L3Caches()
+	if len(l3s) == 0 {
+		return nil, fmt.Errorf("Topology has no L3 caches")
+	}
+	type domain struct {
+		id   uint32
+		bits int
+	}
+	domains := make([]domain, 0, len(l3s))
+	for _, l3 := range l3s {
+		cacheID, ok := cacheIDs[l3]
+		if !ok {
+			return nil, fmt.Errorf("no resctrl ID for L3 cache %d", l3)
+		}
+		bits := cbmBits
+		if bits <= 0 {
+			if attrs := t.Nodes[l3].Data.Attributes; nil != attrs && attrs.Associativity > 0 {
+				bits = int(attrs.Associativity)
+			}
+		}
+		if bits <= 0 || bits > 64 {
+			return nil, fmt.Errorf("unknown or unsupported number of ways for L3 cache %d", l3)
+		}
+		domains = append(domains, domain{id: cacheID, bits: bits})
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].id < domains[j].id })
+
+	total := 0
+	for _, class := range classes {
+		if class.Ways <= 0 {
+			return nil, fmt.Errorf("invalid number of ways %d for class '%s'", class.Ways, class.Name)
+		}
+		total += class.Ways
+	}
+	for _, d := range domains {
+		if total > d.bits {
+			return nil, fmt.Errorf("classes require %d ways, but L3 cache %d only has %d", total, d.id, d.bits)
+		}
+	}
+	ret := make([]ResctrlGroup, 0, len(classes))
+	used := 0
+	for _, class := range classes {
+		parts := make([]string, 0, len(domains))
+		for _, d := range domains {
+			// Ways are handed out from the most significant bit.
+			mask := (uint64(1)<<class.Ways - 1) << (d.bits - used - class.Ways)
+			parts = append(parts, fmt.Sprintf("%d=%x", d.id, mask))
+		}
+		used += class.Ways
+		ret = append(ret, ResctrlGroup{Name: class.Name, Schemata: "L3:" + strings.Join(parts, ";")})
+	}
+	return ret, nil
+}
@@ -0,0 +1,86 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestResctrlCacheIDs(t *testing.T) {
+	fsys := sysfsFixture()
+	topo, err := DiscoverSysfs(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cpu := 0; cpu < 4; cpu++ {
+		fsys[fmt.Sprintf("devices/system/cpu/cpu%d/cache/index3/id", cpu)] = &fstest.MapFile{Data: []byte("7\n")}
+	}
+	ids, err := topo.ResctrlCacheIDs(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[NodeID]uint32{topo.L3Caches()[0]: 7}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got %v; expected %v", ids, want)
+	}
+
+	delete(fsys, "devices/system/cpu/cpu0/cache/index3/id")
+	if _, err = topo.ResctrlCacheIDs(fsys); err == nil {
+		t.Errorf("expected an error for a missing cache ID")
+	}
+}
+
+func TestCATSchemata(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 1, 2, 1)}
+	l3s := topo.L3Caches()
+	ids := map[NodeID]uint32{l3s[0]: 0, l3s[1]: 1}
+	for _, l3 := range l3s {
+		topo.Nodes[l3].Data.Attributes.Associativity = 12
+	}
+
+	groups, err := topo.CATSchemata(ids, 0, []CATClass{{Name: "latency", Ways: 4}, {Name: "batch", Ways: 8}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ResctrlGroup{
+		{Name: "latency", Schemata: "L3:0=f00;1=f00"},
+		{Name: "batch", Schemata: "L3:0=ff;1=ff"},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("got %v; expected %v", groups, want)
+	}
+
+	// The length of the bitmasks may be overridden.
+	if groups, err = topo.CATSchemata(ids, 16, []CATClass{{Name: "a", Ways: 2}}); err != nil ||
+		groups[0].Schemata != "L3:0=c000;1=c000" {
+		t.Errorf("got %v, %v", groups, err)
+	}
+
+	for _, classes := range [][]CATClass{
+		{{Name: "a", Ways: 8}, {Name: "b", Ways: 8}},
+		{{Name: "a", Ways: 0}},
+	} {
+		if _, err = topo.CATSchemata(ids, 0, classes); err == nil {
+			t.Errorf("expected an error for %v", classes)
+		}
+	}
+	if _, err = topo.CATSchemata(map[NodeID]uint32{l3s[0]: 0}, 0, []CATClass{{Name: "a", Ways: 1}}); err == nil {
+		t.Errorf("expected an error for a missing resctrl ID")
+	}
+}
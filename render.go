@@ -0,0 +1,490 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteLstopo writes a textual representation of the Topology to the provided
+// io.Writer, in the compact format of hwloc's lstopo console output (e.g.,
+// "Package L#0 + L3 L#0 (32MB)"), so that it can be diffed against hwloc's
+// view of the same machine. It returns a non-nil error value in case of
+// failure.
+//
+// As in lstopo, each Element is written on its own line, indented by its
+// depth, except for Elements that have a single child, which are joined with
+// it on the same line. Processing nodes are given logical indices (L#) in the
+// order they appear in the Tree, and their OS indices (P#) are included for
+// NUMA nodes and hardware threads (PUs). L1 caches are written as L1d, since
+// data caches are the ones that hwloc attaches hardware threads to. Memory
+// sizes, which the Topology does not contain, are omitted.
+func (t *Topology) WriteLstopo(w io.Writer) error {
+	if nil == t || nil == t.Tree {
+		return ErrNilTree
+	}
+	if t.IsEmpty() {
+		return ErrEmptyTree
+	}
+
+	logical := t.logicalIndices()
+	bw := bufio.NewWriter(w)
+	stack := []struct {
+		id    NodeID
+		depth int
+	}{{id: 0}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		labels := []string{lstopoLabel(t.Nodes[top.id].Data, logical[top.id])}
+		id := top.id
+		for len(t.Nodes[id].Children) == 1 {
+			id = t.Nodes[id].Children[0]
+			labels = append(labels, lstopoLabel(t.Nodes[id].Data, logical[id]))
+		}
+		fmt.Fprintf(bw, "%s%s\n", strings.Repeat("  ", top.depth), strings.Join(labels, " + "))
+
+		children := t.Nodes[id].Children
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, struct {
+				id    NodeID
+				depth int
+			}{id: children[i], depth: top.depth + 1})
+		}
+	}
+	return bw.Flush()
+}
+
+// logicalIndices returns the logical index of each Processing node (i.e., its
+// position among the Processing nodes of the same kind, in the order they
+// appear in the Tree) and of each Cache, indexed by NodeID.
+func (t *Topology) logicalIndices() []uint32 {
+	ret := make([]uint32, len(t.Nodes))
+	for _, ids := range t.getIndexes().processing {
+		for li, id := range ids {
+			ret[id] = uint32(li)
+		}
+	}
+	for id := range t.Nodes {
+		if t.Nodes[id].Data.IsCache() {
+			ret[id] = t.Nodes[id].Data.LogicalIndex
+		}
+	}
+	return ret
+}
+
+// lstopoLabel returns the label of the provided Element in lstopo's console
+// output, given its logical index.
+func lstopoLabel(e *Element, logical uint32) string {
+	switch {
+	case e.IsRoot():
+		return "Machine"
+	case e.IsCache():
+		level := e.Level.String()
+		if e.Level == L1 {
+			level = "L1d"
+		}
+		if nil == e.Attributes {
+			return fmt.Sprintf("%s L#%d", level, logical)
+		}
+		return fmt.Sprintf("%s L#%d (%s)", level, logical, lstopoSize(e.Attributes.Size))
+	case e.IsProcessing():
+		switch e.Kind {
+		case NUMANode:
+			return fmt.Sprintf("NUMANode L#%d (P#%d)", logical, e.ID)
+		case Thread:
+			return fmt.Sprintf("PU L#%d (P#%d)", logical, e.ID)
+		default:
+			return fmt.Sprintf("%s L#%d", e.Kind, logical)
+		}
+	default:
+		return e.String()
+	}
+}
+
+// lstopoSize formats the provided size in bytes the way lstopo does, i.e., in
+// the largest unit that keeps the value at or above 10 (e.g., "8192KB", but
+// "32MB").
+func lstopoSize(size uint64) string {
+	switch {
+	case size >= 10<<40:
+		return fmt.Sprintf("%dTB", size>>40)
+	case size >= 10<<30:
+		return fmt.Sprintf("%dGB", size>>30)
+	case size >= 10<<20:
+		return fmt.Sprintf("%dMB", size>>20)
+	default:
+		return fmt.Sprintf("%dKB", size>>10)
+	}
+}
+
+// MarkdownSummary returns a Markdown table that summarizes the Topology (i.e.,
+// the number of Packages, NUMA nodes, Cores and hardware threads, the sizes of
+// the Caches of each level, and the number of hardware threads per Core), to
+// be pasted into documents and pull request descriptions.
+func (t *Topology) MarkdownSummary() string {
+	var sb strings.Builder
+	sb.WriteString("| Property | Value |\n")
+	sb.WriteString("|---|---|\n")
+	row := func(property, value string) {
+		fmt.Fprintf(&sb, "| %s | %s |\n", property, value)
+	}
+	if nil == t || nil == t.Tree {
+		return sb.String()
+	}
+
+	packages, numaNodes, cores, threads := t.Packages(), t.NUMANodes(), t.Cores(), t.Threads()
+	row("Packages", strconv.Itoa(len(packages)))
+	row("NUMA nodes", strconv.Itoa(len(numaNodes)))
+	row("Cores", strconv.Itoa(len(cores)))
+	row("Threads", strconv.Itoa(len(threads)))
+	for level := L1; level <= L5; level++ {
+		caches := t.getAllCacheLevel(level)
+		if len(caches) == 0 {
+			continue
+		}
+		row(level.String()+" caches", t.describeCacheSizes(caches))
+	}
+	switch {
+	case len(cores) == 0:
+		row("SMT", "unknown (no Cores)")
+	case len(threads) > len(cores):
+		row("SMT", fmt.Sprintf("yes (%s threads per core)", formatRatio(len(threads), len(cores))))
+	default:
+		row("SMT", "no")
+	}
+	return sb.String()
+}
+
+// describeCacheSizes returns a description of the sizes of the provided Caches
+// (e.g., "12 × 256 KiB (3 MiB total)").
+func (t *Topology) describeCacheSizes(caches []NodeID) string {
+	counts := make(map[uint64]int)
+	var total uint64
+	for _, id := range caches {
+		var size uint64
+		if attrs := t.Nodes[id].Data.Attributes; nil != attrs {
+			size = attrs.Size
+		}
+		counts[size]++
+		total += size
+	}
+	sizes := make([]uint64, 0, len(counts))
+	for size := range counts {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+
+	parts := make([]string, 0, len(sizes))
+	for _, size := range sizes {
+		parts = append(parts, fmt.Sprintf("%d × %s", counts[size], formatBytes(size)))
+	}
+	return fmt.Sprintf("%s (%s total)", strings.Join(parts, ", "), formatBytes(total))
+}
+
+// formatBytes formats the provided size in bytes in the largest binary unit
+// that keeps the value at or above 1, with at most one decimal digit (e.g.,
+// "256 KiB" or "1.5 MiB").
+func formatBytes(size uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	value, unit := float64(size), 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return strconv.FormatFloat(math.Round(value*10)/10, 'f', -1, 64) + " " + units[unit]
+}
+
+// formatRatio returns the ratio of the provided integers, with at most one
+// decimal digit.
+func formatRatio(a, b int) string {
+	return strconv.FormatFloat(math.Round(float64(a)*10/float64(b))/10, 'f', -1, 64)
+}
+
+// RenderOptions configures the output of WriteTree.
+type RenderOptions struct {
+	// Color, if true, colorizes the Elements by their kind, through ANSI
+	// escape sequences.
+	Color bool
+	// Filter, if non-nil, selects the Elements to be written; the root
+	// Element is always written. Elements that are filtered out are
+	// collapsed, i.e., their selected descendants are written in their
+	// place, one level up.
+	Filter func(e *Element) bool
+	// MaxDepth, if positive, is the maximum depth of the written Elements
+	// (where the root Element is at depth 0), after filtering.
+	MaxDepth int
+	// ThreadCounts, if true, annotates each written Element with the number
+	// of hardware threads in its subtree.
+	ThreadCounts bool
+	// Overlay, if non-nil, annotates the written Elements with its values
+	// and labels; if Color is true, Elements with a value are colorized by
+	// it instead of by their kind.
+	Overlay *Overlay
+}
+
+// Overlay annotates the Elements of a Topology with values (e.g., per-CPU
+// utilization) and labels (e.g., the owners of Allocations), to be visualized
+// on top of the structural Tree by WriteTree, WriteDOT, WriteSVG and
+// WriteHTMLOverlay.
+type Overlay struct {
+	// Values maps NodeIDs to values in [0, 1], which are visualized as a
+	// heatmap, from green (0) to red (1); values outside of the range are
+	// clamped.
+	Values map[NodeID]float64 `json:"values,omitempty"`
+	// Labels maps NodeIDs to arbitrary labels.
+	Labels map[NodeID]string `json:"labels,omitempty"`
+}
+
+// CPUListOverlay returns an Overlay that highlights the provided hardware
+// threads, identified by their OS indices (e.g., as parsed by ParseCPUList):
+// every Element is assigned the fraction of the hardware threads in its
+// subtree that are among them.
+func (t *Topology) CPUListOverlay(cpus []uint32) *Overlay {
+	overlay := &Overlay{Values: make(map[NodeID]float64)}
+	if nil == t || nil == t.Tree {
+		return overlay
+	}
+	highlighted := make(map[uint32]bool, len(cpus))
+	for _, cpu := range cpus {
+		highlighted[cpu] = true
+	}
+
+	// Count the total and the highlighted threads in every subtree, from
+	// the leaves up, relying on the pre-order layout of the Tree.
+	total, selected := make([]int, len(t.Nodes)), make([]int, len(t.Nodes))
+	for id := len(t.Nodes) - 1; id >= 0; id-- {
+		if data := t.Nodes[id].Data; data.IsProcessing() && data.Kind == Thread {
+			total[id]++
+			if highlighted[data.ID] {
+				selected[id]++
+			}
+		}
+		for _, child := range t.Nodes[id].Children {
+			total[id] += total[child]
+			selected[id] += selected[child]
+		}
+		if total[id] > 0 {
+			overlay.Values[NodeID(id)] = float64(selected[id]) / float64(total[id])
+		}
+	}
+	return overlay
+}
+
+// heatColor returns the ANSI escape sequence that WriteTree colorizes an
+// Element with the provided overlay value with.
+func heatColor(value float64) string {
+	switch {
+	case value < 1.0/3:
+		return ansiGreen
+	case value < 2.0/3:
+		return ansiYellow
+	default:
+		return ansiRed
+	}
+}
+
+// KindFilter returns a RenderOptions.Filter that selects the Processing nodes
+// of the provided kinds and the Caches of the provided levels.
+func KindFilter(kinds []ProcessingKind, levels []CacheLevel) func(e *Element) bool {
+	return func(e *Element) bool {
+		switch {
+		case e.IsProcessing():
+			for _, kind := range kinds {
+				if e.Kind == kind {
+					return true
+				}
+			}
+		case e.IsCache():
+			for _, level := range levels {
+				if e.Level == level {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// ANSI escape sequences used by WriteTree.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+)
+
+// ansiColor returns the ANSI escape sequence that WriteTree colorizes the
+// provided Element with.
+func ansiColor(e *Element) string {
+	switch {
+	case e.IsRoot():
+		return ansiBold
+	case e.IsCache():
+		return ansiYellow
+	case e.IsDevice():
+		return ansiReset
+	case e.IsProcessing():
+		switch e.Kind {
+		case Package:
+			return ansiMagenta
+		case NUMANode:
+			return ansiBlue
+		case Core:
+			return ansiCyan
+		case Thread:
+			return ansiGreen
+		}
+	}
+	return ansiRed
+}
+
+// WriteTree writes a textual representation of the Topology to the provided
+// io.Writer, one Element per line, indented by its depth, as configured by the
+// provided RenderOptions. It returns a non-nil error value in case of failure.
+//
+// For instance, writing only the Packages and the L3 caches along with their
+// thread counts provides a readable overview of a large machine:
+//
+//	topo.WriteTree(os.Stdout, RenderOptions{
+//		Filter:       KindFilter([]ProcessingKind{Package}, []CacheLevel{L3}),
+//		ThreadCounts: true,
+//	})
+func (t *Topology) WriteTree(w io.Writer, opts RenderOptions) error {
+	if nil == t || nil == t.Tree {
+		return ErrNilTree
+	}
+	if t.IsEmpty() {
+		return ErrEmptyTree
+	}
+
+	bw := bufio.NewWriter(w)
+	type frame struct {
+		id    NodeID
+		depth int
+	}
+	stack := []frame{{id: 0}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		data := t.Nodes[top.id].Data
+		depth := top.depth
+		if top.id == 0 || nil == opts.Filter || opts.Filter(data) {
+			if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+				continue
+			}
+			label, color := data.String(), ansiColor(data)
+			if nil != opts.Overlay {
+				if value, ok := opts.Overlay.Values[top.id]; ok {
+					value = math.Max(0, math.Min(1, value))
+					label, color = fmt.Sprintf("%s (%.2f)", label, value), heatColor(value)
+				}
+			}
+			if opts.Color {
+				label = color + label + ansiReset
+			}
+			bw.WriteString(strings.Repeat("  ", depth))
+			bw.WriteString(label)
+			if opts.ThreadCounts {
+				fmt.Fprintf(bw, " [%d threads]", t.countThreads(top.id))
+			}
+			if nil != opts.Overlay {
+				if text, ok := opts.Overlay.Labels[top.id]; ok {
+					fmt.Fprintf(bw, " %q", text)
+				}
+			}
+			bw.WriteByte('\n')
+			depth++
+		}
+
+		children := t.Nodes[top.id].Children
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, frame{id: children[i], depth: depth})
+		}
+	}
+	return bw.Flush()
+}
+
+// countThreads returns the number of hardware threads in the subtree of the
+// element stored under the provided NodeID.
+func (t *Topology) countThreads(id NodeID) int {
+	start, end, err := t.SubtreeRange(id)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, node := range t.Nodes[start:end] {
+		if node.Data.IsProcessing() && node.Data.Kind == Thread {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteDOT writes the Topology to the provided io.Writer as a directed graph
+// in the DOT language of Graphviz (e.g., to be rendered through `dot -Tsvg`),
+// optionally annotated with the provided Overlay (which may be nil). It
+// returns a non-nil error value in case of failure.
+//
+// Each Element is a node named after its NodeID (e.g., "n42"), and Elements
+// with an Overlay value are filled with the corresponding heatmap color.
+func (t *Topology) WriteDOT(w io.Writer, overlay *Overlay) error {
+	if nil == t || nil == t.Tree {
+		return ErrNilTree
+	}
+	if t.IsEmpty() {
+		return ErrEmptyTree
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph topology {\n\tnode [shape=box, fontname=monospace];\n")
+	for id := range t.Nodes {
+		data := t.Nodes[id].Data
+		label := data.String()
+		attrs := ""
+		if nil != overlay {
+			if value, ok := overlay.Values[NodeID(id)]; ok {
+				value = math.Max(0, math.Min(1, value))
+				label = fmt.Sprintf("%s (%.2f)", label, value)
+				attrs = fmt.Sprintf(", style=filled, fillcolor=\"%.3f 0.5 1.0\"", (1-value)/3)
+			}
+			if text, ok := overlay.Labels[NodeID(id)]; ok {
+				label += "\n" + text
+			}
+		}
+		fmt.Fprintf(bw, "\tn%d [label=%s%s];\n", id, strconv.Quote(label), attrs)
+	}
+	for id := range t.Nodes {
+		for _, child := range t.Nodes[id].Children {
+			fmt.Fprintf(bw, "\tn%d -> n%d;\n", id, child)
+		}
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
@@ -0,0 +1,263 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteLstopo(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+	var sb strings.Builder
+	if err := topo.WriteLstopo(&sb); err != nil {
+		t.Fatalf("Failed to write lstopo text: %v\n", err)
+	}
+	t.Logf("lstopo text:\n%s", sb.String())
+
+	want := []string{
+		"Machine",
+		"  Package L#0 + NUMANode L#0 (P#0)",
+		"    L2 L#0 (256KB)",
+		"      PU L#0 (P#0)",
+		"      PU L#1 (P#12)",
+		"    L2 L#1 (256KB)",
+	}
+	lines := strings.Split(sb.String(), "\n")
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: got %q; want %q", i, lines[i], want[i])
+		}
+	}
+
+	sb.Reset()
+	synthetic := &Topology{Tree: syntheticTree(1, 1, 2, 1)}
+	if err := synthetic.WriteLstopo(&sb); err != nil {
+		t.Fatalf("Failed to write lstopo text: %v\n", err)
+	}
+	want = []string{
+		"Machine + Package L#0 + NUMANode L#0 (P#0) + L3 L#0 (32MB)",
+		"  L2 L#0 (1024KB) + L1d L#0 (32KB) + Core L#0 + PU L#0 (P#0)",
+		"  L2 L#1 (1024KB) + L1d L#1 (32KB) + Core L#1 + PU L#1 (P#1)",
+		"",
+	}
+	if got := sb.String(); got != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestMarkdownSummary(t *testing.T) {
+	topo := &Topology{Tree: syntheticTree(2, 2, 8, 2)}
+	got := topo.MarkdownSummary()
+	t.Logf("Markdown summary:\n%s", got)
+	want := strings.Join([]string{
+		"| Property | Value |",
+		"|---|---|",
+		"| Packages | 2 |",
+		"| NUMA nodes | 4 |",
+		"| Cores | 32 |",
+		"| Threads | 64 |",
+		"| L1 caches | 32 × 32 KiB (1 MiB total) |",
+		"| L2 caches | 32 × 1 MiB (32 MiB total) |",
+		"| L3 caches | 4 × 32 MiB (128 MiB total) |",
+		"| SMT | yes (2 threads per core) |",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	fixture := loadTopology(t, "test_artifacts/t4_de.json").MarkdownSummary()
+	if !strings.Contains(fixture, "| L2 caches | 12 × 256 KiB (3 MiB total) |") ||
+		!strings.Contains(fixture, "| SMT | unknown (no Cores) |") {
+		t.Errorf("unexpected summary of the fixture:\n%s", fixture)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+	var sb strings.Builder
+	if err := topo.WriteHTML(&sb, "t4 <de>"); err != nil {
+		t.Fatalf("Failed to write HTML: %v\n", err)
+	}
+	got := sb.String()
+	for _, want := range []string{
+		"<title>t4 &lt;de&gt;</title>",
+		`const topology = {"nodes":[{"data":"machine","desc":[1,21]}`,
+		`{"data":{"cache":{"lvl":"L2","li":0,"attrs":{"size":262144,"line":64,"ways":8}}}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HTML document does not contain %q", want)
+		}
+	}
+	if strings.Contains(got, "<script src") || strings.Contains(got, "<link") {
+		t.Errorf("HTML document loads external resources")
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	topo := &Topology{Tree: syntheticTree(2, 2, 8, 2)}
+	var sb strings.Builder
+	err := topo.WriteTree(&sb, RenderOptions{
+		Filter:       KindFilter([]ProcessingKind{Package}, []CacheLevel{L3}),
+		ThreadCounts: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to write tree: %v\n", err)
+	}
+	want := strings.Join([]string{
+		"Machine [64 threads]",
+		"  Package(0) [32 threads]",
+		"    Cache{ L3(L#0), attrs: 33554432B/64B/8-way } [16 threads]",
+		"    Cache{ L3(L#1), attrs: 33554432B/64B/8-way } [16 threads]",
+		"  Package(1) [32 threads]",
+		"    Cache{ L3(L#2), attrs: 33554432B/64B/8-way } [16 threads]",
+		"    Cache{ L3(L#3), attrs: 33554432B/64B/8-way } [16 threads]",
+		"",
+	}, "\n")
+	if got := sb.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	sb.Reset()
+	if err = topo.WriteTree(&sb, RenderOptions{Color: true, MaxDepth: 1}); err != nil {
+		t.Fatalf("Failed to write tree: %v\n", err)
+	}
+	want = "\x1b[1mMachine\x1b[0m\n  \x1b[35mPackage(0)\x1b[0m\n  \x1b[35mPackage(1)\x1b[0m\n"
+	if got := sb.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	topo := &Topology{Tree: syntheticTree(1, 2, 2, 2)}
+	alloc, err := NewAllocator(topo)
+	if err != nil {
+		t.Fatalf("Failed to create Allocator: %v\n", err)
+	}
+	if err = alloc.Reserve("0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = alloc.Allocate(AllocationRequest{Owner: "web", MinThreads: 2, NUMANodes: topo.NUMANodes()[1:]}); err != nil {
+		t.Fatal(err)
+	}
+	overlay := alloc.Overlay()
+	if v := overlay.Values[0]; v != 3.0/8 {
+		t.Errorf("got %v for the root; want 0.375", v)
+	}
+
+	var sb strings.Builder
+	err = topo.WriteTree(&sb, RenderOptions{
+		Filter:  KindFilter([]ProcessingKind{NUMANode, Thread}, nil),
+		Overlay: overlay,
+	})
+	if err != nil {
+		t.Fatalf("Failed to write tree: %v\n", err)
+	}
+	t.Logf("Tree:\n%s", sb.String())
+	for _, want := range []string{
+		"  NUMANode(0) (0.25)\n",
+		"    Thread(0) (1.00) \"reserved\"\n",
+		"  NUMANode(1) (0.50)\n",
+		"    Thread(4) (1.00) \"web\"\n",
+		"    Thread(7) (0.00)\n",
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("tree does not contain %q", want)
+		}
+	}
+
+	sb.Reset()
+	if err = topo.WriteTree(&sb, RenderOptions{Color: true, MaxDepth: 0, Overlay: &Overlay{Values: map[NodeID]float64{0: 7}}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sb.String(), ansiRed+"Machine (1.00)"+ansiReset) {
+		t.Errorf("got %q; want a red, clamped root", sb.String())
+	}
+
+	sb.Reset()
+	if err = topo.WriteHTMLOverlay(&sb, "allocations", overlay); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), `"labels":{`) || !strings.Contains(sb.String(), `"web"`) {
+		t.Errorf("HTML document does not embed the Overlay")
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	topo := &Topology{Tree: syntheticTree(1, 1, 1, 2)}
+	var sb strings.Builder
+	overlay := &Overlay{Values: map[NodeID]float64{7: 1}, Labels: map[NodeID]string{8: `a "quoted" owner`}}
+	if err := topo.WriteDOT(&sb, overlay); err != nil {
+		t.Fatalf("Failed to write DOT: %v\n", err)
+	}
+	got := sb.String()
+	t.Logf("DOT:\n%s", got)
+	for _, want := range []string{
+		"digraph topology {\n",
+		"\tn0 [label=\"Machine\"];\n",
+		"\tn7 [label=\"Thread(0) (1.00)\", style=filled, fillcolor=\"0.000 0.5 1.0\"];\n",
+		"\tn8 [label=\"Thread(1)\\na \\\"quoted\\\" owner\"];\n",
+		"\tn6 -> n8;\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("DOT does not contain %q", want)
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	topo := &Topology{Tree: syntheticTree(1, 1, 2, 2)}
+	overlay := topo.CPUListOverlay([]uint32{0, 1})
+	if v := overlay.Values[0]; v != 0.5 {
+		t.Errorf("got %v for the root; want 0.5", v)
+	}
+	overlay.Labels = map[NodeID]string{7: "<owner>"}
+
+	var sb strings.Builder
+	if err := topo.WriteSVG(&sb, overlay); err != nil {
+		t.Fatalf("Failed to write SVG: %v\n", err)
+	}
+	got := sb.String()
+	t.Logf("SVG:\n%s", got)
+	var doc struct {
+		XMLName xml.Name `xml:"svg"`
+		Rects   []struct {
+			Width int `xml:"width,attr"`
+		} `xml:"rect"`
+		Texts []string `xml:"text"`
+	}
+	if err := xml.Unmarshal([]byte(got), &doc); err != nil {
+		t.Fatalf("Failed to parse SVG: %v\n", err)
+	}
+	if len(doc.Rects) != len(topo.Nodes) || len(doc.Texts) != len(topo.Nodes)+1 {
+		t.Errorf("got %d boxes and %d lines of text", len(doc.Rects), len(doc.Texts))
+	}
+	for _, want := range []string{"Machine (0.50)", "Thread(0) (1.00)", "<owner>", "Thread(3) (0.00)"} {
+		found := false
+		for _, text := range doc.Texts {
+			found = found || text == want
+		}
+		if !found {
+			t.Errorf("SVG does not contain the text %q", want)
+		}
+	}
+	if doc.Rects[0].Width < doc.Rects[len(doc.Rects)-1].Width*4 {
+		t.Errorf("the root's box is not wide enough to contain all threads")
+	}
+}
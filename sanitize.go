@@ -0,0 +1,372 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Issue describes a problem that Sanitize detected in a JSON payload.
+type Issue struct {
+	// ID is the index of the offending TreeNode in the original payload.
+	ID NodeID `json:"id"`
+	// Description is a human-readable description of the problem.
+	Description string `json:"desc"`
+	// Repaired is true if Sanitize repaired the problem, and false if it
+	// only detected it.
+	Repaired bool `json:"repaired"`
+}
+
+// String returns the string representation of the Issue.
+func (i Issue) String() string {
+	status := "detected"
+	if i.Repaired {
+		status = "repaired"
+	}
+	return fmt.Sprintf("node %d: %s (%s)", i.ID, i.Description, status)
+}
+
+// Sanitize inspects the provided JSON payload of a Tree (or Topology) for
+// common corruption, as produced by buggy or older collectors, and returns a
+// clean, canonical payload along with a list of the Issues it found, or a
+// non-nil error value if the payload is beyond repair.
+//
+// The following are detected and repaired:
+//   - Caches with missing or partial attributes, which are filled in with
+//     zeros;
+//   - ProcessingKinds and CacheLevels spelled in alternative ways (e.g.,
+//     "Socket", "PU", "L3Cache" or "3"), which are normalized;
+//   - Elements that cannot be interpreted at all (e.g., unknown kinds or
+//     missing IDs), which are removed while their children are attached to
+//     their parent (the root Element is replaced by a Machine instead);
+//   - dangling or malformed child references, cyclic child references and
+//     second parents, which are removed;
+//   - Elements that are not reachable from the root, which are removed.
+//
+// Removing Elements renumbers the remaining ones, and so does storing them in
+// pre-order (see Tree), if they were not already; the IDs of the returned
+// Issues always refer to the original payload. Problems that cannot be
+// repaired (e.g., duplicate IDs) result in a non-nil error value; a payload
+// that is returned, on the other hand, is guaranteed to be a valid Tree.
+//
+// Callers that cannot tolerate any repairs should reject payloads for which
+// any Issue is returned.
+func Sanitize(data []byte) (clean []byte, issues []Issue, err error) {
+	var raw struct {
+		Nodes []struct {
+			Data interface{}   `json:"data"`
+			Desc []interface{} `json:"desc"`
+		} `json:"nodes"`
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("cannot sanitize payload: %w", err)
+	}
+	if len(raw.Nodes) == 0 {
+		return nil, nil, fmt.Errorf("cannot sanitize payload: %w", ErrEmptyTree)
+	}
+
+	report := func(id int, repaired bool, format string, args ...interface{}) {
+		issues = append(issues, Issue{ID: NodeID(id), Description: fmt.Sprintf(format, args...), Repaired: repaired})
+	}
+
+	// Interpret each Element and its child references on their own.
+	n := len(raw.Nodes)
+	elements := make([]*Element, n)
+	children := make([][]int, n)
+	for id, node := range raw.Nodes {
+		var problem string
+		if elements[id], problem = sanitizeElement(id, node.Data, report); problem != "" {
+			if id == 0 {
+				elements[id] = &Element{}
+				report(id, true, "%s; replaced by Machine", problem)
+			} else {
+				report(id, true, "%s; element removed", problem)
+			}
+		}
+		for _, desc := range node.Desc {
+			f, ok := desc.(float64)
+			if !ok || f < 0 || f != math.Trunc(f) || f >= float64(n) {
+				report(id, true, "invalid child reference %v removed", desc)
+				continue
+			}
+			children[id] = append(children[id], int(f))
+		}
+	}
+
+	// Remove cyclic child references and second parents, through a DFS
+	// from the root (see Tree.Validate).
+	const (
+		unvisited = iota
+		open
+		closed
+	)
+	state := make([]byte, n)
+	type frame struct {
+		id, next int
+	}
+	stack := []frame{{id: 0}}
+	state[0] = open
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next == len(children[top.id]) {
+			state[top.id] = closed
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		child := children[top.id][top.next]
+		switch state[child] {
+		case open:
+			report(top.id, true, "cyclic child reference %d removed", child)
+		case closed:
+			report(top.id, true, "child reference %d to an element with another parent removed", child)
+		case unvisited:
+			top.next++
+			state[child] = open
+			stack = append(stack, frame{id: child})
+			continue
+		}
+		children[top.id] = append(children[top.id][:top.next], children[top.id][top.next+1:]...)
+	}
+	for id := range state {
+		if state[id] == unvisited && elements[id] != nil {
+			report(id, true, "element is not reachable from the root; element removed")
+			elements[id] = nil
+		}
+	}
+
+	// Attach the children of removed Elements to their closest remaining
+	// ancestor, and renumber the remaining Elements in pre-order.
+	var expand func(id int, out []int) []int
+	expand = func(id int, out []int) []int {
+		for _, child := range children[id] {
+			if elements[child] != nil {
+				out = append(out, child)
+			} else {
+				out = expand(child, out)
+			}
+		}
+		return out
+	}
+	newIDs := make([]NodeID, n)
+	order := make([]int, 0, n)
+	var renumber func(id int)
+	renumber = func(id int) {
+		newIDs[id] = NodeID(len(order))
+		order = append(order, id)
+		children[id] = expand(id, nil)
+		for _, child := range children[id] {
+			renumber(child)
+		}
+	}
+	renumber(0)
+	nodes := make([]TreeNode, len(order))
+	for newID, id := range order {
+		nodes[newID].Data = elements[id]
+		for _, child := range children[id] {
+			nodes[newID].Children = append(nodes[newID].Children, newIDs[child])
+		}
+	}
+
+	tree := NewTree(nodes)
+	if err = tree.Validate(); err != nil {
+		return nil, issues, fmt.Errorf("cannot sanitize payload: %w", err)
+	}
+	if clean, err = json.Marshal(tree); err != nil {
+		return nil, issues, err
+	}
+	return clean, issues, nil
+}
+
+// sanitizeElement interprets the raw data of the TreeNode with the provided
+// index as an Element, reporting any repairs made along the way, or returns a
+// non-empty description of the problem if it cannot be interpreted at all.
+func sanitizeElement(
+	id int,
+	data interface{},
+	report func(id int, repaired bool, format string, args ...interface{}),
+) (*Element, string) {
+	if str, ok := data.(string); ok {
+		if strings.EqualFold(str, "machine") {
+			return &Element{}, ""
+		}
+		return nil, fmt.Sprintf("unknown element '%s'", str)
+	}
+	obj, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, "missing or malformed data"
+	}
+	variants := 0
+	for _, key := range []string{"processing", "cache", "device"} {
+		if _, ok := obj[key]; ok {
+			variants++
+		}
+	}
+	if variants > 1 {
+		return nil, "element is more than one of a processing node, a cache and a device"
+	}
+
+	uint32Field := func(m map[string]interface{}, key string) (uint32, bool) {
+		f, ok := m[key].(float64)
+		if !ok || f < 0 || f > math.MaxUint32 || f != math.Trunc(f) {
+			return 0, false
+		}
+		return uint32(f), true
+	}
+
+	if processing, isProcessing := obj["processing"].(map[string]interface{}); isProcessing {
+		kindStr, _ := processing["kind"].(string)
+		kind, err := ParseProcessingKind(kindStr)
+		if err != nil {
+			switch strings.ToLower(kindStr) {
+			case "socket":
+				kind = Package
+			case "numa":
+				kind = NUMANode
+			case "pu":
+				kind = Thread
+			default:
+				return nil, fmt.Sprintf("unknown processing kind '%v'", processing["kind"])
+			}
+			report(id, true, "processing kind '%s' normalized to '%s'", kindStr, kind)
+		}
+		osID, ok := uint32Field(processing, "id")
+		if !ok {
+			return nil, fmt.Sprintf("missing or malformed OS index '%v'", processing["id"])
+		}
+		data := &Element{Processing: &Processing{Kind: kind, ID: osID}}
+		if mem, ok := processing["mem"]; ok {
+			memObj, _ := mem.(map[string]interface{})
+			f, ok := memObj["bytes"].(float64)
+			switch {
+			case kind != NUMANode:
+				report(id, true, "memory attributes of %s removed", kind)
+			case !ok || f < 0 || f > math.MaxUint64 || f != math.Trunc(f):
+				report(id, true, "malformed memory attributes removed")
+			default:
+				data.Memory = &MemoryAttributes{Bytes: uint64(f)}
+				if tier, ok := memObj["tier"]; ok {
+					tierStr, _ := tier.(string)
+					if data.Memory.Tier, err = ParseMemoryTier(tierStr); err != nil {
+						report(id, true, "unknown memory tier '%v' replaced by '%s'", tier, DRAM)
+					}
+				}
+				if dist, ok := memObj["dist"]; ok {
+					if data.Memory.Distances, err = parseDistances(dist); err != nil {
+						report(id, true, "%v; distances removed", err)
+					}
+				}
+			}
+		}
+		return data, ""
+	}
+
+	if cache, isCache := obj["cache"].(map[string]interface{}); isCache {
+		levelStr, _ := cache["lvl"].(string)
+		level, err := ParseCacheLevel(levelStr)
+		if err != nil {
+			normalized := strings.TrimSuffix(strings.ToUpper(levelStr), "CACHE")
+			if !strings.HasPrefix(normalized, "L") {
+				normalized = "L" + normalized
+			}
+			if level, err = ParseCacheLevel(normalized); err != nil {
+				return nil, fmt.Sprintf("unknown cache level '%v'", cache["lvl"])
+			}
+			report(id, true, "cache level '%s' normalized to '%s'", levelStr, level)
+		}
+		li, ok := uint32Field(cache, "li")
+		if !ok {
+			return nil, fmt.Sprintf("missing or malformed logical index '%v'", cache["li"])
+		}
+
+		attrs := &CacheAttributes{}
+		rawAttrs, ok := cache["attrs"].(map[string]interface{})
+		if !ok {
+			report(id, true, "missing cache attributes filled in with zeros")
+			rawAttrs = map[string]interface{}{}
+		}
+		for _, key := range []string{"size", "line", "ways"} {
+			f, ok := rawAttrs[key].(float64)
+			if !ok && len(rawAttrs) > 0 {
+				report(id, true, "missing cache attribute '%s' filled in with zero", key)
+			}
+			switch key {
+			case "size":
+				if f >= 0 && f <= math.MaxUint64 {
+					attrs.Size = uint64(f)
+				}
+			case "line":
+				if f >= 0 && f <= math.MaxUint32 {
+					attrs.Linesize = uint32(f)
+				}
+			case "ways":
+				if f >= math.MinInt32 && f <= math.MaxInt32 {
+					attrs.Associativity = int32(f)
+				}
+			}
+		}
+		if incl, ok := rawAttrs["incl"]; ok {
+			inclStr, _ := incl.(string)
+			if attrs.Inclusion, err = ParseCacheInclusion(inclStr); err != nil {
+				report(id, true, "unknown cache inclusion '%v' replaced by '%s'", incl, Inclusive)
+			}
+		}
+		return &Element{Cache: &Cache{Level: level, LogicalIndex: li, Attributes: attrs}}, ""
+	}
+
+	if device, isDevice := obj["device"].(map[string]interface{}); isDevice {
+		typeStr, _ := device["type"].(string)
+		typ, err := ParseDeviceType(typeStr)
+		if err != nil {
+			return nil, fmt.Sprintf("unknown device type '%v'", device["type"])
+		}
+		data := &Element{Device: &Device{Type: typ}}
+		for _, field := range []struct {
+			key string
+			dst *string
+		}{{"name", &data.Name}, {"bus", &data.BusID}, {"class", &data.Class}, {"path", &data.Path}, {"pf", &data.PhysicalFunction}} {
+			if val, ok := device[field.key]; ok {
+				if *field.dst, ok = val.(string); !ok {
+					report(id, true, "malformed device %s '%v' removed", field.key, val)
+				}
+			}
+		}
+		if links, ok := device["links"]; ok {
+			var err error
+			if data.Links, err = parseDeviceLinks(links); err != nil {
+				report(id, true, "%v; links removed", err)
+			}
+		}
+		if cxl, ok := device["cxl"]; ok {
+			var err error
+			if data.CXLLink, err = parseCXLLink(cxl); err != nil {
+				report(id, true, "%v; CXL link removed", err)
+			}
+		}
+		if mem, ok := device["mem"]; ok {
+			var err error
+			if data.MemoryNodes, err = parseMemoryNodes(mem); err != nil {
+				report(id, true, "%v; memory nodes removed", err)
+			}
+		}
+		return data, ""
+	}
+
+	return nil, "element is neither a processing node, a cache nor a device"
+}
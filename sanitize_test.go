@@ -0,0 +1,88 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeCleanPayload(t *testing.T) {
+	data, err := os.ReadFile("test_artifacts/t4_de.json")
+	if err != nil {
+		t.Fatalf("Error reading from file until EOF: %v\n", err)
+	}
+	clean, issues, err := Sanitize(data)
+	if err != nil {
+		t.Fatalf("Failed to sanitize a valid payload: %v\n", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("Sanitize reported issues for a valid payload: %v", issues)
+	}
+	if !bytes.Equal(clean, data) {
+		t.Errorf("Sanitize modified a valid payload:\n%s", clean)
+	}
+}
+
+func TestSanitizeRepairs(t *testing.T) {
+	payload := `{"nodes":[` +
+		`{"data":"machine","desc":[1,7,42]},` +
+		`{"data":{"processing":{"kind":"Socket","id":0}},"desc":[2,1]},` +
+		`{"data":{"cache":{"lvl":"L3Cache","li":0}},"desc":[3]},` +
+		`{"data":{"processing":{"kind":"gpu","id":0}},"desc":[4,5]},` +
+		`{"data":{"processing":{"kind":"PU","id":0}}},` +
+		`{"data":{"processing":{"kind":"thread","id":1}},"desc":[0]},` +
+		`{"data":{"processing":{"kind":"thread","id":2}}},` +
+		`{"data":{"cache":{"lvl":"2","li":0,"attrs":{"size":1024}}},"desc":[4]}` +
+		`]}`
+	clean, issues, err := Sanitize([]byte(payload))
+	if err != nil {
+		t.Fatalf("Failed to sanitize payload: %v\n", err)
+	}
+	for _, issue := range issues {
+		t.Logf("%v", issue)
+	}
+	t.Logf("Sanitized:\n%s", clean)
+
+	var tree Tree
+	if err = json.Unmarshal(clean, &tree); err != nil {
+		t.Fatalf("Failed to unmarshal sanitized payload: %v\n", err)
+	}
+	// The unknown "gpu" element and the unreachable thread are removed.
+	if tree.Size() != 6 {
+		t.Errorf("sanitized Tree has %d elements; want 6", tree.Size())
+	}
+	if len(issues) != 13 {
+		t.Errorf("got %d issues; want 13", len(issues))
+	}
+
+	if _, _, err = Sanitize([]byte(`{"nodes":[{"data":"machine","desc":[1,2]},` +
+		`{"data":{"processing":{"kind":"core","id":0}}},{"data":{"processing":{"kind":"core","id":0}}}]}`)); err == nil {
+		t.Errorf("Sanitize succeeded on a payload with duplicate IDs")
+	}
+	clean, issues, err = Sanitize([]byte(`{"nodes":[{"data":"machine","desc":[1]},` +
+		`{"data":{"device":{"type":"network","name":"eth0","bus":18}}}]}`))
+	if err != nil || len(issues) != 1 || !strings.Contains(string(clean), `{"device":{"type":"nic","name":"eth0"}}`) {
+		t.Errorf("Sanitize() = %s, %v, %v; expected the malformed bus ID of the NIC removed", clean, issues, err)
+	}
+	if _, _, err = Sanitize([]byte(`[]`)); err == nil {
+		t.Errorf("Sanitize succeeded on a non-object payload")
+	}
+}
@@ -0,0 +1,171 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Selector selects Elements of a Topology by their path in the hierarchy
+// (e.g., "package:0/numanode:1/core:3/thread:*").
+//
+// A selector is a list of steps separated by '/', each of which selects the
+// descendants (not only the children) of the Elements selected by the previous
+// step (or of the root, for the first step) that match it. A step is one of:
+//
+//	package[:<os index>|*]
+//	numanode[:<os index>|*]
+//	core[:<os index>|*]
+//	thread[:<os index>|*]
+//	cache:<level>[:<logical index>|*]
+//
+// where an omitted index is equivalent to "*", which matches any index, and
+// <level> is one of "l1" to "l5". Steps are case-insensitive, and kinds may
+// also be written in plural (e.g., "threads", "numanode:1/threads" or
+// "cache:l3:2/threads").
+//
+// Selectors implement encoding.TextMarshaler and encoding.TextUnmarshaler, so
+// that they can be embedded in configuration files and annotations directly.
+type Selector struct {
+	str   string
+	steps []func(*Element) bool
+}
+
+// ParseSelector returns the Selector parsed from the provided string (see
+// Selector for its grammar), or a non-nil error value if it is invalid.
+func ParseSelector(selector string) (*Selector, error) {
+	s := &Selector{str: selector, steps: make([]func(*Element) bool, 0)}
+	for _, step := range strings.Split(selector, "/") {
+		parts := strings.Split(strings.ToLower(strings.TrimSpace(step)), ":")
+		name, index := strings.TrimSuffix(parts[0], "s"), "*"
+		if name == "cache" {
+			if len(parts) < 2 || len(parts) > 3 {
+				return nil, fmt.Errorf("invalid selector step '%s': expected cache:<level>[:<index>]", step)
+			}
+			level, err := ParseCacheLevel(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid selector step '%s': %v", step, err)
+			}
+			if len(parts) == 3 {
+				index = parts[2]
+			}
+			match, err := indexMatcher(step, index)
+			if err != nil {
+				return nil, err
+			}
+			s.steps = append(s.steps, func(e *Element) bool {
+				return e.IsCache() && e.Level == level && match(e.LogicalIndex)
+			})
+			continue
+		}
+
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("invalid selector step '%s': expected <kind>[:<index>]", step)
+		}
+		kind, err := ParseProcessingKind(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector step '%s': %v", step, err)
+		}
+		if len(parts) == 2 {
+			index = parts[1]
+		}
+		match, err := indexMatcher(step, index)
+		if err != nil {
+			return nil, err
+		}
+		s.steps = append(s.steps, func(e *Element) bool {
+			return e.IsProcessing() && e.Kind == kind && match(e.ID)
+		})
+	}
+	return s, nil
+}
+
+// indexMatcher returns a predicate that matches the provided index of a
+// selector step, which may also be "*" to match any index.
+func indexMatcher(step, index string) (func(uint32) bool, error) {
+	if index == "*" {
+		return func(uint32) bool { return true }, nil
+	}
+	n, err := strconv.ParseUint(index, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector step '%s': invalid index '%s'", step, index)
+	}
+	return func(i uint32) bool { return i == uint32(n) }, nil
+}
+
+// String returns the string that the Selector was parsed from.
+func (s *Selector) String() string {
+	return s.str
+}
+
+// MarshalText returns the string that the Selector was parsed from.
+func (s *Selector) MarshalText() ([]byte, error) {
+	return []byte(s.str), nil
+}
+
+// UnmarshalText parses the Selector from the provided text (see
+// ParseSelector), or returns a non-nil error value if it is invalid.
+func (s *Selector) UnmarshalText(text []byte) error {
+	parsed, err := ParseSelector(string(text))
+	if err != nil {
+		return err
+	}
+	*s = *parsed
+	return nil
+}
+
+// Select returns the NodeIDs of the Elements of the provided Topology that the
+// Selector selects, in ascending order.
+func (s *Selector) Select(t *Topology) []NodeID {
+	if t.IsEmpty() {
+		return []NodeID{}
+	}
+	current := []NodeID{0}
+	for _, match := range s.steps {
+		seen := make(map[NodeID]bool)
+		next := make([]NodeID, 0)
+		for _, id := range current {
+			start, end, err := t.SubtreeRange(id)
+			if err != nil {
+				continue
+			}
+			for desc := start + 1; desc < end; desc++ {
+				if !seen[desc] && match(t.Nodes[desc].Data) {
+					seen[desc] = true
+					next = append(next, desc)
+				}
+			}
+		}
+		sort.Slice(next, func(i, j int) bool { return next[i] < next[j] })
+		current = next
+	}
+	return current
+}
+
+// Select returns the NodeIDs of the Elements of the Topology that the provided
+// selector selects (see Selector for its grammar), in ascending order, or a
+// non-nil error value if the selector is invalid.
+func (t *Topology) Select(selector string) ([]NodeID, error) {
+	s, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(t), nil
+}
@@ -0,0 +1,87 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	topo, err := FromSpec(TopologySpec{
+		Packages: 2, NUMANodesPerPackage: 2, L3PerNUMANode: 1, Cores: 4, ThreadsPerCore: 2,
+		L3Size: 32 << 20, L2Size: 1 << 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	osIDs := func(ids []NodeID) []uint32 {
+		ret := make([]uint32, 0, len(ids))
+		for _, id := range ids {
+			if data := topo.Nodes[id].Data; data.IsProcessing() {
+				ret = append(ret, data.ID)
+			} else {
+				ret = append(ret, data.LogicalIndex)
+			}
+		}
+		return ret
+	}
+
+	for _, tc := range []struct {
+		selector string
+		want     string
+	}{
+		{"package:0/numanode:1/core:7/thread:*", "7,23"},
+		{"package:1/threads", "8-15,24-31"},
+		{"NUMANode:2/Core", "0-3"},
+		{"cache:l3:3/threads", "12-15,28-31"},
+		{"cache:l2", "0-15"},
+		{"numanode:1/cache:l2:*", "4-7"},
+		{"package:2", ""},
+	} {
+		ids, err := topo.Select(tc.selector)
+		if err != nil {
+			t.Errorf("%s: %v", tc.selector, err)
+			continue
+		}
+		if got := FormatCPUList(osIDs(ids)); got != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.selector, got, tc.want)
+		}
+	}
+
+	for _, selector := range []string{"", "socket:0", "cache", "cache:l9", "core:x", "package:0:1", "cache:l3:1:2"} {
+		if _, err := topo.Select(selector); err == nil {
+			t.Errorf("expected an error for selector '%s'", selector)
+		}
+	}
+
+	var config struct {
+		Selector *Selector `json:"selector"`
+	}
+	if err = json.Unmarshal([]byte(`{"selector":"numanode:3/threads"}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	if got := FormatCPUList(osIDs(config.Selector.Select(topo))); got != "12-15,28-31" {
+		t.Errorf("got %s from an unmarshalled Selector", got)
+	}
+	if data, _ := json.Marshal(config); string(data) != `{"selector":"numanode:3/threads"}` {
+		t.Errorf("got %s", data)
+	}
+	if err = json.Unmarshal([]byte(`{"selector":"core:-1"}`), &config); err == nil {
+		t.Errorf("expected an error for an invalid Selector")
+	}
+}
@@ -0,0 +1,122 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// signedPayload is the JSON representation of a signed Topology (see Sign).
+type signedPayload struct {
+	Topology json.RawMessage `json:"topology"`
+	HMAC     string          `json:"hmac"`
+}
+
+// Sign returns the canonical JSON serialization of the provided Topology (as
+// produced by its MarshalJSON), signed with an HMAC-SHA256 under the provided
+// key, or a non-nil error value in case of failure (e.g., if the Topology is
+// not valid).
+//
+// The payload is a JSON object with the fields "topology", which holds the
+// Topology as a bare Tree payload would, and "hmac", which holds the
+// hex-encoded HMAC of the exact bytes of "topology"; it can be verified and
+// decoded through Verify or Decoder.DecodeSigned, and must be shipped as is.
+func Sign(topo *Topology, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errors.New("empty HMAC key")
+	}
+	if nil == topo || nil == topo.Tree {
+		return nil, ErrNilTree
+	}
+	if err := topo.Validate(); err != nil {
+		return nil, err
+	}
+	canonical, err := json.Marshal(topo)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, len(canonical)+96)
+	buf = append(buf, `{"topology":`...)
+	buf = append(buf, canonical...)
+	buf = append(buf, `,"hmac":"`...)
+	buf = append(buf, hex.EncodeToString(topologyMAC(canonical, key))...)
+	buf = append(buf, `"}`...)
+	return buf, nil
+}
+
+// Verify decodes the provided signed payload (see Sign) and returns its
+// Topology if its HMAC under the provided key is valid, through a Decoder
+// that enforces DefaultDecodeLimits (see Decoder.DecodeSigned).
+func Verify(data, key []byte) (*Topology, error) {
+	return NewDecoder(DefaultDecodeLimits).DecodeSigned(bytes.NewReader(data), key)
+}
+
+// DecodeSigned reads a signed JSON payload (see Sign) from the provided
+// io.Reader and returns the Topology decoded from it, much like Decode does,
+// or a non-nil error value in case of failure.
+//
+// The HMAC is verified against the exact bytes of the "topology" field of the
+// payload, before any of them is decoded; only then is the Topology decoded,
+// under the DecodeLimits of the Decoder (and renumbered against its
+// Reference, if any). If the HMAC is missing, malformed or does not match,
+// ErrSignatureMismatch is returned.
+func (d *Decoder) DecodeSigned(r io.Reader, key []byte) (*Topology, error) {
+	if len(key) == 0 {
+		return nil, errors.New("empty HMAC key")
+	}
+	if d.Limits.MaxBytes > 0 {
+		r = io.LimitReader(r, d.Limits.MaxBytes+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if d.Limits.MaxBytes > 0 && int64(len(data)) > d.Limits.MaxBytes {
+		return nil, fmt.Errorf("%w: payload is larger than %d bytes", ErrLimitExceeded, d.Limits.MaxBytes)
+	}
+
+	var payload signedPayload
+	if err = json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("invalid signed payload: %w", err)
+	}
+	if payload.HMAC == "" {
+		return nil, fmt.Errorf("%w: missing HMAC", ErrSignatureMismatch)
+	}
+	mac, err := hex.DecodeString(payload.HMAC)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed HMAC", ErrSignatureMismatch)
+	}
+	if !hmac.Equal(mac, topologyMAC(payload.Topology, key)) {
+		return nil, ErrSignatureMismatch
+	}
+	return d.Decode(bytes.NewReader(payload.Topology))
+}
+
+// topologyMAC returns the HMAC-SHA256 of the provided canonical serialization
+// of a Topology under the provided key.
+func topologyMAC(canonical, key []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(canonical)
+	return h.Sum(nil)
+}
@@ -0,0 +1,86 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 1, 2, 2)}
+	key := []byte("secret")
+	signed, err := Sign(topo, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Verify(signed, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Nodes, topo.Nodes) {
+		t.Error("verified Topology differs from the signed one")
+	}
+
+	// The HMAC covers the exact bytes that were signed, so even encodings
+	// that decode to the same Topology are rejected.
+	var indented bytes.Buffer
+	if err = json.Indent(&indented, signed, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Verify(indented.Bytes(), key); !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("got %v for a reformatted payload; expected ErrSignatureMismatch", err)
+	}
+
+	// Decoders that renumber against a Reference verify the payload before
+	// renumbering it.
+	decoder := NewDecoder(DefaultDecodeLimits)
+	decoder.Reference = topo
+	if _, err = decoder.DecodeSigned(bytes.NewReader(signed), key); err != nil {
+		t.Errorf("failed to verify a payload through a Decoder with a Reference: %v", err)
+	}
+
+	tampered := strings.Replace(string(signed), `"kind":"thread","id":3`, `"kind":"thread","id":9`, 1)
+	if tampered == string(signed) {
+		t.Fatal("failed to tamper with the payload")
+	}
+	for name, tc := range map[string]struct {
+		payload string
+		key     []byte
+	}{
+		"wrong key": {string(signed), []byte("other")},
+		"tampered":  {tampered, key},
+		"missing":   {`{"topology":` + string(mustMarshal(t, topo)) + `}`, key},
+		"malformed": {`{"topology":` + string(mustMarshal(t, topo)) + `,"hmac":"zz"}`, key},
+		"unsigned":  {string(mustMarshal(t, topo)), key},
+	} {
+		if _, err := Verify([]byte(tc.payload), tc.key); !errors.Is(err, ErrSignatureMismatch) {
+			t.Errorf("%s: got %v; expected ErrSignatureMismatch", name, err)
+		}
+	}
+
+	if _, err = Sign(topo, nil); err == nil {
+		t.Error("signing with an empty key succeeded")
+	}
+	if _, err = NewDecoder(DecodeLimits{MaxBytes: 16}).DecodeSigned(bytes.NewReader(signed), key); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got %v for an oversized payload; expected ErrLimitExceeded", err)
+	}
+}
@@ -0,0 +1,254 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TopologySpec describes a symmetric Topology in terms of the numbers of its
+// Elements at each level of the hierarchy and the sizes of its caches, so that
+// realistic Topologies can be built (e.g., for tests and simulations) without
+// writing out their TreeNodes (see FromSpec).
+type TopologySpec struct {
+	Packages            int `json:"packages"`
+	NUMANodesPerPackage int `json:"numa_nodes_per_package"`
+	// L3PerNUMANode is the number of L3 caches per NUMA node (e.g., more
+	// than 1 for chiplet designs); if 0, there are no L3 caches.
+	L3PerNUMANode int `json:"l3_per_numa_node,omitempty"`
+	// Cores is the number of Cores per L3 cache, or per NUMA node if there
+	// are no L3 caches.
+	Cores int `json:"cores"`
+	// ThreadsPerCore is the number of hardware threads per Core (i.e., 1
+	// if SMT is disabled).
+	ThreadsPerCore int `json:"threads_per_core"`
+	// L3Size, L2Size and L1Size are the sizes (in bytes) of each L3, L2
+	// and L1 cache, where each Core has its own L2 and L1 caches; if L2Size
+	// or L1Size is 0, there are no caches of the level.
+	L3Size uint64 `json:"l3_size,omitempty"`
+	L2Size uint64 `json:"l2_size,omitempty"`
+	L1Size uint64 `json:"l1_size,omitempty"`
+	// LineSize and Associativity are the attributes of all caches; if 0,
+	// they default to 64 bytes and 8 ways, respectively.
+	LineSize      uint32 `json:"line_size,omitempty"`
+	Associativity int32  `json:"associativity,omitempty"`
+}
+
+// FromSpec returns a new Topology built as the provided TopologySpec describes,
+// or a non-nil error value if the TopologySpec is invalid.
+//
+// Elements are nested as Machine, Package, NUMA node, L3, L2, L1, Core and
+// Thread. NUMA nodes are numbered across the machine and Cores within their
+// Package, as Linux does, while hardware threads are numbered like Linux does
+// on most x86 machines: the first threads of all Cores come first (0 to N-1,
+// for N Cores), followed by their second threads (N to 2N-1), and so on.
+func FromSpec(spec TopologySpec) (*Topology, error) {
+	for _, count := range []struct {
+		name  string
+		value int
+	}{
+		{"Packages", spec.Packages},
+		{"NUMANodesPerPackage", spec.NUMANodesPerPackage},
+		{"Cores", spec.Cores},
+		{"ThreadsPerCore", spec.ThreadsPerCore},
+	} {
+		if count.value <= 0 {
+			return nil, fmt.Errorf("invalid TopologySpec: %s must be positive", count.name)
+		}
+	}
+	if spec.L3PerNUMANode < 0 {
+		return nil, fmt.Errorf("invalid TopologySpec: L3PerNUMANode must not be negative")
+	}
+
+	hasL3 := spec.L3PerNUMANode > 0
+	l3s := spec.L3PerNUMANode
+	if !hasL3 {
+		l3s = 1
+	}
+	// Check the size of the Tree in floating point, to avoid overflows.
+	numaNodes := float64(spec.Packages) * float64(spec.NUMANodesPerPackage)
+	cores := numaNodes * float64(l3s) * float64(spec.Cores)
+	size := 1 + float64(spec.Packages) + numaNodes + cores*(1+float64(spec.ThreadsPerCore))
+	if hasL3 {
+		size += numaNodes * float64(l3s)
+	}
+	if spec.L2Size > 0 {
+		size += cores
+	}
+	if spec.L1Size > 0 {
+		size += cores
+	}
+	if size > math.MaxUint32 {
+		return nil, fmt.Errorf("invalid TopologySpec: %w", ErrLimitExceeded)
+	}
+	coresPerPackage := spec.NUMANodesPerPackage * l3s * spec.Cores
+
+	lineSize, ways := spec.LineSize, spec.Associativity
+	if lineSize == 0 {
+		lineSize = 64
+	}
+	if ways == 0 {
+		ways = 8
+	}
+	tree := NewTree(make([]TreeNode, 1, int(size)))
+	tree.Nodes[0].Data = &Element{}
+	add := func(parent NodeID, data *Element) NodeID {
+		id := NodeID(len(tree.Nodes))
+		tree.Nodes = append(tree.Nodes, TreeNode{Data: data})
+		tree.Nodes[parent].Children = append(tree.Nodes[parent].Children, id)
+		return id
+	}
+	var logicalIndex [L5 + 1]uint32
+	cache := func(parent NodeID, level CacheLevel, size uint64, present bool) NodeID {
+		if !present {
+			return parent
+		}
+		li := logicalIndex[level]
+		logicalIndex[level]++
+		return add(parent, &Element{Cache: &Cache{
+			Level:        level,
+			LogicalIndex: li,
+			Attributes:   &CacheAttributes{Size: size, Linesize: lineSize, Associativity: ways},
+		}})
+	}
+	processing := func(parent NodeID, kind ProcessingKind, id int) NodeID {
+		return add(parent, &Element{Processing: &Processing{Kind: kind, ID: uint32(id)}})
+	}
+
+	var numaID, coreIndex int
+	for p := 0; p < spec.Packages; p++ {
+		pkg := processing(0, Package, p)
+		for n := 0; n < spec.NUMANodesPerPackage; n++ {
+			numa := processing(pkg, NUMANode, numaID)
+			numaID++
+			for l := 0; l < l3s; l++ {
+				l3 := cache(numa, L3, spec.L3Size, hasL3)
+				for c := 0; c < spec.Cores; c++ {
+					l2 := cache(l3, L2, spec.L2Size, spec.L2Size > 0)
+					l1 := cache(l2, L1, spec.L1Size, spec.L1Size > 0)
+					core := processing(l1, Core, coreIndex%coresPerPackage)
+					for th := 0; th < spec.ThreadsPerCore; th++ {
+						processing(core, Thread, th*int(cores)+coreIndex)
+					}
+					coreIndex++
+				}
+			}
+		}
+	}
+	if err := tree.Validate(); err != nil {
+		return nil, err
+	}
+	return &Topology{tree}, nil
+}
+
+// GenerateSynthetic returns a new Topology built as the provided compact shape
+// string describes, or a non-nil error value if it is malformed (see FromSpec).
+//
+// The shape is a colon-separated list of the numbers of Packages, NUMA nodes
+// per Package, Cores per NUMA node and hardware threads per Core (e.g.,
+// "2:4:8:2"), optionally followed by the sizes of the L3, L2 and L1 caches, in
+// that order (e.g., "2:4:8:2:32M:1M:32K"). Sizes are in bytes, with an optional
+// binary suffix (K, M or G, optionally followed by "iB" or "B"); a size of 0
+// omits the caches of the level altogether, while the L3 size may be prefixed
+// by the number of L3 caches per NUMA node (e.g., "2x16M"), which must divide
+// the number of Cores per NUMA node. Omitted cache sizes default to one 32MiB
+// L3 per NUMA node, a 1MiB L2 and a 32KiB L1.
+func GenerateSynthetic(shape string) (*Topology, error) {
+	fields := strings.Split(shape, ":")
+	if len(fields) < 4 || len(fields) > 7 {
+		return nil, fmt.Errorf("invalid synthetic shape '%s': expected 4 to 7 fields", shape)
+	}
+	counts := make([]int, 4)
+	for i := range counts {
+		count, err := strconv.Atoi(strings.TrimSpace(fields[i]))
+		if err != nil || count <= 0 {
+			return nil, fmt.Errorf("invalid synthetic shape '%s': invalid count '%s'", shape, fields[i])
+		}
+		counts[i] = count
+	}
+	spec := TopologySpec{
+		Packages:            counts[0],
+		NUMANodesPerPackage: counts[1],
+		L3PerNUMANode:       1,
+		Cores:               counts[2],
+		ThreadsPerCore:      counts[3],
+		L3Size:              32 << 20,
+		L2Size:              1 << 20,
+		L1Size:              32 << 10,
+	}
+
+	for i, field := range fields[4:] {
+		field = strings.TrimSpace(field)
+		var err error
+		switch i {
+		case 0:
+			if count, size, ok := strings.Cut(field, "x"); ok {
+				if spec.L3PerNUMANode, err = strconv.Atoi(count); err != nil || spec.L3PerNUMANode <= 0 {
+					return nil, fmt.Errorf("invalid synthetic shape '%s': invalid L3 count '%s'", shape, count)
+				}
+				field = size
+			}
+			if spec.L3Size, err = parseSize(field); err == nil && spec.L3Size == 0 {
+				spec.L3PerNUMANode = 0
+			}
+		case 1:
+			spec.L2Size, err = parseSize(field)
+		case 2:
+			spec.L1Size, err = parseSize(field)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid synthetic shape '%s': %v", shape, err)
+		}
+	}
+	if spec.L3PerNUMANode > 1 {
+		if spec.Cores%spec.L3PerNUMANode != 0 {
+			return nil, fmt.Errorf("invalid synthetic shape '%s': %d L3 caches per NUMA node do not divide %d Cores",
+				shape, spec.L3PerNUMANode, spec.Cores)
+		}
+		spec.Cores /= spec.L3PerNUMANode
+	}
+	return FromSpec(spec)
+}
+
+// parseSize returns the number of bytes parsed from the provided string, which
+// may carry a binary suffix (e.g., "32K", "1MiB" or "2GB"), or a non-nil error
+// value if parsing fails.
+func parseSize(str string) (uint64, error) {
+	num := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(str), "B"), "I")
+	shift := 0
+	if len(num) > 0 {
+		switch num[len(num)-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		}
+		if shift > 0 {
+			num = num[:len(num)-1]
+		}
+	}
+	size, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || size > math.MaxUint64>>shift {
+		return 0, fmt.Errorf("invalid size '%s'", str)
+	}
+	return size << shift, nil
+}
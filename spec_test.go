@@ -0,0 +1,128 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFromSpec(t *testing.T) {
+	topo, err := FromSpec(TopologySpec{
+		Packages:            2,
+		NUMANodesPerPackage: 2,
+		L3PerNUMANode:       2,
+		Cores:               4,
+		ThreadsPerCore:      2,
+		L3Size:              32 << 20,
+		L2Size:              1 << 20,
+		L1Size:              48 << 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := HardwareProfile{
+		Packages: 2, NUMANodes: 4, Cores: 32, Threads: 64, ThreadsPerCore: 2,
+		Caches: []CacheProfile{
+			{Level: L1, Count: 32, TotalSize: 32 * 48 << 10},
+			{Level: L2, Count: 32, TotalSize: 32 << 20},
+			{Level: L3, Count: 8, TotalSize: 8 * 32 << 20},
+		},
+	}
+	got := topo.Profile()
+	if got.Packages != want.Packages || got.NUMANodes != want.NUMANodes || got.Cores != want.Cores ||
+		got.Threads != want.Threads || got.ThreadsPerCore != want.ThreadsPerCore || len(got.Caches) != 3 {
+		t.Fatalf("got profile %+v; expected %+v", got, want)
+	}
+	for i := range want.Caches {
+		if got.Caches[i] != want.Caches[i] {
+			t.Errorf("got %+v; expected %+v", got.Caches[i], want.Caches[i])
+		}
+	}
+
+	// SMT siblings are numbered N apart, and Cores within their Package.
+	threads := topo.ThreadsDetailed()
+	if threads[0].Processing.ID != 0 || threads[1].Processing.ID != 32 {
+		t.Errorf("got threads %d and %d on the first Core; expected 0 and 32",
+			threads[0].Processing.ID, threads[1].Processing.ID)
+	}
+	if cores := topo.CoresDetailed(); cores[16].Processing.ID != 0 || cores[31].Processing.ID != 15 {
+		t.Errorf("got Core IDs %d and %d in the second Package", cores[16].Processing.ID, cores[31].Processing.ID)
+	}
+
+	// Without caches, Cores are attached to their NUMA nodes.
+	bare, err := FromSpec(TopologySpec{Packages: 1, NUMANodesPerPackage: 1, Cores: 2, ThreadsPerCore: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bare.Size() != 7 || bare.Nodes[3].Data.Kind != Core {
+		t.Errorf("got %d elements; expected Machine, Package, NUMA node and 2 Cores with a Thread each", bare.Size())
+	}
+
+	if _, err = FromSpec(TopologySpec{Packages: 1, NUMANodesPerPackage: 1, Cores: 1}); err == nil {
+		t.Errorf("expected an error for zero threads per Core")
+	}
+	huge := TopologySpec{Packages: 1 << 20, NUMANodesPerPackage: 1 << 20, Cores: 1 << 20, ThreadsPerCore: 2}
+	if _, err = FromSpec(huge); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got %v for a huge TopologySpec; expected ErrLimitExceeded", err)
+	}
+}
+
+func TestGenerateSynthetic(t *testing.T) {
+	for _, tc := range []struct {
+		shape                                      string
+		packages, numaNodes, cores, threads, ratio int
+		caches                                     []CacheProfile
+	}{
+		{"2:4:8:2", 2, 8, 64, 128, 2, []CacheProfile{
+			{Level: L1, Count: 64, TotalSize: 64 * 32 << 10},
+			{Level: L2, Count: 64, TotalSize: 64 << 20},
+			{Level: L3, Count: 8, TotalSize: 8 * 32 << 20},
+		}},
+		{"1:1:4:1:0:512KiB:0", 1, 1, 4, 4, 1, []CacheProfile{
+			{Level: L2, Count: 4, TotalSize: 4 * 512 << 10},
+		}},
+		{"1:2:8:2:2x16M:2MB:48K", 1, 2, 16, 32, 2, []CacheProfile{
+			{Level: L1, Count: 16, TotalSize: 16 * 48 << 10},
+			{Level: L2, Count: 16, TotalSize: 32 << 20},
+			{Level: L3, Count: 4, TotalSize: 4 * 16 << 20},
+		}},
+	} {
+		topo, err := GenerateSynthetic(tc.shape)
+		if err != nil {
+			t.Errorf("GenerateSynthetic(%q): %v", tc.shape, err)
+			continue
+		}
+		got := topo.Profile()
+		if got.Packages != tc.packages || got.NUMANodes != tc.numaNodes || got.Cores != tc.cores ||
+			got.Threads != tc.threads || got.ThreadsPerCore != tc.ratio || len(got.Caches) != len(tc.caches) {
+			t.Errorf("GenerateSynthetic(%q): got profile %+v", tc.shape, got)
+			continue
+		}
+		for i := range tc.caches {
+			if got.Caches[i] != tc.caches[i] {
+				t.Errorf("GenerateSynthetic(%q): got %+v; expected %+v", tc.shape, got.Caches[i], tc.caches[i])
+			}
+		}
+	}
+
+	for _, shape := range []string{"", "2:4:8", "2:4:8:0", "2:4:x:2", "1:1:4:1:3x8M", "1:1:4:1:8Q", "1:1:1:1:1:1:1:1"} {
+		if _, err := GenerateSynthetic(shape); err == nil {
+			t.Errorf("expected an error for shape %q", shape)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "sort"
+
+// RenumberLike returns a Topology with the same Elements as this one (which
+// are shared with it), renumbered so that they keep the NodeIDs of the
+// respective Elements of the provided previous Topology of the same machine as
+// far as possible, or a non-nil error value in case of failure. Elements are
+// matched between the Topologies by their keys (see ElementKey).
+//
+// Since the Elements of a Tree are stored in pre-order, only the order of the
+// children of each Element can change: children that exist in the previous
+// Topology are ordered as they were there, followed by any new ones, in their
+// original order. Hence, if both Topologies contain the same Elements under
+// the same parents, all NodeIDs are preserved, no matter the traversal order
+// of the collector that produced either of them; otherwise, the NodeIDs of the
+// Elements that precede the first difference in pre-order are preserved, and
+// MapNodeIDs can be used to translate the rest.
+func (t *Topology) RenumberLike(prev *Topology) (*Topology, error) {
+	keys, err := t.elementKeys()
+	if err != nil {
+		return nil, err
+	}
+	prevKeys, err := prev.elementKeys()
+	if err != nil {
+		return nil, err
+	}
+	prevIDs := make(map[string]NodeID, len(prevKeys))
+	for id, key := range prevKeys {
+		prevIDs[key] = NodeID(id)
+	}
+	rank := func(id NodeID) (NodeID, bool) {
+		prevID, ok := prevIDs[keys[id]]
+		return prevID, ok
+	}
+
+	nodes := make([]TreeNode, 0, len(t.Nodes))
+	var visit func(id NodeID) NodeID
+	visit = func(id NodeID) NodeID {
+		newID := NodeID(len(nodes))
+		nodes = append(nodes, TreeNode{Data: t.Nodes[id].Data})
+		children := append([]NodeID(nil), t.Nodes[id].Children...)
+		sort.SliceStable(children, func(i, j int) bool {
+			ri, oki := rank(children[i])
+			rj, okj := rank(children[j])
+			if oki != okj {
+				return oki
+			}
+			return oki && ri < rj
+		})
+		if len(children) > 0 {
+			nodes[newID].Children = make([]NodeID, 0, len(children))
+		}
+		for _, child := range children {
+			childID := visit(child)
+			nodes[newID].Children = append(nodes[newID].Children, childID)
+		}
+		return newID
+	}
+	if len(t.Nodes) > 0 {
+		visit(0)
+	}
+	return &Topology{NewTree(nodes)}, nil
+}
+
+// MapNodeIDs returns the NodeIDs of the Elements of the Topology to, indexed by
+// the NodeIDs of the respective Elements of the Topology from, for all
+// Elements that exist in both of them, or a non-nil error value in case of
+// failure. Elements are matched between the Topologies by their keys (see
+// ElementKey).
+//
+// Systems that persist NodeIDs may use it to translate them after the Topology
+// of a machine changes (see RenumberLike).
+func MapNodeIDs(from, to *Topology) (map[NodeID]NodeID, error) {
+	fromKeys, err := from.elementKeys()
+	if err != nil {
+		return nil, err
+	}
+	toKeys, err := to.elementKeys()
+	if err != nil {
+		return nil, err
+	}
+	toIDs := make(map[string]NodeID, len(toKeys))
+	for id, key := range toKeys {
+		toIDs[key] = NodeID(id)
+	}
+	ret := make(map[NodeID]NodeID, len(fromKeys))
+	for id, key := range fromKeys {
+		if toID, ok := toIDs[key]; ok {
+			ret[NodeID(id)] = toID
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,111 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// reversedTopology returns a copy of the Topology with the children of every
+// Element in reverse order, renumbered in pre-order, as a collector with a
+// different traversal order would produce.
+func reversedTopology(t *Topology) *Topology {
+	nodes := make([]TreeNode, 0, len(t.Nodes))
+	var visit func(id NodeID) NodeID
+	visit = func(id NodeID) NodeID {
+		newID := NodeID(len(nodes))
+		nodes = append(nodes, TreeNode{Data: t.Nodes[id].Data})
+		for i := len(t.Nodes[id].Children) - 1; i >= 0; i-- {
+			childID := visit(t.Nodes[id].Children[i])
+			nodes[newID].Children = append(nodes[newID].Children, childID)
+		}
+		return newID
+	}
+	visit(0)
+	return &Topology{NewTree(nodes)}
+}
+
+func TestRenumberLike(t *testing.T) {
+	prev := &Topology{syntheticTree(2, 2, 2, 2)}
+	reversed := reversedTopology(prev)
+	if reversed.Nodes[1].Data.ID == prev.Nodes[1].Data.ID {
+		t.Fatalf("the reversed Topology has the same NodeIDs")
+	}
+
+	renumbered, err := reversed.RenumberLike(prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = renumbered.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for id := range prev.Nodes {
+		want, _ := prev.ElementKey(NodeID(id))
+		if got, _ := renumbered.ElementKey(NodeID(id)); got != want {
+			t.Errorf("got %s as node %d; expected %s", got, id, want)
+		}
+	}
+
+	// After a Core goes offline, the Elements before it keep their NodeIDs.
+	offline, p, err := NewMutator(1).Perturb(prev, OfflineCore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renumbered, err = reversedTopology(offline).RenumberLike(prev); err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := MapNodeIDs(prev, renumbered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mapping) != offline.Size() {
+		t.Errorf("got %d mapped NodeIDs; expected %d", len(mapping), offline.Size())
+	}
+	for id, newID := range mapping {
+		if id < p.Elements[0] && newID != id {
+			t.Errorf("node %d became %d, although it precedes the offline Core %d", id, newID, p.Elements[0])
+		}
+	}
+}
+
+func TestDecoderReference(t *testing.T) {
+	prev := &Topology{syntheticTree(1, 2, 2, 1)}
+	payload, err := json.Marshal(reversedTopology(prev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder(DecodeLimits{})
+	dec.Reference = prev
+	topo, err := dec.Decode(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mustMarshal(t, topo), mustMarshal(t, prev); !bytes.Equal(got, want) {
+		t.Errorf("got %s; expected %s", got, want)
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
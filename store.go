@@ -0,0 +1,213 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Store persists the Topologies of the nodes of a cluster, keyed by the nodes'
+// names, so that multiple components (e.g., the node agents that discover the
+// Topologies and the schedulers that consume them) can share them.
+//
+// All methods return a non-nil error value in case of failure; Get and Delete
+// return ErrNodeNotFound if the Store holds no Topology for the node.
+type Store interface {
+	// Put stores the Topology of the node with the provided name,
+	// replacing any previous one.
+	Put(ctx context.Context, name string, topo *Topology) error
+	// Get returns the Topology of the node with the provided name.
+	Get(ctx context.Context, name string) (*Topology, error)
+	// List returns the Topologies of all nodes in the Store.
+	List(ctx context.Context) (*ClusterTopology, error)
+	// Delete removes the Topology of the node with the provided name.
+	Delete(ctx context.Context, name string) error
+	// Watch returns a channel that receives a StoreEvent for every
+	// subsequent change to the Store, in order, until the provided
+	// context is done; the channel is closed then, or if watching fails.
+	Watch(ctx context.Context) (<-chan StoreEvent, error)
+}
+
+// StoreEvent describes a change to a Store.
+type StoreEvent struct {
+	// Name is the name of the node whose Topology changed.
+	Name string
+	// Topology is the new Topology of the node, or nil if it was deleted.
+	Topology *Topology
+}
+
+// MemoryStore is a Store that keeps the Topologies in memory, e.g., for tests
+// or for components that run in a single process.
+//
+// A MemoryStore is safe for concurrent use by multiple goroutines. Changes never
+// block on watchers: each watcher has its own queue of pending events, which
+// is delivered in order by a dedicated goroutine, without holding the lock of
+// the MemoryStore; hence, watchers may freely call back into the MemoryStore
+// while handling events. Events queue up for as long as a watcher does not
+// receive them, until its context is done.
+type MemoryStore struct {
+	mu       sync.Mutex
+	nodes    map[string]*Topology
+	watchers []*memoryWatcher
+}
+
+// memoryWatcher is a watcher of a MemoryStore.
+type memoryWatcher struct {
+	ctx    context.Context
+	events chan StoreEvent
+
+	// mu protects queue, which holds the events that are pending delivery;
+	// wake is signalled whenever an event is queued.
+	mu    sync.Mutex
+	queue []StoreEvent
+	wake  chan struct{}
+}
+
+// enqueue queues the provided StoreEvent for delivery to the watcher, without
+// blocking.
+func (w *memoryWatcher) enqueue(event StoreEvent) {
+	w.mu.Lock()
+	w.queue = append(w.queue, event)
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the queued events to the watcher, in order, until its context
+// is done, at which point it removes the watcher from the provided MemoryStore
+// and closes its channel.
+func (w *memoryWatcher) run(s *MemoryStore) {
+	defer func() {
+		s.mu.Lock()
+		for i := range s.watchers {
+			if s.watchers[i] == w {
+				s.watchers = append(s.watchers[:i], s.watchers[i+1:]...)
+				break
+			}
+		}
+		s.mu.Unlock()
+		close(w.events)
+	}()
+	for {
+		w.mu.Lock()
+		queue := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+		for _, event := range queue {
+			select {
+			case w.events <- event:
+			case <-w.ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-w.wake:
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// NewMemoryStore returns a new, empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nodes: make(map[string]*Topology)}
+}
+
+// Put stores the Topology of the node with the provided name, replacing any
+// previous one, or returns a non-nil error value if the name is empty, the
+// Topology is nil or empty, or the provided context is done. The Topology must
+// not be modified afterwards.
+func (s *MemoryStore) Put(ctx context.Context, name string, topo *Topology) error {
+	if name == "" {
+		return fmt.Errorf("empty node name")
+	}
+	if nil == topo || topo.IsEmpty() {
+		return fmt.Errorf("node '%s': %w", name, ErrEmptyTree)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes[name] = topo
+	s.notify(StoreEvent{Name: name, Topology: topo})
+	return nil
+}
+
+// Get returns the Topology of the node with the provided name, or
+// ErrNodeNotFound if the MemoryStore holds none.
+func (s *MemoryStore) Get(ctx context.Context, name string) (*Topology, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	topo, ok := s.nodes[name]
+	if !ok {
+		return nil, fmt.Errorf("node '%s': %w", name, ErrNodeNotFound)
+	}
+	return topo, nil
+}
+
+// List returns the Topologies of all nodes in the MemoryStore.
+func (s *MemoryStore) List(ctx context.Context) (*ClusterTopology, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cluster := NewClusterTopology()
+	for name, topo := range s.nodes {
+		cluster.Nodes[name] = topo
+	}
+	return cluster, nil
+}
+
+// Delete removes the Topology of the node with the provided name, or returns
+// ErrNodeNotFound if the MemoryStore holds none, or a non-nil error value if
+// the provided context is done.
+func (s *MemoryStore) Delete(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.nodes[name]; !ok {
+		return fmt.Errorf("node '%s': %w", name, ErrNodeNotFound)
+	}
+	delete(s.nodes, name)
+	s.notify(StoreEvent{Name: name})
+	return nil
+}
+
+// Watch returns a channel that receives a StoreEvent for every subsequent
+// change to the MemoryStore, until the provided context is done.
+func (s *MemoryStore) Watch(ctx context.Context) (<-chan StoreEvent, error) {
+	w := &memoryWatcher{ctx: ctx, events: make(chan StoreEvent), wake: make(chan struct{}, 1)}
+	s.mu.Lock()
+	s.watchers = append(s.watchers, w)
+	s.mu.Unlock()
+	go w.run(s)
+	return w.events, nil
+}
+
+// notify queues the provided StoreEvent for delivery to all watchers, without
+// blocking; it must be called with the lock held, so that all watchers receive
+// the changes in the order they were made.
+func (s *MemoryStore) notify(event StoreEvent) {
+	for _, w := range s.watchers {
+		w.enqueue(event)
+	}
+}
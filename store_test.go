@@ -0,0 +1,338 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStoreReentrantWatcher(t *testing.T) {
+	store := NewMemoryStore()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events, err := store.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The watcher calls back into the store while handling each event,
+	// while other changes are in flight.
+	const writers, puts = 4, 25
+	done := make(chan int)
+	go func() {
+		received := 0
+		for range events {
+			if _, err := store.List(ctx); err != nil {
+				t.Error(err)
+			}
+			if received++; received == writers*puts {
+				break
+			}
+		}
+		done <- received
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < puts; j++ {
+				if err := store.Put(ctx, fmt.Sprintf("node-%d-%d", i, j), &Topology{syntheticTree(1, 1, 1, 1)}); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	select {
+	case received := <-done:
+		if received != writers*puts {
+			t.Errorf("got %d events; expected %d", received, writers*puts)
+		}
+	case <-ctx.Done():
+		t.Fatal("the re-entrant watcher deadlocked")
+	}
+
+	canceled, cancelPut := context.WithCancel(context.Background())
+	cancelPut()
+	if err = store.Put(canceled, "node-x", &Topology{syntheticTree(1, 1, 1, 1)}); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v for a canceled context; expected context.Canceled", err)
+	}
+}
+
+func TestEtcdStore(t *testing.T) {
+	fake := newFakeEtcd()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	store := NewEtcdStore(server.URL+"/", "/actik8s/topologies/", server.Client())
+	testStore(t, store)
+
+	// Keys outside the prefix are not listed.
+	other := NewEtcdStore(server.URL, "/other/", server.Client())
+	if err := other.Put(context.Background(), "node-x", &Topology{syntheticTree(1, 1, 1, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if cluster, err := store.List(context.Background()); err != nil || cluster.Size() != 1 {
+		t.Errorf("got %v (%v) from List; expected a single node", cluster.Names(), err)
+	}
+
+	// Payloads are decoded under the DecodeLimits of the store.
+	if err := store.Put(context.Background(), "node-big", &Topology{syntheticTree(2, 2, 4, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	store.Limits = DecodeLimits{MaxNodes: 8}
+	if _, err := store.Get(context.Background(), "node-big"); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got %v for an oversized payload; expected ErrLimitExceeded", err)
+	}
+
+	// Watches read newline-delimited messages, however they are split
+	// into chunks, and skip those larger than four times MaxBytes.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store.Limits = DecodeLimits{MaxBytes: 1024}
+	events, err := store.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, _ := json.Marshal(&Topology{syntheticTree(1, 1, 1, 1)})
+	msg, _ := json.Marshal(etcdWatchResponse{Result: &etcdWatchResult{Events: []etcdWatchEvent{
+		{KV: etcdKeyValue{Key: []byte("/actik8s/topologies/node-split"), Value: value}},
+	}}})
+	huge, _ := json.Marshal(etcdWatchResponse{Result: &etcdWatchResult{Events: []etcdWatchEvent{
+		{KV: etcdKeyValue{Key: append([]byte("/actik8s/topologies/node-"), bytes.Repeat([]byte{'x'}, 1<<20)...), Value: value}},
+	}}})
+	fake.inject(append(huge, '\n'), msg[:len(msg)/2], append(msg[len(msg)/2:], '\n'))
+	if err = store.Delete(ctx, "node-big"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"node-split", "node-big"} {
+		select {
+		case event := <-events:
+			if event.Name != want {
+				t.Errorf("got an event for %s; expected %s", event.Name, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for an event for %s", want)
+		}
+	}
+}
+
+// testStore exercises the provided, initially empty, Store.
+func testStore(t *testing.T, store Store) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	events, err := store.Watch(watchCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received []StoreEvent
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			received = append(received, event)
+			if len(received) == 3 {
+				stopWatching()
+			}
+		}
+	}()
+
+	small, large := &Topology{syntheticTree(1, 1, 2, 2)}, &Topology{syntheticTree(2, 2, 4, 2)}
+	if err = store.Put(ctx, "node-a", small); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Put(ctx, "node-b", large); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Put(ctx, "", small); err == nil {
+		t.Errorf("expected an error for an empty node name")
+	}
+	if topo, err := store.Get(ctx, "node-b"); err != nil || topo.Size() != large.Size() {
+		t.Errorf("got a Topology of %d elements (%v); expected %d", topo.Size(), err, large.Size())
+	}
+	if _, err = store.Get(ctx, "node-c"); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("got %v for a missing node; expected ErrNodeNotFound", err)
+	}
+	cluster, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := cluster.Names(); len(names) != 2 || names[0] != "node-a" || names[1] != "node-b" {
+		t.Errorf("got nodes %v; expected [node-a node-b]", names)
+	}
+	if err = store.Delete(ctx, "node-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Delete(ctx, "node-a"); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("got %v for a missing node; expected ErrNodeNotFound", err)
+	}
+
+	<-done
+	if len(received) != 3 {
+		t.Fatalf("got %d events; expected 3", len(received))
+	}
+	for i, want := range []struct {
+		name string
+		size int
+	}{{"node-a", small.Size()}, {"node-b", large.Size()}, {"node-a", 0}} {
+		size := 0
+		if nil != received[i].Topology {
+			size = received[i].Topology.Size()
+		}
+		if received[i].Name != want.name || size != want.size {
+			t.Errorf("event %d: got %s with %d elements; expected %s with %d", i,
+				received[i].Name, size, want.name, want.size)
+		}
+	}
+}
+
+// fakeEtcd emulates the subset of etcd's JSON gateway that EtcdStore uses.
+type fakeEtcd struct {
+	mu       sync.Mutex
+	kvs      map[string][]byte
+	watchers []fakeWatcher
+	mux      *http.ServeMux
+}
+
+// fakeWatcher is a watch of fakeEtcd, which streams the raw chunks sent to it.
+type fakeWatcher struct {
+	prefix []byte
+	chunks chan []byte
+}
+
+func newFakeEtcd() *fakeEtcd {
+	f := &fakeEtcd{kvs: make(map[string][]byte), mux: http.NewServeMux()}
+	f.mux.HandleFunc("/v3/kv/put", func(w http.ResponseWriter, r *http.Request) {
+		var req etcdKeyValue
+		if json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, `{"message":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.kvs[string(req.Key)] = req.Value
+		f.notify("", req)
+		w.Write([]byte(`{"header":{}}`))
+	})
+	f.mux.HandleFunc("/v3/kv/range", func(w http.ResponseWriter, r *http.Request) {
+		var req etcdRangeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var resp struct {
+			KVs []etcdKeyValue `json:"kvs,omitempty"`
+		}
+		for _, key := range f.keys(req) {
+			resp.KVs = append(resp.KVs, etcdKeyValue{Key: []byte(key), Value: f.kvs[key]})
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	f.mux.HandleFunc("/v3/kv/deleterange", func(w http.ResponseWriter, r *http.Request) {
+		var req etcdRangeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		keys := f.keys(req)
+		for _, key := range keys {
+			delete(f.kvs, key)
+			f.notify("DELETE", etcdKeyValue{Key: []byte(key)})
+		}
+		if len(keys) == 0 {
+			w.Write([]byte(`{"header":{}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"deleted": "1"})
+	})
+	f.mux.HandleFunc("/v3/watch", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			CreateRequest etcdRangeRequest `json:"create_request"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		watcher := fakeWatcher{prefix: req.CreateRequest.Key, chunks: make(chan []byte, 16)}
+		f.mu.Lock()
+		f.watchers = append(f.watchers, watcher)
+		f.mu.Unlock()
+		w.Write([]byte(`{"result":{"header":{},"created":true}}` + "\n"))
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case chunk := <-watcher.chunks:
+				w.Write(chunk)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	return f
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mux.ServeHTTP(w, r)
+}
+
+// keys returns the sorted keys in the requested range; it must be called with
+// the lock held.
+func (f *fakeEtcd) keys(req etcdRangeRequest) []string {
+	var keys []string
+	for key := range f.kvs {
+		if key == string(req.Key) ||
+			(len(req.RangeEnd) > 0 && key > string(req.Key) && key < string(req.RangeEnd)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// notify sends an event to the watchers of its key, as a newline-delimited
+// message (like the gateway); it must be called with the lock held.
+func (f *fakeEtcd) notify(typ string, kv etcdKeyValue) {
+	msg, _ := json.Marshal(etcdWatchResponse{Result: &etcdWatchResult{Events: []etcdWatchEvent{{Type: typ, KV: kv}}}})
+	for _, watcher := range f.watchers {
+		if bytes.HasPrefix(kv.Key, watcher.prefix) {
+			watcher.chunks <- append(msg, '\n')
+		}
+	}
+}
+
+// inject sends the provided raw chunks to all watchers, each flushed
+// separately.
+func (f *fakeEtcd) inject(chunks ...[]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, watcher := range f.watchers {
+		for _, chunk := range chunks {
+			watcher.chunks <- chunk
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Dimensions (in pixels) of the layout of WriteSVG.
+const (
+	svgPadding   = 6
+	svgLine      = 16
+	svgCharWidth = 7
+)
+
+// svgLayout holds the size of the box of every Element in WriteSVG.
+type svgLayout struct {
+	width, height []int
+}
+
+// WriteSVG writes the Topology to the provided io.Writer as a standalone SVG
+// image, in which every Element is a box that contains the boxes of its
+// children side by side (much like the graphical output of hwloc's lstopo),
+// optionally annotated with the provided Overlay (which may be nil). It
+// returns a non-nil error value in case of failure.
+//
+// Elements are filled by their kind, or with the corresponding heatmap color
+// if they have an Overlay value.
+func (t *Topology) WriteSVG(w io.Writer, overlay *Overlay) error {
+	if nil == t || nil == t.Tree {
+		return ErrNilTree
+	}
+	if t.IsEmpty() {
+		return ErrEmptyTree
+	}
+	if nil == overlay {
+		overlay = &Overlay{}
+	}
+
+	// Compute the size of every box from the leaves up, relying on the
+	// pre-order layout of the Tree.
+	labels := make([][]string, len(t.Nodes))
+	layout := svgLayout{width: make([]int, len(t.Nodes)), height: make([]int, len(t.Nodes))}
+	for id := len(t.Nodes) - 1; id >= 0; id-- {
+		labels[id] = svgLabels(t.Nodes[id].Data, NodeID(id), overlay)
+		textWidth := 0
+		for _, line := range labels[id] {
+			if width := len(line)*svgCharWidth + 2*svgPadding; width > textWidth {
+				textWidth = width
+			}
+		}
+		header := len(labels[id])*svgLine + 2*svgPadding
+		childrenWidth, childrenHeight := 0, 0
+		for i, child := range t.Nodes[id].Children {
+			if int(child) <= id {
+				return ErrNotPreOrder
+			}
+			if i > 0 {
+				childrenWidth += svgPadding
+			}
+			childrenWidth += layout.width[child]
+			if layout.height[child] > childrenHeight {
+				childrenHeight = layout.height[child]
+			}
+		}
+		layout.width[id] = textWidth
+		if width := childrenWidth + 2*svgPadding; width > textWidth {
+			layout.width[id] = width
+		}
+		layout.height[id] = header
+		if childrenHeight > 0 {
+			layout.height[id] += childrenHeight + svgPadding
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" "+
+		"font-family=\"monospace\" font-size=\"12\">\n", layout.width[0], layout.height[0])
+	var draw func(id NodeID, x, y int)
+	draw = func(id NodeID, x, y int) {
+		fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\" stroke=\"black\"/>\n",
+			x, y, layout.width[id], layout.height[id], svgFill(t.Nodes[id].Data, id, overlay))
+		for i, line := range labels[id] {
+			fmt.Fprintf(bw, "<text x=\"%d\" y=\"%d\">", x+svgPadding, y+svgPadding+(i+1)*svgLine-4)
+			xml.EscapeText(bw, []byte(line))
+			bw.WriteString("</text>\n")
+		}
+		childX, childY := x+svgPadding, y+len(labels[id])*svgLine+2*svgPadding
+		for _, child := range t.Nodes[id].Children {
+			draw(child, childX, childY)
+			childX += layout.width[child] + svgPadding
+		}
+	}
+	draw(0, 0, 0)
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// svgLabels returns the lines of text in the box of the provided Element.
+func svgLabels(data *Element, id NodeID, overlay *Overlay) []string {
+	lines := []string{data.String()}
+	if value, ok := overlay.Values[id]; ok {
+		lines[0] = fmt.Sprintf("%s (%.2f)", lines[0], math.Max(0, math.Min(1, value)))
+	}
+	if text, ok := overlay.Labels[id]; ok {
+		lines = append(lines, text)
+	}
+	return lines
+}
+
+// svgFill returns the color that the box of the provided Element is filled
+// with in WriteSVG.
+func svgFill(data *Element, id NodeID, overlay *Overlay) string {
+	if value, ok := overlay.Values[id]; ok {
+		value = math.Max(0, math.Min(1, value))
+		return fmt.Sprintf("hsl(%.0f, 70%%, 75%%)", (1-value)*120)
+	}
+	switch {
+	case data.IsRoot():
+		return "#ffffff"
+	case data.IsCache():
+		return "#f5f5f5"
+	case data.IsDevice():
+		return "#def0de"
+	case data.IsProcessing():
+		switch data.Kind {
+		case Package:
+			return "#dedede"
+		case NUMANode:
+			return "#efdfde"
+		case Core:
+			return "#bebebe"
+		case Thread:
+			return "#ffffff"
+		}
+	}
+	return "#ff0000"
+}
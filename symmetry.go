@@ -0,0 +1,93 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "fmt"
+
+// EquivalenceClass is a group of Elements of a Topology whose subtrees are
+// identical, apart from the OS indices of their Processing nodes and the
+// logical indices of their Caches (see EquivalenceClasses).
+type EquivalenceClass struct {
+	// Shape is the Merkle hash that all subtrees of the class share.
+	Shape Hash `json:"shape"`
+	// Members are the NodeIDs of the Elements of the class, in ascending
+	// order.
+	Members []NodeID `json:"members"`
+}
+
+// EquivalenceClasses returns the EquivalenceClasses of all Elements of the
+// Topology, in the order of their first Members, or a non-nil error value in
+// case of failure.
+//
+// Two Elements are equivalent if they are of the same kind (and, for Caches,
+// have the same attributes) and their children can be paired so that each
+// pair is equivalent too; e.g., on a symmetric machine, all Cores are
+// equivalent, and so are all L2 caches or all Packages. Every Element belongs
+// to exactly one EquivalenceClass, which may contain it alone, so that
+// allocators can enumerate candidates per class rather than per Element.
+func (t *Topology) EquivalenceClasses() ([]EquivalenceClass, error) {
+	if nil == t || nil == t.Tree {
+		return nil, ErrNilTree
+	}
+
+	shapes := make([]Hash, len(t.Nodes))
+	// Relying on the pre-order layout of the Tree, children are hashed
+	// before their parents (see Fingerprint).
+	for id := len(t.Nodes) - 1; id >= 0; id-- {
+		children := t.Nodes[id].Children
+		for _, child := range children {
+			if int(child) <= id || int(child) >= len(t.Nodes) {
+				return nil, ErrNotPreOrder
+			}
+		}
+		shapes[id] = merkleDigest(shapeLabel(t.Nodes[id].Data), children, shapes)
+	}
+
+	var classes []EquivalenceClass
+	index := make(map[Hash]int)
+	for id, shape := range shapes {
+		i, ok := index[shape]
+		if !ok {
+			i = len(classes)
+			index[shape] = i
+			classes = append(classes, EquivalenceClass{Shape: shape})
+		}
+		classes[i].Members = append(classes[i].Members, NodeID(id))
+	}
+	return classes, nil
+}
+
+// shapeLabel returns the label of the provided Element in the hashes of the
+// EquivalenceClasses, which consists of its kind and, for Caches and NUMA nodes,
+// their attributes.
+func shapeLabel(data *Element) string {
+	if data.IsCache() && nil != data.Attributes {
+		if data.Attributes.Inclusion != Inclusive {
+			return fmt.Sprintf("%s:%d:%d:%d:%s", structureLabel(data), data.Attributes.Size,
+				data.Attributes.Linesize, data.Attributes.Associativity, data.Attributes.Inclusion)
+		}
+		return fmt.Sprintf("%s:%d:%d:%d", structureLabel(data),
+			data.Attributes.Size, data.Attributes.Linesize, data.Attributes.Associativity)
+	}
+	if data.IsProcessing() && nil != data.Memory {
+		if data.Memory.Tier != DRAM {
+			return fmt.Sprintf("%s:%d:%s", structureLabel(data), data.Memory.Bytes, data.Memory.Tier)
+		}
+		return fmt.Sprintf("%s:%d", structureLabel(data), data.Memory.Bytes)
+	}
+	return structureLabel(data)
+}
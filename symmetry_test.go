@@ -0,0 +1,71 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "testing"
+
+func TestEquivalenceClasses(t *testing.T) {
+	topo := &Topology{syntheticTree(2, 2, 4, 2)}
+	classes, err := topo.EquivalenceClasses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Machine, Packages, NUMA nodes, L3, L2, L1, Cores and Threads.
+	if len(classes) != 8 {
+		t.Fatalf("got %d classes; expected 8", len(classes))
+	}
+	members := 0
+	for _, class := range classes {
+		members += len(class.Members)
+		kind := elementKind(topo.Nodes[class.Members[0]].Data)
+		for _, id := range class.Members {
+			if k := elementKind(topo.Nodes[id].Data); k != kind {
+				t.Errorf("class of %s contains %s (node %d)", kind, k, id)
+			}
+		}
+	}
+	if members != topo.Size() {
+		t.Errorf("got %d members in total; expected %d", members, topo.Size())
+	}
+	if cores := classes[6].Members; len(cores) != len(topo.Cores()) {
+		t.Errorf("got %d equivalent Cores; expected %d", len(cores), len(topo.Cores()))
+	}
+
+	// A Core without SMT breaks the symmetry of its ancestors.
+	core := topo.Cores()[0]
+	topo.Nodes[core].Children = topo.Nodes[core].Children[:1]
+	topo.Nodes = append(topo.Nodes[:core+2], topo.Nodes[core+3:]...)
+	for id := range topo.Nodes {
+		for i, child := range topo.Nodes[id].Children {
+			if child > core+2 {
+				topo.Nodes[id].Children[i]--
+			}
+		}
+	}
+	topo.InvalidateIndexes()
+	if err = topo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if classes, err = topo.EquivalenceClasses(); err != nil {
+		t.Fatal(err)
+	}
+	// The Core and each of its ancestors but the Machine (i.e., its
+	// Package, NUMA node, L3, L2 and L1) get a class of their own.
+	if len(classes) != 8+6 {
+		t.Errorf("got %d classes; expected %d", len(classes), 8+6)
+	}
+}
@@ -0,0 +1,246 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// SyncTopology guards a Topology that is modified while it is being queried,
+// through a sync.RWMutex, so that callers do not need to synchronize every
+// query and modification themselves.
+//
+// Its query methods mirror those of Topology, but never expose the Elements or
+// the lists that it stores internally (i.e., Elements are returned by value).
+// Modifications go through Update, which applies them atomically. Callers that
+// need to run several queries against the same version of the Topology should
+// use View (or Freeze) instead.
+type SyncTopology struct {
+	mu   sync.RWMutex
+	topo *Topology
+}
+
+// NewSyncTopology returns a new SyncTopology that takes ownership of the
+// provided Topology, which must not be accessed directly afterwards.
+func NewSyncTopology(topo *Topology) *SyncTopology {
+	return &SyncTopology{topo: topo}
+}
+
+// View calls the provided function with the guarded Topology, under a read
+// lock, and returns its error value. The function must not modify the Topology
+// or retain any references to it or its Elements.
+func (s *SyncTopology) View(fn func(t *Topology) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(s.topo)
+}
+
+// Update applies the provided function to a copy of the guarded Topology and,
+// if it succeeds and the result is valid, replaces the guarded Topology with
+// it, under a write lock; otherwise, the guarded Topology is left intact and a
+// non-nil error value is returned. The function does not need to call
+// InvalidateIndexes, and must not retain any references to the Topology.
+func (s *SyncTopology) Update(fn func(t *Topology) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.topo.clone()
+	if err := fn(t); err != nil {
+		return err
+	}
+	t.InvalidateIndexes()
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	s.topo = t
+	return nil
+}
+
+// Replace replaces the guarded Topology with the provided one, taking
+// ownership of it (see NewSyncTopology).
+func (s *SyncTopology) Replace(topo *Topology) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.topo = topo
+}
+
+// Snapshot returns a deep copy of the guarded Topology, which is owned by the
+// caller.
+func (s *SyncTopology) Snapshot() *Topology {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.clone()
+}
+
+// Freeze returns an immutable copy of the guarded Topology (see
+// Topology.Freeze).
+func (s *SyncTopology) Freeze() (*FrozenTopology, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Freeze()
+}
+
+// Size returns the number of Elements of the guarded Topology.
+func (s *SyncTopology) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Size()
+}
+
+// Get returns a copy of the Element stored under the provided NodeID, or a
+// non-nil error value in case of failure.
+func (s *SyncTopology) Get(id NodeID) (Element, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, err := s.topo.Get(id)
+	if err != nil {
+		return Element{}, err
+	}
+	return *cloneTreeNode(&TreeNode{Data: e}).Data, nil
+}
+
+// ImmediateDescendantIDs returns the NodeIDs of the children of the element
+// stored under the provided NodeID (see Tree.ImmediateDescendantIDs).
+func (s *SyncTopology) ImmediateDescendantIDs(id NodeID) ([]NodeID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	children, err := s.topo.ImmediateDescendantIDs(id)
+	return append([]NodeID(nil), children...), err
+}
+
+// ParentID returns the NodeID of the parent of the element stored under the
+// provided NodeID (see Tree.ParentID).
+func (s *SyncTopology) ParentID(id NodeID) (NodeID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.ParentID(id)
+}
+
+// AncestorIDs returns the NodeIDs of the ancestors of the element stored under
+// the provided NodeID (see Tree.AncestorIDs).
+func (s *SyncTopology) AncestorIDs(id NodeID) ([]NodeID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.AncestorIDs(id)
+}
+
+// LeafDescendantIDs returns the NodeIDs of the leaves under the element stored
+// under the provided NodeID (see Tree.LeafDescendantIDs).
+func (s *SyncTopology) LeafDescendantIDs(id NodeID) ([]NodeID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.LeafDescendantIDs(id)
+}
+
+// SubtreeRange returns the range of the NodeIDs of the subtree of the element
+// stored under the provided NodeID (see Tree.SubtreeRange).
+func (s *SyncTopology) SubtreeRange(id NodeID) (start, end NodeID, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.SubtreeRange(id)
+}
+
+// Depth returns the depth of the element stored under the provided NodeID (see
+// Tree.Depth).
+func (s *SyncTopology) Depth(id NodeID) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Depth(id)
+}
+
+// Packages returns the NodeIDs of all Packages (see Topology.Packages).
+func (s *SyncTopology) Packages() []NodeID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Packages()
+}
+
+// NUMANodes returns the NodeIDs of all NUMA nodes (see Topology.NUMANodes).
+func (s *SyncTopology) NUMANodes() []NodeID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.NUMANodes()
+}
+
+// Cores returns the NodeIDs of all physical cores (see Topology.Cores).
+func (s *SyncTopology) Cores() []NodeID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Cores()
+}
+
+// Threads returns the NodeIDs of all hardware threads (see Topology.Threads).
+func (s *SyncTopology) Threads() []NodeID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Threads()
+}
+
+// Caches returns the NodeIDs of all caches of the provided level (see
+// Topology.L1Caches, Topology.L2Caches, etc.).
+func (s *SyncTopology) Caches(level CacheLevel) []NodeID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.getAllCacheLevel(level)
+}
+
+// Select returns the NodeIDs of the Elements that the provided selector selects
+// (see Topology.Select).
+func (s *SyncTopology) Select(selector string) ([]NodeID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Select(selector)
+}
+
+// Find returns the NodeIDs of the Elements that the provided Predicate matches
+// (see Topology.Find); the Predicate must not modify the Elements.
+func (s *SyncTopology) Find(pred Predicate) []NodeID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Find(pred)
+}
+
+// Descendants returns the NodeIDs of the descendants of the element stored
+// under the provided NodeID (see Topology.Descendants).
+func (s *SyncTopology) Descendants(id NodeID, opts ...Option) ([]NodeID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Descendants(id, opts...)
+}
+
+// Fingerprint returns the Fingerprint of the guarded Topology (see
+// Topology.Fingerprint).
+func (s *SyncTopology) Fingerprint() (Fingerprint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Fingerprint()
+}
+
+// Marshal returns the guarded Topology marshalled in JSON (see
+// Topology.Marshal).
+func (s *SyncTopology) Marshal(opts ...Option) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topo.Marshal(opts...)
+}
+
+// MarshalJSON returns the guarded Topology marshalled in JSON.
+func (s *SyncTopology) MarshalJSON() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return json.Marshal(s.topo)
+}
@@ -0,0 +1,84 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSyncTopology(t *testing.T) {
+	st := NewSyncTopology(&Topology{syntheticTree(1, 2, 2, 2)})
+	l3 := st.Caches(L3)[0]
+
+	// Concurrent queries and updates are race-free, and every query
+	// observes a consistent version of the Topology.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if e, err := st.Get(l3); err != nil || e.Attributes.Size%(32<<20) != 0 {
+					t.Errorf("got %v (%v)", e, err)
+				}
+				if ids, err := st.Select("numanode:0/threads"); err != nil || len(ids) != 4 {
+					t.Errorf("got %v (%v)", ids, err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				err := st.Update(func(t *Topology) error {
+					t.Nodes[l3].Data.Attributes.Size += 32 << 20
+					return nil
+				})
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if e, _ := st.Get(l3); e.Attributes.Size != 41*32<<20 {
+		t.Errorf("got an L3 of %d bytes after 40 updates", e.Attributes.Size)
+	}
+
+	// Invalid updates leave the Topology intact.
+	if err := st.Update(func(t *Topology) error { t.Nodes[0].Children = nil; return nil }); err == nil {
+		t.Errorf("expected an error for an invalid update")
+	}
+	if n := len(st.Threads()); n != 8 {
+		t.Errorf("got %d threads after an invalid update; expected 8", n)
+	}
+	err := st.View(func(t *Topology) error {
+		if n := len(t.Cores()); n != 4 {
+			return ErrEmptyTree
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("got %v from View", err)
+	}
+
+	snapshot := st.Snapshot()
+	snapshot.Nodes[l3].Data.Attributes.Size = 0
+	if e, _ := st.Get(l3); e.Attributes.Size == 0 {
+		t.Errorf("modifying a Snapshot affected the SyncTopology")
+	}
+}
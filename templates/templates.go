@@ -0,0 +1,197 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+// Package templates provides realistic, canned hardware topologies of common
+// machines, to be used in tests and simulations instead of copies of JSON
+// fixtures.
+//
+// Every function returns a new, valid Topology on each call, which is owned by
+// the caller.
+package templates
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+// DualSocketXeon returns the Topology of a dual-socket Intel Xeon Platinum
+// 8380 (Ice Lake-SP) machine: 2 Packages of 40 Cores with 2 hardware threads
+// each, a NUMA node and a 60MiB L3 per Package, and a 1.25MiB L2 and a 48KiB
+// L1 per Core.
+func DualSocketXeon() *actitopo.Topology {
+	topo := mustFromSpec(actitopo.TopologySpec{
+		Packages:            2,
+		NUMANodesPerPackage: 1,
+		L3PerNUMANode:       1,
+		Cores:               40,
+		ThreadsPerCore:      2,
+		L3Size:              60 << 20,
+		L2Size:              1280 << 10,
+		L1Size:              48 << 10,
+	})
+	// The caches of each level have a different associativity.
+	for _, level := range []struct {
+		caches []actitopo.NodeID
+		ways   int32
+	}{
+		{topo.L3Caches(), 12},
+		{topo.L2Caches(), 20},
+		{topo.L1Caches(), 12},
+	} {
+		for _, id := range level.caches {
+			topo.Nodes[id].Data.Attributes.Associativity = level.ways
+		}
+	}
+	return topo
+}
+
+// EPYC8CCD returns the Topology of a single-socket AMD EPYC 7763 (Milan)
+// machine in NPS1 mode: a Package and a NUMA node with 8 CCDs, each with a
+// 32MiB L3 shared by 8 Cores with 2 hardware threads each, and a 512KiB L2 and
+// a 32KiB L1 per Core.
+func EPYC8CCD() *actitopo.Topology {
+	return mustFromSpec(actitopo.TopologySpec{
+		Packages:            1,
+		NUMANodesPerPackage: 1,
+		L3PerNUMANode:       8,
+		Cores:               8,
+		ThreadsPerCore:      2,
+		L3Size:              32 << 20,
+		L2Size:              512 << 10,
+		L1Size:              32 << 10,
+	})
+}
+
+// Graviton returns the Topology of an AWS Graviton3 machine: a Package and a
+// NUMA node with a 32MiB L3 shared by 64 Cores without SMT, and a 1MiB L2 and
+// a 64KiB L1 per Core.
+func Graviton() *actitopo.Topology {
+	return mustFromSpec(actitopo.TopologySpec{
+		Packages:            1,
+		NUMANodesPerPackage: 1,
+		L3PerNUMANode:       1,
+		Cores:               64,
+		ThreadsPerCore:      1,
+		L3Size:              32 << 20,
+		L2Size:              1 << 20,
+		L1Size:              64 << 10,
+	})
+}
+
+// DesktopHybrid returns the Topology of a desktop Intel Core i9-12900K (Alder
+// Lake) machine, with a 30MiB L3 shared by 8 performance Cores and 8
+// efficiency Cores. Each performance Core has 2 hardware threads, a 1.25MiB L2
+// and a 48KiB L1, whereas the efficiency Cores have a single hardware thread
+// and a 32KiB L1 each, and share a 2MiB L2 in clusters of 4.
+//
+// Hardware threads are numbered like Linux does on such machines: the SMT
+// siblings of each performance Core are numbered consecutively (0 to 15),
+// followed by the efficiency Cores (16 to 23).
+func DesktopHybrid() *actitopo.Topology {
+	tree := actitopo.NewTree([]actitopo.TreeNode{{Data: &actitopo.Element{}}})
+	add := func(parent actitopo.NodeID, data *actitopo.Element) actitopo.NodeID {
+		id := actitopo.NodeID(len(tree.Nodes))
+		tree.Nodes = append(tree.Nodes, actitopo.TreeNode{Data: data})
+		tree.Nodes[parent].Children = append(tree.Nodes[parent].Children, id)
+		return id
+	}
+	processing := func(parent actitopo.NodeID, kind actitopo.ProcessingKind, id uint32) actitopo.NodeID {
+		return add(parent, &actitopo.Element{Processing: &actitopo.Processing{Kind: kind, ID: id}})
+	}
+	var logicalIndex [actitopo.L5 + 1]uint32
+	cache := func(parent actitopo.NodeID, level actitopo.CacheLevel, size uint64, ways int32) actitopo.NodeID {
+		li := logicalIndex[level]
+		logicalIndex[level]++
+		return add(parent, &actitopo.Element{Cache: &actitopo.Cache{
+			Level:        level,
+			LogicalIndex: li,
+			Attributes:   &actitopo.CacheAttributes{Size: size, Linesize: 64, Associativity: ways},
+		}})
+	}
+
+	pkg := processing(0, actitopo.Package, 0)
+	numa := processing(pkg, actitopo.NUMANode, 0)
+	l3 := cache(numa, actitopo.L3, 30<<20, 12)
+	thread := uint32(0)
+	for c := uint32(0); c < 8; c++ {
+		l2 := cache(l3, actitopo.L2, 1280<<10, 10)
+		l1 := cache(l2, actitopo.L1, 48<<10, 12)
+		core := processing(l1, actitopo.Core, 4*c)
+		for th := 0; th < 2; th++ {
+			processing(core, actitopo.Thread, thread)
+			thread++
+		}
+	}
+	for cluster := uint32(0); cluster < 2; cluster++ {
+		l2 := cache(l3, actitopo.L2, 2<<20, 16)
+		for c := uint32(0); c < 4; c++ {
+			l1 := cache(l2, actitopo.L1, 32<<10, 8)
+			core := processing(l1, actitopo.Core, 32+4*cluster+c)
+			processing(core, actitopo.Thread, thread)
+			thread++
+		}
+	}
+	return mustValidate(&actitopo.Topology{Tree: tree})
+}
+
+// templates maps the name of each template to its function.
+var templates = map[string]func() *actitopo.Topology{
+	"dual-socket-xeon": DualSocketXeon,
+	"epyc-8ccd":        EPYC8CCD,
+	"graviton":         Graviton,
+	"desktop-hybrid":   DesktopHybrid,
+}
+
+// Names returns the sorted names of all templates (see ByName).
+func Names() []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ByName returns a new Topology of the template with the provided name (e.g.,
+// "epyc-8ccd"), or a non-nil error value if no such template exists.
+func ByName(name string) (*actitopo.Topology, error) {
+	template, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown template '%s'", name)
+	}
+	return template(), nil
+}
+
+// mustFromSpec returns the Topology that the provided TopologySpec describes,
+// and panics if it is invalid, which would be a bug in the template.
+func mustFromSpec(spec actitopo.TopologySpec) *actitopo.Topology {
+	topo, err := actitopo.FromSpec(spec)
+	if err != nil {
+		panic(fmt.Sprintf("templates: invalid TopologySpec: %v", err))
+	}
+	return topo
+}
+
+// mustValidate returns the provided Topology, and panics if it is invalid,
+// which would be a bug in the template.
+func mustValidate(topo *actitopo.Topology) *actitopo.Topology {
+	if err := topo.Validate(); err != nil {
+		panic(fmt.Sprintf("templates: invalid Topology: %v", err))
+	}
+	return topo
+}
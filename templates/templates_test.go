@@ -0,0 +1,87 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package templates
+
+import (
+	"testing"
+
+	"github.com/ckatsak/actitopo-go"
+)
+
+func TestTemplates(t *testing.T) {
+	for _, tc := range []struct {
+		name                                string
+		packages, numaNodes, cores, threads int
+		l3s                                 int
+	}{
+		{"desktop-hybrid", 1, 1, 16, 24, 1},
+		{"dual-socket-xeon", 2, 2, 80, 160, 2},
+		{"epyc-8ccd", 1, 1, 64, 128, 8},
+		{"graviton", 1, 1, 64, 64, 1},
+	} {
+		topo, err := ByName(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = topo.Validate(); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if err = topo.ValidateCaches(); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		profile := topo.Profile()
+		if profile.Packages != tc.packages || profile.NUMANodes != tc.numaNodes ||
+			profile.Cores != tc.cores || profile.Threads != tc.threads {
+			t.Errorf("%s: got profile %+v", tc.name, profile)
+		}
+		if l3s := len(topo.L3Caches()); l3s != tc.l3s {
+			t.Errorf("%s: got %d L3 caches; expected %d", tc.name, l3s, tc.l3s)
+		}
+	}
+	if names := Names(); len(names) != 4 || names[0] != "desktop-hybrid" {
+		t.Errorf("got template names %v", names)
+	}
+	if _, err := ByName("pdp-11"); err == nil {
+		t.Errorf("expected an error for an unknown template")
+	}
+}
+
+func TestTemplatesAreNotShared(t *testing.T) {
+	a, b := DualSocketXeon(), DualSocketXeon()
+	a.Nodes[0].Children = nil
+	if len(b.Nodes[0].Children) == 0 {
+		t.Errorf("modifying a template's Topology affected another one")
+	}
+}
+
+func TestDesktopHybridThreads(t *testing.T) {
+	topo := DesktopHybrid()
+	threads := topo.ThreadsDetailed()
+	for i, thread := range threads {
+		if thread.Processing.ID != uint32(i) {
+			t.Errorf("got thread %d at position %d", thread.Processing.ID, i)
+		}
+	}
+	// The efficiency Cores of a cluster share their L2.
+	l2, err := topo.CommonAncestorID(threads[16].ID, threads[19].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := topo.Get(l2); !data.IsCache() || data.Level != actitopo.L2 {
+		t.Errorf("got %v as the common ancestor of an efficiency cluster; expected an L2", data)
+	}
+}
@@ -51,17 +51,34 @@ func (t *Topology) Threads() []NodeID {
 	return t.getAllProcessingKind(Thread)
 }
 
+// coreOf returns the NodeID of the nearest Core ancestor of the hardware
+// thread stored under the provided NodeID, which need not be its parent (e.g.,
+// if an SMT level or a cache lies between them), and false if it has none, or
+// a non-nil error value in case of failure.
+func (t *Topology) coreOf(threadID NodeID) (NodeID, bool, error) {
+	ancestorIDs, err := t.AncestorIDs(threadID)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, ancestorID := range ancestorIDs {
+		if data := t.Nodes[ancestorID].Data; data.IsProcessing() && data.Kind == Core {
+			return ancestorID, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
 // getAllProcessingKind returns a list of all NodeIDs that correspond to a
 // processing element of the provided kind in the hierarchical hardware
 // topology.
+//
+// The list is served from the Tree's secondary indexes (see
+// InvalidateIndexes), and is owned by the caller.
 func (t *Topology) getAllProcessingKind(kind ProcessingKind) []NodeID {
-	ret := make([]NodeID, 0)
-	for id := range t.Nodes {
-		if t.Nodes[id].Data.IsProcessing() && t.Nodes[id].Data.Kind == kind {
-			ret = append(ret, NodeID(id))
-		}
+	if kind > Thread {
+		return make([]NodeID, 0)
 	}
-	return ret
+	return append(make([]NodeID, 0), t.getIndexes().processing[kind]...)
 }
 
 // L1Caches returns a list of all NodeIDs that correspond to a L1 cache element
@@ -96,12 +113,77 @@ func (t *Topology) L5Caches() []NodeID {
 
 // getAllCacheLevel returns a list of all NodeIDs that correspond to a cache
 // element of the provided cache level in the hierarchical hardware topology.
+//
+// The list is served from the Tree's secondary indexes (see
+// InvalidateIndexes), and is owned by the caller.
 func (t *Topology) getAllCacheLevel(level CacheLevel) []NodeID {
-	ret := make([]NodeID, 0)
-	for id := range t.Nodes {
-		if t.Nodes[id].Data.IsCache() && t.Nodes[id].Data.Level == level {
-			ret = append(ret, NodeID(id))
-		}
+	if level > L5 {
+		return make([]NodeID, 0)
+	}
+	return append(make([]NodeID, 0), t.getIndexes().caches[level]...)
+}
+
+// ProcessingElement pairs a processing element of a Topology with its NodeID.
+type ProcessingElement struct {
+	ID         NodeID
+	Processing *Processing
+}
+
+// CacheElement pairs a cache element of a Topology with its NodeID.
+type CacheElement struct {
+	ID    NodeID
+	Cache *Cache
+}
+
+// PackagesDetailed returns all CPU Package processing elements of the
+// Topology along with their NodeIDs (see Packages).
+func (t *Topology) PackagesDetailed() []ProcessingElement {
+	return t.getAllProcessingKindDetailed(Package)
+}
+
+// NUMANodesDetailed returns all NUMA node processing elements of the Topology
+// along with their NodeIDs (see NUMANodes).
+func (t *Topology) NUMANodesDetailed() []ProcessingElement {
+	return t.getAllProcessingKindDetailed(NUMANode)
+}
+
+// CoresDetailed returns all physical core processing elements of the Topology
+// along with their NodeIDs (see Cores).
+func (t *Topology) CoresDetailed() []ProcessingElement {
+	return t.getAllProcessingKindDetailed(Core)
+}
+
+// ThreadsDetailed returns all hardware thread processing elements of the
+// Topology along with their NodeIDs (see Threads).
+func (t *Topology) ThreadsDetailed() []ProcessingElement {
+	return t.getAllProcessingKindDetailed(Thread)
+}
+
+// getAllProcessingKindDetailed returns all processing elements of the provided
+// kind in the Topology, along with their NodeIDs, in ascending NodeID order.
+func (t *Topology) getAllProcessingKindDetailed(kind ProcessingKind) []ProcessingElement {
+	if kind > Thread {
+		return make([]ProcessingElement, 0)
+	}
+	ids := t.getIndexes().processing[kind]
+	ret := make([]ProcessingElement, len(ids))
+	for i, id := range ids {
+		ret[i] = ProcessingElement{ID: id, Processing: t.Nodes[id].Data.Processing}
+	}
+	return ret
+}
+
+// CachesDetailed returns all cache elements of the provided level in the
+// Topology, including their attributes, along with their NodeIDs, in ascending
+// NodeID order (see L1Caches, L2Caches, etc.).
+func (t *Topology) CachesDetailed(level CacheLevel) []CacheElement {
+	if level > L5 {
+		return make([]CacheElement, 0)
+	}
+	ids := t.getIndexes().caches[level]
+	ret := make([]CacheElement, len(ids))
+	for i, id := range ids {
+		ret[i] = CacheElement{ID: id, Cache: t.Nodes[id].Data.Cache}
 	}
 	return ret
 }
@@ -19,6 +19,7 @@ package actitopo
 import (
 	"encoding/json"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -52,3 +53,68 @@ func TestDesererializeTopoFromFile(t *testing.T) {
 		t.Fatalf("Failed to write remarshaled Topology into file %v: %v\n", OUT_FILE_PATH, err)
 	}
 }
+
+func TestTopologyIndexes(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+
+	// Concurrent readers should all observe the same, complete indexes.
+	want := len(topo.Threads())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := len(topo.Threads()); got != want {
+				t.Errorf("got %d threads; want %d", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Returned lists are owned by the caller.
+	threads := topo.Threads()
+	threads[0] = 12345
+	if topo.Threads()[0] == 12345 {
+		t.Errorf("modifying a returned list affected the indexes")
+	}
+
+	// Mutations are only observed after invalidating the indexes.
+	topo.Nodes[threads[1]].Data.Processing.Kind = Core
+	if got := len(topo.Threads()); got != want {
+		t.Errorf("got %d threads before InvalidateIndexes; want %d", got, want)
+	}
+	topo.InvalidateIndexes()
+	if got := len(topo.Threads()); got != want-1 {
+		t.Errorf("got %d threads after InvalidateIndexes; want %d", got, want-1)
+	}
+}
+
+func TestTopologyDetailed(t *testing.T) {
+	topo := loadTopology(t, "test_artifacts/t4_de.json")
+
+	threads, detailed := topo.Threads(), topo.ThreadsDetailed()
+	if len(detailed) != len(threads) {
+		t.Fatalf("got %d detailed threads; want %d", len(detailed), len(threads))
+	}
+	for i, pe := range detailed {
+		if pe.ID != threads[i] || pe.Processing != topo.Nodes[threads[i]].Data.Processing || pe.Processing.Kind != Thread {
+			t.Errorf("got %+v for thread %d", pe, threads[i])
+		}
+	}
+	if len(topo.PackagesDetailed()) != len(topo.Packages()) ||
+		len(topo.NUMANodesDetailed()) != len(topo.NUMANodes()) ||
+		len(topo.CoresDetailed()) != len(topo.Cores()) {
+		t.Errorf("got different numbers of detailed and bare processing elements")
+	}
+
+	l2 := topo.CachesDetailed(L2)
+	if len(l2) != len(topo.L2Caches()) || len(l2) == 0 {
+		t.Fatalf("got %d detailed L2 caches; want %d", len(l2), len(topo.L2Caches()))
+	}
+	if l2[0].Cache.Level != L2 || nil == l2[0].Cache.Attributes || l2[0].Cache.Attributes.Size != 262144 {
+		t.Errorf("got %+v for the first L2 cache", l2[0].Cache)
+	}
+	if len(topo.CachesDetailed(CacheLevel(42))) != 0 {
+		t.Errorf("got caches of an invalid level")
+	}
+}
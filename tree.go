@@ -16,54 +16,66 @@
 
 package actitopo
 
-import "fmt"
-
 // NodeID serves as a unique identifier of an Element in the Tree.
 // It is also its index in the Tree.
 type NodeID = uint32
 
 // TreeNode contains an Element of the hardware topology along with the NodeIDs
 // of its immediate descendants in the Tree.
-type TreeNode struct {
-	// Data is the hardware topology Element contained in this TreeNode.
-	Data *Element `json:"data"`
-	// Children is a list of the NodeIDs that correspond to the immediate
-	// descendants (i.e., children) Elements of the Element contained in
-	// this TreeNode.
-	Children []NodeID `json:"desc,omitempty"`
-}
+type TreeNode = Node[*Element]
 
 // Tree represents the hierarchy of the hardware topology hierarchy in ActiK8s.
+//
+// It is a Hierarchy of Elements, from which it inherits all of its traversal
+// and structural indexing machinery; on top of that, it indexes its Elements
+// by their kind (see InvalidateIndexes).
+//
+// The Elements of a valid Tree are stored in pre-order: the root Element is
+// stored first, and every Element is immediately followed by the subtrees of
+// its children, in the order they are listed. Hence, the subtree of every
+// Element occupies a contiguous range of NodeIDs (see SubtreeRange).
 type Tree struct {
-	// Nodes contains all TreeNode objects that constitute the Tree, and is
-	// indexed by Elements' NodeIDs in the Tree.
-	Nodes []TreeNode `json:"nodes"`
+	// Hierarchy contains all TreeNode objects that constitute the Tree in
+	// its Nodes, which are indexed by Elements' NodeIDs in the Tree.
+	Hierarchy[*Element]
+
+	// elements are built lazily; see InvalidateIndexes.
+	elements treeIndexes
 }
 
-// Size returns the number of Elements currently stored in the Tree.
-func (t *Tree) Size() int {
+// NewTree returns a new Tree that consists of the provided TreeNodes, which
+// should be stored in pre-order (see Validate).
+func NewTree(nodes []TreeNode) *Tree {
+	return &Tree{Hierarchy: Hierarchy[*Element]{Nodes: nodes}}
+}
+
+// hierarchy returns the Hierarchy that the Tree is built on, or nil if the
+// Tree is nil, so that the Hierarchy's methods report ErrNilTree for it.
+func (t *Tree) hierarchy() *Hierarchy[*Element] {
 	if nil == t {
-		return 0
+		return nil
 	}
-	return len(t.Nodes)
+	return &t.Hierarchy
+}
+
+// Size returns the number of Elements currently stored in the Tree.
+func (t *Tree) Size() int {
+	return t.hierarchy().Size()
 }
 
 // IsEmpty returns true if there are no Elements currently stored in the Tree.
 func (t *Tree) IsEmpty() bool {
-	if nil == t {
-		return true
-	}
-	return 0 == len(t.Nodes)
+	return t.hierarchy().IsEmpty()
 }
 
 // Root returns the Element stored at the root of the hardware topology Tree,
 // or a non-nil error value in case of failure.
 func (t *Tree) Root() (*Element, error) {
 	if nil == t {
-		return nil, fmt.Errorf("Tree is nil")
+		return nil, ErrNilTree
 	}
 	if len(t.Nodes) == 0 {
-		return nil, fmt.Errorf("Tree is empty")
+		return nil, ErrEmptyTree
 	}
 	return t.Nodes[0].Data, nil
 }
@@ -71,216 +83,140 @@ func (t *Tree) Root() (*Element, error) {
 // Get returns a reference to the Element that is stored in the Tree under
 // the provided NodeID, if it exists, or a non-nil error value otherwise.
 func (t *Tree) Get(id NodeID) (*Element, error) {
-	if nil == t {
-		return nil, fmt.Errorf("Tree is nil")
-	}
-	if int(id) >= len(t.Nodes) {
-		return nil, fmt.Errorf("Invalid NodeID %d", id)
-	}
+	return t.hierarchy().Get(id)
+}
 
-	return t.Nodes[id].Data, nil
+// SubtreeRange returns the range [start, end) of the NodeIDs of the Elements in
+// the subtree of the element stored in the Tree under the provided NodeID
+// (including itself), or a non-nil error value in case of failure. Therefore,
+// all descendants of the element can be accessed as t.Nodes[start+1:end].
+//
+// It relies on the pre-order layout of the Tree, which is guaranteed for all
+// Trees that pass Validate, and runs in O(depth).
+func (t *Tree) SubtreeRange(id NodeID) (start, end NodeID, err error) {
+	return t.hierarchy().SubtreeRange(id)
 }
 
 // ImmediateDescendantIDs returns a list of NodeIDs that correspond to the
 // immediate descendant (i.e., children) elements of the element stored in the
 // Tree under the provided NodeID.
 func (t *Tree) ImmediateDescendantIDs(id NodeID) ([]NodeID, error) {
-	if nil == t {
-		return nil, fmt.Errorf("Tree is nil")
-	}
-	if int(id) >= len(t.Nodes) {
-		return nil, fmt.Errorf("Invalid NodeID %d", id)
-	}
-
-	return t.Nodes[id].Children, nil
+	return t.hierarchy().ImmediateDescendantIDs(id)
 }
 
 // ImmediateDescendants returns a list of the immediate descendant (i.e.,
 // children) elements of the element stored in the Tree under the provided
 // NodeID.
 func (t *Tree) ImmediateDescendants(id NodeID) (children []*Element, err error) {
-	if nil == t {
-		return nil, fmt.Errorf("Tree is nil")
+	childIDs, err := t.ImmediateDescendantIDs(id)
+	if err != nil {
+		return nil, err
 	}
-	if int(id) >= len(t.Nodes) {
-		return nil, fmt.Errorf("Invalid NodeID %d", id)
-	}
-
-	children = make([]*Element, 0, len(t.Nodes[id].Children))
-	for i := range t.Nodes[id].Children {
-		children = append(children, t.Nodes[t.Nodes[id].Children[i]].Data)
-	}
-	return
+	return t.elementsOf(childIDs), nil
 }
 
 // LeafDescendantIDs returns a list of NodeIDs that correspond to the elements
 // at the leaves of the Tree, which are also descendants of the element stored
 // under the provided NodeID.
 func (t *Tree) LeafDescendantIDs(id NodeID) (leafIDs []NodeID, err error) {
-	if nil == t {
-		return nil, fmt.Errorf("Tree is nil")
-	}
-	if int(id) >= len(t.Nodes) {
-		return nil, fmt.Errorf("Invalid NodeID %d", id)
-	}
+	return t.hierarchy().LeafDescendantIDs(id)
+}
 
-	leafIDs = make([]NodeID, 0)
-	stack := make([]*struct {
-		id NodeID
-		tn *TreeNode
-	}, 0, len(t.Nodes)/2)
-	stack = append(stack, &struct {
-		id NodeID
-		tn *TreeNode
-	}{
-		id: NodeID(id),
-		tn: &t.Nodes[id],
-	})
-	for len(stack) > 0 {
-		last := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-		if len(last.tn.Children) > 0 {
-			for j := range last.tn.Children {
-				stack = append(stack, &struct {
-					id NodeID
-					tn *TreeNode
-				}{
-					id: NodeID(last.tn.Children[j]),
-					tn: &t.Nodes[last.tn.Children[j]],
-				})
-			}
-		} else {
-			leafIDs = append(leafIDs, last.id)
-		}
-	}
-	return
+// AppendLeafDescendantIDs appends to dst the NodeIDs that correspond to the
+// elements at the leaves of the Tree, which are also descendants of the
+// element stored under the provided NodeID, and returns the extended slice.
+//
+// Callers that query the Tree in a hot path may reuse dst across calls (e.g.,
+// dst[:0]) to avoid any heap allocations.
+func (t *Tree) AppendLeafDescendantIDs(dst []NodeID, id NodeID) ([]NodeID, error) {
+	return t.hierarchy().AppendLeafDescendantIDs(dst, id)
 }
 
 // LeafDescendants returns a list of the elements at the leaves of the Tree,
 // which are also descendants of the element stored under the provided NodeID.
 func (t *Tree) LeafDescendants(id NodeID) (leaves []*Element, err error) {
-	if nil == t {
-		return nil, fmt.Errorf("Tree is nil")
-	}
-	if int(id) >= len(t.Nodes) {
-		return nil, fmt.Errorf("Invalid NodeID %d", id)
-	}
-
-	leaves = make([]*Element, 0)
-	stack := make([]*TreeNode, 0, len(t.Nodes)/2)
-	stack = append(stack, &t.Nodes[id])
-	for len(stack) > 0 {
-		last := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-		if len(last.Children) > 0 {
-			for child_id := range last.Children {
-				stack = append(stack, &t.Nodes[last.Children[child_id]])
-			}
-		} else {
-			leaves = append(leaves, last.Data)
-		}
+	leafIDs, err := t.LeafDescendantIDs(id)
+	if err != nil {
+		return nil, err
 	}
-	return
+	return t.elementsOf(leafIDs), nil
 }
 
+// leafStackSize is the capacity of the stack-allocated buffer that is used to
+// traverse the Tree in LeafDescendantIDs and LeafDescendants; it only spills
+// to the heap for Trees that are both very wide and deep.
+const leafStackSize = 128
+
 // ParentID returns the NodeID of the immediate ancestor (i.e., the parent)
 // element of the element stored in the Tree under the provided NodeID, or a
 // non-nil error value in case of failure.
 //
-// Querying for the parent of the root Element returns an error too.
+// Querying for the parent of the root Element returns ErrNoParent, while
+// querying for the parent of an Element that is not listed as a child of any
+// other Element returns ErrOrphan.
+//
+// The parent is looked up in the Tree's parent index, which is built in O(n)
+// on first use (see InvalidateIndexes); each query is then O(1).
 func (t *Tree) ParentID(id NodeID) (NodeID, error) {
-	if nil == t {
-		return 0, fmt.Errorf("Tree is nil")
-	}
-	if int(id) >= len(t.Nodes) {
-		return 0, fmt.Errorf("Invalid NodeID %d", id)
-	}
-	if id == 0 {
-		return 0, fmt.Errorf("Root element does not have a parent")
-	}
-
-	for parentID := range t.Nodes {
-		for j := range t.Nodes[parentID].Children {
-			if id == t.Nodes[parentID].Children[j] {
-				return NodeID(parentID), nil
-			}
-		}
-	}
-	panic("UNREACHABLE") // XXX(ckatsak)
+	return t.hierarchy().ParentID(id)
 }
 
 // Parent returns the immediate ancestor (i.e., the parent) element of the
 // element stored in the Tree under the provided NodeID, or a non-nil error
 // value in case of failure.
 //
-// Querying for the parent of the root Element returns an error too.
+// Querying for the parent of the root Element returns ErrNoParent, while
+// querying for the parent of an Element that is not listed as a child of any
+// other Element returns ErrOrphan.
 func (t *Tree) Parent(id NodeID) (*Element, error) {
-	if nil == t {
-		return nil, fmt.Errorf("Tree is nil")
-	}
-	if int(id) >= len(t.Nodes) {
-		return nil, fmt.Errorf("Invalid NodeID %d", id)
+	parentID, err := t.ParentID(id)
+	if err != nil {
+		return nil, err
 	}
-	if id == 0 {
-		return nil, fmt.Errorf("Root element does not have a parent")
-	}
-
-	for parentID := range t.Nodes {
-		for j := range t.Nodes[parentID].Children {
-			if id == t.Nodes[parentID].Children[j] {
-				return t.Nodes[parentID].Data, nil
-			}
-		}
-	}
-	panic("UNREACHABLE") // XXX(ckatsak)
+	return t.Nodes[parentID].Data, nil
 }
 
 // AncestorIDs returns a list of NodeIDs that correspond to the ancestor (i.e.,
 // parent) elements of the element stored in the Tree under the provided
 // NodeID, all the way up to the root element of the Tree.
-func (t *Tree) AncestorIDs(id NodeID) (ancestorIDs []NodeID, err error) {
-	if nil == t {
-		return nil, fmt.Errorf("Tree is nil")
-	}
-	if int(id) >= len(t.Nodes) {
-		return nil, fmt.Errorf("Invalid NodeID %d", id)
-	}
-
-	allAncestorIDs := make([]NodeID, len(t.Nodes))
-	for parentID := range t.Nodes {
-		for j := range t.Nodes[parentID].Children {
-			allAncestorIDs[t.Nodes[parentID].Children[j]] = NodeID(parentID)
-		}
-	}
-
-	ancestorIDs = make([]NodeID, 0, len(t.Nodes)/2)
-	for ; id != NodeID(0); id = allAncestorIDs[id] {
-		ancestorIDs = append(ancestorIDs, allAncestorIDs[id])
-	}
-	return
+//
+// Ancestors are looked up in the Tree's parent index, which is built in O(n)
+// on first use (see InvalidateIndexes); each query is then O(depth), amortized.
+// Querying for the ancestors of an Element that is not reachable from the root
+// returns ErrOrphan or ErrCycle.
+func (t *Tree) AncestorIDs(id NodeID) ([]NodeID, error) {
+	return t.hierarchy().AncestorIDs(id)
 }
 
 // Ancestors returns a list of the ancestor (i.e., parent) elements of the
 // element stored in the Tree under the provided NodeID, all the way up to the
 // root element of the Tree.
+//
+// See AncestorIDs for its complexity and failure modes.
 func (t *Tree) Ancestors(id NodeID) (ancestors []*Element, err error) {
-	if nil == t {
-		return nil, fmt.Errorf("Tree is nil")
-	}
-	if int(id) >= len(t.Nodes) {
-		return nil, fmt.Errorf("Invalid NodeID %d", id)
+	ancestorIDs, err := t.AncestorIDs(id)
+	if err != nil {
+		return nil, err
 	}
+	return t.elementsOf(ancestorIDs), nil
+}
 
-	allAncestorIDs := make([]NodeID, len(t.Nodes))
-	for parentID := range t.Nodes {
-		for j := range t.Nodes[parentID].Children {
-			allAncestorIDs[t.Nodes[parentID].Children[j]] = NodeID(parentID)
-		}
-	}
+// CommonAncestorID returns the NodeID of the deepest element of the Tree whose
+// subtree contains all elements stored under the provided NodeIDs (i.e., their
+// lowest common ancestor, which may be one of them), or a non-nil error value
+// in case of failure.
+//
+// See AncestorIDs for its failure modes.
+func (t *Tree) CommonAncestorID(ids ...NodeID) (NodeID, error) {
+	return t.hierarchy().CommonAncestorID(ids...)
+}
 
-	ancestors = make([]*Element, 0, len(t.Nodes)/2)
-	for ; id != NodeID(0); id = allAncestorIDs[id] {
-		ancestors = append(ancestors, t.Nodes[allAncestorIDs[id]].Data)
+// elementsOf returns the Elements stored in the Tree under the provided
+// NodeIDs, which are assumed to be valid.
+func (t *Tree) elementsOf(ids []NodeID) []*Element {
+	elements := make([]*Element, 0, len(ids))
+	for _, id := range ids {
+		elements = append(elements, t.Nodes[id].Data)
 	}
-	return
+	return elements
 }
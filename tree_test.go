@@ -18,6 +18,7 @@ package actitopo
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -100,3 +101,240 @@ func TestDesererializeTreeFromFile(t *testing.T) {
 		t.Fatalf("Failed to write remarshaled tree into file %v: %v\n", OUT_FILE_PATH, err)
 	}
 }
+
+// syntheticTree returns a Tree resembling a large machine with the provided
+// numbers of Packages, NUMA nodes per Package, Cores per NUMA node and Threads
+// per Core, where each NUMA node has its own L3 cache and each Core has its own
+// L2 and L1 caches.
+func syntheticTree(packages, numaNodes, cores, threads int) *Tree {
+	tree := NewTree([]TreeNode{{Data: &Element{}}})
+	add := func(parent NodeID, data *Element) NodeID {
+		id := NodeID(len(tree.Nodes))
+		tree.Nodes = append(tree.Nodes, TreeNode{Data: data})
+		tree.Nodes[parent].Children = append(tree.Nodes[parent].Children, id)
+		return id
+	}
+	cache := func(level CacheLevel, li int, size uint64) *Element {
+		return &Element{Cache: &Cache{
+			Level:        level,
+			LogicalIndex: uint32(li),
+			Attributes:   &CacheAttributes{Size: size, Linesize: 64, Associativity: 8},
+		}}
+	}
+	processing := func(kind ProcessingKind, id int) *Element {
+		return &Element{Processing: &Processing{Kind: kind, ID: uint32(id)}}
+	}
+
+	var numaID, coreID, threadID int
+	for p := 0; p < packages; p++ {
+		pkg := add(0, processing(Package, p))
+		for n := 0; n < numaNodes; n++ {
+			numa := add(pkg, processing(NUMANode, numaID))
+			l3 := add(numa, cache(L3, numaID, 32<<20))
+			numaID++
+			for c := 0; c < cores; c++ {
+				l2 := add(l3, cache(L2, coreID, 1<<20))
+				l1 := add(l2, cache(L1, coreID, 32<<10))
+				core := add(l1, processing(Core, coreID%(numaNodes*cores)))
+				coreID++
+				for th := 0; th < threads; th++ {
+					add(core, processing(Thread, threadID))
+					threadID++
+				}
+			}
+		}
+	}
+	return tree
+}
+
+func TestLeafDescendantIDs(t *testing.T) {
+	tree := syntheticTree(2, 2, 32, 2)
+	leafIDs, err := tree.LeafDescendantIDs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leafIDs) != 256 {
+		t.Fatalf("got %d leaves; want 256", len(leafIDs))
+	}
+	leaves, err := tree.LeafDescendants(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range leafIDs {
+		if !tree.Nodes[leafIDs[i]].Data.IsProcessing() || tree.Nodes[leafIDs[i]].Data.Kind != Thread {
+			t.Errorf("leaf %d is %s; want a Thread", leafIDs[i], tree.Nodes[leafIDs[i]].Data)
+		}
+		if leaves[i] != tree.Nodes[leafIDs[i]].Data {
+			t.Errorf("LeafDescendants and LeafDescendantIDs disagree at position %d", i)
+		}
+	}
+
+	dst, err := tree.AppendLeafDescendantIDs([]NodeID{42}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dst) != 257 || dst[0] != 42 || dst[1] != leafIDs[0] {
+		t.Errorf("AppendLeafDescendantIDs did not append to the provided slice")
+	}
+}
+
+func BenchmarkLeafDescendantIDs(b *testing.B) {
+	tree := syntheticTree(2, 2, 32, 2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tree.LeafDescendantIDs(0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLeafDescendants(b *testing.B) {
+	tree := syntheticTree(2, 2, 32, 2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tree.LeafDescendants(0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendLeafDescendantIDs(b *testing.B) {
+	tree := syntheticTree(2, 2, 32, 2)
+	buf := make([]NodeID, 0, 256)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = tree.AppendLeafDescendantIDs(buf[:0], 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAncestorIDs(t *testing.T) {
+	tree := syntheticTree(2, 2, 32, 2)
+	threadID := NodeID(len(tree.Nodes) - 1)
+	ancestorIDs, err := tree.AncestorIDs(threadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Core, L1, L2, L3, NUMANode, Package, Machine
+	if len(ancestorIDs) != 7 || ancestorIDs[6] != 0 {
+		t.Fatalf("got ancestors %v; want 7 ending at the root", ancestorIDs)
+	}
+	for i, id := range ancestorIDs[:6] {
+		if parentID, err := tree.ParentID(id); err != nil || parentID != ancestorIDs[i+1] {
+			t.Errorf("ParentID(%d) = %d, %v; want %d", id, parentID, err, ancestorIDs[i+1])
+		}
+	}
+
+	// Detach the thread from its Core.
+	coreID := ancestorIDs[0]
+	tree.Nodes[coreID].Children = tree.Nodes[coreID].Children[:1]
+	tree.InvalidateIndexes()
+	if _, err = tree.AncestorIDs(threadID); !errors.Is(err, ErrOrphan) {
+		t.Errorf("got %v for a detached Element; want ErrOrphan", err)
+	}
+}
+
+func TestCommonAncestorID(t *testing.T) {
+	tree := syntheticTree(1, 2, 2, 2)
+	for _, tc := range []struct {
+		ids  []NodeID
+		want NodeID
+	}{
+		{[]NodeID{7}, 7},
+		{[]NodeID{7, 8}, 6},  // SMT siblings share a Core
+		{[]NodeID{8, 6}, 6},  // an Element is its own ancestor
+		{[]NodeID{7, 12}, 3}, // Cores of a NUMA node share an L3
+		{[]NodeID{12, 7, 19}, 1},
+		{[]NodeID{19, 0}, 0},
+	} {
+		if got, err := tree.CommonAncestorID(tc.ids...); err != nil || got != tc.want {
+			t.Errorf("CommonAncestorID(%v) = %d, %v; want %d", tc.ids, got, err, tc.want)
+		}
+	}
+	if _, err := tree.CommonAncestorID(); err == nil {
+		t.Errorf("expected an error for no elements")
+	}
+	if _, err := tree.CommonAncestorID(7, NodeID(len(tree.Nodes))); err == nil {
+		t.Errorf("expected an error for an invalid NodeID")
+	}
+}
+
+func BenchmarkAncestorIDs(b *testing.B) {
+	tree := syntheticTree(2, 2, 32, 2)
+	threadID := NodeID(len(tree.Nodes) - 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tree.AncestorIDs(threadID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSubtreeRange(t *testing.T) {
+	tree := syntheticTree(2, 2, 4, 2)
+	for id := range tree.Nodes {
+		start, end, err := tree.SubtreeRange(NodeID(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		leafIDs, err := tree.LeafDescendantIDs(NodeID(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, leafID := range leafIDs {
+			if leafID < start || leafID >= end {
+				t.Errorf("leaf %d of %d is outside of [%d, %d)", leafID, id, start, end)
+			}
+		}
+		for descID := start + 1; descID < end; descID++ {
+			ancestorIDs, err := tree.AncestorIDs(descID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, ancestorID := range ancestorIDs {
+				found = found || ancestorID == NodeID(id)
+			}
+			if !found {
+				t.Errorf("%d in [%d, %d) is not a descendant of %d", descID, start, end, id)
+			}
+		}
+	}
+	if _, end, _ := tree.SubtreeRange(0); int(end) != len(tree.Nodes) {
+		t.Errorf("got subtree end %d for the root; want %d", end, len(tree.Nodes))
+	}
+}
+
+func TestDepthAndSubtreeSize(t *testing.T) {
+	tree := syntheticTree(2, 2, 4, 2)
+	depths, sizes := tree.Depths(), tree.SubtreeSizes()
+	for id := range tree.Nodes {
+		ancestorIDs, err := tree.AncestorIDs(NodeID(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if depth, err := tree.Depth(NodeID(id)); err != nil || depth != len(ancestorIDs) || depths[id] != depth {
+			t.Errorf("Depth(%d) = %d, %v; want %d", id, depth, err, len(ancestorIDs))
+		}
+		start, end, err := tree.SubtreeRange(NodeID(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size, err := tree.SubtreeSize(NodeID(id)); err != nil || size != int(end-start) || sizes[id] != size {
+			t.Errorf("SubtreeSize(%d) = %d, %v; want %d", id, size, err, end-start)
+		}
+	}
+
+	// Depths of detached Elements are reported as such.
+	tree.Nodes[0].Children = tree.Nodes[0].Children[:1]
+	tree.InvalidateIndexes()
+	if _, err := tree.Depth(NodeID(len(tree.Nodes) - 1)); !errors.Is(err, ErrOrphan) {
+		t.Errorf("got %v for a detached Element; want ErrOrphan", err)
+	}
+}
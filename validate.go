@@ -0,0 +1,229 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import "fmt"
+
+// Validate checks the structural integrity of the Tree, and returns a non-nil
+// error value describing the first problem found, if any.
+//
+// In particular, it makes sure that:
+//   - every child NodeID refers to an existing Element;
+//   - no Element lists itself or any of its ancestors as a child, which would
+//     send all traversals into an infinite loop;
+//   - no Element is listed as a child more than once (i.e., no Element has
+//     multiple parents), which would break all ancestry queries;
+//   - every Element is reachable from the root Element;
+//   - the Elements are stored in pre-order (see Tree);
+//   - no two Processing elements of the same kind share the same OS index
+//     (with the exception of Cores, whose OS indices are only unique within
+//     their Package);
+//   - no two Caches of the same level share the same logical index;
+//   - only NUMA nodes have memory attributes;
+//   - Devices only have other Devices as children;
+//   - SR-IOV virtual functions are attached under their physical function.
+//
+// Problems that concern a specific Element are reported as a *NodeError.
+func (t *Tree) Validate() error {
+	return t.validate(nil)
+}
+
+// ValidateCaches makes sure that the attributes of all Caches of the Tree are
+// consistent with each other (see CacheAttributes.Validate), and returns a
+// *NodeError describing the first Cache whose attributes are not, if any.
+//
+// It is not part of Validate, since inconsistent attributes do not affect the
+// structure of the Tree, and many collectors report them anyway.
+func (t *Tree) ValidateCaches() error {
+	if nil == t {
+		return ErrNilTree
+	}
+	for id := range t.Nodes {
+		if data := t.Nodes[id].Data; data.IsCache() {
+			if err := data.Attributes.Validate(); err != nil {
+				return t.nodeError(NodeID(id), nil, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate implements Validate; if offsets is non-nil, it holds the byte
+// offset of each TreeNode in the JSON payload that the Tree was decoded from,
+// to be reported in NodeErrors.
+func (t *Tree) validate(offsets []int64) error {
+	if nil == t {
+		return ErrNilTree
+	}
+	if len(t.Nodes) == 0 {
+		return nil
+	}
+	for id := range t.Nodes {
+		data := t.Nodes[id].Data
+		switch {
+		case nil == data:
+			return t.nodeError(NodeID(id), offsets, fmt.Errorf("%w: missing data", ErrInvalidElement))
+		case !data.IsRoot() && !data.IsProcessing() && !data.IsCache() && !data.IsDevice():
+			return t.nodeError(NodeID(id), offsets, fmt.Errorf("%w: more than one of Processing, Cache and Device are set", ErrInvalidElement))
+		case nil != data.Processing && nil != data.Memory && data.Kind != NUMANode:
+			return t.nodeError(NodeID(id), offsets, fmt.Errorf("%w: memory attributes on a %s", ErrInvalidElement, data.Kind))
+		case data.IsDevice():
+			for _, child := range t.Nodes[id].Children {
+				if int(child) < len(t.Nodes) && nil != t.Nodes[child].Data && !t.Nodes[child].Data.IsDevice() {
+					return t.nodeError(NodeID(id), offsets, fmt.Errorf("%w: child %d of a Device is not a Device", ErrInvalidElement, child))
+				}
+			}
+		}
+	}
+
+	if err := validateStructure(t.Nodes, func(id NodeID, err error) error {
+		return t.nodeError(id, offsets, err)
+	}); err != nil {
+		return err
+	}
+	for id := range t.Nodes {
+		parent := t.Nodes[id].Data
+		for _, child := range t.Nodes[id].Children {
+			data := t.Nodes[child].Data
+			if data.IsDevice() && data.IsVirtualFunction() && (!parent.IsDevice() || parent.BusID != data.PhysicalFunction) {
+				return t.nodeError(child, offsets, fmt.Errorf("%w: virtual function is not attached under its physical function %s",
+					ErrInvalidElement, data.PhysicalFunction))
+			}
+		}
+	}
+	if err := t.validateIDs(offsets); err != nil {
+		return err
+	}
+	return t.validateMemoryNodes(offsets)
+}
+
+// validateMemoryNodes makes sure that the NUMA nodes that Devices contribute
+// memory to exist, and that each is contributed by a single Device.
+//
+// The Tree is assumed to be a proper tree, and all child NodeIDs to be valid.
+func (t *Tree) validateMemoryNodes(offsets []int64) error {
+	numaNodes := make(map[uint32]bool)
+	for _, node := range t.Nodes {
+		if node.Data.IsProcessing() && node.Data.Kind == NUMANode {
+			numaNodes[node.Data.ID] = true
+		}
+	}
+	contributors := make(map[uint32]NodeID)
+	for id, node := range t.Nodes {
+		if !node.Data.IsDevice() {
+			continue
+		}
+		for _, osID := range node.Data.MemoryNodes {
+			if !numaNodes[osID] {
+				return t.nodeError(NodeID(id), offsets, fmt.Errorf("%w: memory of missing NUMA node %d", ErrInvalidElement, osID))
+			}
+			if other, ok := contributors[osID]; ok {
+				return t.nodeError(NodeID(id), offsets, fmt.Errorf("%w: memory of NUMA node %d is contributed by element %d too",
+					ErrInvalidElement, osID, other))
+			}
+			contributors[osID] = NodeID(id)
+		}
+	}
+	return nil
+}
+
+// nodeError wraps the provided error into a NodeError for the element stored
+// under the provided NodeID.
+func (t *Tree) nodeError(id NodeID, offsets []int64, err error) *NodeError {
+	offset := int64(-1)
+	if int(id) < len(offsets) {
+		offset = offsets[id]
+	}
+	return &NodeError{ID: id, Kind: elementKind(t.Nodes[id].Data), Offset: offset, Err: err}
+}
+
+// validateIDs makes sure that there are no duplicate OS indices among the
+// Processing elements, and no duplicate logical indices among the Caches.
+//
+// The Tree is assumed to be a proper tree, and all child NodeIDs to be valid.
+func (t *Tree) validateIDs(offsets []int64) error {
+	type processingKey struct {
+		kind ProcessingKind
+		// pkg is the NodeID of the enclosing Package, for Cores only.
+		pkg NodeID
+		id  uint32
+	}
+	type cacheKey struct {
+		level CacheLevel
+		li    uint32
+	}
+	processingSeen := make(map[processingKey]NodeID)
+	cacheSeen := make(map[cacheKey]NodeID)
+
+	type frame struct {
+		id  NodeID
+		pkg NodeID
+	}
+	stack := []frame{{id: 0}}
+	for len(stack) > 0 {
+		last := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		data := t.Nodes[last.id].Data
+		switch {
+		case data.IsProcessing():
+			key := processingKey{kind: data.Kind, id: data.ID}
+			switch data.Kind {
+			case Package:
+				last.pkg = last.id
+			case Core:
+				key.pkg = last.pkg
+			}
+			if other, dup := processingSeen[key]; dup {
+				return t.nodeError(last.id, offsets, fmt.Errorf("%w: element %d is %s too", ErrDuplicateID, other, data))
+			}
+			processingSeen[key] = last.id
+		case data.IsCache():
+			key := cacheKey{level: data.Level, li: data.LogicalIndex}
+			if other, dup := cacheSeen[key]; dup {
+				return t.nodeError(last.id, offsets, fmt.Errorf("%w: element %d is %s(L#%d) too",
+					ErrDuplicateID, other, data.Level, data.LogicalIndex))
+			}
+			cacheSeen[key] = last.id
+		}
+
+		for _, child := range t.Nodes[last.id].Children {
+			stack = append(stack, frame{id: child, pkg: last.pkg})
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON attempts to unmarshal the Tree from the provided byte slice,
+// and returns a non-nil error if it fails or if the unmarshalled Tree is not
+// valid (see Validate).
+//
+// Problems that concern a specific TreeNode are reported as a *NodeError,
+// including its byte offset in the provided byte slice.
+func (t *Tree) UnmarshalJSON(data []byte) error {
+	nodes, offsets, err := decodeTreeNodes(data, 0, nil)
+	if err != nil {
+		return err
+	}
+	tree := NewTree(nodes)
+	if err = tree.validate(offsets); err != nil {
+		return err
+	}
+	t.Nodes = tree.Nodes
+	t.InvalidateIndexes()
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+  Copyright 2022 Christos Katsakioris
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+*/
+
+package actitopo
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestValidateFixtures(t *testing.T) {
+	for _, path := range []string{
+		"test_artifacts/t4_de.json",
+		"test_artifacts/topo__immutree.json",
+	} {
+		if err := loadTopology(t, path).Validate(); err != nil {
+			t.Errorf("Validate() failed for %s: %v", path, err)
+		}
+	}
+}
+
+func TestUnmarshalRejectsCycles(t *testing.T) {
+	for name, payload := range map[string]string{
+		"self-reference": `{"nodes":[{"data":"machine","desc":[1]},{"data":{"processing":{"kind":"thread","id":0}},"desc":[1]}]}`,
+		"root as child":  `{"nodes":[{"data":"machine","desc":[1]},{"data":{"processing":{"kind":"core","id":0}},"desc":[0]}]}`,
+		"ancestor": `{"nodes":[{"data":"machine","desc":[1]},{"data":{"processing":{"kind":"package","id":0}},"desc":[2]},` +
+			`{"data":{"processing":{"kind":"core","id":0}},"desc":[1]}]}`,
+	} {
+		var tree Tree
+		err := json.Unmarshal([]byte(payload), &tree)
+		if !errors.Is(err, ErrCycle) {
+			t.Errorf("%s: got %v; want ErrCycle", name, err)
+		} else {
+			t.Logf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestUnmarshalRejectsDuplicateIDs(t *testing.T) {
+	for name, payload := range map[string]string{
+		"threads": `{"nodes":[{"data":"machine","desc":[1,2]},{"data":{"processing":{"kind":"thread","id":3}}},` +
+			`{"data":{"processing":{"kind":"thread","id":3}}}]}`,
+		"cores in package": `{"nodes":[{"data":"machine","desc":[1]},{"data":{"processing":{"kind":"package","id":0}},"desc":[2,3]},` +
+			`{"data":{"processing":{"kind":"core","id":1}}},{"data":{"processing":{"kind":"core","id":1}}}]}`,
+		"caches": `{"nodes":[{"data":"machine","desc":[1,2]},{"data":{"cache":{"lvl":"L2","li":0,"attrs":{"size":1,"line":1,"ways":1}}}},` +
+			`{"data":{"cache":{"lvl":"L2","li":0,"attrs":{"size":1,"line":1,"ways":1}}}}]}`,
+	} {
+		var tree Tree
+		err := json.Unmarshal([]byte(payload), &tree)
+		if !errors.Is(err, ErrDuplicateID) {
+			t.Errorf("%s: got %v; want ErrDuplicateID", name, err)
+		} else {
+			t.Logf("%s: %v", name, err)
+		}
+	}
+
+	// Cores with the same OS index in different Packages are fine.
+	payload := `{"nodes":[{"data":"machine","desc":[1,3]},` +
+		`{"data":{"processing":{"kind":"package","id":0}},"desc":[2]},{"data":{"processing":{"kind":"core","id":0}}},` +
+		`{"data":{"processing":{"kind":"package","id":1}},"desc":[4]},{"data":{"processing":{"kind":"core","id":0}}}]}`
+	var tree Tree
+	if err := json.Unmarshal([]byte(payload), &tree); err != nil {
+		t.Errorf("cores in different packages: %v", err)
+	}
+}
+
+func TestUnmarshalRejectsOutOfRangeChildren(t *testing.T) {
+	payload := `{"nodes":[{"data":"machine","desc":[1]},{"data":{"processing":{"kind":"core","id":0}},"desc":[7]}]}`
+	var tree Tree
+	err := json.Unmarshal([]byte(payload), &tree)
+	var invalid ErrInvalidNodeID
+	if !errors.As(err, &invalid) || invalid.ID != 7 {
+		t.Errorf("got %v; want ErrInvalidNodeID{ID: 7}", err)
+	} else {
+		t.Logf("%v", err)
+	}
+}
+
+func TestUnmarshalRejectsMultipleParents(t *testing.T) {
+	for name, payload := range map[string]string{
+		"two parents": `{"nodes":[{"data":"machine","desc":[1,2]},{"data":{"processing":{"kind":"package","id":0}},"desc":[3]},` +
+			`{"data":{"processing":{"kind":"package","id":1}},"desc":[3]},{"data":{"processing":{"kind":"thread","id":0}}}]}`,
+		"listed twice": `{"nodes":[{"data":"machine","desc":[1,1]},{"data":{"processing":{"kind":"thread","id":0}}}]}`,
+	} {
+		var tree Tree
+		err := json.Unmarshal([]byte(payload), &tree)
+		if !errors.Is(err, ErrMultipleParents) {
+			t.Errorf("%s: got %v; want ErrMultipleParents", name, err)
+		} else {
+			t.Logf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestUnmarshalRejectsOutOfOrderNodes(t *testing.T) {
+	payload := `{"nodes":[{"data":"machine","desc":[2,1]},` +
+		`{"data":{"processing":{"kind":"thread","id":0}}},{"data":{"processing":{"kind":"thread","id":1}}}]}`
+	var tree Tree
+	err := json.Unmarshal([]byte(payload), &tree)
+	var nodeErr *NodeError
+	if !errors.Is(err, ErrNotPreOrder) || !errors.As(err, &nodeErr) || nodeErr.ID != 2 {
+		t.Errorf("got %v; want ErrNotPreOrder for node 2", err)
+	}
+
+	clean, issues, err := Sanitize([]byte(payload))
+	if err != nil {
+		t.Fatalf("Failed to sanitize payload: %v\n", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("got issues %v for a reordered payload; want none", issues)
+	}
+	if err = json.Unmarshal(clean, &tree); err != nil {
+		t.Errorf("Failed to unmarshal sanitized payload %s: %v\n", clean, err)
+	} else if tree.Nodes[1].Data.ID != 1 {
+		t.Errorf("got %s in position 1; want Thread(1)", tree.Nodes[1].Data)
+	}
+}
+
+func TestValidateCaches(t *testing.T) {
+	tree := syntheticTree(1, 1, 2, 2)
+	if err := tree.ValidateCaches(); err != nil {
+		t.Fatal(err)
+	}
+	l2 := (&Topology{tree}).L2Caches()[1]
+	tree.Nodes[l2].Data.Attributes.Linesize = 100
+	var nodeErr *NodeError
+	if err := tree.ValidateCaches(); !errors.As(err, &nodeErr) || nodeErr.ID != l2 {
+		t.Errorf("got %v; expected a NodeError for node %d", err, l2)
+	}
+}